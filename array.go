@@ -0,0 +1,448 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"time"
+)
+
+// arrayDesc describes an ARRAY column, read from RDB$FIELDS and
+// RDB$FIELD_DIMENSIONS. dtype is the blr type of the elements.
+type arrayDesc struct {
+	relname   string
+	fieldname string
+	dtype     int
+	scale     int
+	length    int
+	lower     []int
+	upper     []int
+}
+
+// element sql types and sizes in the client buffer, by blr type
+var arrayElementTypes = map[int]struct {
+	sqltype int
+	size    int
+}{
+	7:  {SQL_TYPE_SHORT, 2},
+	8:  {SQL_TYPE_LONG, 4},
+	16: {SQL_TYPE_INT64, 8},
+	10: {SQL_TYPE_FLOAT, 4},
+	27: {SQL_TYPE_DOUBLE, 8},
+	12: {SQL_TYPE_DATE, 4},
+	13: {SQL_TYPE_TIME, 4},
+	35: {SQL_TYPE_TIMESTAMP, 8},
+	23: {SQL_TYPE_BOOLEAN, 1},
+	14: {SQL_TYPE_TEXT, 0},    // length
+	37: {SQL_TYPE_VARYING, 2}, // length + 2
+}
+
+func loadArrayDesc(fc *firebirdsqlConn, relname string, fieldname string) (d *arrayDesc, err error) {
+	rows, err := fc.Query(`
+		SELECT f.RDB$FIELD_TYPE, f.RDB$FIELD_SCALE, f.RDB$FIELD_LENGTH, d.RDB$LOWER_BOUND, d.RDB$UPPER_BOUND
+		FROM RDB$RELATION_FIELDS rf
+		JOIN RDB$FIELDS f ON f.RDB$FIELD_NAME = rf.RDB$FIELD_SOURCE
+		JOIN RDB$FIELD_DIMENSIONS d ON d.RDB$FIELD_NAME = f.RDB$FIELD_NAME
+		WHERE rf.RDB$RELATION_NAME = ? AND rf.RDB$FIELD_NAME = ?
+		ORDER BY d.RDB$DIMENSION`, []driver.Value{relname, fieldname})
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	d = &arrayDesc{relname: relname, fieldname: fieldname}
+	dest := make([]driver.Value, 5)
+	for rows.Next(dest) == nil {
+		d.dtype = int(toInt64(dest[0]))
+		d.scale = int(toInt64(dest[1]))
+		d.length = int(toInt64(dest[2]))
+		d.lower = append(d.lower, int(toInt64(dest[3])))
+		d.upper = append(d.upper, int(toInt64(dest[4])))
+	}
+	if len(d.lower) == 0 {
+		return nil, fmt.Errorf("firebirdsql: array %s.%s is not found", relname, fieldname)
+	}
+	if _, ok := arrayElementTypes[d.dtype]; !ok {
+		return nil, fmt.Errorf("firebirdsql: array element type %d is not supported", d.dtype)
+	}
+	return
+}
+
+func toInt64(v driver.Value) int64 {
+	switch i := v.(type) {
+	case int:
+		return int64(i)
+	case int8:
+		return int64(i)
+	case int16:
+		return int64(i)
+	case int32:
+		return int64(i)
+	case int64:
+		return i
+	}
+	return 0
+}
+
+func sdlLiteral(n int) []byte {
+	switch {
+	case -128 <= n && n <= 127:
+		return []byte{isc_sdl_tiny_integer, byte(n)}
+	case -32768 <= n && n <= 32767:
+		return []byte{isc_sdl_short_integer, byte(n), byte(n >> 8)}
+	}
+	return append([]byte{isc_sdl_long_integer}, int32_to_bytes(int32(n))...)
+}
+
+// sdl returns the slice description of the whole array, like isc_array_gen_sdl.
+func (d *arrayDesc) sdl() []byte {
+	sdl := []byte{isc_sdl_version1, isc_sdl_struct, 1, byte(d.dtype)}
+	switch d.dtype {
+	case 7, 8, 16: // short, long, int64
+		sdl = append(sdl, byte(d.scale))
+	case 14, 37: // text, varying
+		sdl = append(sdl, byte(d.length), byte(d.length>>8))
+	}
+	sdl = append(sdl, isc_sdl_relation, byte(len(d.relname)))
+	sdl = append(sdl, d.relname...)
+	sdl = append(sdl, isc_sdl_field, byte(len(d.fieldname)))
+	sdl = append(sdl, d.fieldname...)
+
+	for i := range d.lower {
+		if d.lower[i] == 1 {
+			sdl = append(sdl, isc_sdl_do1, byte(i))
+		} else {
+			sdl = append(sdl, isc_sdl_do2, byte(i))
+			sdl = append(sdl, sdlLiteral(d.lower[i])...)
+		}
+		sdl = append(sdl, sdlLiteral(d.upper[i])...)
+	}
+
+	sdl = append(sdl, isc_sdl_element, 1, isc_sdl_scalar, 0, byte(len(d.lower)))
+	for i := range d.lower {
+		sdl = append(sdl, isc_sdl_variable, byte(i))
+	}
+	return append(sdl, isc_sdl_eoc)
+}
+
+func (d *arrayDesc) count() int {
+	n := 1
+	for i := range d.lower {
+		n *= d.upper[i] - d.lower[i] + 1
+	}
+	return n
+}
+
+func (d *arrayDesc) elementSize() int {
+	size := arrayElementTypes[d.dtype].size
+	if d.dtype == 14 || d.dtype == 37 {
+		size += d.length
+	}
+	return size
+}
+
+// element returns the xSQLVAR to decode an element.
+func (d *arrayDesc) element() *xSQLVAR {
+	return &xSQLVAR{
+		sqltype:  arrayElementTypes[d.dtype].sqltype,
+		sqlscale: d.scale,
+		sqllen:   d.length,
+	}
+}
+
+// nest shapes the elements in row-major order into nested []interface{}.
+func (d *arrayDesc) nest(elements []interface{}, dim int) []interface{} {
+	n := d.upper[dim] - d.lower[dim] + 1
+	if dim == len(d.lower)-1 {
+		return elements[:n]
+	}
+	r := make([]interface{}, n)
+	size := len(elements) / n
+	for i := range r {
+		r[i] = d.nest(elements[i*size:(i+1)*size], dim+1)
+	}
+	return r
+}
+
+// arrayId is an array id written by op_put_slice, bound like a blob id.
+type arrayId []byte
+
+// isArrayArg reports whether v is a slice to be written to an ARRAY column.
+func isArrayArg(v interface{}) bool {
+	if v == nil {
+		return false
+	}
+	if _, ok := v.([]byte); ok {
+		return false
+	}
+	k := reflect.ValueOf(v).Kind()
+	return k == reflect.Slice || k == reflect.Array
+}
+
+// flatten checks the shape of v against the dimensions and appends the
+// elements to elements in row-major order.
+func (d *arrayDesc) flatten(v interface{}, dim int, elements []interface{}) ([]interface{}, error) {
+	n := d.upper[dim] - d.lower[dim] + 1
+	rv := reflect.ValueOf(v)
+	if _, ok := v.([]byte); ok || v == nil || (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) {
+		return nil, fmt.Errorf("firebirdsql: array %s.%s dimension %d expects a slice of %d elements, got %T", d.relname, d.fieldname, dim+1, n, v)
+	}
+	if rv.Len() != n {
+		return nil, fmt.Errorf("firebirdsql: array %s.%s dimension %d expects %d elements, got %d", d.relname, d.fieldname, dim+1, n, rv.Len())
+	}
+	var err error
+	for i := 0; i < n; i++ {
+		e := rv.Index(i).Interface()
+		if dim < len(d.lower)-1 {
+			elements, err = d.flatten(e, dim+1, elements)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			elements = append(elements, e)
+		}
+	}
+	return elements, nil
+}
+
+func toRat(v interface{}) (*big.Rat, bool) {
+	switch n := v.(type) {
+	case int:
+		return new(big.Rat).SetInt64(int64(n)), true
+	case int8:
+		return new(big.Rat).SetInt64(int64(n)), true
+	case int16:
+		return new(big.Rat).SetInt64(int64(n)), true
+	case int32:
+		return new(big.Rat).SetInt64(int64(n)), true
+	case int64:
+		return new(big.Rat).SetInt64(n), true
+	case float32:
+		return new(big.Rat).SetFloat64(float64(n)), !math.IsInf(float64(n), 0) && !math.IsNaN(float64(n))
+	case float64:
+		return new(big.Rat).SetFloat64(n), !math.IsInf(n, 0) && !math.IsNaN(n)
+	case Decimal:
+		r, err := n.rat()
+		return r, err == nil
+	case string:
+		return new(big.Rat).SetString(n)
+	}
+	return nil, false
+}
+
+// encodeElement returns the XDR encoding of an element in the slice.
+func (d *arrayDesc) encodeElement(v interface{}) ([]byte, error) {
+	switch d.dtype {
+	case 7, 8, 16: // short, long, int64
+		r, ok := toRat(v)
+		if !ok {
+			break
+		}
+		_, b, err := _ratToBlr(r, d.scale)
+		if err != nil {
+			return nil, err
+		}
+		if d.dtype == 16 {
+			return b, nil
+		}
+		q := bytes_to_bint64(b)
+		if (d.dtype == 7 && (q < math.MinInt16 || q > math.MaxInt16)) || q < math.MinInt32 || q > math.MaxInt32 {
+			return nil, fmt.Errorf("firebirdsql: array %s.%s element %v out of range", d.relname, d.fieldname, v)
+		}
+		return bint32_to_bytes(int32(q)), nil
+	case 10, 27: // float, double
+		r, ok := toRat(v)
+		if !ok {
+			break
+		}
+		f, _ := r.Float64()
+		if d.dtype == 10 {
+			return bint32_to_bytes(int32(math.Float32bits(float32(f)))), nil
+		}
+		return bint64_to_bytes(int64(math.Float64bits(f))), nil
+	case 12, 13, 35: // date, time, timestamp
+		t, ok := v.(time.Time)
+		if !ok {
+			break
+		}
+		switch d.dtype {
+		case 12:
+			return _convert_date(t), nil
+		case 13:
+			return _convert_time(t), nil
+		}
+		return append(_convert_date(t), _convert_time(t)...), nil
+	case 23: // boolean
+		b, ok := v.(bool)
+		if !ok {
+			break
+		}
+		if b {
+			return []byte{1, 0, 0, 0}, nil
+		}
+		return []byte{0, 0, 0, 0}, nil
+	case 14, 37: // text, varying
+		var b []byte
+		switch s := v.(type) {
+		case string:
+			b = str_to_bytes(s)
+		case []byte:
+			b = s
+		default:
+			return nil, fmt.Errorf("firebirdsql: array %s.%s element %T is not supported", d.relname, d.fieldname, v)
+		}
+		if len(b) > d.length {
+			return nil, fmt.Errorf("firebirdsql: array %s.%s element %q is longer than %d bytes", d.relname, d.fieldname, b, d.length)
+		}
+		if d.dtype == 37 {
+			return xdrBytes(b), nil
+		}
+		_, b = _bytesToBlr(append(b, bytes.Repeat([]byte{' '}, d.length-len(b))...))
+		return b, nil
+	}
+	return nil, fmt.Errorf("firebirdsql: array %s.%s element %T is not supported", d.relname, d.fieldname, v)
+}
+
+// encode checks v against the array description and returns the XDR
+// encoded elements of the whole array.
+func (d *arrayDesc) encode(v interface{}) ([]byte, error) {
+	elements, err := d.flatten(v, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	var slice []byte
+	for _, e := range elements {
+		b, err := d.encodeElement(e)
+		if err != nil {
+			return nil, err
+		}
+		slice = append(slice, b...)
+	}
+	return slice, nil
+}
+
+func (p *wireProtocol) opGetSlice(transHandle int32, arrayId []byte, sliceLength int32, sdl []byte) {
+	debugPrint(p, "opGetSlice")
+	p.packInt(op_get_slice)
+	p.packInt(transHandle)
+	p.appendBytes(arrayId)
+	p.packInt(sliceLength)
+	p.packBytes(sdl)
+	p.packInt(0) // parameters
+	p.packInt(0) // slice
+	p.sendPackets()
+}
+
+// opSliceResponse reads op_slice, the elements are XDR encoded one by one.
+func (p *wireProtocol) opSliceResponse(d *arrayDesc) ([]interface{}, error) {
+	b, err := p.recvPackets(4)
+	for bytes_to_bint32(b) == op_dummy {
+		b, err = p.recvPackets(4)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if bytes_to_bint32(b) == op_response {
+		_, _, _, err = p._parse_op_response()
+		if err == nil {
+			err = errors.New("Error op_slice")
+		}
+		return nil, err
+	}
+	if bytes_to_bint32(b) != op_slice {
+		return nil, errors.New("Error op_slice")
+	}
+	b, err = p.recvPackets(8) // length, slice length
+	if err != nil {
+		return nil, err
+	}
+	n := int(bytes_to_bint32(b[4:8])) / d.elementSize()
+
+	x := d.element()
+	elements := make([]interface{}, n)
+	for i := range elements {
+		ln := x.ioLength()
+		if ln < 0 {
+			b, err = p.recvPackets(4)
+			if err != nil {
+				return nil, err
+			}
+			ln = int(bytes_to_bint32(b))
+		}
+		raw, err := p.recvPacketsAlignment(ln)
+		if err != nil {
+			return nil, err
+		}
+		elements[i], err = x.value(raw, p.dsn)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return elements, nil
+}
+
+// getArray reads the whole array and returns it nested by dimension.
+func (p *wireProtocol) getArray(d *arrayDesc, arrayId []byte, transHandle int32) ([]interface{}, error) {
+	suspendBuf := p.suspendBuffer()
+	defer p.resumeBuffer(suspendBuf)
+
+	p.opGetSlice(transHandle, arrayId, int32(d.count()*d.elementSize()), d.sdl())
+	elements, err := p.opSliceResponse(d)
+	if err != nil {
+		return nil, err
+	}
+	if len(elements) < d.count() {
+		return nil, errors.New("firebirdsql: array slice is too short")
+	}
+	return d.nest(elements, 0), nil
+}
+
+func (p *wireProtocol) opPutSlice(transHandle int32, sliceLength int32, sdl []byte, slice []byte) {
+	debugPrint(p, "opPutSlice")
+	p.packInt(op_put_slice)
+	p.packInt(transHandle)
+	p.appendBytes(make([]byte, 8)) // new array
+	p.packInt(sliceLength)
+	p.packBytes(sdl)
+	p.packInt(0) // parameters
+	p.packInt(sliceLength)
+	p.appendBytes(slice)
+	p.sendPackets()
+}
+
+// putArray writes a new array and returns its id.
+func (p *wireProtocol) putArray(d *arrayDesc, slice []byte, transHandle int32) (arrayId, error) {
+	p.opPutSlice(transHandle, int32(d.count()*d.elementSize()), d.sdl(), slice)
+	_, oid, _, err := p.opResponse()
+	if err != nil {
+		return nil, err
+	}
+	return arrayId(oid), nil
+}
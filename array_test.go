@@ -0,0 +1,134 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestArraySdl(t *testing.T) {
+	d := &arrayDesc{relname: "T", fieldname: "A", dtype: 8, lower: []int{1}, upper: []int{3}}
+	want := []byte{
+		isc_sdl_version1, isc_sdl_struct, 1, 8, 0,
+		isc_sdl_relation, 1, 'T', isc_sdl_field, 1, 'A',
+		isc_sdl_do1, 0, isc_sdl_tiny_integer, 3,
+		isc_sdl_element, 1, isc_sdl_scalar, 0, 1, isc_sdl_variable, 0,
+		isc_sdl_eoc,
+	}
+	if sdl := d.sdl(); !bytes.Equal(sdl, want) {
+		t.Errorf("INTEGER[3]: %v", sdl)
+	}
+
+	d = &arrayDesc{relname: "T", fieldname: "A", dtype: 37, length: 10, lower: []int{-2, 0}, upper: []int{300, 1}}
+	want = []byte{
+		isc_sdl_version1, isc_sdl_struct, 1, 37, 10, 0,
+		isc_sdl_relation, 1, 'T', isc_sdl_field, 1, 'A',
+		isc_sdl_do2, 0, isc_sdl_tiny_integer, 0xfe, isc_sdl_short_integer, 0x2c, 0x01,
+		isc_sdl_do2, 1, isc_sdl_tiny_integer, 0, isc_sdl_tiny_integer, 1,
+		isc_sdl_element, 1, isc_sdl_scalar, 0, 2, isc_sdl_variable, 0, isc_sdl_variable, 1,
+		isc_sdl_eoc,
+	}
+	if sdl := d.sdl(); !bytes.Equal(sdl, want) {
+		t.Errorf("VARCHAR(10)[-2:300, 0:1]: %v", sdl)
+	}
+	if d.count() != 606 || d.elementSize() != 12 {
+		t.Errorf("count %d, element size %d", d.count(), d.elementSize())
+	}
+}
+
+func TestArrayNest(t *testing.T) {
+	d := &arrayDesc{lower: []int{0, 1}, upper: []int{1, 3}}
+	got := d.nest([]interface{}{1, 2, 3, 4, 5, 6}, 0)
+	want := []interface{}{[]interface{}{1, 2, 3}, []interface{}{4, 5, 6}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("nest: %v", got)
+	}
+}
+
+func TestArrayEncode(t *testing.T) {
+	d := &arrayDesc{relname: "T", fieldname: "A", dtype: 8, scale: -2, lower: []int{0, 1}, upper: []int{1, 2}}
+	b, err := d.encode([][]interface{}{{1, "2.5"}, {Decimal("-0.125"), 4.01}})
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	want := []byte{0, 0, 0, 100, 0, 0, 0, 250, 0xff, 0xff, 0xff, 0xf3, 0, 0, 1, 145}
+	if !bytes.Equal(b, want) {
+		t.Errorf("NUMERIC(9,2)[0:1, 1:2]: %v", b)
+	}
+
+	if _, err = d.encode([]int{1, 2}); err == nil {
+		t.Error("1 dimension for 2 dimensions array")
+	}
+	if _, err = d.encode([][]int{{1, 2}, {3}}); err == nil {
+		t.Error("short dimension")
+	}
+	if _, err = d.encode([][]int{{1, 2}, {3, 4}, {5, 6}}); err == nil {
+		t.Error("long dimension")
+	}
+	if _, err = d.encode([][]interface{}{{1, 2}, {3, true}}); err == nil {
+		t.Error("bool element for NUMERIC")
+	}
+
+	d = &arrayDesc{relname: "T", fieldname: "A", dtype: 7, lower: []int{1}, upper: []int{1}}
+	if _, err = d.encode([]int{40000}); err == nil {
+		t.Error("SMALLINT out of range")
+	}
+
+	d = &arrayDesc{relname: "T", fieldname: "A", dtype: 37, length: 3, lower: []int{-1}, upper: []int{0}}
+	b, err = d.encode([]string{"ab", ""})
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	want = []byte{0, 0, 0, 2, 'a', 'b', 0, 0, 0, 0, 0, 0}
+	if !bytes.Equal(b, want) {
+		t.Errorf("VARCHAR(3)[-1:0]: %v", b)
+	}
+	if _, err = d.encode([]string{"abcd", ""}); err == nil {
+		t.Error("too long string")
+	}
+
+	d = &arrayDesc{relname: "T", fieldname: "A", dtype: 14, length: 3, lower: []int{1}, upper: []int{1}}
+	b, err = d.encode([]string{"a"})
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if !bytes.Equal(b, []byte{'a', ' ', ' ', 0}) {
+		t.Errorf("CHAR(3)[1]: %v", b)
+	}
+}
+
+func TestIsArrayArg(t *testing.T) {
+	for _, v := range []interface{}{[]int{1}, []interface{}{}, [2]string{}, [][]float64{}} {
+		if !isArrayArg(v) {
+			t.Errorf("%T is an array argument", v)
+		}
+	}
+	for _, v := range []interface{}{nil, []byte("a"), "a", 1} {
+		if isArrayArg(v) {
+			t.Errorf("%T is not an array argument", v)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// ErrAttachmentNotFound is returned by CancelAttachment when no attachment
+// of the id is visible to the user of the connection.
+var ErrAttachmentNotFound = errors.New("firebirdsql: attachment not found")
+
+// ErrAttachmentPermission is returned by CancelAttachment when the server
+// denies the user the delete from MON$ATTACHMENTS.
+var ErrAttachmentPermission = errors.New("firebirdsql: no permission to cancel the attachment")
+
+// CancelAttachment disconnects the attachment of id, the MON$ATTACHMENT_ID
+// of MON$ATTACHMENTS, with its running statements and transactions rolled
+// back. db must be connected as SYSDBA, the owner of the database or a user
+// with the RDB$ADMIN role to cancel the attachments of the other users, else
+// only those of the same user are visible and the others are
+// ErrAttachmentNotFound. The attachment of the connection used itself can't
+// be cancelled.
+func CancelAttachment(ctx context.Context, db *sql.DB, id int64) error {
+	result, err := db.ExecContext(ctx,
+		"DELETE FROM MON$ATTACHMENTS WHERE MON$ATTACHMENT_ID = ? AND MON$ATTACHMENT_ID <> CURRENT_CONNECTION", id)
+	if err != nil {
+		return attachmentError(err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrAttachmentNotFound
+	}
+	return nil
+}
+
+// attachmentError maps the permission error of the server to
+// ErrAttachmentPermission, other errors are returned as they are.
+func attachmentError(err error) error {
+	var e *FirebirdError
+	if errors.As(err, &e) && e.HasCode(isc_no_priv) {
+		return ErrAttachmentPermission
+	}
+	return err
+}
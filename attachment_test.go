@@ -0,0 +1,45 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestAttachmentError(t *testing.T) {
+	noPriv := &FirebirdError{codes: []int{isc_no_priv}, message: "no permission for DELETE access to TABLE MON$ATTACHMENTS\n"}
+	if err := attachmentError(fmt.Errorf("wrapped: %w", noPriv)); err != ErrAttachmentPermission {
+		t.Errorf("no permission: %v", err)
+	}
+	other := &FirebirdError{codes: []int{isc_lock_conflict}}
+	if err := attachmentError(other); err != other {
+		t.Errorf("lock conflict: %v", err)
+	}
+	plain := errors.New("bad connection")
+	if err := attachmentError(plain); err != plain {
+		t.Errorf("not a FirebirdError: %v", err)
+	}
+}
@@ -0,0 +1,481 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Batcher is implemented by the driver connection to execute a statement
+// for many rows of parameters. Get it with sql.Conn.Raw.
+// The rows are sent with the batch interface of Firebird 4 or later, or
+// without waiting for each response on older servers.
+type Batcher interface {
+	ExecBatch(query string, rows [][]driver.Value) ([]int64, error)
+}
+
+const (
+	batchBufferSize = 16 * 1024 * 1024
+	pipelineRows    = 100
+	batchSavepoint  = "FB_BATCH"
+)
+
+// parameter types of a batch message
+const (
+	batchNull = iota
+	batchInt
+	batchFloat
+	batchBool
+	batchTime
+	batchTimestamp
+	batchString
+)
+
+func batchType(v driver.Value) int {
+	switch f := v.(type) {
+	case nil:
+		return batchNull
+	case int, int8, int16, int32, int64:
+		return batchInt
+	case float32, float64:
+		return batchFloat
+	case bool:
+		return batchBool
+	case time.Time:
+		if f.Year() == 0 {
+			return batchTime
+		}
+		return batchTimestamp
+	case string, []byte, Decimal:
+		return batchString
+	}
+	return -1
+}
+
+func batchBytes(v driver.Value) []byte {
+	switch f := v.(type) {
+	case string:
+		return str_to_bytes(f)
+	case Decimal:
+		return str_to_bytes(string(f))
+	}
+	return v.([]byte)
+}
+
+// batchFormat returns the parameter types and the string lengths shared by
+// all rows. ok is false if a column has values of different types, or
+// values which can't be in a batch message.
+func batchFormat(rows [][]driver.Value) (types []int, lengths []int, ok bool) {
+	types = make([]int, len(rows[0]))
+	lengths = make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, v := range row {
+			t := batchType(v)
+			switch {
+			case t < 0:
+				return nil, nil, false
+			case t == batchNull:
+				continue
+			case types[i] == batchNull:
+				types[i] = t
+			case types[i] != t:
+				return nil, nil, false
+			}
+			if t == batchString {
+				if n := len(batchBytes(v)); n > lengths[i] {
+					lengths[i] = n
+				}
+			}
+		}
+	}
+	for _, n := range lengths {
+		if n >= MAX_CHAR_LENGTH {
+			return nil, nil, false
+		}
+	}
+	return types, lengths, true
+}
+
+// batchBlr returns the message BLR and the message length in the server,
+// each parameter is aligned by its type.
+func batchBlr(types []int, lengths []int) ([]byte, int) {
+	ln := len(types) * 2
+	blr := []byte{5, 2, 4, 0, byte(ln & 255), byte(ln >> 8)}
+	msgLen := 0
+	for i, t := range types {
+		var size, align int
+		switch t {
+		case batchNull:
+			blr = append(blr, 14, 0, 0)
+			size, align = 0, 1
+		case batchInt:
+			blr = append(blr, 16, 0)
+			size, align = 8, 8
+		case batchFloat:
+			blr = append(blr, 27)
+			size, align = 8, 8
+		case batchBool:
+			blr = append(blr, 23)
+			size, align = 1, 1
+		case batchTime:
+			blr = append(blr, 13)
+			size, align = 4, 4
+		case batchTimestamp:
+			blr = append(blr, 35)
+			size, align = 8, 4
+		case batchString:
+			blr = append(blr, 37, byte(lengths[i]&255), byte(lengths[i]>>8))
+			size, align = lengths[i]+2, 2
+		}
+		msgLen = (msgLen+align-1)/align*align + size
+		blr = append(blr, 7, 0) // null indicator
+		msgLen = (msgLen+1)/2*2 + 2
+	}
+	return append(blr, 255, 76), msgLen // [blr_end, blr_eoc]
+}
+
+// batchMessage encodes row like paramsToBlr, the null bitmap and the values.
+// A time.Time is sent in loc, the location it is read in.
+func batchMessage(types []int, row []driver.Value, loc *time.Location) []byte {
+	n := (len(row) + 7) / 8
+	n += (4 - n%4) % 4
+	msg := make([]byte, n)
+	for i, v := range row {
+		if v == nil {
+			msg[i/8] |= 1 << uint(i%8)
+		}
+	}
+	for i, v := range row {
+		switch f := v.(type) {
+		case nil:
+		case bool:
+			if f {
+				msg = append(msg, 1, 0, 0, 0)
+			} else {
+				msg = append(msg, 0, 0, 0, 0)
+			}
+		case float32:
+			msg = append(msg, bint64_to_bytes(int64(math.Float64bits(float64(f))))...)
+		case float64:
+			msg = append(msg, bint64_to_bytes(int64(math.Float64bits(f)))...)
+		case time.Time:
+			f = f.In(loc)
+			if types[i] == batchTimestamp {
+				msg = append(msg, _convert_date(f)...)
+			}
+			msg = append(msg, _convert_time(f)...)
+		case string, []byte, Decimal:
+			msg = append(msg, xdrBytes(batchBytes(f))...)
+		default:
+			msg = append(msg, bint64_to_bytes(toInt64(f))...)
+		}
+	}
+	return msg
+}
+
+func batchTag(tag byte, v int32) []byte {
+	return append([]byte{tag, 4, 0, 0, 0}, int32_to_bytes(v)...)
+}
+
+func (p *wireProtocol) opBatchCreate(stmtHandle int32, blr []byte, msgLen int32) {
+	debugPrint(p, "opBatchCreate")
+	pb := []byte{BATCH_VERSION1}
+	pb = append(pb, batchTag(BATCH_TAG_RECORD_COUNTS, 1)...)
+	pb = append(pb, batchTag(BATCH_TAG_BUFFER_BYTES_SIZE, batchBufferSize)...)
+	p.packInt(op_batch_create)
+	p.packInt(stmtHandle)
+	p.packBytes(blr)
+	p.packInt(msgLen)
+	p.packBytes(pb)
+	p.sendPackets()
+}
+
+func (p *wireProtocol) opBatchMsg(stmtHandle int32, messages [][]byte) {
+	debugPrint(p, "opBatchMsg")
+	p.packInt(op_batch_msg)
+	p.packInt(stmtHandle)
+	p.packInt(int32(len(messages)))
+	for _, m := range messages {
+		p.appendBytes(m)
+	}
+	p.sendPackets()
+}
+
+func (p *wireProtocol) opBatchExec(stmtHandle int32, transHandle int32) {
+	debugPrint(p, "opBatchExec")
+	p.packInt(op_batch_exec)
+	p.packInt(stmtHandle)
+	p.packInt(transHandle)
+	p.sendPackets()
+}
+
+func (p *wireProtocol) opBatchRls(stmtHandle int32) {
+	debugPrint(p, "opBatchRls")
+	p.packInt(op_batch_rls)
+	p.packInt(stmtHandle)
+	p.sendPackets()
+}
+
+// opBatchCs reads op_batch_cs, the completion state of op_batch_exec.
+// failed is the position of the first failed message or -1.
+func (p *wireProtocol) opBatchCs() (counts []int64, failed int, failure error, err error) {
+	b, err := p.recvPackets(4)
+	for bytes_to_bint32(b) == op_dummy {
+		b, err = p.recvPackets(4)
+	}
+	for bytes_to_bint32(b) == op_response && p.lazyResponseCount > 0 {
+		p.lazyResponseCount--
+		p._parse_op_response()
+		b, err = p.recvPackets(4)
+	}
+	if err != nil {
+		return
+	}
+	if bytes_to_bint32(b) == op_response {
+		_, _, _, err = p._parse_op_response()
+		if err == nil {
+			err = errors.New("Error op_batch_cs")
+		}
+		return
+	}
+	if bytes_to_bint32(b) != op_batch_cs {
+		err = errors.New("Error op_batch_cs")
+		return
+	}
+	// statement, record count, updates, vectors, errors
+	b, err = p.recvPackets(20)
+	if err != nil {
+		return
+	}
+	updates := int(bytes_to_bint32(b[8:12]))
+	vectors := int(bytes_to_bint32(b[12:16]))
+	errs := int(bytes_to_bint32(b[16:20]))
+
+	counts = make([]int64, updates)
+	for i := range counts {
+		b, err = p.recvPackets(4)
+		if err != nil {
+			return
+		}
+		counts[i] = int64(bytes_to_bint32(b))
+	}
+	failed = -1
+	for i := 0; i < vectors+errs; i++ {
+		b, err = p.recvPackets(4)
+		if err != nil {
+			return
+		}
+		pos := int(bytes_to_bint32(b))
+		e := errors.New("execution failed")
+		if i < vectors {
+			gds_codes, sql_code, message, sql_state, err := p._parse_status_vector()
+			if err != nil {
+				return nil, -1, nil, err
+			}
+			e = newFirebirdError(gds_codes, sql_code, sql_state, message)
+		}
+		if failed < 0 || pos < failed {
+			failed, failure = pos, e
+		}
+	}
+	return
+}
+
+// ExecBatch executes query for each of rows, and returns the number of
+// rows affected by each execution.
+func (fc *firebirdsqlConn) ExecBatch(query string, rows [][]driver.Value) (counts []int64, err error) {
+	stmt, err := fc.prepareCached(query)
+	if err != nil {
+		return
+	}
+	counts, err = stmt.execBatch(rows)
+	stmt.Close()
+	if fc.isAutocommit && fc.tx.isAutocommit {
+		if err != nil {
+			fc.tx.Rollback()
+		} else {
+			err = fc.tx.Commit()
+		}
+	}
+	return
+}
+
+func (stmt *firebirdsqlStmt) execBatch(rows [][]driver.Value) ([]int64, error) {
+	if len(stmt.xsqlda) > 0 {
+		return nil, errors.New("firebirdsql: ExecBatch doesn't support statements with output columns")
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	for i, row := range rows {
+		if len(row) != len(rows[0]) {
+			return nil, fmt.Errorf("firebirdsql: batch rows[%d] has %d parameters, rows[0] has %d", i, len(row), len(rows[0]))
+		}
+	}
+	if stmt.wp.protocolVersion >= PROTOCOL_VERSION16 {
+		if types, lengths, ok := batchFormat(rows); ok {
+			if blr, msgLen := batchBlr(types, lengths); msgLen <= math.MaxUint16 {
+				return stmt.execBatchMessages(blr, msgLen, types, rows)
+			}
+		}
+	}
+	return stmt.execPipelined(rows)
+}
+
+// execBatchMessages executes rows with the batch interface, as many
+// messages as the batch buffer can keep at a time.
+func (stmt *firebirdsqlStmt) execBatchMessages(blr []byte, msgLen int, types []int, rows [][]driver.Value) ([]int64, error) {
+	p := stmt.wp
+	p.opBatchCreate(stmt.stmtHandle, blr, int32(msgLen))
+	if _, _, _, err := p.opResponse(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		p.opBatchRls(stmt.stmtHandle)
+		if p.acceptType == ptype_lazy_send {
+			p.lazyResponseCount++
+		} else {
+			p.opResponse()
+		}
+	}()
+
+	chunk := batchBufferSize / ((msgLen + 7) / 8 * 8)
+	counts := make([]int64, 0, len(rows))
+	for start := 0; start < len(rows); start += chunk {
+		end := start + chunk
+		if end > len(rows) {
+			end = len(rows)
+		}
+		messages := make([][]byte, 0, end-start)
+		for _, row := range rows[start:end] {
+			messages = append(messages, batchMessage(types, row, p.dsn.timeLocation()))
+		}
+		p.opBatchMsg(stmt.stmtHandle, messages)
+		if _, _, _, err := p.opResponse(); err != nil {
+			return counts, err
+		}
+		p.opBatchExec(stmt.stmtHandle, stmt.tx.transHandle)
+		c, failed, failure, err := p.opBatchCs()
+		if err != nil {
+			return counts, err
+		}
+		if failed >= 0 {
+			if failed < len(c) {
+				counts = append(counts, c[:failed]...)
+			}
+			return counts, fmt.Errorf("firebirdsql: batch rows[%d]: %w", start+failed, failure)
+		}
+		counts = append(counts, c...)
+	}
+	return counts, nil
+}
+
+// execPipelined sends the executions of pipelineRows rows at a time before
+// reading their responses. Blobs and arrays are written before sending.
+// The rows after a failed one are executed too, so each chunk runs in a
+// savepoint that is rolled back on failure, and the rows before the
+// failed one are executed again.
+func (stmt *firebirdsqlStmt) execPipelined(rows [][]driver.Value) ([]int64, error) {
+	type message struct {
+		blr    []byte
+		values []byte
+	}
+	p := stmt.wp
+	counts := make([]int64, 0, len(rows))
+	for start := 0; start < len(rows); start += pipelineRows {
+		end := start + pipelineRows
+		if end > len(rows) {
+			end = len(rows)
+		}
+		messages := make([]message, 0, end-start)
+		for _, args := range rows[start:end] {
+			err := stmt.describeBind(args)
+			if err != nil {
+				return counts, err
+			}
+			args, err = stmt.writeArrays(args)
+			if err != nil {
+				return counts, err
+			}
+			var m message
+			if len(args) > 0 {
+				m.blr, m.values, err = p.paramsToBlr(stmt.tx.transHandle, args, stmt.bindXsqlda, p.protocolVersion)
+				if err != nil {
+					return counts, err
+				}
+			}
+			messages = append(messages, m)
+		}
+
+		err := stmt.tx.executeImmediate("SAVEPOINT " + batchSavepoint)
+		if err != nil {
+			return counts, err
+		}
+		for _, m := range messages {
+			p.opExecuteMessage(stmt.stmtHandle, stmt.tx.transHandle, m.blr, m.values)
+			p.opInfoSql(stmt.stmtHandle, []byte{isc_info_sql_records}, BUFFER_LEN)
+		}
+		var failed error
+		failedRow := 0
+		for i := range messages {
+			_, _, _, err := p.opResponse()
+			_, _, buf, infoErr := p.opResponse()
+			if err == nil {
+				err = infoErr
+			}
+			if err != nil && failed == nil {
+				failed = fmt.Errorf("firebirdsql: batch rows[%d]: %w", start+i, err)
+				failedRow = i
+			}
+			if failed == nil {
+				counts = append(counts, parseRecordCount(buf, stmt.stmtType))
+			}
+		}
+		if failed != nil {
+			counts = counts[:start]
+			err = stmt.tx.executeImmediate("ROLLBACK TO SAVEPOINT " + batchSavepoint)
+			if err == nil {
+				err = stmt.tx.executeImmediate("RELEASE SAVEPOINT " + batchSavepoint + " ONLY")
+			}
+			if err != nil {
+				return counts, err
+			}
+			c, err := stmt.execPipelined(rows[start : start+failedRow])
+			counts = append(counts, c...)
+			if err != nil {
+				return counts, err
+			}
+			return counts, failed
+		}
+		err = stmt.tx.executeImmediate("RELEASE SAVEPOINT " + batchSavepoint + " ONLY")
+		if err != nil {
+			return counts, err
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,101 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBatchFormat(t *testing.T) {
+	rows := [][]driver.Value{
+		{1, "abc", nil, Decimal("1.5")},
+		{int64(2), nil, true, "12"},
+		{nil, []byte("abcde"), false, nil},
+	}
+	types, lengths, ok := batchFormat(rows)
+	if !ok {
+		t.Fatal("batchFormat failed")
+	}
+	if !reflect.DeepEqual(types, []int{batchInt, batchString, batchBool, batchString}) {
+		t.Errorf("types: %v", types)
+	}
+	if !reflect.DeepEqual(lengths, []int{0, 5, 0, 3}) {
+		t.Errorf("lengths: %v", lengths)
+	}
+
+	for _, rows := range [][][]driver.Value{
+		{{1}, {"a"}},
+		{{1.5}, {1}},
+		{{time.Date(0, 1, 1, 1, 2, 3, 0, time.UTC)}, {time.Now()}},
+		{{bytes.NewReader(nil)}},
+		{{string(make([]byte, MAX_CHAR_LENGTH))}},
+	} {
+		if _, _, ok := batchFormat(rows); ok {
+			t.Errorf("batchFormat must fail: %v", rows)
+		}
+	}
+}
+
+func TestBatchBlr(t *testing.T) {
+	blr, msgLen := batchBlr([]int{batchBool, batchInt, batchString, batchTimestamp, batchNull}, []int{0, 0, 3, 0, 0})
+	want := []byte{
+		5, 2, 4, 0, 10, 0,
+		23, 7, 0,
+		16, 0, 7, 0,
+		37, 3, 0, 7, 0,
+		35, 7, 0,
+		14, 0, 0, 7, 0,
+		255, 76,
+	}
+	if !bytes.Equal(blr, want) {
+		t.Errorf("blr: %v", blr)
+	}
+	// bool 0, null 2, int64 8, null 16, varchar(3) 18, null 24, timestamp 28, null 36, text(0) 38, null 38
+	if msgLen != 40 {
+		t.Errorf("message length: %d", msgLen)
+	}
+}
+
+func TestBatchMessage(t *testing.T) {
+	types := []int{batchInt, batchString, batchBool, batchFloat, batchTime}
+	row := []driver.Value{int32(-2), nil, true, 1.0, time.Date(0, 1, 1, 0, 0, 1, 0, time.UTC)}
+	want := []byte{
+		2, 0, 0, 0, // null bitmap
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe,
+		1, 0, 0, 0,
+		0x3f, 0xf0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0x27, 0x10,
+	}
+	if msg := batchMessage(types, row, time.UTC); !bytes.Equal(msg, want) {
+		t.Errorf("message: %v", msg)
+	}
+	msg := batchMessage([]int{batchString}, []driver.Value{"ab"}, time.UTC)
+	if !bytes.Equal(msg, []byte{0, 0, 0, 0, 0, 0, 0, 2, 'a', 'b', 0, 0}) {
+		t.Errorf("varchar message: %v", msg)
+	}
+}
@@ -0,0 +1,163 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// BlobReader reads a BLOB column value lazily, one segment at a time.
+// Rows return it for BLOB columns when the connection uses
+// blob_mode=stream, or for blobs larger than max_inline_blob.
+// It must be read before the transaction ends.
+//
+//	var r firebirdsql.BlobReader
+//	rows.Scan(&r)
+//	defer r.Close()
+//	io.Copy(w, &r)
+type BlobReader struct {
+	wp          *wireProtocol
+	transHandle int32
+	blobId      []byte
+	blobHandle  int32
+	opened      bool
+	eof         bool
+	buf         []byte
+	r           io.Reader
+}
+
+func newBlobReader(wp *wireProtocol, transHandle int32, blobId []byte) *BlobReader {
+	return &BlobReader{wp: wp, transHandle: transHandle, blobId: blobId}
+}
+
+// Scan implements sql.Scanner. Already fetched blob contents ([]byte or
+// string) are accepted too, so the same destination works in every blob_mode.
+func (b *BlobReader) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case *BlobReader:
+		*b = *v
+	case []byte:
+		*b = BlobReader{r: bytes.NewReader(v)}
+	case string:
+		*b = BlobReader{r: bytes.NewReader(str_to_bytes(v))}
+	case nil:
+		*b = BlobReader{r: bytes.NewReader(nil)}
+	default:
+		return errors.New("BlobReader: unsupported source type")
+	}
+	return nil
+}
+
+func (b *BlobReader) open() (err error) {
+	if b.opened {
+		return
+	}
+	suspendBuf := b.wp.suspendBuffer()
+	b.wp.opOpenBlob(b.blobId, b.transHandle)
+	b.blobHandle, _, _, err = b.wp.opResponse()
+	b.wp.resumeBuffer(suspendBuf)
+	if err == nil {
+		b.opened = true
+	}
+	return
+}
+
+// length returns the total length of the blob.
+func (b *BlobReader) length() (n int, err error) {
+	if err = b.open(); err != nil {
+		return
+	}
+	suspendBuf := b.wp.suspendBuffer()
+	b.wp.opInfoBlob(b.blobHandle, []byte{isc_info_blob_total_length, isc_info_end})
+	_, _, buf, err := b.wp.opResponse()
+	b.wp.resumeBuffer(suspendBuf)
+	if err != nil {
+		return
+	}
+	return parseBlobLength(buf)
+}
+
+func parseBlobLength(buf []byte) (int, error) {
+	if len(buf) < 3 || buf[0] != isc_info_blob_total_length {
+		return 0, errors.New("firebirdsql: invalid blob info")
+	}
+	ln := int(bytes_to_int16(buf[1:3]))
+	if 3+ln > len(buf) {
+		return 0, errors.New("firebirdsql: invalid blob info")
+	}
+	return infoInt(buf[3 : 3+ln]), nil
+}
+
+func (b *BlobReader) Read(p []byte) (n int, err error) {
+	if b.r != nil {
+		return b.r.Read(p)
+	}
+	if err = b.open(); err != nil {
+		return
+	}
+
+	for len(b.buf) == 0 && !b.eof {
+		var more_data int32
+		var rbuf []byte
+		suspendBuf := b.wp.suspendBuffer()
+		b.wp.opGetSegment(b.blobHandle)
+		more_data, _, rbuf, err = b.wp.opResponse()
+		b.wp.resumeBuffer(suspendBuf)
+		if err != nil {
+			return
+		}
+		for len(rbuf) > 0 {
+			ln := int(bytes_to_int16(rbuf[0:2]))
+			b.buf = append(b.buf, rbuf[2:ln+2]...)
+			rbuf = rbuf[ln+2:]
+		}
+		b.eof = more_data == 2
+	}
+
+	if len(b.buf) == 0 {
+		return 0, io.EOF
+	}
+	n = copy(p, b.buf)
+	b.buf = b.buf[n:]
+	return
+}
+
+// Close releases the server side blob handle.
+func (b *BlobReader) Close() (err error) {
+	if !b.opened {
+		return
+	}
+	b.opened = false
+	suspendBuf := b.wp.suspendBuffer()
+	b.wp.opCloseBlob(b.blobHandle)
+	if b.wp.acceptType == ptype_lazy_send {
+		b.wp.lazyResponseCount++
+	} else {
+		_, _, _, err = b.wp.opResponse()
+	}
+	b.wp.resumeBuffer(suspendBuf)
+	return
+}
@@ -0,0 +1,213 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BulkInserter is implemented by the driver connection to insert many rows
+// into a table with few round trips, also on servers without the batch
+// interface of Batcher. Get it with sql.Conn.Raw.
+type BulkInserter interface {
+	BulkInsert(table string, columns []string, rows [][]driver.Value, chunkRows int) (int64, error)
+}
+
+const (
+	bulkChunkRows = 100
+	// the parameters of an EXECUTE BLOCK, a BLR message has at most 65535
+	// fields, a value and its NULL indicator each
+	bulkMaxParams = 32767
+	// the length of the statement text and of the parameter message
+	bulkMaxLength = 65535
+)
+
+// bulkName quotes name as a delimited identifier, uppercased if it's a
+// regular identifier like the server does, so a reserved word like DATE
+// or POSITION is a column too. Other names keep their exact case.
+func bulkName(name string) string {
+	if isIdentifier(name) {
+		name = strings.ToUpper(name)
+	}
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// bulkBlock returns the EXECUTE BLOCK inserting n rows, its parameters are
+// typed as the columns. The parameters of a chunk are named the same for
+// each chunk, so the blocks of the same size are the cached statement.
+func bulkBlock(table string, columns []string, n int) string {
+	var params, inserts strings.Builder
+	params.WriteString("EXECUTE BLOCK (")
+	into := "INSERT INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES ("
+	for r := 0; r < n; r++ {
+		inserts.WriteString(into)
+		for c, column := range columns {
+			name := "P" + strconv.Itoa(r*len(columns)+c)
+			if r > 0 || c > 0 {
+				params.WriteString(", ")
+			}
+			params.WriteString(name + " TYPE OF COLUMN " + table + "." + column + " = ?")
+			if c > 0 {
+				inserts.WriteString(", ")
+			}
+			inserts.WriteString(":" + name)
+		}
+		inserts.WriteString(");\n")
+	}
+	return params.String() + ")\nAS BEGIN\n" + inserts.String() + "END"
+}
+
+// bulkRowLength is the length of a row of parameters of xsqlda in the
+// message, with the alignment and the NULL indicator of each.
+func bulkRowLength(xsqlda []xSQLVAR) int {
+	n := 0
+	for _, x := range xsqlda {
+		ln := x.ioLength()
+		if ln < 0 { // VARCHAR
+			ln = x.sqllen + 2
+		}
+		n += ln + 8
+	}
+	return n
+}
+
+// BulkInsert inserts rows into the columns of table with EXECUTE BLOCKs of
+// chunkRows rows each (100 if it is 0), one round trip a chunk. A chunk is
+// split further to keep the parameters and the statement text within the
+// limits of the server. It returns the number of inserted rows. In
+// autocommit mode all the rows are rolled back if one chunk fails.
+// table and columns are quoted, the regular identifiers uppercased.
+func (fc *firebirdsqlConn) BulkInsert(table string, columns []string, rows [][]driver.Value, chunkRows int) (n int64, err error) {
+	if len(columns) == 0 {
+		return 0, fmt.Errorf("firebirdsql: BulkInsert into %s without columns", table)
+	}
+	for i, row := range rows {
+		if len(row) != len(columns) {
+			return 0, fmt.Errorf("firebirdsql: bulk rows[%d] has %d values for %d columns", i, len(row), len(columns))
+		}
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	table = bulkName(table)
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = bulkName(column)
+	}
+
+	if chunkRows <= 0 {
+		chunkRows = bulkChunkRows
+	}
+	if max := bulkMaxParams / len(columns); chunkRows > max {
+		chunkRows = max
+	}
+	rowLength, err := fc.bulkRowLength(table, names)
+	if err != nil {
+		return
+	}
+	if max := bulkMaxLength / rowLength; chunkRows > max {
+		chunkRows = max
+	}
+	if chunkRows < 1 {
+		chunkRows = 1
+	}
+
+	n, err = fc.bulkInsert(table, names, rows, chunkRows)
+	if fc.isAutocommit && fc.tx.isAutocommit {
+		if err != nil {
+			fc.tx.Rollback()
+			n = 0
+		} else {
+			err = fc.tx.Commit()
+		}
+	}
+	return
+}
+
+// bulkRowLength describes the parameters of an INSERT of a row, which
+// also checks the table and the columns.
+func (fc *firebirdsqlConn) bulkRowLength(table string, names []string) (int, error) {
+	query := "INSERT INTO " + table + " (" + strings.Join(names, ", ") + ") VALUES (?" + strings.Repeat(", ?", len(names)-1) + ")"
+	stmt, err := fc.prepareCached(query)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	if stmt.bindDescribed {
+		return bulkRowLength(stmt.bindXsqlda), nil
+	}
+	fc.wp.opInfoSql(stmt.stmtHandle, _INFO_SQL_BIND_DESCRIBE_VARS(), BUFFER_LEN)
+	_, _, buf, err := fc.wp.opResponse()
+	if err != nil {
+		return 0, err
+	}
+	_, _, xsqlda, err := fc.wp.parse_xsqlda(buf, stmt.stmtHandle)
+	if err != nil {
+		return 0, err
+	}
+	return bulkRowLength(xsqlda), nil
+}
+
+func (fc *firebirdsqlConn) bulkInsert(table string, names []string, rows [][]driver.Value, chunkRows int) (n int64, err error) {
+	for start := 0; start < len(rows); {
+		size := chunkRows
+		if size > len(rows)-start {
+			size = len(rows) - start
+		}
+		query := bulkBlock(table, names, size)
+		for len(query) > bulkMaxLength && size > 1 {
+			size /= 2
+			chunkRows = size
+			query = bulkBlock(table, names, size)
+		}
+		args := make([]driver.Value, 0, size*len(names))
+		for i, row := range rows[start : start+size] {
+			for j, v := range row {
+				v, err = fc.convertValue(v)
+				if err != nil {
+					return n, fmt.Errorf("firebirdsql: bulk rows[%d][%d]: %w", start+i, j, err)
+				}
+				args = append(args, v)
+			}
+		}
+
+		var stmt *firebirdsqlStmt
+		stmt, err = fc.prepareCached(query)
+		if err != nil {
+			return
+		}
+		_, err = stmt.Exec(args)
+		stmt.Close()
+		if err != nil {
+			return n, fmt.Errorf("firebirdsql: bulk rows[%d:%d]: %w", start, start+size, err)
+		}
+		// each INSERT of the block inserts its row or fails
+		n += int64(size)
+		start += size
+	}
+	return
+}
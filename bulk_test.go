@@ -0,0 +1,117 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"math/big"
+	"testing"
+)
+
+func TestBulkName(t *testing.T) {
+	for name, want := range map[string]string{
+		"items":       `"ITEMS"`,
+		"Item_2":      `"ITEM_2"`,
+		"date":        `"DATE"`,
+		"POSITION":    `"POSITION"`,
+		"Order Lines": `"Order Lines"`,
+		`say "hi"`:    `"say ""hi"""`,
+		"2nd":         `"2nd"`,
+	} {
+		if got := bulkName(name); got != want {
+			t.Errorf("%s: got %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestBulkBlock(t *testing.T) {
+	want := "EXECUTE BLOCK (P0 TYPE OF COLUMN T.A = ?, P1 TYPE OF COLUMN T.B = ?, P2 TYPE OF COLUMN T.A = ?, P3 TYPE OF COLUMN T.B = ?)\n" +
+		"AS BEGIN\n" +
+		"INSERT INTO T (A, B) VALUES (:P0, :P1);\n" +
+		"INSERT INTO T (A, B) VALUES (:P2, :P3);\n" +
+		"END"
+	if got := bulkBlock("T", []string{"A", "B"}, 2); got != want {
+		t.Errorf("got %q", got)
+	}
+
+	// a reserved word as a column
+	want = "EXECUTE BLOCK (P0 TYPE OF COLUMN \"T\".\"DATE\" = ?)\n" +
+		"AS BEGIN\n" +
+		"INSERT INTO \"T\" (\"DATE\") VALUES (:P0);\n" +
+		"END"
+	if got := bulkBlock(bulkName("t"), []string{bulkName("date")}, 1); got != want {
+		t.Errorf("reserved word: got %q", got)
+	}
+}
+
+func TestBulkRowLength(t *testing.T) {
+	xsqlda := []xSQLVAR{
+		{sqltype: SQL_TYPE_LONG, sqllen: 4},
+		{sqltype: SQL_TYPE_VARYING, sqllen: 40},
+		{sqltype: SQL_TYPE_TEXT, sqllen: 10},
+	}
+	if n := bulkRowLength(xsqlda); n != 4+8+42+8+10+8 {
+		t.Errorf("row length %d", n)
+	}
+}
+
+func TestBulkInsertArgs(t *testing.T) {
+	fc := &firebirdsqlConn{}
+	if _, err := fc.BulkInsert("T", nil, [][]driver.Value{{1}}, 0); err == nil {
+		t.Errorf("no columns was accepted")
+	}
+	if _, err := fc.BulkInsert("T", []string{"A", "B"}, [][]driver.Value{{1, 2}, {3}}, 0); err == nil {
+		t.Errorf("short row was accepted")
+	}
+	if n, err := fc.BulkInsert("T", []string{"A"}, nil, 0); n != 0 || err != nil {
+		t.Errorf("no rows: %d %v", n, err)
+	}
+}
+
+func TestBulkConvertValue(t *testing.T) {
+	fc := &firebirdsqlConn{}
+	tests := []struct {
+		v    interface{}
+		want driver.Value
+	}{
+		{sql.NullString{String: "a", Valid: true}, "a"},
+		{sql.NullString{}, nil},
+		{NullDecimal{Decimal: "1.50", Valid: true}, Decimal("1.50")},
+		{NullDecimal{}, nil},
+		{(*big.Int)(nil), nil},
+		{float32(0.5), float64(0.5)},
+		{uint16(7), int64(7)},
+		{int8(-3), int64(-3)},
+		{"ok", "ok"},
+	}
+	for _, tt := range tests {
+		if v, err := fc.convertValue(tt.v); err != nil || v != tt.want {
+			t.Errorf("%#v: got %#v %v, want %#v", tt.v, v, err, tt.want)
+		}
+	}
+	if _, err := fc.convertValue(struct{}{}); err == nil {
+		t.Errorf("struct was accepted")
+	}
+}
@@ -24,13 +24,20 @@ CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
 package firebirdsql
 
 import (
+	"context"
+	"database/sql"
 	"database/sql/driver"
+	"errors"
+	"io"
 	"math/big"
+	"reflect"
+	"time"
 )
 
 type firebirdsqlConn struct {
 	wp             *wireProtocol
 	tx             *firebirdsqlTx
+	dsn            *firebirdDsn
 	addr           string
 	dbName         string
 	user           string
@@ -39,6 +46,10 @@ type firebirdsqlConn struct {
 	isAutocommit   bool
 	clientPublic   *big.Int
 	clientSecret   *big.Int
+	stmtCache      *stmtCache
+	serverVersion  *ServerVersion
+	// loadTimeZoneNames is tried once, the offsets are used if it fails
+	timeZoneNamesTried bool
 }
 
 func (fc *firebirdsqlConn) Begin() (driver.Tx, error) {
@@ -47,22 +58,125 @@ func (fc *firebirdsqlConn) Begin() (driver.Tx, error) {
 	return driver.Tx(tx), err
 }
 
+func (fc *firebirdsqlConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	tx, err := newFirebirdsqlTxWithOptions(ctx, fc, opts)
+	if err != nil {
+		return nil, err
+	}
+	fc.tx = tx
+	return driver.Tx(tx), nil
+}
+
+func (fc *firebirdsqlConn) Savepoint(name string) (string, error) {
+	return fc.tx.Savepoint(name)
+}
+
+func (fc *firebirdsqlConn) ReleaseSavepoint(name string) error {
+	return fc.tx.ReleaseSavepoint(name)
+}
+
+func (fc *firebirdsqlConn) RollbackToSavepoint(name string) error {
+	return fc.tx.RollbackToSavepoint(name)
+}
+
+func (fc *firebirdsqlConn) PrepareTransaction(description []byte) error {
+	return fc.tx.PrepareTransaction(description)
+}
+
+func (fc *firebirdsqlConn) CommitRetaining() error {
+	return fc.tx.CommitRetaining()
+}
+
+func (fc *firebirdsqlConn) RollbackRetaining() error {
+	return fc.tx.RollbackRetaining()
+}
+
 func (fc *firebirdsqlConn) Close() (err error) {
+	fc.dropCachedStatements()
 	fc.wp.opDetach()
 	fc.wp.conn.Close()
 	return
 }
 
+// dropCachedStatements drops the statements of the statement cache, which
+// hold the metadata locks of their tables and procedures.
+func (fc *firebirdsqlConn) dropCachedStatements() {
+	for _, stmt := range fc.stmtCache.clear() {
+		stmt.drop()
+	}
+}
+
 func (fc *firebirdsqlConn) Prepare(query string) (driver.Stmt, error) {
 	return newFirebirdsqlStmt(fc, query)
 }
 
-func (fc *firebirdsqlConn) Exec(query string, args []driver.Value) (result driver.Result, err error) {
-	stmt, err := fc.Prepare(query)
+// prepareCached takes the statement for query out of the statement cache,
+// or prepares it. Close puts it back.
+func (fc *firebirdsqlConn) prepareCached(query string) (*firebirdsqlStmt, error) {
+	return fc.prepareCachedContext(context.Background(), query)
+}
+
+func (fc *firebirdsqlConn) prepareCachedContext(ctx context.Context, query string) (stmt *firebirdsqlStmt, err error) {
+	if stmt = fc.stmtCache.get(query); stmt != nil {
+		stmt.tx = fc.tx
+		return stmt, nil
+	}
+	err = fc.dsn.observe(ctx, QueryOpPrepare, query, func(ctx context.Context) (int64, error) {
+		stmt, err = newFirebirdsqlStmt(fc, query)
+		return -1, err
+	})
+	if err == nil && fc.stmtCache != nil && stmt.cacheable() {
+		stmt.cache = fc.stmtCache
+	}
+	return stmt, err
+}
+
+func (fc *firebirdsqlConn) Exec(query string, args []driver.Value) (driver.Result, error) {
+	return fc.exec(context.Background(), query, positionalArgs(args))
+}
+
+// positionalArgs are args of Exec and Query as the driver.NamedValue
+// arguments of ExecContext and QueryContext.
+func positionalArgs(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
+
+// exec runs query. A DDL statement without arguments is executed
+// immediately, without the statement to allocate, prepare and free.
+// args are bound to the placeholders of the prepared statement, whose
+// names are parsed once with the prepare.
+func (fc *firebirdsqlConn) exec(ctx context.Context, query string, args []driver.NamedValue) (result driver.Result, err error) {
+	ddl := isDDL(query)
+	if ddl {
+		// else a DROP or ALTER of a cached table fails with "object in use"
+		fc.dropCachedStatements()
+	}
+	if len(args) == 0 && ddl {
+		if fc.dsn.autocommitDDL && fc.tx.isAutocommit {
+			return fc.execDDL(query)
+		}
+		result, err = fc.execImmediate(fc.tx.transHandle, query)
+		if err == nil && fc.isAutocommit && fc.tx.isAutocommit {
+			if err = fc.commitAutocommit(); err != nil {
+				result = nil
+			}
+		}
+		return
+	}
+	stmt, err := fc.prepareCachedContext(ctx, query)
 	if err != nil {
 		return
 	}
-	result, err = stmt.Exec(args)
+	values, err := bindNamedValues(stmt.paramNames, args)
+	if err != nil {
+		stmt.Close()
+		return
+	}
+	result, err = stmt.Exec(values)
 	if err != nil {
 		return
 	}
@@ -73,78 +187,287 @@ func (fc *firebirdsqlConn) Exec(query string, args []driver.Value) (result drive
 	return
 }
 
-func (fc *firebirdsqlConn) Query(query string, args []driver.Value) (rows driver.Rows, err error) {
-	stmt, err := fc.Prepare(query)
+// commitAutocommit commits the autocommit transaction after a DDL
+// statement. The server reports many DDL errors at commit, then the
+// transaction is rolled back.
+func (fc *firebirdsqlConn) commitAutocommit() error {
+	transHandle := fc.tx.transHandle
+	err := fc.tx.Commit()
 	if err != nil {
-		return
+		fc.wp.opRollback(transHandle)
+		fc.wp.opResponse()
 	}
-	rows, err = stmt.Query(args)
-	return
+	return err
 }
 
-func newFirebirdsqlConn(dsn string) (fc *firebirdsqlConn, err error) {
-	addr, dbName, user, password, role, authPluginName, wireCrypt, isolationLevel, err := parseDSN(dsn)
-	wp, err := newWireProtocol(addr)
+// execImmediate runs the DDL query in the transaction with
+// op_exec_immediate in one round trip. It affects no row.
+func (fc *firebirdsqlConn) execImmediate(transHandle int32, query string) (driver.Result, error) {
+	// no statement is prepared, it's only for the stats
+	stmt := &firebirdsqlStmt{wp: fc.wp, query: query}
+	defer stmt.reportStats()
+	defer stmt.collectStats()()
+	fc.wp.opExecuteImmediate(transHandle, query)
+	if _, _, _, err := fc.wp.opResponse(); err != nil {
+		return nil, err
+	}
+	return &firebirdsqlResult{}, nil
+}
+
+// execDDL runs the DDL query of autocommit_ddl in a transaction of its
+// own, committed at once. The autocommit transaction, whose cursors may
+// be open, is left as it is.
+func (fc *firebirdsqlConn) execDDL(query string) (driver.Result, error) {
+	fc.wp.opTransaction(transactionTpb(ISOLATION_LEVEL_READ_COMMITED, fc.dsn.readOnly, fc.dsn.lockTimeout))
+	transHandle, _, _, err := fc.wp.opResponse()
 	if err != nil {
-		return
+		return nil, err
 	}
-	clientPublic, clientSecret := getClientSeed()
+	result, err := fc.execImmediate(transHandle, query)
+	if err != nil {
+		fc.wp.opRollback(transHandle)
+		fc.wp.opResponse()
+		return nil, err
+	}
+	fc.wp.opCommit(transHandle)
+	if _, _, _, err = fc.wp.opResponse(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Query runs query. The output parameters of EXECUTE PROCEDURE are read
+// with the execute, and a connection in autocommit mode commits it like
+// Exec, unless a blob_mode=stream value still reads from the transaction.
+func (fc *firebirdsqlConn) Query(query string, args []driver.Value) (driver.Rows, error) {
+	return fc.query(context.Background(), query, positionalArgs(args))
+}
 
-	wp.opConnect(dbName, user, password, authPluginName, wireCrypt, clientPublic)
-	err = wp.opAccept(user, password, authPluginName, clientPublic, clientSecret)
+func (fc *firebirdsqlConn) query(ctx context.Context, query string, args []driver.NamedValue) (rows driver.Rows, err error) {
+	stmt, err := fc.prepareCachedContext(ctx, query)
 	if err != nil {
 		return
 	}
-	wp.opAttach(dbName, user, password, role)
-	wp.dbHandle, _, _, err = wp.opResponse()
+	values, err := bindNamedValues(stmt.paramNames, args)
 	if err != nil {
+		stmt.Close()
+		return
+	}
+	rows, err = stmt.Query(values)
+	if err != nil || stmt.stmtType != isc_info_sql_stmt_exec_procedure {
 		return
 	}
+	if fc.isAutocommit && fc.tx.isAutocommit && !hasBlobReader(rows.(*firebirdsqlRows).result) {
+		if err = fc.tx.Commit(); err != nil {
+			rows.Close()
+			rows = nil
+		}
+	}
+	return
+}
 
-	fc = new(firebirdsqlConn)
-	fc.wp = wp
-	fc.addr = addr
-	fc.dbName = dbName
-	fc.user = user
-	fc.password = password
-	fc.isolationLevel = isolationLevel
+func hasBlobReader(values []driver.Value) bool {
+	for _, v := range values {
+		if _, ok := v.(*BlobReader); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (fc *firebirdsqlConn) PrepareContext(ctx context.Context, query string) (stmt driver.Stmt, err error) {
+	err = fc.dsn.observe(ctx, QueryOpPrepare, query, func(ctx context.Context) (int64, error) {
+		return -1, fc.wp.withContext(ctx, func() (err error) {
+			stmt, err = fc.Prepare(query)
+			return
+		})
+	})
+	return
+}
+
+func (fc *firebirdsqlConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (result driver.Result, err error) {
+	err = fc.dsn.observe(ctx, QueryOpExec, query, func(ctx context.Context) (int64, error) {
+		return rowsAffected(result, fc.wp.withContext(ctx, func() (err error) {
+			result, err = fc.exec(ctx, query, args)
+			return
+		}))
+	})
+	return
+}
+
+func (fc *firebirdsqlConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (rows driver.Rows, err error) {
+	err = fc.dsn.observe(ctx, QueryOpQuery, query, func(ctx context.Context) (int64, error) {
+		return -1, fc.wp.withContext(ctx, func() (err error) {
+			rows, err = fc.query(ctx, query, args)
+			return
+		})
+	})
+	return
+}
+
+// Ping sends a database info request, and returns driver.ErrBadConn if
+// the server doesn't answer so database/sql discards the connection.
+func (fc *firebirdsqlConn) Ping(ctx context.Context) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		fc.wp.conn.SetDeadline(deadline)
+		defer fc.wp.conn.SetDeadline(time.Time{})
+	}
+	fc.wp.opInfoDatabase([]byte{isc_info_ods_version, isc_info_end}, BUFFER_LEN)
+	if _, _, _, err := fc.wp.opResponse(); err != nil {
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+// ResetSession is called by database/sql before a pooled connection is
+// reused. It rolls back whatever the previous user left in the current
+// transaction, and starts a new autocommit transaction with the settings
+// of the DSN, unless the transaction is untouched.
+func (fc *firebirdsqlConn) ResetSession(ctx context.Context) error {
+	if fc.wp.conn.broken {
+		return driver.ErrBadConn
+	}
 	fc.isAutocommit = true
-	fc.tx, err = newFirebirdsqlTx(fc, fc.isAutocommit)
-	fc.clientPublic = clientPublic
-	fc.clientSecret = clientSecret
+	fc.isolationLevel = fc.dsn.isolationLevel
+	if fc.tx.untouched() {
+		return nil
+	}
+	if err := fc.tx.Rollback(); err != nil {
+		return driver.ErrBadConn
+	}
+	return nil
+}
 
-	return fc, err
+// IsValid implements driver.Validator. A connection whose socket timed out,
+// or was closed or reset, isn't put back into the pool of database/sql.
+func (fc *firebirdsqlConn) IsValid() bool {
+	return !fc.wp.conn.broken
+}
+
+// CheckNamedValue accepts io.Reader arguments, which are written as blobs,
+// Decimal, NullDecimal, *big.Int and *big.Rat arguments, which are scaled
+// to the target column, Date and Time arguments, which are sent as DATE and TIME, and
+// sql.Out arguments, which receive the RETURNING values or the procedure
+// output parameters of Exec and send their value with In set, and slices,
+// which are written to ARRAY columns. A nil pointer, slice or []byte is
+// NULL.
+// Everything else uses the database/sql default conversion, which makes
+// NULL of nil pointers and of the invalid sql.NullString, sql.NullInt64...
+func (fc *firebirdsqlConn) CheckNamedValue(nv *driver.NamedValue) error {
+	switch v := nv.Value.(type) {
+	case io.Reader:
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+			nv.Value = nil // a typed nil is NULL
+		}
+		return nil
+	case Decimal, Date, Time, sql.Out:
+		return nil
+	case *big.Int:
+		if v == nil {
+			nv.Value = nil
+		}
+		return nil
+	case *big.Rat:
+		if v == nil {
+			nv.Value = nil
+		}
+		return nil
+	case NullDecimal:
+		nv.Value = nil
+		if v.Valid {
+			nv.Value = v.Decimal
+		}
+		return nil
+	}
+	if isArrayArg(nv.Value) {
+		if rv := reflect.ValueOf(nv.Value); rv.Kind() == reflect.Slice && rv.IsNil() {
+			nv.Value = nil
+		}
+		return nil
+	}
+	if b, ok := nv.Value.([]byte); ok && b == nil {
+		nv.Value = nil
+		return nil
+	}
+	return driver.ErrSkip
+}
+
+// convertValue converts an argument given to the driver connection itself,
+// e.g. by BulkInsert, like database/sql does: CheckNamedValue, else
+// driver.DefaultParameterConverter, which calls driver.Valuer.
+func (fc *firebirdsqlConn) convertValue(v interface{}) (driver.Value, error) {
+	nv := &driver.NamedValue{Value: v}
+	err := fc.CheckNamedValue(nv)
+	if err == driver.ErrSkip {
+		return driver.DefaultParameterConverter.ConvertValue(v)
+	}
+	return nv.Value, err
+}
+
+func newFirebirdsqlConn(dsn string) (fc *firebirdsqlConn, err error) {
+	d, err := parseDSN(dsn)
+	if err != nil {
+		return
+	}
+	return openFirebirdsqlConn(context.Background(), d, false)
 }
 
 func createFirebirdsqlConn(dsn string) (fc *firebirdsqlConn, err error) {
-	// Create Database
-	addr, dbName, user, password, role, authPluginName, wireCrypt, isolationLevel, err := parseDSN(dsn)
-	wp, err := newWireProtocol(addr)
+	d, err := parseDSN(dsn)
 	if err != nil {
 		return
 	}
+	return openFirebirdsqlConn(context.Background(), d, true)
+}
 
+// openFirebirdsqlConn attaches to the database, or creates it.
+func openFirebirdsqlConn(ctx context.Context, d *firebirdDsn, create bool) (fc *firebirdsqlConn, err error) {
+	wp, err := newWireProtocol(ctx, d)
+	if err != nil {
+		return
+	}
+	defer func() {
+		// the server may have dropped the connection in the handshake
+		if err != nil {
+			wp.conn.Close()
+		}
+	}()
 	clientPublic, clientSecret := getClientSeed()
 
-	wp.opConnect(dbName, user, password, authPluginName, wireCrypt, clientPublic)
-	err = wp.opAccept(user, password, authPluginName, clientPublic, clientSecret)
+	wp.opConnect(d.dbName, d.user, d.passwd, d.authPluginName, d.wireCrypt, clientPublic)
+	err = wp.opAccept(d.user, d.passwd, d.authPluginName, clientPublic, clientSecret)
 	if err != nil {
 		return
 	}
-	wp.opCreate(dbName, user, password, role)
+	if d.noDBTriggers && wp.protocolVersion < PROTOCOL_VERSION11 {
+		// older servers ignore the DPB item and fire the triggers
+		err = errors.New("firebirdsql: no_db_triggers needs Firebird 2.1 or later")
+		return
+	}
+	if create {
+		wp.opCreate(d.dbName, d.user, d.passwd, d.role)
+	} else {
+		wp.opAttach(d.dbName, d.user, d.passwd, d.role)
+	}
 	wp.dbHandle, _, _, err = wp.opResponse()
+	if err != nil {
+		return
+	}
 
 	fc = new(firebirdsqlConn)
 	fc.wp = wp
-	fc.addr = addr
-	fc.dbName = dbName
-	fc.user = user
-	fc.password = password
-	fc.isolationLevel = isolationLevel
+	fc.dsn = d
+	fc.addr = d.addr
+	fc.dbName = d.dbName
+	fc.user = d.user
+	fc.password = d.passwd
+	fc.isolationLevel = d.isolationLevel
 	fc.isAutocommit = true
 	fc.tx, err = newFirebirdsqlTx(fc, fc.isAutocommit)
 	fc.clientPublic = clientPublic
 	fc.clientSecret = clientSecret
+	fc.stmtCache = newStmtCache(d.stmtCacheSize)
+	wp.conn.attached = true
 
 	return fc, err
 }
@@ -0,0 +1,188 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"net/url"
+	"time"
+)
+
+// Connector is a driver.Connector for sql.OpenDB. The fields are those of
+// the DSN, and Dial can route the connection through a proxy or a Unix
+// domain socket.
+type Connector struct {
+	User     string
+	Password string
+	Addr     string // host:port, the host defaults to localhost and the port to 3050
+	Database string // path or alias of the database
+	// Params are the DSN parameters, e.g. role, charset or wire_crypt.
+	Params url.Values
+	// TLSConfig overrides the tls parameters, and connects with TLS if it is not nil.
+	TLSConfig *tls.Config
+	// Location overrides the timezone parameter.
+	Location *time.Location
+	// Dial connects to the server instead of a TCP connection to Addr.
+	Dial func(ctx context.Context) (net.Conn, error)
+	// CreateDatabase creates the database instead of attaching to it,
+	// like the firebirdsql_createdb driver.
+	CreateDatabase bool
+	// Observer is called around the statements of the connections.
+	Observer QueryObserver
+	// Stats is called after each execution of a statement, or the close of
+	// the rows of a query, with its wire protocol counters.
+	Stats func(query string, stats StatementStats)
+	// ConnectAttempts is how many times Connect tries to connect when the
+	// server can't be reached or drops the connection in the handshake.
+	// 0 and 1 try once.
+	ConnectAttempts int
+	// ConnectBackoff is the wait before the second attempt, doubled for
+	// each next one.
+	ConnectBackoff time.Duration
+}
+
+// NewConnector returns a Connector with the fields of the DSN.
+func NewConnector(dsn string) (*Connector, error) {
+	d, err := parseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	u, _ := url.Parse("firebird://" + dsn)
+	params, _ := url.ParseQuery(u.RawQuery)
+	return &Connector{
+		User:     d.user,
+		Password: d.passwd,
+		Addr:     d.addr,
+		Database: d.dbName,
+		Params:   params,
+	}, nil
+}
+
+func (c *Connector) dsn() (*firebirdDsn, error) {
+	d := &firebirdDsn{
+		user:     c.User,
+		passwd:   c.Password,
+		addr:     c.Addr,
+		dbName:   c.Database,
+		dial:     c.Dial,
+		observer: c.Observer,
+		stats:    c.Stats,
+	}
+	if _, _, err := net.SplitHostPort(d.addr); err != nil {
+		d.addr = net.JoinHostPort(d.addr, "3050")
+	}
+	if host, port, _ := net.SplitHostPort(d.addr); host == "" {
+		d.addr = net.JoinHostPort("localhost", port)
+	}
+	params := c.Params
+	if params == nil {
+		params = url.Values{}
+	}
+	if err := d.parseParams(params); err != nil {
+		return nil, err
+	}
+	if c.TLSConfig != nil {
+		d.tlsConfig = c.TLSConfig.Clone()
+	}
+	if c.Location != nil {
+		d.location = c.Location
+	}
+	return d, nil
+}
+
+// Connect implements driver.Connector.
+func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
+	d, err := c.dsn()
+	if err != nil {
+		return nil, err
+	}
+	backoff := c.ConnectBackoff
+	for attempt := 1; ; attempt++ {
+		fc, err := openFirebirdsqlConn(ctx, d, c.CreateDatabase)
+		if err == nil {
+			return fc, nil
+		}
+		if attempt >= c.ConnectAttempts || !isConnectRetryable(err) {
+			return nil, err
+		}
+		t := time.NewTimer(backoff)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// isConnectRetryable reports whether err of a connection attempt is a
+// network error, and not e.g. the wrong password.
+func isConnectRetryable(err error) bool {
+	var ne net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.As(err, &ne)
+}
+
+// Driver implements driver.Connector.
+func (c *Connector) Driver() driver.Driver {
+	if c.CreateDatabase {
+		return &firebirdsqlCreateDbDriver{}
+	}
+	return &firebirdsqlDriver{}
+}
+
+// Warmup opens n connections of the pool of db up front, and pings them,
+// so the first queries don't wait for the connection and the handshake.
+// Set db.SetMaxIdleConns to n or more before it, else the pool closes the
+// connections beyond its idle limit (2 by default) when they are put back.
+// The connections are opened one after the other, and Warmup returns the
+// error of ctx if it's done before they are all opened.
+func Warmup(ctx context.Context, db *sql.DB, n int) error {
+	conns := make([]*sql.Conn, 0, n)
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	for len(conns) < n {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// the connections are held, so each one is a new one
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return err
+		}
+		conns = append(conns, conn)
+		if err = conn.PingContext(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestNewConnector(t *testing.T) {
+	c, err := NewConnector("user:password@localhost/tmp/test.fdb?role=admin&wire_crypt=disabled")
+	if err != nil {
+		t.Fatalf("NewConnector: %v", err)
+	}
+	if c.User != "user" || c.Password != "password" || c.Addr != "localhost:3050" || c.Database != "/tmp/test.fdb" {
+		t.Errorf("unexpected connector %+v", c)
+	}
+	if c.Params.Get("role") != "admin" || c.Params.Get("wire_crypt") != "disabled" {
+		t.Errorf("unexpected params %v", c.Params)
+	}
+	if _, err = NewConnector("user:password@localhost/dbname?unknown=1"); err == nil {
+		t.Errorf("invalid parameter was accepted")
+	}
+
+	// the server on the same host
+	if c, _ = NewConnector("user:password@/tmp/test.fdb"); c.Addr != "localhost:3050" {
+		t.Errorf("unexpected addr without host %s", c.Addr)
+	}
+	for addr, want := range map[string]string{"": "localhost:3050", ":3051": "localhost:3051"} {
+		d, _ := (&Connector{Addr: addr, Database: "employee"}).dsn()
+		if d.addr != want {
+			t.Errorf("Addr %q: %s", addr, d.addr)
+		}
+	}
+}
+
+func TestConnectorDial(t *testing.T) {
+	dialErr := errors.New("no route")
+	var dialed bool
+	c := &Connector{
+		User:     "user",
+		Password: "password",
+		Addr:     "db.example.com",
+		Database: "employee",
+		Dial: func(ctx context.Context) (net.Conn, error) {
+			dialed = true
+			return nil, dialErr
+		},
+	}
+	d, err := c.dsn()
+	if err != nil {
+		t.Fatalf("dsn: %v", err)
+	}
+	if d.addr != "db.example.com:3050" || d.dialect != 3 || d.wireCrypt != WIRE_CRYPT_ENABLED {
+		t.Errorf("unexpected dsn %+v", d)
+	}
+	if _, err = c.Connect(context.Background()); err != dialErr || !dialed {
+		t.Errorf("expected the error of Dial, got %v", err)
+	}
+}
+
+func TestConnectorRetry(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	dialErr := errors.New("no route")
+	var dials int
+	c := &Connector{
+		Addr:            "db.example.com",
+		Database:        "employee",
+		ConnectAttempts: 4,
+		ConnectBackoff:  time.Millisecond,
+		Dial: func(ctx context.Context) (net.Conn, error) {
+			dials++
+			switch dials {
+			case 1:
+				return nil, refused
+			case 2:
+				// the server drops the connection in the handshake
+				c1, c2 := net.Pipe()
+				go func() {
+					c1.Read(make([]byte, 1024))
+					c1.Close()
+				}()
+				return c2, nil
+			}
+			return nil, dialErr
+		},
+	}
+	if _, err := c.Connect(context.Background()); err != dialErr || dials != 3 {
+		t.Errorf("expected the error of Dial after 3 dials, got %v after %d", err, dials)
+	}
+
+	dials = 0
+	c.ConnectAttempts = 0
+	if _, err := c.Connect(context.Background()); !errors.Is(err, syscall.ECONNREFUSED) || dials != 1 {
+		t.Errorf("expected a single dial, got %v after %d", err, dials)
+	}
+
+	dials = 0
+	c.ConnectAttempts = 3
+	c.ConnectBackoff = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.Connect(ctx); err != context.DeadlineExceeded || dials != 1 {
+		t.Errorf("expected the error of ctx in the backoff, got %v after %d", err, dials)
+	}
+}
+
+func TestWarmup(t *testing.T) {
+	dialErr := errors.New("no route")
+	var dials int
+	c := &Connector{
+		Addr:     "db.example.com",
+		Database: "employee",
+		Dial: func(ctx context.Context) (net.Conn, error) {
+			dials++
+			return nil, dialErr
+		},
+	}
+	db := sql.OpenDB(c)
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Warmup(ctx, db, 2); err != context.Canceled || dials != 0 {
+		t.Errorf("expected context.Canceled without a dial, got %v after %d dials", err, dials)
+	}
+	if err := Warmup(context.Background(), db, 2); !errors.Is(err, dialErr) {
+		t.Errorf("expected the error of Dial, got %v", err)
+	}
+	if err := Warmup(context.Background(), db, 0); err != nil {
+		t.Errorf("Warmup of no connection: %v", err)
+	}
+}
@@ -30,9 +30,15 @@ const (
 	ptype_batch_send  = 3 // Batch sends, no asynchrony
 	ptype_out_of_band = 4 // Batch sends w/ out of band notification
 	ptype_lazy_send   = 5 // Deferred packets delivery
+	ptype_MASK        = 0xFF
+	pflag_compress    = 0x100 // Set on top of the protocol type if wire compression is used
 
 	// Protocol Version
+	PROTOCOL_VERSION11 = 11 // Firebird 2.1
 	PROTOCOL_VERSION13 = 13
+	PROTOCOL_VERSION16 = 16
+	PROTOCOL_VERSION17 = 17
+	PROTOCOL_VERSION18 = 18
 
 	CNCT_user              = 1
 	CNCT_passwd            = 2
@@ -52,6 +58,8 @@ const (
 	isc_info_length         = 126
 	isc_info_flag_end       = 127
 
+	isc_info_blob_total_length = 6
+
 	isc_info_db_id                 = 4
 	isc_info_reads                 = 5
 	isc_info_writes                = 6
@@ -155,6 +163,25 @@ const (
 	ISOLATION_LEVEL_SERIALIZABLE            = 3
 	ISOLATION_LEVEL_READ_COMMITED_READ_ONLY = 4
 
+	// How scaled NUMERIC/DECIMAL values are returned
+	DECIMAL_MODE_RAT    = 0 // *big.Rat
+	DECIMAL_MODE_STRING = 1 // string with exactly -sqlscale fraction digits
+	DECIMAL_MODE_FLOAT  = 2 // float64, the nearest value
+
+	// How BLOB columns are returned
+	BLOB_MODE_CONTENT = 0 // []byte, or string for SUB_TYPE 1
+	BLOB_MODE_ID      = 1 // 8 bytes blob id
+	BLOB_MODE_STREAM  = 2 // *BlobReader
+
+	// How TIME columns are returned
+	TIME_MODE_TIME  = 0 // time.Time on 0000-01-01
+	TIME_MODE_CLOCK = 1 // Time
+
+	// What DATE and TIMESTAMP values out of 0001-01-01 .. 9999-12-31 are
+	TIMESTAMP_OVERFLOW_ERROR = 0 // an error
+	TIMESTAMP_OVERFLOW_CLAMP = 1 // time.Time of the nearest end
+	TIMESTAMP_OVERFLOW_RAW   = 2 // RawTimestamp
+
 	isc_tpb_version1         = 1
 	isc_tpb_version3         = 3
 	isc_tpb_consistency      = 1
@@ -179,6 +206,34 @@ const (
 	isc_tpb_no_auto_undo     = 20
 	isc_tpb_lock_timeout     = 21
 
+	// Blob Parameter Block parameter
+	isc_bpb_version1    = 1
+	isc_bpb_source_type = 1
+	isc_bpb_target_type = 2
+
+	// Database Parameter Block parameter
+	isc_dpb_version1              = 1
+	isc_dpb_page_size             = 4
+	isc_dpb_num_buffers           = 5
+	isc_dpb_dbkey_scope           = 13
+	isc_dpb_no_garbage_collect    = 16
+	isc_dpb_sweep_interval        = 22
+	isc_dpb_force_write           = 24
+	isc_dpb_user_name             = 28
+	isc_dpb_password              = 29
+	isc_dpb_lc_messages           = 47
+	isc_dpb_lc_ctype              = 48
+	isc_dpb_overwrite             = 54
+	isc_dpb_connect_timeout       = 57
+	isc_dpb_dummy_packet_interval = 58
+	isc_dpb_sql_role_name         = 60
+	isc_dpb_sql_dialect           = 63
+	isc_dpb_set_db_charset        = 68
+	isc_dpb_process_name          = 71
+	isc_dpb_process_id            = 72
+	isc_dpb_no_db_triggers        = 88
+	isc_dpb_session_time_zone     = 91
+
 	// Service Parameter Block parameter
 	isc_spb_version1              = 1
 	isc_spb_current_version       = 2
@@ -232,6 +287,45 @@ const (
 	isc_spb_trc_name = 2
 	isc_spb_trc_cfg  = 3
 
+	// users
+	isc_spb_sec_userid     = 5
+	isc_spb_sec_groupid    = 6
+	isc_spb_sec_username   = 7
+	isc_spb_sec_password   = 8
+	isc_spb_sec_groupname  = 9
+	isc_spb_sec_firstname  = 10
+	isc_spb_sec_middlename = 11
+	isc_spb_sec_lastname   = 12
+	isc_spb_sec_admin      = 13
+
+	// properties
+	isc_spb_prp_page_buffers          = 5
+	isc_spb_prp_sweep_interval        = 6
+	isc_spb_prp_shutdown_db           = 7
+	isc_spb_prp_deny_new_attachments  = 9
+	isc_spb_prp_deny_new_transactions = 10
+	isc_spb_prp_reserve_space         = 11
+	isc_spb_prp_write_mode            = 12
+	isc_spb_prp_access_mode           = 13
+	isc_spb_prp_set_sql_dialect       = 14
+	isc_spb_prp_activate              = 0x0100
+	isc_spb_prp_db_online             = 0x0200
+	isc_spb_prp_force_shutdown        = 41
+	isc_spb_prp_attachments_shutdown  = 42
+	isc_spb_prp_transactions_shutdown = 43
+	isc_spb_prp_shutdown_mode         = 44
+	isc_spb_prp_online_mode           = 45
+	isc_spb_prp_sm_normal             = 0
+	isc_spb_prp_sm_multi              = 1
+	isc_spb_prp_sm_single             = 2
+	isc_spb_prp_sm_full               = 3
+	isc_spb_prp_res_use_full          = 35
+	isc_spb_prp_res                   = 36
+	isc_spb_prp_wm_async              = 37
+	isc_spb_prp_wm_sync               = 38
+	isc_spb_prp_am_readonly           = 39
+	isc_spb_prp_am_readwrite          = 40
+
 	// isc_info_svc_svr_db_info params
 	isc_spb_num_att = 5
 	isc_spb_num_db  = 6
@@ -256,6 +350,32 @@ const (
 	isc_spb_rpr_kill_shadows     = 0x40
 	isc_spb_rpr_full             = 0x80
 
+	// limbo transactions
+	isc_spb_rpr_commit_trans         = 15
+	isc_spb_rpr_rollback_trans       = 34
+	isc_spb_rpr_recover_two_phase    = 17
+	isc_spb_tra_id                   = 18
+	isc_spb_single_tra_id            = 19
+	isc_spb_multi_tra_id             = 20
+	isc_spb_tra_state                = 21
+	isc_spb_tra_state_limbo          = 22
+	isc_spb_tra_state_commit         = 23
+	isc_spb_tra_state_rollback       = 24
+	isc_spb_tra_state_unknown        = 25
+	isc_spb_tra_host_site            = 26
+	isc_spb_tra_remote_site          = 27
+	isc_spb_tra_db_path              = 28
+	isc_spb_tra_advise               = 29
+	isc_spb_tra_advise_commit        = 30
+	isc_spb_tra_advise_rollback      = 31
+	isc_spb_tra_advise_unknown       = 33
+	isc_spb_tra_id_64                = 46
+	isc_spb_single_tra_id_64         = 47
+	isc_spb_multi_tra_id_64          = 48
+	isc_spb_rpr_commit_trans_64      = 49
+	isc_spb_rpr_rollback_trans_64    = 50
+	isc_spb_rpr_recover_two_phase_64 = 51
+
 	// Service Action Items
 	isc_action_svc_backup           = 1
 	isc_action_svc_restore          = 2
@@ -313,6 +433,7 @@ const (
 	isc_info_sql_get_plan      = 22
 	isc_info_sql_records       = 23
 	isc_info_sql_batch_fetch   = 24
+	isc_info_sql_explain_plan  = 26
 
 	isc_info_sql_stmt_select         = 1
 	isc_info_sql_stmt_insert         = 2
@@ -366,10 +487,12 @@ const (
 	op_close_blob         = 39
 	op_info_database      = 40
 	op_info_transaction   = 42
+	op_info_blob          = 43
 	op_batch_segments     = 44
 	op_que_events         = 48
 	op_cancel_events      = 49
 	op_commit_retaining   = 50
+	op_prepare2           = 51
 	op_event              = 52
 	op_connect_request    = 53
 	op_aux_connect        = 53
@@ -381,6 +504,7 @@ const (
 	op_fetch_response     = 66
 	op_free_statement     = 67
 	op_prepare_statement  = 68
+	op_set_cursor         = 69
 	op_info_sql           = 70
 	op_dummy              = 71
 	op_execute2           = 76
@@ -404,4 +528,79 @@ const (
 	op_crypt                = 96
 	op_crypt_key_callback   = 97
 	op_cond_accept          = 98
+	op_batch_create         = 99
+	op_batch_msg            = 100
+	op_batch_exec           = 101
+	op_batch_rls            = 102
+	op_batch_cs             = 103
+	// FB5
+	op_fetch_scroll = 112
+)
+
+const (
+	// op_fetch_scroll operations
+	fetch_next     = 0
+	fetch_prior    = 1
+	fetch_first    = 2
+	fetch_last     = 3
+	fetch_absolute = 4
+	fetch_relative = 5
+
+	// cursor flags of op_execute
+	CURSOR_TYPE_SCROLLABLE = 1
+)
+
+const (
+	fb_cancel_disable = 1
+	fb_cancel_enable  = 2
+	fb_cancel_raise   = 3
+	fb_cancel_abort   = 4
+)
+
+const (
+	WIRE_CRYPT_DISABLED = 0
+	WIRE_CRYPT_ENABLED  = 1
+	WIRE_CRYPT_REQUIRED = 2
+)
+
+const (
+	// op_accept_data/op_cond_accept keys
+	TAG_KEY_TYPE        = 0
+	TAG_KEY_PLUGINS     = 1
+	TAG_KNOWN_PLUGINS   = 2
+	TAG_PLUGIN_SPECIFIC = 3
+)
+
+const (
+	// batch parameters block (IBatch)
+	BATCH_VERSION1              = 1
+	BATCH_TAG_MULTIERROR        = 1
+	BATCH_TAG_RECORD_COUNTS     = 2
+	BATCH_TAG_BUFFER_BYTES_SIZE = 3
+	BATCH_TAG_BLOB_POLICY       = 4
+	BATCH_TAG_DETAILED_ERRORS   = 5
+)
+
+const (
+	op_get_slice = 58
+	op_put_slice = 59
+	op_slice     = 60
+
+	// Slice description language
+	isc_sdl_version1      = 1
+	isc_sdl_eoc           = 255
+	isc_sdl_relation      = 2
+	isc_sdl_rid           = 3
+	isc_sdl_field         = 4
+	isc_sdl_fid           = 5
+	isc_sdl_struct        = 6
+	isc_sdl_variable      = 7
+	isc_sdl_scalar        = 8
+	isc_sdl_tiny_integer  = 9
+	isc_sdl_short_integer = 10
+	isc_sdl_long_integer  = 11
+	isc_sdl_element       = 12
+	isc_sdl_do3           = 13
+	isc_sdl_do2           = 14
+	isc_sdl_do1           = 15
 )
@@ -0,0 +1,143 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"errors"
+)
+
+// DatabaseInfo is a snapshot of the runtime counters of the database
+// the connection is attached to.
+type DatabaseInfo struct {
+	PageSize           int // in bytes
+	Pages              int // allocated to the database
+	NumBuffers         int // pages of the page cache
+	CurrentMemory      int // of the server, in bytes
+	MaxMemory          int // of the server since the first attachment, in bytes
+	SweepInterval      int
+	ForcedWrites       bool
+	ReadOnly           bool
+	Dialect            int
+	AttachmentID       int
+	Attachments        int // the user attachments the user can see
+	OldestTransaction  int // the oldest interesting transaction
+	OldestActive       int
+	OldestSnapshot     int
+	NextTransaction    int
+	ActiveTransactions int // of Firebird 2.5 and later
+}
+
+// DatabaseInformer is implemented by the driver connection.
+// Get it with sql.Conn.Raw.
+type DatabaseInformer interface {
+	DatabaseInfo() (DatabaseInfo, error)
+}
+
+// databaseInfoItems are the isc_info_* items of DatabaseInfo.
+var databaseInfoItems = []byte{
+	isc_info_page_size,
+	isc_info_allocation,
+	isc_info_num_buffers,
+	isc_info_current_memory,
+	isc_info_max_memory,
+	isc_info_sweep_interval,
+	isc_info_forced_writes,
+	isc_info_db_read_only,
+	isc_info_db_sql_dialect,
+	isc_info_attachment_id,
+	isc_info_oldest_transaction,
+	isc_info_oldest_active,
+	isc_info_oldest_snapshot,
+	isc_info_next_transaction,
+	isc_info_active_tran_count,
+	isc_info_user_names, // one item per attachment
+	isc_info_end,
+}
+
+func parseDatabaseInfo(buf []byte) (info DatabaseInfo, err error) {
+	for i := 0; i < len(buf) && buf[i] != isc_info_end; {
+		item := buf[i]
+		if item == isc_info_truncated {
+			return info, errors.New("firebirdsql: truncated database info")
+		}
+		if i+3 > len(buf) {
+			break
+		}
+		ln := int(bytes_to_int16(buf[i+1 : i+3]))
+		i += 3
+		if i+ln > len(buf) {
+			break
+		}
+		data := buf[i : i+ln]
+		i += ln
+		switch item {
+		case isc_info_page_size:
+			info.PageSize = infoInt(data)
+		case isc_info_allocation:
+			info.Pages = infoInt(data)
+		case isc_info_num_buffers:
+			info.NumBuffers = infoInt(data)
+		case isc_info_current_memory:
+			info.CurrentMemory = infoInt(data)
+		case isc_info_max_memory:
+			info.MaxMemory = infoInt(data)
+		case isc_info_sweep_interval:
+			info.SweepInterval = infoInt(data)
+		case isc_info_forced_writes:
+			info.ForcedWrites = infoInt(data) != 0
+		case isc_info_db_read_only:
+			info.ReadOnly = infoInt(data) != 0
+		case isc_info_db_sql_dialect:
+			info.Dialect = infoInt(data)
+		case isc_info_attachment_id:
+			info.AttachmentID = infoInt(data)
+		case isc_info_oldest_transaction:
+			info.OldestTransaction = infoInt(data)
+		case isc_info_oldest_active:
+			info.OldestActive = infoInt(data)
+		case isc_info_oldest_snapshot:
+			info.OldestSnapshot = infoInt(data)
+		case isc_info_next_transaction:
+			info.NextTransaction = infoInt(data)
+		case isc_info_active_tran_count:
+			info.ActiveTransactions = infoInt(data)
+		case isc_info_user_names:
+			info.Attachments++
+		}
+	}
+	if info.PageSize == 0 {
+		err = errors.New("firebirdsql: no page size in database info")
+	}
+	return
+}
+
+// DatabaseInfo requests the runtime counters of the database, e.g. for
+// monitoring. Each call is a round trip to the server.
+func (fc *firebirdsqlConn) DatabaseInfo() (DatabaseInfo, error) {
+	buf, err := fc.wp.infoDatabase(databaseInfoItems)
+	if err != nil {
+		return DatabaseInfo{}, err
+	}
+	return parseDatabaseInfo(buf)
+}
@@ -0,0 +1,70 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"testing"
+)
+
+func TestParseDatabaseInfo(t *testing.T) {
+	buf := []byte{
+		isc_info_page_size, 4, 0, 0, 0x20, 0, 0,
+		isc_info_allocation, 4, 0, 0xd0, 0x07, 0, 0,
+		isc_info_num_buffers, 4, 0, 0, 0x08, 0, 0,
+		isc_info_current_memory, 4, 0, 0, 0, 0x10, 0,
+		isc_info_forced_writes, 4, 0, 1, 0, 0, 0,
+		isc_info_db_read_only, 4, 0, 0, 0, 0, 0,
+		isc_info_db_sql_dialect, 1, 0, 3,
+		isc_info_oldest_transaction, 4, 0, 0x64, 0, 0, 0,
+		isc_info_next_transaction, 8, 0, 0xc8, 0, 0, 0, 0, 0, 0, 0,
+		isc_info_user_names, 7, 0, 6, 'S', 'Y', 'S', 'D', 'B', 'A',
+		isc_info_user_names, 4, 0, 3, 'B', 'O', 'B',
+		isc_info_end,
+	}
+	info, err := parseDatabaseInfo(buf)
+	if err != nil {
+		t.Fatalf("parseDatabaseInfo: %v", err)
+	}
+	expected := DatabaseInfo{
+		PageSize:          8192,
+		Pages:             2000,
+		NumBuffers:        2048,
+		CurrentMemory:     1 << 20,
+		ForcedWrites:      true,
+		Dialect:           3,
+		Attachments:       2,
+		OldestTransaction: 100,
+		NextTransaction:   200,
+	}
+	if info != expected {
+		t.Errorf("expected %+v, got %+v", expected, info)
+	}
+
+	if _, err = parseDatabaseInfo([]byte{isc_info_page_size, 4, 0, 0, 0x20, 0, 0, isc_info_truncated}); err == nil {
+		t.Errorf("expected an error for a truncated response")
+	}
+	if _, err = parseDatabaseInfo([]byte{isc_info_end}); err == nil {
+		t.Errorf("expected an error without the page size")
+	}
+}
@@ -0,0 +1,165 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Firebird sends DECFLOAT(16) and DECFLOAT(34) as IEEE 754-2008
+// decimal64 / decimal128 in densely packed decimal (DPD) encoding.
+
+func dpdToInt(declet int) int {
+	// Decode one 10 bit declet into 3 decimal digits.
+	b := func(n uint) int { return (declet >> n) & 1 }
+	var d2, d1, d0 int
+	if b(3) == 0 {
+		d2 = declet >> 7 & 7
+		d1 = declet >> 4 & 7
+		d0 = declet & 7
+	} else {
+		switch declet >> 1 & 3 {
+		case 0:
+			d2 = declet >> 7 & 7
+			d1 = declet >> 4 & 7
+			d0 = 8 + b(0)
+		case 1:
+			d2 = declet >> 7 & 7
+			d1 = 8 + b(4)
+			d0 = b(6)<<2 | b(5)<<1 | b(0)
+		case 2:
+			d2 = 8 + b(7)
+			d1 = declet >> 4 & 7
+			d0 = b(9)<<2 | b(8)<<1 | b(0)
+		case 3:
+			switch declet >> 5 & 3 {
+			case 0:
+				d2 = 8 + b(7)
+				d1 = 8 + b(4)
+				d0 = b(9)<<2 | b(8)<<1 | b(0)
+			case 1:
+				d2 = 8 + b(7)
+				d1 = b(9)<<2 | b(8)<<1 | b(4)
+				d0 = 8 + b(0)
+			case 2:
+				d2 = declet >> 7 & 7
+				d1 = 8 + b(4)
+				d0 = 8 + b(0)
+			case 3:
+				d2 = 8 + b(7)
+				d1 = 8 + b(4)
+				d0 = 8 + b(0)
+			}
+		}
+	}
+	return d2*100 + d1*10 + d0
+}
+
+func decimalToString(negative bool, coefficient *big.Int, exponent int) string {
+	// Same as the decNumber to-scientific-string conversion.
+	digits := coefficient.String()
+	adjusted := exponent + len(digits) - 1
+	var s string
+	if exponent <= 0 && adjusted >= -6 {
+		if exponent == 0 {
+			s = digits
+		} else if len(digits) > -exponent {
+			s = digits[:len(digits)+exponent] + "." + digits[len(digits)+exponent:]
+		} else {
+			s = "0." + strings.Repeat("0", -exponent-len(digits)) + digits
+		}
+	} else {
+		s = digits[:1]
+		if len(digits) > 1 {
+			s += "." + digits[1:]
+		}
+		s += "E"
+		if adjusted >= 0 {
+			s += "+"
+		}
+		s += strconv.Itoa(adjusted)
+	}
+	if negative {
+		s = "-" + s
+	}
+	return s
+}
+
+func decimalFixedToString(raw_value []byte, expContinuationBits uint, bias int) string {
+	n := new(big.Int).SetBytes(raw_value)
+	totalBits := uint(len(raw_value) * 8)
+	bits := func(pos uint, length uint) int {
+		// pos counts from the most significant bit
+		v := new(big.Int).Rsh(n, totalBits-pos-length)
+		return int(v.Int64() & (1<<length - 1))
+	}
+
+	negative := bits(0, 1) == 1
+	combination := bits(1, 5)
+	if combination>>1 == 15 {
+		if combination&1 == 0 {
+			if negative {
+				return "-Infinity"
+			}
+			return "Infinity"
+		}
+		s := "NaN"
+		if bits(6, 1) == 1 {
+			s = "sNaN"
+		}
+		if negative {
+			s = "-" + s
+		}
+		return s
+	}
+
+	var expMsb, leadingDigit int
+	if combination>>3 == 3 {
+		expMsb = combination >> 1 & 3
+		leadingDigit = 8 + combination&1
+	} else {
+		expMsb = combination >> 3
+		leadingDigit = combination & 7
+	}
+	exponent := expMsb<<expContinuationBits | bits(6, expContinuationBits)
+
+	coefficient := big.NewInt(int64(leadingDigit))
+	thousand := big.NewInt(1000)
+	for pos := 6 + expContinuationBits; pos < totalBits; pos += 10 {
+		coefficient.Mul(coefficient, thousand)
+		coefficient.Add(coefficient, big.NewInt(int64(dpdToInt(bits(pos, 10)))))
+	}
+
+	return decimalToString(negative, coefficient, exponent-bias)
+}
+
+func decimal64ToString(raw_value []byte) string {
+	return decimalFixedToString(raw_value, 8, 398)
+}
+
+func decimal128ToString(raw_value []byte) string {
+	return decimalFixedToString(raw_value, 12, 6176)
+}
@@ -0,0 +1,81 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestDecFloat(t *testing.T) {
+	var tests = []struct {
+		sqltype  int
+		raw      string
+		expected string
+	}{
+		{SQL_TYPE_DEC16, "2238000000000001", "1"},
+		{SQL_TYPE_DEC16, "22300000000000a3", "1.23"},
+		{SQL_TYPE_DEC16, "a2300000000000a3", "-1.23"},
+		{SQL_TYPE_DEC16, "2238000000000000", "0"},
+		{SQL_TYPE_DEC16, "263934b9c1e28e56", "1234567890123456"},
+		{SQL_TYPE_DEC16, "7800000000000000", "Infinity"},
+		{SQL_TYPE_DEC16, "f800000000000000", "-Infinity"},
+		{SQL_TYPE_DEC16, "7c00000000000000", "NaN"},
+		{SQL_TYPE_DEC16, "7e00000000000000", "sNaN"},
+		{SQL_TYPE_DEC34, "22080000000000000000000000000001", "1"},
+		{SQL_TYPE_DEC34, "220780000000000000000000000000a3", "1.23"},
+		{SQL_TYPE_DEC34, "78000000000000000000000000000000", "Infinity"},
+		{SQL_TYPE_DEC34, "7c000000000000000000000000000000", "NaN"},
+	}
+
+	for _, tt := range tests {
+		raw, _ := hex.DecodeString(tt.raw)
+		x := &xSQLVAR{sqltype: tt.sqltype}
+		if len(raw) != x.ioLength() {
+			t.Fatalf("bad ioLength %d for %s", x.ioLength(), tt.raw)
+		}
+		v, err := x.value(raw, &firebirdDsn{})
+		if err != nil {
+			t.Fatalf("value(): %v", err)
+		}
+		if v != tt.expected {
+			t.Errorf("%s: expected <%v>, got <%v>", tt.raw, tt.expected, v)
+		}
+	}
+}
+
+func TestDpdDeclet(t *testing.T) {
+	var tests = []struct {
+		declet   int
+		expected int
+	}{
+		{0x000, 0}, {0x009, 9}, {0x079, 79}, {0x0a3, 123},
+		{0x0ff, 999}, {0x06e, 888}, {0x3ff, 999}, {0x18a, 380},
+	}
+	for _, tt := range tests {
+		if v := dpdToInt(tt.declet); v != tt.expected {
+			t.Errorf("dpd %03x: expected %d, got %d", tt.declet, tt.expected, v)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// Decimal is an exact decimal parameter, e.g. Decimal("123.45").
+// When it is bound to a NUMERIC/DECIMAL column it is scaled to the
+// column scale on the client side (rounding half away from zero).
+type Decimal string
+
+func (d Decimal) rat() (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(string(d))
+	if !ok {
+		return nil, errors.New("firebirdsql: invalid decimal value " + string(d))
+	}
+	return r, nil
+}
+
+// Value implements driver.Valuer.
+func (d Decimal) Value() (driver.Value, error) {
+	if _, err := d.rat(); err != nil {
+		return nil, err
+	}
+	return string(d), nil
+}
+
+// NullDecimal is a nullable NUMERIC/DECIMAL value, which scans the
+// *big.Rat, string or float64 of any decimal_mode.
+type NullDecimal struct {
+	Decimal Decimal
+	Valid   bool // Valid is true if Decimal is not NULL
+}
+
+// canonicalDecimal returns the shortest exact decimal string of r,
+// e.g. "-1.2" for 1.200.
+func canonicalDecimal(r *big.Rat) (string, error) {
+	d := new(big.Int).Set(r.Denom())
+	digits := 0
+	for _, f := range []int64{2, 5} {
+		n := 0
+		m := new(big.Int)
+		for {
+			q, rem := new(big.Int).QuoRem(d, big.NewInt(f), m)
+			if rem.Sign() != 0 {
+				break
+			}
+			d = q
+			n++
+		}
+		if n > digits {
+			digits = n
+		}
+	}
+	if d.Cmp(big.NewInt(1)) != 0 {
+		return "", errors.New("firebirdsql: " + r.String() + " is not a finite decimal")
+	}
+	return r.FloatString(digits), nil
+}
+
+// Scan implements sql.Scanner.
+func (n *NullDecimal) Scan(src interface{}) (err error) {
+	var r *big.Rat
+	switch v := src.(type) {
+	case nil:
+		n.Decimal, n.Valid = "", false
+		return nil
+	case *big.Rat:
+		r = v
+	case string:
+		r, err = Decimal(v).rat()
+	case []byte:
+		r, err = Decimal(v).rat()
+	case Decimal:
+		r, err = v.rat()
+	case float64:
+		r, err = Decimal(strconv.FormatFloat(v, 'f', -1, 64)).rat()
+	case int64:
+		r = new(big.Rat).SetInt64(v)
+	case int32:
+		r = new(big.Rat).SetInt64(int64(v))
+	case int16:
+		r = new(big.Rat).SetInt64(int64(v))
+	default:
+		return fmt.Errorf("firebirdsql: can't scan %T into NullDecimal", src)
+	}
+	if err != nil {
+		return err
+	}
+	s, err := canonicalDecimal(r)
+	if err != nil {
+		return err
+	}
+	n.Decimal, n.Valid = Decimal(s), true
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (n NullDecimal) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.Decimal.Value()
+}
@@ -0,0 +1,169 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"database/sql/driver"
+	"math/big"
+	"testing"
+)
+
+func TestRatToBlr(t *testing.T) {
+	var tests = []struct {
+		value    Decimal
+		scale    int
+		expected int64
+	}{
+		{"123.45", -2, 12345},
+		{"-123.45", -2, -12345},
+		{"0.05", -2, 5},
+		{"1.005", -2, 101},
+		{"-1.005", -2, -101},
+		{"1.004", -2, 100},
+		{"7", -3, 7000},
+		{"1e2", -1, 1000},
+	}
+	for _, tt := range tests {
+		r, err := tt.value.rat()
+		if err != nil {
+			t.Fatalf("rat(): %v", err)
+		}
+		blr, v, err := _ratToBlr(r, tt.scale)
+		if err != nil {
+			t.Fatalf("_ratToBlr(): %v", err)
+		}
+		if blr[0] != 16 || int(int8(blr[1])) != tt.scale {
+			t.Errorf("%s: bad blr %v", tt.value, blr)
+		}
+		if bytes_to_bint64(v) != tt.expected {
+			t.Errorf("%s: expected %d, got %d", tt.value, tt.expected, bytes_to_bint64(v))
+		}
+	}
+
+	if _, err := Decimal("12,3").Value(); err == nil {
+		t.Errorf("invalid decimal was accepted")
+	}
+	r, _ := Decimal("100000000000000000000").rat()
+	if _, _, err := _ratToBlr(r, -2); err == nil {
+		t.Errorf("out of range decimal was accepted")
+	}
+}
+
+func TestNullDecimalScan(t *testing.T) {
+	var tests = []struct {
+		src      interface{}
+		expected NullDecimal
+	}{
+		{nil, NullDecimal{}},
+		{big.NewRat(12345, 100), NullDecimal{"123.45", true}},
+		{big.NewRat(-6, 5), NullDecimal{"-1.2", true}},
+		{"1.200", NullDecimal{"1.2", true}},
+		{[]byte("-0.050"), NullDecimal{"-0.05", true}},
+		{123.25, NullDecimal{"123.25", true}},
+		{int64(42), NullDecimal{"42", true}},
+	}
+	for _, tt := range tests {
+		n := NullDecimal{"stale", true}
+		if err := n.Scan(tt.src); err != nil || n != tt.expected {
+			t.Errorf("%v: expected %v, got %v %v", tt.src, tt.expected, n, err)
+		}
+	}
+	for _, src := range []interface{}{big.NewRat(1, 3), "abc", true} {
+		var n NullDecimal
+		if err := n.Scan(src); err == nil {
+			t.Errorf("%v was accepted", src)
+		}
+	}
+}
+
+func TestNullDecimalValue(t *testing.T) {
+	if v, err := (NullDecimal{}).Value(); v != nil || err != nil {
+		t.Errorf("NULL: %v %v", v, err)
+	}
+	if v, err := (NullDecimal{"12.5", true}).Value(); v != "12.5" || err != nil {
+		t.Errorf("12.5: %v %v", v, err)
+	}
+
+	fc := &firebirdsqlConn{}
+	nv := &driver.NamedValue{Value: NullDecimal{"12.5", true}}
+	if err := fc.CheckNamedValue(nv); err != nil || nv.Value != Decimal("12.5") {
+		t.Errorf("CheckNamedValue: %v %v", nv.Value, err)
+	}
+	nv = &driver.NamedValue{Value: NullDecimal{}}
+	if err := fc.CheckNamedValue(nv); err != nil || nv.Value != nil {
+		t.Errorf("CheckNamedValue NULL: %v %v", nv.Value, err)
+	}
+}
+
+func TestBigRatToBlr(t *testing.T) {
+	numeric18 := &xSQLVAR{sqltype: SQL_TYPE_INT64, sqlsubtype: 1, sqlscale: -2}
+	p := &wireProtocol{dsn: &firebirdDsn{dialect: 3}, protocolVersion: PROTOCOL_VERSION13}
+	blr, v, err := p.paramsToBlr(0, []driver.Value{big.NewRat(12345, 100)}, []xSQLVAR{*numeric18}, p.protocolVersion)
+	if err != nil || blr[6] != 16 || blr[7] != 0xfe || string(v[4:]) != string(bint64_to_bytes(12345)) {
+		t.Errorf("NUMERIC(18,2): %v %v %v", blr, v, err)
+	}
+
+	var tests = []struct {
+		value    *big.Rat
+		x        *xSQLVAR
+		expected int64
+	}{
+		{big.NewRat(1, 3), numeric18, 33},
+		{big.NewRat(-1, 200), numeric18, -1},
+		{new(big.Rat).SetInt64(-7), &xSQLVAR{sqltype: SQL_TYPE_LONG}, -7},
+		{big.NewRat(9999, 100), &xSQLVAR{sqltype: SQL_TYPE_SHORT, sqlsubtype: 1, sqlscale: -2}, 9999},
+	}
+	for _, tt := range tests {
+		_, v, err := _bigRatToBlr(tt.value, tt.x)
+		if err != nil || string(v) != string(bint64_to_bytes(tt.expected)) {
+			t.Errorf("%v: expected %d, got %v %v", tt.value, tt.expected, v, err)
+		}
+	}
+
+	// the precision of the column, or the bits of an integer
+	if _, _, err = _bigRatToBlr(big.NewRat(12345, 100), &xSQLVAR{sqltype: SQL_TYPE_SHORT, sqlsubtype: 1, sqlscale: -2}); err == nil {
+		t.Errorf("123.45 fits NUMERIC(4,2)")
+	}
+	if _, _, err = _bigRatToBlr(new(big.Rat).SetInt64(1<<40), &xSQLVAR{sqltype: SQL_TYPE_LONG}); err == nil {
+		t.Errorf("2^40 fits INTEGER")
+	}
+	big20, _ := new(big.Rat).SetString("123456789012345678901234.5")
+	blr, v, err = _bigRatToBlr(big20, &xSQLVAR{sqltype: SQL_TYPE_INT128, sqlsubtype: 1, sqlscale: -2})
+	if err != nil || blr[0] != 14 || string(v[:blr[1]]) != "123456789012345678901234.50" {
+		t.Errorf("NUMERIC(38,2): %v %q %v", blr, v, err)
+	}
+	numeric38 := xSQLVAR{sqltype: SQL_TYPE_INT128, sqlsubtype: 1, sqlscale: -2}
+	blr, v, err = p.paramsToBlr(0, []driver.Value{Decimal("123456789012345678901234.5")}, []xSQLVAR{numeric38}, p.protocolVersion)
+	if err != nil || blr[6] != 14 || string(v[4:4+27]) != "123456789012345678901234.50" {
+		t.Errorf("Decimal to NUMERIC(38,2): %v %q %v", blr, v, err)
+	}
+
+	// without a numeric column
+	if s, err := ratDecimalString(big.NewRat(-1, 8)); err != nil || s != "-0.125" {
+		t.Errorf("-1/8: %s %v", s, err)
+	}
+	if _, err = ratDecimalString(big.NewRat(1, 3)); err == nil {
+		t.Errorf("1/3 has an exact decimal value")
+	}
+}
@@ -34,12 +34,29 @@ func (d *firebirdsqlDriver) Open(dsn string) (driver.Conn, error) {
 	return newFirebirdsqlConn(dsn)
 }
 
+func (d *firebirdsqlDriver) OpenConnector(dsn string) (driver.Connector, error) {
+	c, err := NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
 type firebirdsqlCreateDbDriver struct{}
 
 func (d *firebirdsqlCreateDbDriver) Open(dsn string) (driver.Conn, error) {
 	return createFirebirdsqlConn(dsn)
 }
 
+func (d *firebirdsqlCreateDbDriver) OpenConnector(dsn string) (driver.Connector, error) {
+	c, err := NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	c.CreateDatabase = true
+	return c, nil
+}
+
 func init() {
 	sql.Register("firebirdsql", &firebirdsqlDriver{})
 	sql.Register("firebirdsql_createdb", &firebirdsqlCreateDbDriver{})
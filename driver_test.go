@@ -24,8 +24,18 @@ CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
 package firebirdsql
 
 import (
+	"bytes"
+	"container/list"
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -145,226 +155,2378 @@ func TestReturning(t *testing.T) {
 
 }
 
-func TestInsertBlobsWithParams(t *testing.T) {
-	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_insert_blobs_with_params.fdb")
-	conn.Exec("CREATE TABLE test_blobs (f1 BLOB SUB_TYPE 0, f2 BLOB SUB_TYPE 1)")
+func TestExecReturning(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "SYSDBA:masterkey@localhost:3050/tmp/go_test_exec_returning.fdb")
 	defer conn.Close()
+	conn.Exec("CREATE SEQUENCE seq_exec_returning")
+	conn.Exec(`
+        CREATE TABLE test_exec_returning (
+            id integer NOT NULL,
+            s varchar(20) default 'abc')`)
 
-	s0 := "Test Text"
-	b0 := []byte{0, 1, 2, 3, 4}
-	if _, err := conn.Exec("INSERT INTO test_blobs (f1, f2) values (?, ?)", s0, b0); err != nil {
-		t.Fatalf("Error inserting blobs with params: %v", err)
+	for i := 1; i <= 2; i++ {
+		result, err := conn.Exec("INSERT INTO test_exec_returning (id) values (next value for seq_exec_returning) returning id")
+		if err != nil {
+			t.Fatalf("Error Insert returning : %v", err)
+		}
+		id, _ := result.LastInsertId()
+		if id != int64(i) {
+			t.Errorf("LastInsertId: %d != %d", id, i)
+		}
 	}
 
+	var id int
 	var s string
-	var b []byte
-	err := conn.QueryRow("SELECT f1, f2 from test_blobs").Scan(&s, &b)
+	_, err := conn.Exec("INSERT INTO test_exec_returning (id) values (?) returning id, s", 10, sql.Out{Dest: &id}, sql.Out{Dest: &s})
 	if err != nil {
-		t.Fatalf("Error in query: %v", err)
+		t.Fatalf("Error Insert returning : %v", err)
 	}
-	if s != s0 {
-		t.Fatalf("Text blob: expected <%s>, got <%s>", s0, s)
+	if id != 10 || s != "abc" {
+		t.Errorf("sql.Out: %v %v", id, s)
 	}
-	if !reflect.DeepEqual(b, b0) {
-		t.Fatalf("Binary blob: expected <%v>, got <%v> (%s)", b0, b, string(b))
+
+	result, _ := conn.Exec("INSERT INTO test_exec_returning (id) values (20)")
+	if id, _ := result.LastInsertId(); id != -1 {
+		t.Errorf("LastInsertId without RETURNING: %d", id)
 	}
 }
 
-func TestError(t *testing.T) {
-	conn, err := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_error.fdb")
+func TestArrayParams(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "SYSDBA:masterkey@localhost:3050/tmp/go_test_array_params.fdb")
+	defer conn.Close()
+	conn.Exec(`
+        CREATE TABLE test_array_params (
+            id integer NOT NULL,
+            i integer[0:2],
+            n numeric(9,2)[2, -1:0],
+            s varchar(10)[-1:1])`)
+
+	_, err := conn.Exec("INSERT INTO test_array_params (id, i, n, s) values (1, ?, ?, ?)",
+		[]int{1, 2, 3},
+		[][]interface{}{{"1.25", -2}, {Decimal("3.5"), 4.75}},
+		[]string{"a", "bc", ""})
 	if err != nil {
-		t.Fatalf("Error connecting: %v", err)
+		t.Fatalf("Error Insert array: %v", err)
 	}
-	_, err = conn.Exec("incorrect sql statement")
-	if err == nil || err.Error() != "Dynamic SQL Error\nSQL error code = -104\nToken unknown - line 1, column 1\nincorrect\n" {
-		t.Fatalf("Incorrect error")
+	_, err = conn.Exec("UPDATE test_array_params SET i = ? WHERE id = 1", []int32{4, 5, 6})
+	if err != nil {
+		t.Fatalf("Error Update array: %v", err)
 	}
-}
 
-func TestRole(t *testing.T) {
-	conn1, err := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_role.fdb")
+	var i, n, s interface{}
+	err = conn.QueryRow("SELECT i, n, s FROM test_array_params WHERE id = 1").Scan(&i, &n, &s)
 	if err != nil {
-		t.Fatalf("Error creating: %v", err)
+		t.Fatalf("Error Select array: %v", err)
 	}
-	conn1.Exec("CREATE TABLE test_role (f1 integer)")
-	conn1.Exec("INSERT INTO test_role (f1) values (1)")
-	if err != nil {
-		t.Fatalf("Error connecting: %v", err)
+	if !reflect.DeepEqual(i, []interface{}{int32(4), int32(5), int32(6)}) {
+		t.Errorf("integer[0:2]: %v", i)
 	}
-	conn1.Exec("CREATE ROLE DRIVERROLE")
-	if err != nil {
-		t.Fatalf("Error creating role: %v", err)
+	if fmt.Sprint(n) != "[[1.25 -2] [3.5 4.75]]" {
+		t.Errorf("numeric(9,2)[2, -1:0]: %v", n)
 	}
-	conn1.Exec("GRANT DRIVERROLE TO SYSDBA")
-	if err != nil {
-		t.Fatalf("Error creating role: %v", err)
+	if !reflect.DeepEqual(s, []interface{}{"a", "bc", ""}) {
+		t.Errorf("varchar(10)[-1:1]: %v", s)
 	}
-	conn1.Exec("GRANT SELECT ON test_role TO DRIVERROLE")
-	if err != nil {
-		t.Fatalf("Error granting right to role: %v", err)
+
+	_, err = conn.Exec("INSERT INTO test_array_params (id, i) values (2, ?)", []int{1, 2})
+	if err == nil {
+		t.Error("Expected error for the short array")
 	}
-	conn1.Close()
+	_, err = conn.Exec("INSERT INTO test_array_params (id, s) values (3, ?)", []string{"a", "b", "too long value"})
+	if err == nil {
+		t.Error("Expected error for the long element")
+	}
+	_, err = conn.Exec("INSERT INTO test_array_params (id) values (?)", []int{1})
+	if err == nil {
+		t.Error("Expected error for the non array column")
+	}
+}
 
-	conn2, err := sql.Open("firebirdsql", "sysdba:masterkey@localhost:3050/tmp/go_test_role.fdb?role=driverrole")
-	if err != nil {
-		t.Fatalf("Error connecting: %v", err)
+func TestStmtCache(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "SYSDBA:masterkey@localhost:3050/tmp/go_test_stmt_cache.fdb?stmt_cache_size=2")
+	defer conn.Close()
+	conn.SetMaxOpenConns(1)
+	conn.Exec("CREATE TABLE test_stmt_cache (i integer)")
+
+	var cached map[string]*list.Element
+	handles := func(queries ...string) (r []int32) {
+		c, _ := conn.Conn(context.Background())
+		defer c.Close()
+		c.Raw(func(driverConn interface{}) error {
+			fc := driverConn.(*firebirdsqlConn)
+			for _, q := range queries {
+				stmt, err := fc.prepareCached(q)
+				if err != nil {
+					t.Fatalf("Error prepare %s: %v", q, err)
+				}
+				r = append(r, stmt.stmtHandle)
+				stmt.Close()
+			}
+			cached = fc.stmtCache.entries
+			return nil
+		})
+		return
 	}
 
-	rows, err := conn2.Query("SELECT f1 FROM test_role")
-	defer conn2.Close()
+	for i := 0; i < 3; i++ {
+		if _, err := conn.Exec("INSERT INTO test_stmt_cache (i) values (?)", i); err != nil {
+			t.Fatalf("Error Insert: %v", err)
+		}
+	}
+	var n int
+	for i := 0; i < 2; i++ {
+		if err := conn.QueryRow("SELECT count(*) FROM test_stmt_cache").Scan(&n); err != nil {
+			t.Fatalf("Error Select: %v", err)
+		}
+		if n != 3 {
+			t.Errorf("count: %d", n)
+		}
+	}
+
+	h := handles("SELECT i FROM test_stmt_cache", "SELECT i FROM test_stmt_cache")
+	if h[0] != h[1] {
+		t.Errorf("statement is not reused: %v", h)
+	}
+	handles("SELECT 1 FROM rdb$database", "SELECT 2 FROM rdb$database")
+	if _, ok := cached["SELECT i FROM test_stmt_cache"]; ok || len(cached) != 2 {
+		t.Errorf("statement is not evicted: %v", cached)
+	}
+
+	rows, err := conn.Query("SELECT i FROM test_stmt_cache ORDER BY i")
 	if err != nil {
 		t.Fatalf("Error Query: %v", err)
 	}
-
+	rows.Next()
+	rows.Close()
+	rows, _ = conn.Query("SELECT i FROM test_stmt_cache ORDER BY i")
+	n = 0
 	for rows.Next() {
+		n++
+	}
+	rows.Close()
+	if n != 3 {
+		t.Errorf("reused statement fetched %d rows", n)
 	}
 }
 
-func TestInsertTimestamp(t *testing.T) {
-	const (
-		sqlSchema = "CREATE TABLE TEST (VAL1 TIMESTAMP, VAL2 TIMESTAMP, VAL3 TIMESTAMP, VAL4 TIMESTAMP);"
-		sqlInsert = "INSERT INTO TEST (VAL1, VAL2, VAL3, VAL4) VALUES (?, ?, ?, '2015/2/9 19:25:50.7405');"
-		sqlSelect = "SELECT * FROM TEST;"
-	)
+func TestExecBatch(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "SYSDBA:masterkey@localhost:3050/tmp/go_test_exec_batch.fdb")
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_exec_batch (id integer NOT NULL PRIMARY KEY, s varchar(20), n numeric(9,2))")
 
-	conn, err := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_timestamp.fdb")
-	if err != nil {
-		t.Fatalf("Error creating: %v", err)
+	execBatch := func(query string, rows [][]driver.Value) (counts []int64, err error) {
+		c, _ := conn.Conn(context.Background())
+		defer c.Close()
+		c.Raw(func(driverConn interface{}) error {
+			counts, err = driverConn.(Batcher).ExecBatch(query, rows)
+			return nil
+		})
+		return
 	}
-	defer conn.Close()
 
-	_, err = conn.Exec(sqlSchema)
+	rows := make([][]driver.Value, 1000)
+	for i := range rows {
+		rows[i] = []driver.Value{i, "row", Decimal("1.25")}
+	}
+	rows[1][1] = nil
+	counts, err := execBatch("INSERT INTO test_exec_batch (id, s, n) values (?, ?, ?)", rows)
 	if err != nil {
-		t.Fatalf("Error creating schema: %v", err)
+		t.Fatalf("Error ExecBatch: %v", err)
+	}
+	if len(counts) != len(rows) || counts[0] != 1 || counts[999] != 1 {
+		t.Errorf("counts: %d %v", len(counts), counts[:2])
 	}
 
-	dt1 := time.Date(2015, 2, 9, 19, 25, 50, 740500000, time.UTC)
-	dt2 := "2015/2/9 19:25:50.7405"
-	dt3 := "2015-2-9 19:25:50.7405"
-
-	if _, err = conn.Exec(sqlInsert, dt1, dt2, dt3); err != nil {
-		t.Fatalf("Error executing insert: %s", err)
+	// mixed types in a column are sent one by one
+	counts, err = execBatch("INSERT INTO test_exec_batch (id, s) values (?, ?)", [][]driver.Value{{1000, "a"}, {"1001", 1}})
+	if err != nil {
+		t.Fatalf("Error ExecBatch: %v", err)
+	}
+	if !reflect.DeepEqual(counts, []int64{1, 1}) {
+		t.Errorf("counts: %v", counts)
 	}
 
-	var rt1, rt2, rt3, rt4 time.Time
+	var n int
+	var sum float64
+	conn.QueryRow("SELECT count(*), sum(n) FROM test_exec_batch").Scan(&n, &sum)
+	if n != 1002 || sum != 1250 {
+		t.Errorf("count %d, sum %v", n, sum)
+	}
 
-	err = conn.QueryRow(sqlSelect).Scan(&rt1, &rt2, &rt3, &rt4)
-	if err != nil {
-		t.Fatalf("Unexpected error in select: %s", err)
+	counts, err = execBatch("UPDATE test_exec_batch SET s = ? WHERE id < ?", [][]driver.Value{{"x", 10}, {"y", 0}})
+	if err != nil || !reflect.DeepEqual(counts, []int64{10, 0}) {
+		t.Errorf("update counts: %v %v", counts, err)
 	}
 
-	if rt1 != dt1 {
-		t.Errorf("Expected <%v>, got <%v>", dt1, rt1)
+	counts, err = execBatch("INSERT INTO test_exec_batch (id) values (?)", [][]driver.Value{{2000}, {2001}, {1}, {2002}})
+	if err == nil || !strings.Contains(err.Error(), "rows[2]") {
+		t.Errorf("Expected error for rows[2]: %v", err)
 	}
-	if rt2 != dt1 {
-		t.Errorf("Expected <%v>, got <%v>", dt1, rt2)
+	if len(counts) != 2 {
+		t.Errorf("counts before the error: %v", counts)
 	}
-	if rt3 != dt1 {
-		t.Errorf("Expected <%v>, got <%v>", dt1, rt3)
+	conn.QueryRow("SELECT count(*) FROM test_exec_batch WHERE id >= 2000").Scan(&n)
+	if n != 0 {
+		t.Errorf("failed batch is not rolled back: %d", n)
 	}
-	if rt4 != dt1 {
-		t.Errorf("Expected <%v>, got <%v>", dt1, rt4)
+
+	// the executions sent one by one stop at the failed row, too
+	c, _ := conn.Conn(context.Background())
+	defer c.Close()
+	tx, _ := c.BeginTx(context.Background(), nil)
+	c.Raw(func(driverConn interface{}) error {
+		counts, err = driverConn.(Batcher).ExecBatch(
+			"INSERT INTO test_exec_batch (id, s) values (?, ?)",
+			[][]driver.Value{{3000, "a"}, {"3001", 1}, {1, "b"}, {3002, "c"}})
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "rows[2]") || len(counts) != 2 {
+		t.Errorf("Expected error for rows[2]: %v %v", counts, err)
 	}
+	tx.QueryRow("SELECT count(*) FROM test_exec_batch WHERE id >= 3000").Scan(&n)
+	if n != 2 {
+		t.Errorf("rows before the error: %d", n)
+	}
+	tx.Rollback()
 }
 
-/*
-func TestBoolean(t *testing.T) {
-	conn, err := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_fb3.fdb")
+func TestQuadColumn(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "SYSDBA:masterkey@localhost:3050/tmp/go_test_quad_column.fdb")
+	defer conn.Close()
+
+	var relation, field string
+	err := conn.QueryRow(`
+        SELECT TRIM(rf.RDB$RELATION_NAME), TRIM(rf.RDB$FIELD_NAME)
+        FROM RDB$RELATION_FIELDS rf
+        JOIN RDB$FIELDS f ON f.RDB$FIELD_NAME = rf.RDB$FIELD_SOURCE
+        JOIN RDB$RELATIONS r ON r.RDB$RELATION_NAME = rf.RDB$RELATION_NAME
+        WHERE f.RDB$FIELD_TYPE = 9 AND r.RDB$VIEW_BLR IS NULL
+        ROWS 1`).Scan(&relation, &field)
+	if err == sql.ErrNoRows {
+		t.Skip("No QUAD column in the system tables")
+	}
 	if err != nil {
-		t.Fatalf("Error connecting: %v", err)
+		t.Fatalf("Error finding a QUAD column: %v", err)
 	}
-	var sql string
-	var n int
 
-	sql = "SELECT Count(*) FROM rdb$relations where rdb$relation_name='TEST_FB3'"
-	err = conn.QueryRow(sql).Scan(&n)
+	rows, err := conn.Query("SELECT " + field + " FROM " + relation)
 	if err != nil {
-		t.Fatalf("Error QueryRow: %v", err)
+		t.Fatalf("Error Query %s.%s: %v", relation, field, err)
 	}
-	if n > 0 {
-		conn.Exec("DROP TABLE test_fb3")
+	defer rows.Close()
+	for rows.Next() {
+		var v interface{}
+		if err := rows.Scan(&v); err != nil {
+			t.Fatalf("Error Scan %s.%s: %v", relation, field, err)
+		}
+		if b, ok := v.([]byte); v != nil && (!ok || len(b) != 8) {
+			t.Errorf("%s.%s: %v", relation, field, v)
+		}
 	}
+}
 
-	sql = `
-        CREATE TABLE test_fb3 (
-            b BOOLEAN
-        )
-    `
-	conn.Exec(sql)
-	conn.Exec("insert into test_fb3(b) values (true)")
-	conn.Exec("insert into test_fb3(b) values (false)")
-    var b bool
-	err = conn.QueryRow("select * from test_fb3 where b is true").Scan(&b)
-	if err != nil {
-		t.Fatalf("Error QueryRow: %v", err)
+func TestFirebirdErrorCode(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "SYSDBA:masterkey@localhost:3050/tmp/go_test_firebird_error_code.fdb")
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_error_code (id integer NOT NULL PRIMARY KEY)")
+	conn.Exec("INSERT INTO test_error_code (id) values (1)")
+
+	_, err := conn.Exec("INSERT INTO test_error_code (id) values (1)")
+	fbErr, ok := err.(*FirebirdError)
+	if !ok {
+		t.Fatalf("Expected FirebirdError: %v", err)
 	}
-	if b != true{
-		conn.Exec("Invalid boolean value")
+	if !fbErr.HasCode(335544665) {
+		t.Errorf("unique key violation: %v", fbErr.Codes())
 	}
-	err = conn.QueryRow("select * from test_fb3 where b is false").Scan(&b)
-	if err != nil {
-		t.Fatalf("Error QueryRow: %v", err)
+	if fbErr.SQLState() != "23000" {
+		t.Errorf("SQLState: %s", fbErr.SQLState())
 	}
-	if b != false{
-		conn.Exec("Invalid boolean value")
+
+	_, err = conn.Exec("SELEC 1 FROM rdb$database")
+	if fbErr, ok = err.(*FirebirdError); !ok || fbErr.SQLCode() != -104 || fbErr.SQLState() != "42000" {
+		t.Errorf("syntax error: %v", err)
 	}
+}
 
-	stmt, _ := conn.Prepare("select * from test_fb3 where b=?")
-	err = stmt.QueryRow(true).Scan(&b)
-	if err != nil {
-		t.Fatalf("Error QueryRow: %v", err)
+func TestConnectDpb(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "SYSDBA:masterkey@localhost:3050/tmp/go_test_dsn_dpb.fdb")
+	conn.Exec("SELECT 1 FROM rdb$database")
+	conn.Close()
+
+	conn, _ = sql.Open("firebirdsql", "SYSDBA:masterkey@localhost:3050/tmp/go_test_dsn_dpb.fdb?dialect=1&num_buffers=1000&dpb.process_name=go_test_dsn_dpb")
+	defer conn.Close()
+	var f float64
+	if err := conn.QueryRow("SELECT 1/2 FROM rdb$database").Scan(&f); err != nil || f != 0.5 {
+		t.Errorf("dialect 1 division: %v %v", f, err)
 	}
-	if b != false{
-		conn.Exec("Invalid boolean value")
+	var name string
+	err := conn.QueryRow("SELECT TRIM(MON$REMOTE_PROCESS) FROM MON$ATTACHMENTS WHERE MON$ATTACHMENT_ID = CURRENT_CONNECTION").Scan(&name)
+	if err != nil || name != "go_test_dsn_dpb" {
+		t.Errorf("dpb.process_name: %v %v", name, err)
 	}
 
-	defer conn.Close()
+	conn2, _ := sql.Open("firebirdsql", "SYSDBA:masterkey@localhost:3050/tmp/go_test_dsn_dpb.fdb?dialect=2")
+	if err := conn2.Ping(); err == nil {
+		t.Error("Expected error for dialect=2")
+	}
 }
-*/
 
-func TestLegacyAuthWireCrypt(t *testing.T) {
-	var n int
-	conn, err := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_connect.fdb")
+func TestDialect1(t *testing.T) {
+	conn, err := sql.Open("firebirdsql_createdb", "SYSDBA:masterkey@localhost:3050/tmp/go_test_dialect1.fdb?dialect=1")
 	if err != nil {
 		t.Fatalf("Error connecting: %v", err)
 	}
+	defer conn.Close()
+	if _, err = conn.Exec("CREATE TABLE test_dialect1 (d date, s varchar(10))"); err != nil {
+		t.Fatalf("Error CREATE TABLE: %v", err)
+	}
+
+	// dialect 1 DATE has the time part, and double quotes are string literals
+	d := time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC)
+	if _, err = conn.Exec(`INSERT INTO test_dialect1 (d, s) values (?, "abc")`, d); err != nil {
+		t.Fatalf("Error INSERT: %v", err)
+	}
+	var d2 time.Time
+	var s string
+	if err = conn.QueryRow("SELECT d, s FROM test_dialect1").Scan(&d2, &s); err != nil {
+		t.Fatalf("Error SELECT: %v", err)
+	}
+	if !d2.Equal(d) || s != "abc" {
+		t.Errorf("dialect 1: %v %v", d2, s)
+	}
+
+	var dialect int
+	conn.QueryRow("SELECT MON$SQL_DIALECT FROM MON$DATABASE").Scan(&dialect)
+	if dialect != 1 {
+		t.Errorf("database dialect: %d", dialect)
+	}
+}
 
-	conn, err = sql.Open("firebirdsql", "sysdba:masterkey@localhost:3050/tmp/go_test_connect.fdb?auth_plugin_anme=Legacy_Auth")
+func TestTimezone(t *testing.T) {
+	conn, err := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_timezone.fdb?timezone=Europe/Berlin")
 	if err != nil {
 		t.Fatalf("Error connecting: %v", err)
 	}
-	err = conn.QueryRow("SELECT Count(*) FROM rdb$relations").Scan(&n)
+	defer conn.Close()
 
-	conn, err = sql.Open("firebirdsql", "sysdba:masterkey@localhost:3050/tmp/go_test_connect.fdb?wire_crypt=false")
+	var d, tm, ts time.Time
+	err = conn.QueryRow("SELECT CAST('2021-02-03' AS DATE), CAST('04:05:06' AS TIME), CAST('2021-02-03 04:05:06' AS TIMESTAMP) FROM rdb$database").Scan(&d, &tm, &ts)
+	if err != nil {
+		t.Fatalf("Error SELECT: %v", err)
+	}
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	if !d.Equal(time.Date(2021, 2, 3, 0, 0, 0, 0, berlin)) || d.Location().String() != "Europe/Berlin" {
+		t.Errorf("DATE: %v", d)
+	}
+	if h, m, s := tm.Clock(); h != 4 || m != 5 || s != 6 || tm.Location().String() != "Europe/Berlin" {
+		t.Errorf("TIME: %v", tm)
+	}
+	if !ts.Equal(time.Date(2021, 2, 3, 4, 5, 6, 0, berlin)) {
+		t.Errorf("TIMESTAMP: %v", ts)
+	}
+}
+
+func TestScanTimeClock(t *testing.T) {
+	conn, err := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_time_mode.fdb?time_mode=clock")
 	if err != nil {
 		t.Fatalf("Error connecting: %v", err)
 	}
-	err = conn.QueryRow("SELECT Count(*) FROM rdb$relations").Scan(&n)
+	defer conn.Close()
 
-	conn, err = sql.Open("firebirdsql", "sysdba:masterkey@localhost:3050/tmp/go_test_connect.fdb?auth_plugin_name=Legacy_Auth&wire_auth=true")
+	var tm Time
+	var ts time.Time
+	err = conn.QueryRow("SELECT CAST('04:05:06.7' AS TIME), CAST('2021-02-03 04:05:06' AS TIMESTAMP) FROM rdb$database").Scan(&tm, &ts)
+	if err != nil {
+		t.Fatalf("Error SELECT: %v", err)
+	}
+	if tm != (Time{4, 5, 6, 700000000}) || tm.String() != "04:05:06.7000" {
+		t.Errorf("TIME: %v", tm)
+	}
+	if ts.Year() != 2021 {
+		t.Errorf("TIMESTAMP: %v", ts)
+	}
+}
+
+func TestTimeFraction(t *testing.T) {
+	conn, err := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_time_fraction.fdb")
 	if err != nil {
 		t.Fatalf("Error connecting: %v", err)
 	}
-	err = conn.QueryRow("SELECT Count(*) FROM rdb$relations").Scan(&n)
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_time_fraction (ts timestamp, tm time)")
+
+	// microseconds are truncated to 1/10000 second
+	ts := time.Date(2021, 2, 3, 4, 5, 6, 123456000, time.UTC)
+	if _, err = conn.Exec("INSERT INTO test_time_fraction (ts, tm) values (?, ?)", ts, time.Date(0, 1, 1, 4, 5, 6, 123456000, time.UTC)); err != nil {
+		t.Fatalf("Error INSERT: %v", err)
+	}
+	var ts2, tm2 time.Time
+	if err = conn.QueryRow("SELECT ts, tm FROM test_time_fraction").Scan(&ts2, &tm2); err != nil {
+		t.Fatalf("Error SELECT: %v", err)
+	}
+	if ts2.Nanosecond() != 123400000 || tm2.Nanosecond() != 123400000 {
+		t.Errorf("fractions: %v %v", ts2, tm2)
+	}
+
+	// and a value read back is stored unchanged
+	var n int
+	if err = conn.QueryRow("SELECT count(*) FROM test_time_fraction WHERE ts = ? AND tm = ?", ts2, tm2).Scan(&n); err != nil || n != 1 {
+		t.Errorf("round trip: %d %v", n, err)
+	}
+}
+
+func TestInsertBlobsWithParams(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_insert_blobs_with_params.fdb")
+	conn.Exec("CREATE TABLE test_blobs (f1 BLOB SUB_TYPE 0, f2 BLOB SUB_TYPE 1)")
+	defer conn.Close()
+
+	s0 := "Test Text"
+	b0 := []byte{0, 1, 2, 3, 4}
+	if _, err := conn.Exec("INSERT INTO test_blobs (f1, f2) values (?, ?)", s0, b0); err != nil {
+		t.Fatalf("Error inserting blobs with params: %v", err)
+	}
+
+	var s string
+	var b []byte
+	err := conn.QueryRow("SELECT f1, f2 from test_blobs").Scan(&s, &b)
+	if err != nil {
+		t.Fatalf("Error in query: %v", err)
+	}
+	if s != s0 {
+		t.Fatalf("Text blob: expected <%s>, got <%s>", s0, s)
+	}
+	if !reflect.DeepEqual(b, b0) {
+		t.Fatalf("Binary blob: expected <%v>, got <%v> (%s)", b0, b, string(b))
+	}
+}
+
+func TestBlobMode(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_blob_mode.fdb")
+	conn.Exec("CREATE TABLE test_blob_mode (f1 BLOB SUB_TYPE 1)")
+	conn.Exec("INSERT INTO test_blob_mode (f1) values ('This is a memo')")
 	conn.Close()
 
-	conn, err = sql.Open("firebirdsql", "sysdba:masterkey@localhost:3050/tmp/go_test_connect.fdb?auth_plugin_name=Legacy_Auth&wire_auth=false")
+	conn, _ = sql.Open("firebirdsql", "sysdba:masterkey@localhost:3050/tmp/go_test_blob_mode.fdb")
+	var s string
+	err := conn.QueryRow("SELECT f1 from test_blob_mode").Scan(&s)
 	if err != nil {
-		t.Fatalf("Error connecting: %v", err)
+		t.Fatalf("Error in query: %v", err)
+	}
+	if s != "This is a memo" {
+		t.Fatalf("Text blob: expected <This is a memo>, got <%s>", s)
 	}
-	err = conn.QueryRow("SELECT Count(*) FROM rdb$relations").Scan(&n)
 	conn.Close()
-}
 
-func TestErrorConnect(t *testing.T) {
-	var n int
-	conn, err := sql.Open("firebirdsql", "foo:bar@something_wrong_hostname:3050/dbname")
+	conn, _ = sql.Open("firebirdsql", "sysdba:masterkey@localhost:3050/tmp/go_test_blob_mode.fdb?blob_mode=id")
+	defer conn.Close()
+	var b []byte
+	err = conn.QueryRow("SELECT f1 from test_blob_mode").Scan(&b)
 	if err != nil {
-		t.Fatalf("Error occured at sql.Open()")
+		t.Fatalf("Error in query: %v", err)
 	}
-	err = conn.QueryRow("SELECT Count(*) FROM rdb$relations").Scan(&n)
-	if err == nil {
-		t.Fatalf("Error not occured")
+	if len(b) != 8 {
+		t.Fatalf("Blob id: expected 8 bytes, got <%v>", b)
+	}
+}
+
+func TestBlobReader(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_blob_reader.fdb")
+	conn.Exec("CREATE TABLE test_blob_reader (f1 BLOB SUB_TYPE 0)")
+	b0 := make([]byte, 5000)
+	for i := range b0 {
+		b0[i] = byte(i)
 	}
+	conn.Exec("INSERT INTO test_blob_reader (f1) values (?)", b0)
 	conn.Close()
+
+	for _, mode := range []string{"stream", "content", "content&max_inline_blob=1000"} {
+		conn, _ = sql.Open("firebirdsql", "sysdba:masterkey@localhost:3050/tmp/go_test_blob_reader.fdb?blob_mode="+mode)
+		rows, err := conn.Query("SELECT f1 from test_blob_reader")
+		if err != nil {
+			t.Fatalf("Error in query: %v", err)
+		}
+		rows.Next()
+		var r BlobReader
+		if err = rows.Scan(&r); err != nil {
+			t.Fatalf("Error in scan: %v", err)
+		}
+		var b []byte
+		chunk := make([]byte, 100)
+		for {
+			n, err := r.Read(chunk)
+			b = append(b, chunk[:n]...)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Error in read: %v", err)
+			}
+		}
+		if err = r.Close(); err != nil {
+			t.Fatalf("Error in close: %v", err)
+		}
+		rows.Close()
+		conn.Close()
+		if !reflect.DeepEqual(b, b0) {
+			t.Fatalf("%s: blob contents differ (%d bytes read)", mode, len(b))
+		}
+	}
+}
+
+func TestMaxInlineBlob(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_max_inline_blob.fdb?max_inline_blob=100")
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_max_inline_blob (id integer, f1 BLOB SUB_TYPE 0)")
+	conn.Exec("INSERT INTO test_max_inline_blob (id, f1) values (1, ?)", make([]byte, 100))
+	conn.Exec("INSERT INTO test_max_inline_blob (id, f1) values (2, ?)", make([]byte, 101))
+
+	rows, err := conn.Query("SELECT f1 FROM test_max_inline_blob ORDER BY id")
+	if err != nil {
+		t.Fatalf("Error in query: %v", err)
+	}
+	defer rows.Close()
+	var small, large interface{}
+	rows.Next()
+	rows.Scan(&small)
+	rows.Next()
+	rows.Scan(&large)
+	if b, ok := small.([]byte); !ok || len(b) != 100 {
+		t.Errorf("100 bytes blob: %T", small)
+	}
+	r, ok := large.(*BlobReader)
+	if !ok {
+		t.Fatalf("101 bytes blob: %T", large)
+	}
+	b, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || len(b) != 101 {
+		t.Errorf("101 bytes blob: %d bytes %v", len(b), err)
+	}
+}
+
+func TestInsertBlobsWithReader(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_insert_blobs_with_reader.fdb")
+	conn.Exec("CREATE TABLE test_blobs (f1 BLOB SUB_TYPE 0, f2 BLOB SUB_TYPE 1)")
+	defer conn.Close()
+
+	b0 := bytes.Repeat([]byte{0, 1, 2, 3, 4}, BLOB_SEGMENT_SIZE)
+	s0 := strings.Repeat("Test Text ", BLOB_SEGMENT_SIZE/5)
+	if _, err := conn.Exec("INSERT INTO test_blobs (f1, f2) values (?, ?)", bytes.NewReader(b0), strings.NewReader(s0)); err != nil {
+		t.Fatalf("Error inserting blobs with reader: %v", err)
+	}
+
+	var b []byte
+	var s string
+	err := conn.QueryRow("SELECT f1, f2 from test_blobs").Scan(&b, &s)
+	if err != nil {
+		t.Fatalf("Error in query: %v", err)
+	}
+	if !reflect.DeepEqual(b, b0) {
+		t.Fatalf("Binary blob: expected %d bytes, got %d bytes", len(b0), len(b))
+	}
+	if s != s0 {
+		t.Fatalf("Text blob: expected %d chars, got %d chars", len(s0), len(s))
+	}
+}
+
+func TestInsertDecimal(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_insert_decimal.fdb?decimal_mode=string")
+	conn.Exec("CREATE TABLE test_decimal (f1 NUMERIC(4,2), f2 NUMERIC(9,3), f3 NUMERIC(18,2))")
+	defer conn.Close()
+
+	if _, err := conn.Exec("INSERT INTO test_decimal (f1, f2, f3) values (?, ?, ?)", Decimal("12.34"), Decimal("-0.5"), Decimal("123.45")); err != nil {
+		t.Fatalf("Error inserting decimals: %v", err)
+	}
+	var f1, f2, f3 string
+	err := conn.QueryRow("SELECT f1, f2, f3 from test_decimal").Scan(&f1, &f2, &f3)
+	if err != nil {
+		t.Fatalf("Error in query: %v", err)
+	}
+	if f1 != "12.34" || f2 != "-0.500" || f3 != "123.45" {
+		t.Fatalf("Bad decimal values: %s, %s, %s", f1, f2, f3)
+	}
+}
+
+func TestInsertBigRat(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_insert_big_rat.fdb?decimal_mode=string")
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_big_rat (f1 NUMERIC(18,2), f2 NUMERIC(4,2), f3 BIGINT)")
+
+	if _, err := conn.Exec("INSERT INTO test_big_rat (f1, f2, f3) values (?, ?, ?)", big.NewRat(12345, 100), big.NewRat(-1, 4), big.NewInt(1<<40)); err != nil {
+		t.Fatalf("Error inserting: %v", err)
+	}
+	var f1, f2, f3 string
+	err := conn.QueryRow("SELECT f1, f2, f3 from test_big_rat").Scan(&f1, &f2, &f3)
+	if err != nil {
+		t.Fatalf("Error in query: %v", err)
+	}
+	if f1 != "123.45" || f2 != "-0.25" || f3 != "1099511627776" {
+		t.Errorf("Bad values: %s, %s, %s", f1, f2, f3)
+	}
+	if _, err = conn.Exec("INSERT INTO test_big_rat (f2) values (?)", big.NewRat(12345, 100)); err == nil {
+		t.Errorf("123.45 was inserted to NUMERIC(4,2)")
+	}
+}
+
+func TestScanDecimalFloat(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_decimal_float.fdb?decimal_mode=float")
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_decimal_float (f1 NUMERIC(4,2), f2 NUMERIC(18,3))")
+	conn.Exec("INSERT INTO test_decimal_float (f1, f2) values (12.34, -0.5)")
+
+	rows, err := conn.Query("SELECT f1, f2 from test_decimal_float")
+	if err != nil {
+		t.Fatalf("Error in query: %v", err)
+	}
+	defer rows.Close()
+	var f1, f2 float64
+	if !rows.Next() {
+		t.Fatalf("no row")
+	}
+	if err = rows.Scan(&f1, &f2); err != nil {
+		t.Fatalf("Error in Scan: %v", err)
+	}
+	if f1 != 12.34 || f2 != -0.5 {
+		t.Errorf("Bad float values: %v, %v", f1, f2)
+	}
+}
+
+func TestNullDecimal(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_null_decimal.fdb")
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_null_decimal (id integer, d NUMERIC(9,3))")
+	if _, err := conn.Exec("INSERT INTO test_null_decimal (id, d) values (1, ?)", NullDecimal{"12.5", true}); err != nil {
+		t.Fatalf("Error inserting: %v", err)
+	}
+	if _, err := conn.Exec("INSERT INTO test_null_decimal (id, d) values (2, ?)", NullDecimal{}); err != nil {
+		t.Fatalf("Error inserting NULL: %v", err)
+	}
+
+	rows, err := conn.Query("SELECT d FROM test_null_decimal ORDER BY id")
+	if err != nil {
+		t.Fatalf("Error in query: %v", err)
+	}
+	var got []NullDecimal
+	for rows.Next() {
+		var n NullDecimal
+		if err = rows.Scan(&n); err != nil {
+			t.Fatalf("Error in Scan: %v", err)
+		}
+		got = append(got, n)
+	}
+	rows.Close()
+	expected := []NullDecimal{{"12.5", true}, {}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestOctets(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_octets.fdb")
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_octets (v VARCHAR(10) CHARACTER SET OCTETS)")
+
+	b0 := []byte{'a', 0, 0xff, 0, 'b'}
+	if _, err := conn.Exec("INSERT INTO test_octets (v) values (?)", b0); err != nil {
+		t.Fatalf("Error inserting: %v", err)
+	}
+	var b []byte
+	var s string
+	if err := conn.QueryRow("SELECT v, v FROM test_octets").Scan(&b, &s); err != nil {
+		t.Fatalf("Error in query: %v", err)
+	}
+	if !bytes.Equal(b, b0) || s != string(b0) {
+		t.Errorf("expected %v, got %v and %q", b0, b, s)
+	}
+}
+
+func TestCharset(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_charset.fdb")
+	conn.Exec("CREATE TABLE test_charset (f1 VARCHAR(20) CHARACTER SET WIN1251, f2 VARCHAR(20) CHARACTER SET UTF8)")
+	conn.Exec("INSERT INTO test_charset (f1, f2) values ('Привет', 'Привет')")
+	conn.Close()
+
+	// The same database through an UTF8 and a WIN1251 connection.
+	for _, cs := range []string{"UTF8", "WIN1251"} {
+		conn, _ = sql.Open("firebirdsql", "sysdba:masterkey@localhost:3050/tmp/go_test_charset.fdb?charset="+cs)
+		var f1, f2 string
+		err := conn.QueryRow("SELECT f1, f2 from test_charset").Scan(&f1, &f2)
+		conn.Close()
+		if err != nil {
+			t.Fatalf("Error in query: %v", err)
+		}
+		if f1 != "Привет" || f2 != "Привет" {
+			t.Errorf("charset=%s: got <%s>, <%s>", cs, f1, f2)
+		}
+	}
+}
+
+func TestTrimChar(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_trim_char.fdb")
+	var s string
+	err := conn.QueryRow("SELECT CAST('ab' AS CHAR(5)) FROM rdb$database").Scan(&s)
+	conn.Close()
+	if err != nil {
+		t.Fatalf("Error in query: %v", err)
+	}
+	if s != "ab" {
+		t.Errorf("trimmed CHAR: <%s>", s)
+	}
+
+	conn, _ = sql.Open("firebirdsql", "sysdba:masterkey@localhost:3050/tmp/go_test_trim_char.fdb?trim_char=false")
+	err = conn.QueryRow("SELECT CAST('ab' AS CHAR(5)) FROM rdb$database").Scan(&s)
+	conn.Close()
+	if err != nil {
+		t.Fatalf("Error in query: %v", err)
+	}
+	if s != "ab   " {
+		t.Errorf("untrimmed CHAR: <%s>", s)
+	}
+}
+
+func TestQueryContextTimeout(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_query_context.fdb")
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	var n int64
+	err := conn.QueryRowContext(ctx, `
+	    SELECT count(*) FROM rdb$fields a, rdb$fields b, rdb$fields c, rdb$fields d`).Scan(&n)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("query was not cancelled promptly: %v", elapsed)
+	}
+
+	err = conn.QueryRow("SELECT count(*) FROM rdb$database").Scan(&n)
+	if err != nil || n != 1 {
+		t.Errorf("connection is unusable after cancel: %v", err)
+	}
+}
+
+func TestPing(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_ping.fdb")
+	if err := conn.Ping(); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	conn.Close()
+
+	fc, err := newFirebirdsqlConn("sysdba:masterkey@localhost:3050/tmp/go_test_ping.fdb")
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	if err = fc.Ping(context.Background()); err != nil {
+		t.Errorf("Ping: %v", err)
+	}
+	fc.wp.conn.Close()
+	if err = fc.Ping(context.Background()); err != driver.ErrBadConn {
+		t.Errorf("expected driver.ErrBadConn for a closed connection, got %v", err)
+	}
+}
+
+func TestResetSession(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_reset_session.fdb")
+	conn.Exec("CREATE TABLE test_reset (a integer)")
+	conn.Close()
+
+	fc, err := newFirebirdsqlConn("sysdba:masterkey@localhost:3050/tmp/go_test_reset_session.fdb")
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	defer fc.Close()
+
+	// left in an uncommitted transaction
+	_, err = fc.BeginTx(context.Background(), driver.TxOptions{Isolation: driver.IsolationLevel(sql.LevelSerializable)})
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	if _, err = fc.Exec("INSERT INTO test_reset (a) values (1)", nil); err != nil {
+		t.Fatalf("INSERT: %v", err)
+	}
+
+	if err = fc.ResetSession(context.Background()); err != nil {
+		t.Fatalf("ResetSession: %v", err)
+	}
+	if !fc.tx.isAutocommit || fc.tx.isolationLevel != fc.dsn.isolationLevel {
+		t.Errorf("transaction settings are not reset")
+	}
+	rows, err := fc.Query("SELECT count(*) FROM test_reset", nil)
+	if err != nil {
+		t.Fatalf("SELECT: %v", err)
+	}
+	dest := make([]driver.Value, 1)
+	rows.Next(dest)
+	rows.Close()
+	if dest[0].(int32) != 0 {
+		t.Errorf("the insert was not rolled back: %v", dest[0])
+	}
+
+	fc.wp.conn.Close()
+	if err = fc.ResetSession(context.Background()); err != driver.ErrBadConn {
+		t.Errorf("expected driver.ErrBadConn for a closed connection, got %v", err)
+	}
+}
+
+func TestDatabaseInfo(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_database_info.fdb")
+	defer conn.Close()
+	c, err := conn.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	defer c.Close()
+
+	var info DatabaseInfo
+	err = c.Raw(func(driverConn interface{}) (err error) {
+		info, err = driverConn.(DatabaseInformer).DatabaseInfo()
+		return
+	})
+	if err != nil {
+		t.Fatalf("DatabaseInfo: %v", err)
+	}
+	if info.PageSize < 4096 || info.Pages == 0 || info.NumBuffers == 0 || info.CurrentMemory == 0 || info.Dialect != 3 {
+		t.Errorf("unexpected database info %+v", info)
+	}
+	if info.Attachments < 1 || info.NextTransaction < info.OldestTransaction {
+		t.Errorf("unexpected database info %+v", info)
+	}
+}
+
+func TestServerVersion(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_server_version.fdb")
+	defer conn.Close()
+	c, err := conn.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	defer c.Close()
+
+	var v ServerVersion
+	err = c.Raw(func(driverConn interface{}) (err error) {
+		v, err = driverConn.(ServerVersioner).ServerVersion()
+		return
+	})
+	if err != nil {
+		t.Fatalf("ServerVersion: %v", err)
+	}
+	if v.Major < 2 || v.OdsMajor < 11 || !strings.HasPrefix(v.EngineVersion, fmt.Sprintf("%d.%d.", v.Major, v.Minor)) {
+		t.Errorf("unexpected server version %+v", v)
+	}
+
+	var engine string
+	conn.QueryRow("SELECT rdb$get_context('SYSTEM', 'ENGINE_VERSION') FROM rdb$database").Scan(&engine)
+	if !strings.HasPrefix(v.EngineVersion, engine) {
+		t.Errorf("expected ENGINE_VERSION %s, got %s", engine, v.EngineVersion)
+	}
+}
+
+func TestConnector(t *testing.T) {
+	c, err := NewConnector("sysdba:masterkey@localhost:3050/tmp/go_test_connector.fdb")
+	if err != nil {
+		t.Fatalf("NewConnector: %v", err)
+	}
+	c.CreateDatabase = true
+	var dialed int
+	c.Dial = func(ctx context.Context) (net.Conn, error) {
+		dialed++
+		var d net.Dialer
+		return d.DialContext(ctx, "tcp", "localhost:3050")
+	}
+	conn := sql.OpenDB(c)
+	var n int
+	err = conn.QueryRow("SELECT 1 FROM rdb$database").Scan(&n)
+	conn.Close()
+	if err != nil || n != 1 {
+		t.Fatalf("QueryRow: %v %d", err, n)
+	}
+	if dialed != 1 {
+		t.Errorf("Dial was called %d times", dialed)
+	}
+}
+
+func TestConnectorObserver(t *testing.T) {
+	c, err := NewConnector("sysdba:masterkey@localhost:3050/tmp/go_test_observer.fdb")
+	if err != nil {
+		t.Fatalf("NewConnector: %v", err)
+	}
+	c.CreateDatabase = true
+	o := &testObserver{}
+	c.Observer = o
+	conn := sql.OpenDB(c)
+	defer conn.Close()
+	conn.SetMaxOpenConns(1)
+	if _, err = conn.Exec("CREATE TABLE test_observer (a integer)"); err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	o.started, o.ended = nil, nil
+	if _, err = conn.Exec("INSERT INTO test_observer (a) VALUES (1)"); err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	// the prepare is observed within the exec
+	if len(o.ended) != 2 || o.ended[0].Op != QueryOpPrepare || o.ended[1].Op != QueryOpExec || o.ended[1].RowsAffected != 1 {
+		t.Errorf("unexpected events %+v", o.ended)
+	}
+	rows, err := conn.Query("SELECT a FROM test_observer")
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	rows.Close()
+	if last := o.ended[len(o.ended)-1]; last.Op != QueryOpQuery || last.SQL != "SELECT a FROM test_observer" || last.Err != nil {
+		t.Errorf("unexpected query event %+v", last)
+	}
+	if _, err = conn.Query("SELECT a FROM test_observer_missing"); err == nil {
+		t.Fatalf("Query of a missing table")
+	}
+	if last := o.ended[len(o.ended)-1]; last.Err == nil {
+		t.Errorf("no error in %+v", last)
+	}
+}
+
+func TestExecImmediateDDL(t *testing.T) {
+	c, err := NewConnector("sysdba:masterkey@localhost:3050/tmp/go_test_exec_immediate.fdb")
+	if err != nil {
+		t.Fatalf("NewConnector: %v", err)
+	}
+	c.CreateDatabase = true
+	stats := map[string]StatementStats{}
+	c.Stats = func(query string, s StatementStats) {
+		stats[query] = s
+	}
+	conn := sql.OpenDB(c)
+	defer conn.Close()
+
+	ddl := "CREATE TABLE test_exec_immediate (id integer)"
+	if _, err = conn.Exec(ddl); err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	if s := stats[ddl]; s.RoundTrips != 1 {
+		t.Errorf("expected 1 round trip, got %+v", s)
+	}
+	if _, err = conn.Exec("INSERT INTO test_exec_immediate (id) VALUES (1)"); err != nil {
+		t.Errorf("the table is not committed: %v", err)
+	}
+	if _, err = conn.Exec("CREATE TABLE test_exec_immediate (id integer)"); err == nil {
+		t.Errorf("the table was created twice")
+	}
+}
+
+func TestAutocommitDDL(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_autocommit_ddl.fdb?autocommit_ddl=true")
+	defer conn.Close()
+	conn.SetMaxOpenConns(1)
+	conn.Exec("CREATE TABLE test_autocommit_ddl (id integer)")
+	conn.Exec("INSERT INTO test_autocommit_ddl (id) VALUES (1)")
+	conn.Exec("INSERT INTO test_autocommit_ddl (id) VALUES (2)")
+
+	ctx := context.Background()
+	c, err := conn.Conn(ctx)
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	defer c.Close()
+	rows, err := c.QueryContext(ctx, "SELECT id FROM test_autocommit_ddl ORDER BY id")
+	if err != nil {
+		t.Fatalf("QueryContext: %v", err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		t.Fatalf("no row")
+	}
+	// the DDL is committed alone, the cursor of the autocommit transaction
+	// is still open
+	if _, err = c.ExecContext(ctx, "CREATE TABLE test_autocommit_ddl2 (id integer)"); err != nil {
+		t.Fatalf("ExecContext: %v", err)
+	}
+	n := 1
+	for rows.Next() {
+		n++
+	}
+	if err = rows.Err(); err != nil || n != 2 {
+		t.Errorf("expected 2 rows, got %d %v", n, err)
+	}
+
+	conn2, _ := sql.Open("firebirdsql", "sysdba:masterkey@localhost:3050/tmp/go_test_autocommit_ddl.fdb")
+	defer conn2.Close()
+	if _, err = conn2.Exec("INSERT INTO test_autocommit_ddl2 (id) VALUES (1)"); err != nil {
+		t.Errorf("the DDL is not committed: %v", err)
+	}
+}
+
+func TestAppNameProcessID(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_app_name.fdb?app_name=go_test_app&process_id=4242")
+	defer conn.Close()
+
+	var name string
+	var pid int
+	err := conn.QueryRow("SELECT TRIM(MON$REMOTE_PROCESS), MON$REMOTE_PID FROM MON$ATTACHMENTS WHERE MON$ATTACHMENT_ID = CURRENT_CONNECTION").Scan(&name, &pid)
+	if err != nil {
+		t.Fatalf("Error QueryRow: %v", err)
+	}
+	if name != "go_test_app" || pid != 4242 {
+		t.Errorf("expected go_test_app 4242, got %s %d", name, pid)
+	}
+}
+
+func TestCancelAttachment(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_cancel_attachment.fdb")
+	defer conn.Close()
+	conn.Ping()
+
+	ctx := context.Background()
+	db, _ := sql.Open("firebirdsql", "sysdba:masterkey@localhost:3050/tmp/go_test_cancel_attachment.fdb")
+	defer db.Close()
+	// held, so the query after the cancel isn't retried on a new connection
+	victim, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	defer victim.Close()
+	var id int64
+	if err = victim.QueryRowContext(ctx, "SELECT CURRENT_CONNECTION FROM RDB$DATABASE").Scan(&id); err != nil {
+		t.Fatalf("Error QueryRow: %v", err)
+	}
+
+	if err := CancelAttachment(ctx, conn, id); err != nil {
+		t.Fatalf("CancelAttachment: %v", err)
+	}
+	if err := CancelAttachment(ctx, conn, id); err != ErrAttachmentNotFound {
+		t.Errorf("expected ErrAttachmentNotFound, got %v", err)
+	}
+	var n int
+	if err := victim.QueryRowContext(ctx, "SELECT 1 FROM RDB$DATABASE").Scan(&n); err == nil {
+		t.Errorf("the cancelled attachment is still usable")
+	}
+}
+
+func TestSelectNull(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_select_null.fdb")
+	defer conn.Close()
+
+	var v interface{}
+	var n int
+	if err := conn.QueryRow("SELECT NULL, 1 FROM RDB$DATABASE").Scan(&v, &n); err != nil {
+		t.Fatalf("Error QueryRow: %v", err)
+	}
+	if v != nil || n != 1 {
+		t.Errorf("expected nil 1, got %v %d", v, n)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_plan.fdb")
+	defer conn.Close()
+
+	ctx := context.Background()
+	c, err := conn.Conn(ctx)
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	defer c.Close()
+	var plan, explained string
+	err = c.Raw(func(driverConn interface{}) error {
+		stmt, err := driverConn.(driver.ConnPrepareContext).PrepareContext(ctx, "SELECT * FROM RDB$DATABASE")
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		if plan, err = stmt.(Planner).Plan(false); err != nil {
+			return err
+		}
+		explained, err = stmt.(Planner).Plan(true)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if plan != "PLAN (RDB$DATABASE NATURAL)" {
+		t.Errorf("unexpected plan %q", plan)
+	}
+	if !strings.Contains(explained, "Table \"RDB$DATABASE\" Full Scan") {
+		t.Errorf("unexpected explained plan %q", explained)
+	}
+}
+
+func TestNoDBTriggers(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_no_db_triggers.fdb")
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_connects (id integer)")
+	conn.Exec("CREATE TRIGGER test_on_connect ON CONNECT AS BEGIN INSERT INTO test_connects (id) VALUES (1); END")
+	conn.Close()
+
+	var n int
+	for _, tt := range []struct {
+		dsn  string
+		want int
+	}{
+		{"sysdba:masterkey@localhost:3050/tmp/go_test_no_db_triggers.fdb?no_db_triggers=true", 0},
+		{"sysdba:masterkey@localhost:3050/tmp/go_test_no_db_triggers.fdb", 1},
+	} {
+		conn, _ = sql.Open("firebirdsql", tt.dsn)
+		err := conn.QueryRow("SELECT COUNT(*) FROM test_connects").Scan(&n)
+		conn.Close()
+		if err != nil || n != tt.want {
+			t.Errorf("%s: expected %d connects, got %d %v", tt.dsn, tt.want, n, err)
+		}
+	}
+}
+
+func TestReadOnlyDatabase(t *testing.T) {
+	path := "/tmp/go_test_read_only.fdb"
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050"+path)
+	conn.Exec("CREATE TABLE test_read_only (id integer)")
+	conn.Exec("INSERT INTO test_read_only (id) VALUES (1)")
+	conn.Close()
+
+	sm, err := NewServiceManager("sysdba:masterkey@localhost:3050")
+	if err != nil {
+		t.Fatalf("NewServiceManager: %v", err)
+	}
+	defer sm.Close()
+	if err = sm.SetReadOnly(path, true); err != nil {
+		t.Fatalf("SetReadOnly: %v", err)
+	}
+	defer sm.SetReadOnly(path, false)
+
+	// the read-write autocommit transaction can't start
+	conn, _ = sql.Open("firebirdsql", "sysdba:masterkey@localhost:3050"+path)
+	if err = conn.Ping(); err == nil {
+		t.Errorf("attached to a read-only database with a read-write transaction")
+	}
+	conn.Close()
+
+	conn, _ = sql.Open("firebirdsql", "sysdba:masterkey@localhost:3050"+path+"?read_only=true")
+	defer conn.Close()
+	var n int
+	if err = conn.QueryRow("SELECT COUNT(*) FROM test_read_only").Scan(&n); err != nil || n != 1 {
+		t.Errorf("expected 1 row, got %d %v", n, err)
+	}
+	tx, err := conn.Begin()
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if _, err = tx.Exec("INSERT INTO test_read_only (id) VALUES (2)"); err == nil {
+		t.Errorf("insert in a read-only transaction")
+	}
+	tx.Rollback()
+}
+
+func TestBulkInsert(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_bulk_insert.fdb")
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_bulk (id integer NOT NULL PRIMARY KEY, \"Name\" varchar(20), amount numeric(9, 2), \"POSITION\" integer)")
+
+	rows := make([][]driver.Value, 250)
+	for i := range rows {
+		rows[i] = []driver.Value{i, fmt.Sprintf("row %d", i), nil, i}
+	}
+	rows[7][2] = "12.50"
+
+	ctx := context.Background()
+	c, err := conn.Conn(ctx)
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	defer c.Close()
+	var n int64
+	err = c.Raw(func(driverConn interface{}) (err error) {
+		n, err = driverConn.(BulkInserter).BulkInsert("test_bulk", []string{"id", "Name", "amount", "position"}, rows, 64)
+		return
+	})
+	if err != nil || n != 250 {
+		t.Fatalf("BulkInsert: %d %v", n, err)
+	}
+	var count int
+	var name string
+	c.QueryRowContext(ctx, "SELECT COUNT(*) FROM test_bulk").Scan(&count)
+	c.QueryRowContext(ctx, "SELECT \"Name\" FROM test_bulk WHERE id = 249").Scan(&name)
+	if count != 250 || name != "row 249" {
+		t.Errorf("expected 250 rows and row 249, got %d %s", count, name)
+	}
+
+	// the duplicate key rolls back all the rows in autocommit mode
+	err = c.Raw(func(driverConn interface{}) (err error) {
+		_, err = driverConn.(BulkInserter).BulkInsert("test_bulk", []string{"id"}, [][]driver.Value{{1000}, {1001}, {0}}, 2)
+		return
+	})
+	if err == nil {
+		t.Errorf("duplicate key was inserted")
+	}
+	c.QueryRowContext(ctx, "SELECT COUNT(*) FROM test_bulk").Scan(&count)
+	if count != 250 {
+		t.Errorf("expected 250 rows after the rollback, got %d", count)
+	}
+}
+
+func TestDropCachedTable(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_drop_cached.fdb?stmt_cache_size=10")
+	defer conn.Close()
+	conn.SetMaxOpenConns(1)
+	conn.Exec("CREATE TABLE test_drop_cached (id integer)")
+	conn.Exec("INSERT INTO test_drop_cached (id) VALUES (1)")
+	var n int
+	if err := conn.QueryRow("SELECT COUNT(*) FROM test_drop_cached").Scan(&n); err != nil {
+		t.Fatalf("Error QueryRow: %v", err)
+	}
+	// the cached INSERT and SELECT don't keep the table in use
+	if _, err := conn.Exec("DROP TABLE test_drop_cached"); err != nil {
+		t.Errorf("DROP TABLE: %v", err)
+	}
+}
+
+func TestDDLCommitError(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_ddl_commit_error.fdb")
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_ddl_commit_error (id integer)")
+
+	// the table is in use by the other connection at the commit of the DROP
+	other, _ := sql.Open("firebirdsql", "sysdba:masterkey@localhost:3050/tmp/go_test_ddl_commit_error.fdb")
+	defer other.Close()
+	tx, _ := other.Begin()
+	defer tx.Rollback()
+	rows, err := tx.Query("SELECT id FROM test_ddl_commit_error")
+	if err != nil {
+		t.Fatalf("Error Query: %v", err)
+	}
+	defer rows.Close()
+
+	if _, err = conn.Exec("DROP TABLE test_ddl_commit_error"); err == nil {
+		t.Errorf("DROP TABLE of a table in use succeeded")
+	}
+}
+
+func TestConnectorWarmup(t *testing.T) {
+	c, err := NewConnector("sysdba:masterkey@localhost:3050/tmp/go_test_warmup.fdb")
+	if err != nil {
+		t.Fatalf("NewConnector: %v", err)
+	}
+	c.CreateDatabase = true
+	conn := sql.OpenDB(c)
+	defer conn.Close()
+	conn.SetMaxIdleConns(3)
+	if err = Warmup(context.Background(), conn, 3); err != nil {
+		t.Fatalf("Warmup: %v", err)
+	}
+	if s := conn.Stats(); s.OpenConnections != 3 || s.Idle != 3 {
+		t.Errorf("expected 3 idle connections, got %+v", s)
+	}
+}
+
+func TestConnectorStats(t *testing.T) {
+	c, err := NewConnector("sysdba:masterkey@localhost:3050/tmp/go_test_stats.fdb?fetch_size=10")
+	if err != nil {
+		t.Fatalf("NewConnector: %v", err)
+	}
+	c.CreateDatabase = true
+	stats := map[string]StatementStats{}
+	c.Stats = func(query string, s StatementStats) {
+		stats[query] = s
+	}
+	conn := sql.OpenDB(c)
+	defer conn.Close()
+	query := "SELECT rdb$relation_name FROM rdb$relations"
+	rows, err := conn.Query(query)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	n := 0
+	for rows.Next() {
+		n++
+	}
+	rows.Close()
+	s := stats[query]
+	if s.Fetches < n/10 || s.RoundTrips <= s.Fetches || s.BytesReceived == 0 || s.BytesSent == 0 {
+		t.Errorf("unexpected stats %+v of %d rows", s, n)
+	}
+}
+
+func TestBindDescribedOnce(t *testing.T) {
+	c, err := NewConnector("sysdba:masterkey@localhost:3050/tmp/go_test_bind_described.fdb")
+	if err != nil {
+		t.Fatalf("NewConnector: %v", err)
+	}
+	c.CreateDatabase = true
+	var stats []StatementStats
+	c.Stats = func(query string, s StatementStats) {
+		stats = append(stats, s)
+	}
+	conn := sql.OpenDB(c)
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_bind_described (b varchar(10) character set octets)")
+	stmt, err := conn.Prepare("INSERT INTO test_bind_described (b) VALUES (?)")
+	if err != nil {
+		t.Fatalf("Prepare: %v", err)
+	}
+	defer stmt.Close()
+	stats = nil
+	for i := 0; i < 3; i++ {
+		if _, err = stmt.Exec([]byte{byte(i)}); err != nil {
+			t.Fatalf("Exec: %v", err)
+		}
+	}
+	// the []byte needs the description, which only the first exec gets
+	if len(stats) != 3 || stats[1].RoundTrips != stats[0].RoundTrips-1 || stats[2].RoundTrips != stats[1].RoundTrips {
+		t.Errorf("unexpected round trips %+v", stats)
+	}
+}
+
+func TestTimeParamColumns(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_time_param_columns.fdb?timezone=UTC")
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_time_params (d date, t time, ts timestamp)")
+	tm := time.Date(2026, 10, 14, 12, 30, 15, 0, time.UTC)
+	if _, err := conn.Exec("INSERT INTO test_time_params (d, t, ts) VALUES (?, ?, ?)", tm, tm, tm); err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	var d, tod, ts time.Time
+	if err := conn.QueryRow("SELECT d, t, ts FROM test_time_params").Scan(&d, &tod, &ts); err != nil {
+		t.Fatalf("QueryRow: %v", err)
+	}
+	if d.Year() != 2026 || d.Month() != 10 || d.Day() != 14 || d.Hour() != 0 {
+		t.Errorf("DATE: %v", d)
+	}
+	if tod.Hour() != 12 || tod.Minute() != 30 || tod.Second() != 15 {
+		t.Errorf("TIME: %v", tod)
+	}
+	if !ts.Equal(tm) {
+		t.Errorf("TIMESTAMP: %v", ts)
+	}
+}
+
+func TestDateTimeParams(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_date_time_params.fdb?timezone=Asia/Tokyo")
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_date_time_params (d date, t time)")
+	d, tm := Date{2026, time.October, 14}, Time{23, 30, 0, 0}
+	if _, err := conn.Exec("INSERT INTO test_date_time_params (d, t) VALUES (?, ?)", d, tm); err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	var d2 Date
+	var tm2 Time
+	if err := conn.QueryRow("SELECT d, t FROM test_date_time_params").Scan(&d2, &tm2); err != nil {
+		t.Fatalf("QueryRow: %v", err)
+	}
+	if d2 != d || tm2 != tm {
+		t.Errorf("expected %v %v, got %v %v", d, tm, d2, tm2)
+	}
+}
+
+func TestNullParams(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_null_params.fdb")
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_null_params (i integer, n numeric(18, 2), s varchar(10), d date, b blob sub_type 0)")
+	var nilString *string
+	args := [][]interface{}{
+		{nil, nil, nil, nil, nil},
+		{sql.NullInt64{}, sql.NullFloat64{}, sql.NullString{}, sql.NullTime{}, []byte(nil)},
+		{(*int)(nil), NullDecimal{}, nilString, (*time.Time)(nil), (*bytes.Buffer)(nil)},
+	}
+	for _, a := range args {
+		if _, err := conn.Exec("INSERT INTO test_null_params (i, n, s, d, b) VALUES (?, ?, ?, ?, ?)", a...); err != nil {
+			t.Fatalf("Exec %v: %v", a, err)
+		}
+	}
+	var n int
+	err := conn.QueryRow("SELECT count(*) FROM test_null_params WHERE i IS NULL AND n IS NULL AND s IS NULL AND d IS NULL AND b IS NULL").Scan(&n)
+	if err != nil || n != len(args) {
+		t.Errorf("NULL rows: %d %v", n, err)
+	}
+}
+
+func TestNamedParams(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_named_params.fdb")
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_named (a integer, b integer)")
+	conn.Exec("INSERT INTO test_named (a, b) values (@a, @b)", sql.Named("b", 2), sql.Named("a", 1))
+	conn.Exec("INSERT INTO test_named (a, b) values (@v, @v)", sql.Named("v", 5))
+
+	var n int
+	err := conn.QueryRow("SELECT count(*) FROM test_named WHERE a = @id OR b = @id", sql.Named("id", 5)).Scan(&n)
+	if err != nil {
+		t.Fatalf("Error in query: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("count: %d", n)
+	}
+
+	stmt, err := conn.Prepare("SELECT b FROM test_named WHERE a = @a")
+	if err != nil {
+		t.Fatalf("Error in prepare: %v", err)
+	}
+	err = stmt.QueryRow(sql.Named("a", 1)).Scan(&n)
+	stmt.Close()
+	if err != nil || n != 2 {
+		t.Errorf("prepared named query: %d %v", n, err)
+	}
+}
+
+func TestFetchSize(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_fetch_size.fdb?fetch_size=3")
+	defer conn.Close()
+
+	// more rows than a fetch
+	rows, err := conn.Query("SELECT rdb$relation_id FROM rdb$relations")
+	if err != nil {
+		t.Fatalf("Error in query: %v", err)
+	}
+	n := 0
+	for rows.Next() {
+		n++
+	}
+	rows.Close()
+
+	var count int
+	conn.QueryRow("SELECT count(*) FROM rdb$relations").Scan(&count)
+	if n != count || n <= 3 {
+		t.Errorf("fetched %d rows of %d", n, count)
+	}
+}
+
+func TestRowsAffected(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_rows_affected.fdb")
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_rows_affected (i integer)")
+	for i := 0; i < 5; i++ {
+		conn.Exec("INSERT INTO test_rows_affected (i) values (?)", i)
+	}
+
+	var tests = []struct {
+		query string
+		want  int64
+	}{
+		{"UPDATE test_rows_affected SET i = i + 10 WHERE i < 3", 3},
+		{"UPDATE test_rows_affected SET i = i WHERE i < 0", 0},
+		{"DELETE FROM test_rows_affected WHERE i >= 10", 3},
+		{"DELETE FROM test_rows_affected", 2},
+	}
+	for _, tt := range tests {
+		result, err := conn.Exec(tt.query)
+		if err != nil {
+			t.Fatalf("Error in %s: %v", tt.query, err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil || n != tt.want {
+			t.Errorf("%s: %d %v", tt.query, n, err)
+		}
+	}
+}
+
+func TestError(t *testing.T) {
+	conn, err := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_error.fdb")
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	_, err = conn.Exec("incorrect sql statement")
+	if err == nil || err.Error() != "Dynamic SQL Error\nSQL error code = -104\nToken unknown - line 1, column 1\nincorrect\n" {
+		t.Fatalf("Incorrect error")
+	}
+}
+
+func TestRole(t *testing.T) {
+	conn1, err := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_role.fdb")
+	if err != nil {
+		t.Fatalf("Error creating: %v", err)
+	}
+	conn1.Exec("CREATE TABLE test_role (f1 integer)")
+	conn1.Exec("INSERT INTO test_role (f1) values (1)")
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	conn1.Exec("CREATE ROLE DRIVERROLE")
+	if err != nil {
+		t.Fatalf("Error creating role: %v", err)
+	}
+	conn1.Exec("GRANT DRIVERROLE TO SYSDBA")
+	if err != nil {
+		t.Fatalf("Error creating role: %v", err)
+	}
+	conn1.Exec("GRANT SELECT ON test_role TO DRIVERROLE")
+	if err != nil {
+		t.Fatalf("Error granting right to role: %v", err)
+	}
+	conn1.Close()
+
+	conn2, err := sql.Open("firebirdsql", "sysdba:masterkey@localhost:3050/tmp/go_test_role.fdb?role=driverrole")
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+
+	rows, err := conn2.Query("SELECT f1 FROM test_role")
+	defer conn2.Close()
+	if err != nil {
+		t.Fatalf("Error Query: %v", err)
+	}
+
+	for rows.Next() {
+	}
+}
+
+func TestInsertTimestamp(t *testing.T) {
+	const (
+		sqlSchema = "CREATE TABLE TEST (VAL1 TIMESTAMP, VAL2 TIMESTAMP, VAL3 TIMESTAMP, VAL4 TIMESTAMP);"
+		sqlInsert = "INSERT INTO TEST (VAL1, VAL2, VAL3, VAL4) VALUES (?, ?, ?, '2015/2/9 19:25:50.7405');"
+		sqlSelect = "SELECT * FROM TEST;"
+	)
+
+	conn, err := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_timestamp.fdb")
+	if err != nil {
+		t.Fatalf("Error creating: %v", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Exec(sqlSchema)
+	if err != nil {
+		t.Fatalf("Error creating schema: %v", err)
+	}
+
+	dt1 := time.Date(2015, 2, 9, 19, 25, 50, 740500000, time.UTC)
+	dt2 := "2015/2/9 19:25:50.7405"
+	dt3 := "2015-2-9 19:25:50.7405"
+
+	if _, err = conn.Exec(sqlInsert, dt1, dt2, dt3); err != nil {
+		t.Fatalf("Error executing insert: %s", err)
+	}
+
+	var rt1, rt2, rt3, rt4 time.Time
+
+	err = conn.QueryRow(sqlSelect).Scan(&rt1, &rt2, &rt3, &rt4)
+	if err != nil {
+		t.Fatalf("Unexpected error in select: %s", err)
+	}
+
+	if rt1 != dt1 {
+		t.Errorf("Expected <%v>, got <%v>", dt1, rt1)
+	}
+	if rt2 != dt1 {
+		t.Errorf("Expected <%v>, got <%v>", dt1, rt2)
+	}
+	if rt3 != dt1 {
+		t.Errorf("Expected <%v>, got <%v>", dt1, rt3)
+	}
+	if rt4 != dt1 {
+		t.Errorf("Expected <%v>, got <%v>", dt1, rt4)
+	}
+}
+
+/*
+func TestBoolean(t *testing.T) {
+	conn, err := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_fb3.fdb")
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	var sql string
+	var n int
+
+	sql = "SELECT Count(*) FROM rdb$relations where rdb$relation_name='TEST_FB3'"
+	err = conn.QueryRow(sql).Scan(&n)
+	if err != nil {
+		t.Fatalf("Error QueryRow: %v", err)
+	}
+	if n > 0 {
+		conn.Exec("DROP TABLE test_fb3")
+	}
+
+	sql = `
+        CREATE TABLE test_fb3 (
+            b BOOLEAN
+        )
+    `
+	conn.Exec(sql)
+	conn.Exec("insert into test_fb3(b) values (true)")
+	conn.Exec("insert into test_fb3(b) values (false)")
+    var b bool
+	err = conn.QueryRow("select * from test_fb3 where b is true").Scan(&b)
+	if err != nil {
+		t.Fatalf("Error QueryRow: %v", err)
+	}
+	if b != true{
+		conn.Exec("Invalid boolean value")
+	}
+	err = conn.QueryRow("select * from test_fb3 where b is false").Scan(&b)
+	if err != nil {
+		t.Fatalf("Error QueryRow: %v", err)
+	}
+	if b != false{
+		conn.Exec("Invalid boolean value")
+	}
+
+	stmt, _ := conn.Prepare("select * from test_fb3 where b=?")
+	err = stmt.QueryRow(true).Scan(&b)
+	if err != nil {
+		t.Fatalf("Error QueryRow: %v", err)
+	}
+	if b != false{
+		conn.Exec("Invalid boolean value")
+	}
+
+	defer conn.Close()
+}
+*/
+
+func TestNullBoolean(t *testing.T) {
+	conn, err := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_null_boolean.fdb")
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_null_boolean (id integer, b boolean)")
+	conn.Exec("INSERT INTO test_null_boolean (id, b) values (1, true)")
+	conn.Exec("INSERT INTO test_null_boolean (id, b) values (2, null)")
+
+	rows, err := conn.Query("SELECT b FROM test_null_boolean ORDER BY id")
+	if err != nil {
+		t.Fatalf("Error Query: %v", err)
+	}
+	var got []sql.NullBool
+	for rows.Next() {
+		var b sql.NullBool
+		if err = rows.Scan(&b); err != nil {
+			t.Fatalf("Error Scan: %v", err)
+		}
+		got = append(got, b)
+	}
+	rows.Close()
+	expected := []sql.NullBool{{Bool: true, Valid: true}, {}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+
+	// a single row is returned by op_execute2
+	var b, b2 sql.NullBool
+	err = conn.QueryRow("UPDATE test_null_boolean SET id = 3 WHERE id = 2 RETURNING b, true").Scan(&b, &b2)
+	if err != nil {
+		t.Fatalf("Error RETURNING: %v", err)
+	}
+	if b.Valid || !b2.Valid || !b2.Bool {
+		t.Errorf("RETURNING: %v %v", b, b2)
+	}
+}
+
+func TestLegacyAuthWireCrypt(t *testing.T) {
+	var n int
+	conn, err := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_connect.fdb")
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+
+	conn, err = sql.Open("firebirdsql", "sysdba:masterkey@localhost:3050/tmp/go_test_connect.fdb?auth_plugin_name=Legacy_Auth")
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	err = conn.QueryRow("SELECT Count(*) FROM rdb$relations").Scan(&n)
+
+	conn, err = sql.Open("firebirdsql", "sysdba:masterkey@localhost:3050/tmp/go_test_connect.fdb?wire_crypt=false")
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	err = conn.QueryRow("SELECT Count(*) FROM rdb$relations").Scan(&n)
+
+	conn, err = sql.Open("firebirdsql", "sysdba:masterkey@localhost:3050/tmp/go_test_connect.fdb?auth_plugin_name=Legacy_Auth&wire_auth=true")
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	err = conn.QueryRow("SELECT Count(*) FROM rdb$relations").Scan(&n)
+	conn.Close()
+
+	conn, err = sql.Open("firebirdsql", "sysdba:masterkey@localhost:3050/tmp/go_test_connect.fdb?auth_plugin_name=Legacy_Auth&wire_auth=false")
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	err = conn.QueryRow("SELECT Count(*) FROM rdb$relations").Scan(&n)
+	conn.Close()
+}
+
+func TestSrp256(t *testing.T) {
+	conn, err := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_srp256.fdb?auth_plugin_name=Srp256")
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	var n int
+	err = conn.QueryRow("SELECT count(*) FROM rdb$database").Scan(&n)
+	conn.Close()
+	if err != nil {
+		t.Fatalf("Error in query: %v", err)
+	}
+}
+
+func TestWireCryptRequired(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_wire_crypt.fdb?wire_crypt=required")
+	var n int
+	err := conn.QueryRow("SELECT count(*) FROM rdb$database").Scan(&n)
+	conn.Close()
+	if err != nil {
+		t.Fatalf("Error in query: %v", err)
+	}
+
+	// Legacy_Auth has no session key to encrypt with
+	conn, _ = sql.Open("firebirdsql", "sysdba:masterkey@localhost:3050/tmp/go_test_wire_crypt.fdb?auth_plugin_name=Legacy_Auth&wire_crypt=required")
+	err = conn.Ping()
+	conn.Close()
+	if err == nil {
+		t.Errorf("wire_crypt=required connected without encryption")
+	}
+}
+
+func TestWireCompression(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_wire_compression.fdb?wire_compression=true")
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_compression (s VARCHAR(8000))")
+	s := strings.Repeat("compressed ", 700)
+	_, err := conn.Exec("INSERT INTO test_compression (s) values (?)", s)
+	if err != nil {
+		t.Fatalf("Error in insert: %v", err)
+	}
+	var got string
+	err = conn.QueryRow("SELECT s FROM test_compression").Scan(&got)
+	if err != nil {
+		t.Fatalf("Error in query: %v", err)
+	}
+	if got != s {
+		t.Errorf("got %d bytes", len(got))
+	}
+}
+
+func TestErrorConnect(t *testing.T) {
+	var n int
+	conn, err := sql.Open("firebirdsql", "foo:bar@something_wrong_hostname:3050/dbname")
+	if err != nil {
+		t.Fatalf("Error occured at sql.Open()")
+	}
+	err = conn.QueryRow("SELECT Count(*) FROM rdb$relations").Scan(&n)
+	if err == nil {
+		t.Fatalf("Error not occured")
+	}
+	conn.Close()
+}
+
+func TestColumnSubType(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_column_sub_type.fdb")
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_column_sub_type (t BLOB SUB_TYPE TEXT CHARACTER SET UTF8, b BLOB SUB_TYPE 0, n NUMERIC(9, 2))")
+
+	rows, err := conn.Query("SELECT t, b, n FROM test_column_sub_type")
+	if err != nil {
+		t.Fatalf("Error Query: %v", err)
+	}
+	types, _ := rows.ColumnTypes()
+	for i, name := range []string{"BLOB SUB_TYPE TEXT", "BLOB SUB_TYPE BINARY", "NUMERIC"} {
+		if types[i].DatabaseTypeName() != name {
+			t.Errorf("column %d: expected %s, got %s", i, name, types[i].DatabaseTypeName())
+		}
+	}
+	rows.Close()
+
+	c, err := conn.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	defer c.Close()
+	err = c.Raw(func(driverConn interface{}) error {
+		r, err := driverConn.(driver.QueryerContext).QueryContext(context.Background(), "SELECT t, b FROM test_column_sub_type", nil)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		st := r.(ColumnSubTyper)
+		if subType, charsetId := st.ColumnTypeSubType(0); subType != 1 || charsetId != 4 {
+			t.Errorf("text blob: got sub type %d charset %d", subType, charsetId)
+		}
+		if subType, charsetId := st.ColumnTypeSubType(1); subType != 0 || charsetId != 0 {
+			t.Errorf("binary blob: got sub type %d charset %d", subType, charsetId)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Raw: %v", err)
+	}
+}
+
+func TestColumnScanType(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_column_scan_type.fdb")
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_column_scan_type (s smallint, i integer, b bigint, n numeric(9, 2), v varchar(10), d date, x blob sub_type text)")
+
+	rows, err := conn.Query("SELECT s, i, b, n, v, d, x FROM test_column_scan_type")
+	if err != nil {
+		t.Fatalf("Error Query: %v", err)
+	}
+	defer rows.Close()
+	types, _ := rows.ColumnTypes()
+	for i, expected := range []interface{}{int16(0), int32(0), int64(0), new(big.Rat), "", time.Time{}, ""} {
+		if types[i].ScanType() != reflect.TypeOf(expected) {
+			t.Errorf("column %s: expected %T, got %v", types[i].Name(), expected, types[i].ScanType())
+		}
+	}
+}
+
+func TestColumnNullable(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_column_nullable.fdb")
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_column_nullable (a integer NOT NULL, b integer)")
+
+	rows, err := conn.Query("SELECT a, b, a + 1, COUNT(*) FROM test_column_nullable GROUP BY a, b")
+	if err != nil {
+		t.Fatalf("Error Query: %v", err)
+	}
+	defer rows.Close()
+	types, _ := rows.ColumnTypes()
+	for i, expected := range []bool{false, true, true, false} {
+		nullable, ok := types[i].Nullable()
+		if !ok || nullable != expected {
+			t.Errorf("column %d: expected nullable %v, got %v %v", i, expected, nullable, ok)
+		}
+	}
+}
+
+func TestColumnPrecisionScale(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_column_precision_scale.fdb")
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_column_precision_scale (a numeric(4, 2), b numeric(9, 4), c numeric(18, 6), d integer)")
+
+	rows, err := conn.Query("SELECT a, b, c, d FROM test_column_precision_scale")
+	if err != nil {
+		t.Fatalf("Error Query: %v", err)
+	}
+	defer rows.Close()
+	types, _ := rows.ColumnTypes()
+	for i, expected := range [][2]int64{{4, 2}, {9, 4}, {18, 6}} {
+		precision, scale, ok := types[i].DecimalSize()
+		if !ok || precision != expected[0] || scale != expected[1] {
+			t.Errorf("column %d: expected %v, got %d %d %v", i, expected, precision, scale, ok)
+		}
+	}
+	if _, _, ok := types[3].DecimalSize(); ok {
+		t.Errorf("integer column has a decimal size")
+	}
+}
+
+func TestColumnLength(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_column_length.fdb")
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_column_length (a varchar(10) CHARACTER SET UTF8, b char(3) CHARACTER SET WIN1252, c blob sub_type text, d integer)")
+
+	rows, err := conn.Query("SELECT a, b, c, d FROM test_column_length")
+	if err != nil {
+		t.Fatalf("Error Query: %v", err)
+	}
+	defer rows.Close()
+	types, _ := rows.ColumnTypes()
+	for i, expected := range []int64{10, 3, math.MaxInt64} {
+		if length, ok := types[i].Length(); !ok || length != expected {
+			t.Errorf("column %d: expected length %d, got %d %v", i, expected, length, ok)
+		}
+	}
+	if _, ok := types[3].Length(); ok {
+		t.Errorf("integer column has a length")
+	}
+}
+
+func TestCheckParamLength(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_check_param_length.fdb?check_param_length=true")
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_check_param_length (id integer, s varchar(10) CHARACTER SET UTF8)")
+
+	if _, err := conn.Exec("INSERT INTO test_check_param_length (id, s) VALUES (?, ?)", 1, "日本語の十文字の文字"); err != nil {
+		t.Fatalf("Error Exec: %v", err)
+	}
+	_, err := conn.Exec("INSERT INTO test_check_param_length (id, s) VALUES (?, ?)", 2, "0123456789abcde")
+	if err == nil || err.Error() != "firebirdsql: parameter 2 exceeds column length (got 15 chars, max 10)" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestMixedCharset(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_mixed_charset.fdb")
+	conn.Exec("CREATE TABLE test_mixed_charset (a varchar(10) CHARACTER SET WIN1252, b varchar(10) CHARACTER SET UTF8)")
+	conn.Exec("INSERT INTO test_mixed_charset (a, b) VALUES ('café', 'café')")
+	conn.Close()
+
+	conn, _ = sql.Open("firebirdsql", "sysdba:masterkey@localhost:3050/tmp/go_test_mixed_charset.fdb?charset=NONE")
+	defer conn.Close()
+	var a, b string
+	if err := conn.QueryRow("SELECT a, b FROM test_mixed_charset").Scan(&a, &b); err != nil {
+		t.Fatalf("Error QueryRow: %v", err)
+	}
+	if a != "café" || b != "café" {
+		t.Errorf("expected café, got %q and %q", a, b)
+	}
+}
+
+func TestCharsetNone(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_charset_none.fdb?charset=NONE")
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_charset_none (s varchar(10) CHARACTER SET NONE)")
+
+	raw := []byte{'c', 'a', 'f', 0xe9, 0x80, 0xff}
+	if _, err := conn.Exec("INSERT INTO test_charset_none (s) VALUES (?)", raw); err != nil {
+		t.Fatalf("Error Exec: %v", err)
+	}
+	var s string
+	if err := conn.QueryRow("SELECT s FROM test_charset_none").Scan(&s); err != nil {
+		t.Fatalf("Error QueryRow: %v", err)
+	}
+	if !bytes.Equal([]byte(s), raw) {
+		t.Errorf("expected % x, got % x", raw, []byte(s))
+	}
+}
+
+func TestStatementTimeout(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_statement_timeout.fdb")
+	defer conn.Close()
+
+	ctx := WithStatementTimeout(context.Background(), 100*time.Millisecond)
+	var n int64
+	err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM rdb$fields a, rdb$fields b, rdb$fields c").Scan(&n)
+	if !IsStatementTimeout(err) {
+		t.Fatalf("expected a statement timeout, got %v %d", err, n)
+	}
+	if err = conn.QueryRowContext(ctx, "SELECT 1 FROM rdb$database").Scan(&n); err != nil || n != 1 {
+		t.Errorf("Error QueryRow after a timeout: %v", err)
+	}
+}
+
+func TestCursorName(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_cursor_name.fdb")
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_cursor_name (id integer, s varchar(10))")
+	conn.Exec("INSERT INTO test_cursor_name (id, s) VALUES (1, 'a')")
+	conn.Exec("INSERT INTO test_cursor_name (id, s) VALUES (2, 'b')")
+	conn.Exec("INSERT INTO test_cursor_name (id, s) VALUES (3, 'c')")
+
+	ctx := context.Background()
+	c, err := conn.Conn(ctx)
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	defer c.Close()
+	tx, err := c.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	err = c.Raw(func(driverConn interface{}) error {
+		stmt, err := driverConn.(driver.ConnPrepareContext).PrepareContext(ctx, "SELECT id FROM test_cursor_name ORDER BY id FOR UPDATE")
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		if err = stmt.(CursorNamer).SetCursorName("test_cursor"); err != nil {
+			return err
+		}
+		rows, err := stmt.(driver.StmtQueryContext).QueryContext(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		dest := make([]driver.Value, 1)
+		for rows.Next(dest) == nil {
+			if dest[0] == int32(2) {
+				_, err = driverConn.(driver.ExecerContext).ExecContext(ctx, "UPDATE test_cursor_name SET s = 'x' WHERE CURRENT OF test_cursor", nil)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("positioned update: %v", err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	var s string
+	conn.QueryRow("SELECT LIST(s, ',') FROM (SELECT s FROM test_cursor_name ORDER BY id)").Scan(&s)
+	if s != "a,x,c" {
+		t.Errorf("expected a,x,c, got %s", s)
+	}
+}
+
+func TestScrollableCursor(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_scrollable_cursor.fdb")
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_scrollable_cursor (id integer)")
+	for i := 1; i <= 5; i++ {
+		conn.Exec("INSERT INTO test_scrollable_cursor (id) VALUES (?)", i)
+	}
+
+	ctx := context.Background()
+	c, err := conn.Conn(ctx)
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	defer c.Close()
+	err = c.Raw(func(driverConn interface{}) error {
+		stmt, err := driverConn.(driver.ConnPrepareContext).PrepareContext(ctx, "SELECT id FROM test_scrollable_cursor ORDER BY id")
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		if err = stmt.(Scrollable).SetScrollable(true); err != nil {
+			t.Skip(err)
+		}
+		rows, err := stmt.(driver.StmtQueryContext).QueryContext(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		s := rows.(Scroller)
+		dest := make([]driver.Value, 1)
+		var tests = []struct {
+			direction FetchDirection
+			n         int
+			expected  int32
+		}{
+			{FetchLast, 0, 5},
+			{FetchFirst, 0, 1},
+			{FetchAbsolute, 3, 3},
+			{FetchPrior, 0, 2},
+			{FetchRelative, 2, 4},
+			{FetchAbsolute, -2, 4},
+		}
+		for _, tt := range tests {
+			if err = s.Scroll(tt.direction, tt.n, dest); err != nil || dest[0] != tt.expected {
+				t.Errorf("Scroll(%d, %d): expected %d, got %v %v", tt.direction, tt.n, tt.expected, dest[0], err)
+			}
+		}
+		if err = rows.Next(dest); err != nil || dest[0] != int32(5) {
+			t.Errorf("Next: expected 5, got %v %v", dest[0], err)
+		}
+		if err = s.Scroll(FetchNext, 0, dest); err != io.EOF {
+			t.Errorf("expected io.EOF after the last row, got %v", err)
+		}
+		if err = s.Scroll(FetchPrior, 0, dest); err != nil || dest[0] != int32(5) {
+			t.Errorf("Scroll back from the end: expected 5, got %v %v", dest[0], err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Raw: %v", err)
+	}
+}
+
+func TestStatementTyper(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_statement_typer.fdb")
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_statement_typer (id integer)")
+	c, err := conn.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	defer c.Close()
+
+	var tests = []struct {
+		query    string
+		expected StatementType
+	}{
+		{"SELECT id FROM test_statement_typer", StatementSelect},
+		{"INSERT INTO test_statement_typer (id) VALUES (1)", StatementInsert},
+		{"INSERT INTO test_statement_typer (id) VALUES (1) RETURNING id", StatementExecProcedure},
+		{"DELETE FROM test_statement_typer", StatementDelete},
+		{"CREATE TABLE test_statement_typer2 (id integer)", StatementDDL},
+	}
+	for _, tt := range tests {
+		err = c.Raw(func(driverConn interface{}) error {
+			stmt, err := driverConn.(driver.ConnPrepareContext).PrepareContext(context.Background(), tt.query)
+			if err != nil {
+				return err
+			}
+			defer stmt.Close()
+			if st := stmt.(StatementTyper).StatementType(); st != tt.expected {
+				t.Errorf("%s: expected %v, got %v", tt.query, tt.expected, st)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+	}
+}
+
+func TestExecuteProcedure(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_execute_procedure.fdb")
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_execute_procedure (a integer, b varchar(10))")
+	conn.Exec(`
+		CREATE PROCEDURE test_execute_procedure_add (a integer, b varchar(10))
+		RETURNS (total integer, label varchar(20))
+		AS
+		BEGIN
+			INSERT INTO test_execute_procedure (a, b) VALUES (:a, :b);
+			SELECT SUM(a) FROM test_execute_procedure INTO :total;
+			label = b || '!';
+		END`)
+	conn.Exec(`
+		CREATE PROCEDURE test_execute_procedure_noop (a integer)
+		AS
+		BEGIN
+		END`)
+
+	var total int
+	var label string
+	err := conn.QueryRow("EXECUTE PROCEDURE test_execute_procedure_add (?, ?)", 2, "two").Scan(&total, &label)
+	if err != nil || total != 2 || label != "two!" {
+		t.Fatalf("QueryRow: %v %d %s", err, total, label)
+	}
+	_, err = conn.Exec("EXECUTE PROCEDURE test_execute_procedure_add (?, ?)", 3, "three", sql.Out{Dest: &total}, sql.Out{Dest: &label})
+	if err != nil || total != 5 || label != "three!" {
+		t.Fatalf("Exec: %v %d %s", err, total, label)
+	}
+
+	rows, err := conn.Query("EXECUTE PROCEDURE test_execute_procedure_noop (?)", 1)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if rows.Next() {
+		t.Errorf("a procedure without output parameters returned a row")
+	}
+	rows.Close()
+
+	// the QueryRow was committed
+	conn2, _ := sql.Open("firebirdsql", "sysdba:masterkey@localhost:3050/tmp/go_test_execute_procedure.fdb")
+	defer conn2.Close()
+	var n int
+	conn2.QueryRow("SELECT COUNT(*) FROM test_execute_procedure").Scan(&n)
+	if n != 2 {
+		t.Errorf("expected 2 committed rows, got %d", n)
+	}
+}
+
+func TestProcedureOutParams(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_procedure_out_params.fdb")
+	defer conn.Close()
+	conn.Exec(`
+		CREATE PROCEDURE test_procedure_out_params (a integer, b varchar(10))
+		RETURNS (a2 integer, b2 varchar(20))
+		AS
+		BEGIN
+			a2 = a * 2;
+			b2 = b || b;
+		END`)
+
+	// the IN value of sql.Out is replaced by the output parameter
+	n := 21
+	var s string
+	_, err := conn.Exec("EXECUTE PROCEDURE test_procedure_out_params (?, ?)", sql.Out{Dest: &n, In: true}, "ab", sql.Out{Dest: &s})
+	if err != nil || n != 42 || s != "abab" {
+		t.Fatalf("Exec: %v %d %s", err, n, s)
+	}
+
+	var extra int
+	_, err = conn.Exec("EXECUTE PROCEDURE test_procedure_out_params (?, ?)", 1, "x", sql.Out{Dest: &n}, sql.Out{Dest: &s}, sql.Out{Dest: &extra})
+	if err == nil {
+		t.Errorf("more sql.Out than output parameters was accepted")
+	}
+}
+
+func TestSelectableProcedure(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_selectable_procedure.fdb?fetch_size=10")
+	defer conn.Close()
+	conn.Exec(`
+		CREATE PROCEDURE test_selectable_procedure (n integer)
+		RETURNS (i integer)
+		AS
+		BEGIN
+			i = 1;
+			WHILE (i <= n) DO
+			BEGIN
+				SUSPEND;
+				i = i + 1;
+			END
+		END`)
+	conn.Exec("CREATE TABLE test_selectable_procedure_lock (id integer)")
+	conn.Exec("INSERT INTO test_selectable_procedure_lock (id) VALUES (1)")
+
+	for _, query := range []string{
+		"SELECT i FROM test_selectable_procedure(?)",
+		"EXECUTE BLOCK (n integer = ?) RETURNS (i integer) AS BEGIN i = 1; WHILE (i <= n) DO BEGIN SUSPEND; i = i + 1; END END",
+	} {
+		rows, err := conn.Query(query, 25)
+		if err != nil {
+			t.Fatalf("Error Query: %v", err)
+		}
+		var n, i int
+		for rows.Next() {
+			rows.Scan(&i)
+			n++
+			if i != n {
+				t.Errorf("row %d: got %d", n, i)
+			}
+		}
+		rows.Close()
+		if n != 25 {
+			t.Errorf("%s: expected 25 rows, got %d", query, n)
+		}
+	}
+
+	var id int
+	if err := conn.QueryRow("SELECT id FROM test_selectable_procedure_lock WITH LOCK").Scan(&id); err != nil || id != 1 {
+		t.Errorf("WITH LOCK: %v %d", err, id)
+	}
+}
+
+func TestServiceManagerBackup(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_service_backup.fdb")
+	conn.Exec("CREATE TABLE test_service_backup (id integer)")
+	conn.Exec("INSERT INTO test_service_backup (id) VALUES (1)")
+	conn.Close()
+
+	sm, err := NewServiceManager("sysdba:masterkey@localhost:3050")
+	if err != nil {
+		t.Fatalf("NewServiceManager: %v", err)
+	}
+	defer sm.Close()
+	out, err := sm.Backup("/tmp/go_test_service_backup.fdb", "/tmp/go_test_service_backup.fbk", BackupOptions{Verbose: true})
+	if err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	b, err := io.ReadAll(out)
+	if err != nil || !strings.Contains(string(b), "gbak:") {
+		t.Fatalf("Backup output: %v %s", err, b)
+	}
+	out, err = sm.Restore("/tmp/go_test_service_backup.fbk", "/tmp/go_test_service_restore.fdb", RestoreOptions{Replace: true, PageSize: 8192})
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if _, err = io.Copy(io.Discard, out); err != nil {
+		t.Fatalf("Restore output: %v", err)
+	}
+
+	conn, _ = sql.Open("firebirdsql", "sysdba:masterkey@localhost:3050/tmp/go_test_service_restore.fdb")
+	defer conn.Close()
+	var id int
+	if err = conn.QueryRow("SELECT id FROM test_service_backup").Scan(&id); err != nil || id != 1 {
+		t.Errorf("restored database: %v %d", err, id)
+	}
+}
+
+func TestServiceManagerStats(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_service_stats.fdb")
+	conn.Exec("CREATE TABLE test_service_stats (id integer)")
+	conn.Close()
+
+	sm, err := NewServiceManager("sysdba:masterkey@localhost:3050")
+	if err != nil {
+		t.Fatalf("NewServiceManager: %v", err)
+	}
+	defer sm.Close()
+	out, err := sm.DatabaseStats("/tmp/go_test_service_stats.fdb", StatsOptions{HeaderOnly: true})
+	if err != nil {
+		t.Fatalf("DatabaseStats: %v", err)
+	}
+	h, err := ParseDatabaseHeader(out)
+	if err != nil {
+		t.Fatalf("ParseDatabaseHeader: %v", err)
+	}
+	if h.PageSize == 0 || h.Dialect != 3 || h.NextTransaction == 0 {
+		t.Errorf("unexpected header %+v", h)
+	}
+}
+
+func TestServiceManagerUsers(t *testing.T) {
+	sm, err := NewServiceManager("sysdba:masterkey@localhost:3050")
+	if err != nil {
+		t.Fatalf("NewServiceManager: %v", err)
+	}
+	defer sm.Close()
+	sm.DeleteUser("GO_TEST_USER")
+	if err = sm.AddUser(User{Username: "GO_TEST_USER", Password: "secret", FirstName: "Go"}); err != nil {
+		t.Fatalf("AddUser: %v", err)
+	}
+	if err = sm.ModifyUser(User{Username: "GO_TEST_USER", LastName: "Test"}); err != nil {
+		t.Fatalf("ModifyUser: %v", err)
+	}
+	users, err := sm.Users()
+	if err != nil {
+		t.Fatalf("Users: %v", err)
+	}
+	found := false
+	for _, u := range users {
+		if u.Username == "GO_TEST_USER" {
+			found = u.FirstName == "Go" && u.LastName == "Test"
+		}
+	}
+	if !found {
+		t.Errorf("GO_TEST_USER not in %+v", users)
+	}
+
+	conn, _ := sql.Open("firebirdsql", "go_test_user:secret@localhost:3050/tmp/go_test_service_stats.fdb")
+	if err = conn.Ping(); err != nil {
+		t.Errorf("connect as GO_TEST_USER: %v", err)
+	}
+	conn.Close()
+
+	if err = sm.DeleteUser("GO_TEST_USER"); err != nil {
+		t.Errorf("DeleteUser: %v", err)
+	}
+}
+
+func TestServiceManagerProperties(t *testing.T) {
+	path := "/tmp/go_test_service_properties.fdb"
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050"+path)
+	conn.Ping()
+	conn.Close()
+
+	sm, err := NewServiceManager("sysdba:masterkey@localhost:3050")
+	if err != nil {
+		t.Fatalf("NewServiceManager: %v", err)
+	}
+	defer sm.Close()
+	if err = sm.SetSweepInterval(path, 10000); err != nil {
+		t.Fatalf("SetSweepInterval: %v", err)
+	}
+	if err = sm.SetPageBuffers(path, 512); err != nil {
+		t.Fatalf("SetPageBuffers: %v", err)
+	}
+	if err = sm.SetForcedWrites(path, false); err != nil {
+		t.Fatalf("SetForcedWrites: %v", err)
+	}
+	out, err := sm.DatabaseStats(path, StatsOptions{HeaderOnly: true})
+	if err != nil {
+		t.Fatalf("DatabaseStats: %v", err)
+	}
+	h, err := ParseDatabaseHeader(out)
+	if err != nil || h.PageBuffers != 512 || strings.Contains(h.Attributes, "force write") {
+		t.Errorf("unexpected header %+v %v", h, err)
+	}
+
+	if err = sm.Shutdown(path, ShutdownFull, ShutdownForce, 0); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	conn, _ = sql.Open("firebirdsql", "sysdba:masterkey@localhost:3050"+path)
+	if err = conn.Ping(); err == nil {
+		t.Errorf("connected to a shut down database")
+	}
+	conn.Close()
+	if err = sm.BringOnline(path, ShutdownNormal); err != nil {
+		t.Fatalf("BringOnline: %v", err)
+	}
+	conn, _ = sql.Open("firebirdsql", "sysdba:masterkey@localhost:3050"+path)
+	if err = conn.Ping(); err != nil {
+		t.Errorf("connect after BringOnline: %v", err)
+	}
+	conn.Close()
+}
+
+func TestServiceManagerLog(t *testing.T) {
+	sm, err := NewServiceManager("sysdba:masterkey@localhost:3050")
+	if err != nil {
+		t.Fatalf("NewServiceManager: %v", err)
+	}
+	defer sm.Close()
+	out, err := sm.ServerLog()
+	if err != nil {
+		t.Fatalf("ServerLog: %v", err)
+	}
+	if _, err = io.Copy(io.Discard, out); err != nil {
+		t.Errorf("ServerLog output: %v", err)
+	}
+	ids, err := sm.LimboTransactions("/tmp/go_test_service_stats.fdb")
+	if err != nil || len(ids) != 0 {
+		t.Errorf("LimboTransactions: %v %v", ids, err)
+	}
 }
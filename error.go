@@ -0,0 +1,156 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"container/list"
+	"errors"
+	"strings"
+)
+
+const (
+	isc_deadlock        = 335544336
+	isc_lock_conflict   = 335544345
+	isc_update_conflict = 335544451
+	isc_relation_lock   = 335544475
+	isc_record_lock     = 335544476
+	isc_lock_timeout    = 335544510
+	isc_read_conflict   = 335545096
+
+	isc_no_priv          = 335544352
+	isc_req_stmt_timeout = 335545266
+)
+
+// ErrStatementTimeout is returned when the statement timeout of
+// WithStatementTimeout expires on a server before Firebird 4, which
+// doesn't time out statements itself.
+var ErrStatementTimeout = errors.New("firebirdsql: statement timeout expired")
+
+// retryableCodes are the gds codes of the errors which may succeed if the
+// transaction is run again.
+var retryableCodes = []int{
+	isc_deadlock,        // deadlock
+	isc_lock_conflict,   // lock conflict on no wait transaction
+	isc_update_conflict, // update conflicts with concurrent update
+	isc_relation_lock,   // lock on table conflicts with existing lock
+	isc_record_lock,     // requested record lock conflicts with existing lock
+	isc_lock_timeout,    // lock time-out on wait transaction
+	isc_read_conflict,   // read conflicts with concurrent update
+}
+
+// FirebirdError is an error returned by the server. It keeps the gds codes
+// of the status vector, the first is the primary error.
+type FirebirdError struct {
+	codes    []int
+	sqlCode  int
+	sqlState string
+	message  string
+}
+
+func newFirebirdError(gdsCodes *list.List, sqlCode int, sqlState string, message string) *FirebirdError {
+	e := &FirebirdError{sqlCode: sqlCode, sqlState: sqlState, message: message}
+	for c := gdsCodes.Front(); c != nil; c = c.Next() {
+		e.codes = append(e.codes, c.Value.(int))
+	}
+	return e
+}
+
+// Error returns the messages of the status vector, one line each.
+func (e *FirebirdError) Error() string {
+	return e.message
+}
+
+// Code returns the primary gds code, e.g. 335544336 for deadlock.
+func (e *FirebirdError) Code() int {
+	if len(e.codes) == 0 {
+		return 0
+	}
+	return e.codes[0]
+}
+
+// Codes returns all gds codes of the status vector.
+func (e *FirebirdError) Codes() []int {
+	return e.codes
+}
+
+// HasCode reports whether code is in the status vector.
+func (e *FirebirdError) HasCode(code int) bool {
+	for _, c := range e.codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// SQLCode returns the legacy SQLCODE, e.g. -803.
+func (e *FirebirdError) SQLCode() int {
+	return e.sqlCode
+}
+
+// SQLState returns the SQLSTATE, e.g. "40001". It is empty if the server
+// doesn't send it (before Firebird 2.5).
+func (e *FirebirdError) SQLState() string {
+	return e.sqlState
+}
+
+// Messages returns the messages of the status vector.
+func (e *FirebirdError) Messages() []string {
+	return strings.Split(strings.TrimRight(e.message, "\n"), "\n")
+}
+
+// IsRetryable reports whether err is a FirebirdError caused by a concurrent
+// transaction, so the transaction can be rolled back and run again.
+// These gds codes in the status vector are retryable:
+//
+//	335544336 deadlock
+//	335544345 lock conflict on no wait transaction
+//	335544451 update conflicts with concurrent update
+//	335544475 lock on table conflicts with existing lock
+//	335544476 requested record lock conflicts with existing lock
+//	335544510 lock time-out on wait transaction
+//	335545096 read conflicts with concurrent update
+func IsRetryable(err error) bool {
+	var e *FirebirdError
+	if !errors.As(err, &e) {
+		return false
+	}
+	for _, code := range retryableCodes {
+		if e.HasCode(code) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsStatementTimeout reports whether err is the expiry of the statement
+// timeout of WithStatementTimeout, either ErrStatementTimeout or the
+// FirebirdError 335545266 (statement level timeout expired) of Firebird 4.
+func IsStatementTimeout(err error) bool {
+	if errors.Is(err, ErrStatementTimeout) {
+		return true
+	}
+	var e *FirebirdError
+	return errors.As(err, &e) && e.HasCode(isc_req_stmt_timeout)
+}
@@ -0,0 +1,106 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestFirebirdError(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	w, _ := newWireChannel(c1)
+	r, _ := newWireChannel(c2)
+	p := &wireProtocol{conn: r}
+
+	go func() {
+		w.Write(bytes.Join([][]byte{
+			bint32_to_bytes(op_response),
+			make([]byte, 16), // handle, object id, no buffer
+			bint32_to_bytes(isc_arg_gds), bint32_to_bytes(335544436),
+			bint32_to_bytes(isc_arg_number), bint32_to_bytes(-803),
+			bint32_to_bytes(isc_arg_gds), bint32_to_bytes(335544665),
+			bint32_to_bytes(isc_arg_string), xdrString("PK_T"),
+			bint32_to_bytes(isc_arg_string), xdrString("T"),
+			bint32_to_bytes(isc_arg_sql_state), xdrString("23000"),
+			bint32_to_bytes(isc_arg_end),
+		}, nil))
+	}()
+	_, _, _, err := p.opResponse()
+
+	var fbErr *FirebirdError
+	if !errors.As(fmt.Errorf("wrapped: %w", err), &fbErr) {
+		t.Fatalf("not a FirebirdError: %v", err)
+	}
+	if fbErr.Code() != 335544436 || !fbErr.HasCode(335544665) || fbErr.HasCode(335544336) {
+		t.Errorf("codes: %v", fbErr.Codes())
+	}
+	if fbErr.SQLCode() != -803 {
+		t.Errorf("SQLCode: %d", fbErr.SQLCode())
+	}
+	if fbErr.SQLState() != "23000" {
+		t.Errorf("SQLState: %s", fbErr.SQLState())
+	}
+	want := "SQL error code = -803\nviolation of PRIMARY or UNIQUE KEY constraint \"PK_T\" on table \"T\"\n"
+	if err.Error() != want {
+		t.Errorf("Error: %q", err.Error())
+	}
+	if m := fbErr.Messages(); len(m) != 2 || m[1] != "violation of PRIMARY or UNIQUE KEY constraint \"PK_T\" on table \"T\"" {
+		t.Errorf("Messages: %q", m)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	if !IsRetryable(&FirebirdError{codes: []int{isc_deadlock}, message: "deadlock\n"}) {
+		t.Error("deadlock")
+	}
+	conflict := &FirebirdError{codes: []int{isc_deadlock, isc_update_conflict, 335544878}}
+	if !IsRetryable(fmt.Errorf("firebirdsql: batch rows[1]: %w", conflict)) {
+		t.Error("wrapped update conflict")
+	}
+	if IsRetryable(&FirebirdError{codes: []int{335544665}}) {
+		t.Error("unique key violation")
+	}
+	if IsRetryable(errors.New("deadlock")) || IsRetryable(nil) {
+		t.Error("not a FirebirdError")
+	}
+}
+
+func TestIsStatementTimeout(t *testing.T) {
+	if !IsStatementTimeout(&FirebirdError{codes: []int{335544794, isc_req_stmt_timeout}}) {
+		t.Error("statement level timeout expired")
+	}
+	if !IsStatementTimeout(fmt.Errorf("report: %w", ErrStatementTimeout)) {
+		t.Error("wrapped ErrStatementTimeout")
+	}
+	if IsStatementTimeout(&FirebirdError{codes: []int{335544794}}) || IsStatementTimeout(context.DeadlineExceeded) || IsStatementTimeout(nil) {
+		t.Error("not a statement timeout")
+	}
+}
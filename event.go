@@ -0,0 +1,258 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+)
+
+const EPB_version1 = 1
+
+// Event is a notification of POST_EVENT. Count is the number of times
+// the event was posted (and committed) since the previous notification.
+type Event struct {
+	Name  string
+	Count int
+}
+
+// EventSubscription receives Firebird events on its own connection.
+//
+//	s, err := firebirdsql.NewEventSubscription(dsn, "order_created")
+//	defer s.Close()
+//	for e := range s.Events() {
+//		fmt.Println(e.Name, e.Count)
+//	}
+type EventSubscription struct {
+	fc      *firebirdsqlConn
+	aux     net.Conn
+	names   []string
+	counts  map[string]int
+	eventId int32
+
+	mu       sync.Mutex // guards fc.wp between the receiver and Close
+	events   chan Event
+	done     chan struct{}
+	finished chan struct{}
+	closed   bool
+}
+
+// NewEventSubscription connects to dsn and registers interest in the
+// named events. The events are delivered to Events() until Close.
+func NewEventSubscription(dsn string, names ...string) (s *EventSubscription, err error) {
+	if len(names) == 0 {
+		return nil, errors.New("firebirdsql: no event name")
+	}
+	for _, name := range names {
+		if len(name) == 0 || len(name) > 255 {
+			return nil, fmt.Errorf("firebirdsql: invalid event name %s", name)
+		}
+	}
+	fc, err := newFirebirdsqlConn(dsn)
+	if err != nil {
+		return
+	}
+	s = &EventSubscription{
+		fc:       fc,
+		names:    names,
+		counts:   make(map[string]int),
+		eventId:  1,
+		events:   make(chan Event, len(names)),
+		done:     make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+
+	port, err := fc.wp.opConnectRequest()
+	if err != nil {
+		fc.Close()
+		return nil, err
+	}
+	// The server answers its own address, which may not be reachable
+	// from here, so only the port is used.
+	host, _, _ := net.SplitHostPort(fc.wp.addr)
+	s.aux, err = dialServer(context.Background(), fc.dsn, net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		fc.Close()
+		return nil, err
+	}
+
+	// The first notification has the current counts
+	err = s.queue()
+	if err == nil {
+		var counts map[string]int
+		counts, err = s.waitEvent()
+		for name, n := range counts {
+			s.counts[name] = n
+		}
+	}
+	if err != nil {
+		s.aux.Close()
+		fc.Close()
+		return nil, err
+	}
+
+	go s.receive()
+	return s, nil
+}
+
+// Events returns the channel of the notifications. It is closed when
+// the subscription is closed or its connection is lost.
+func (s *EventSubscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close cancels the events and closes the connections.
+func (s *EventSubscription) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	s.fc.wp.opCancelEvents(s.eventId)
+	_, _, _, err := s.fc.wp.opResponse()
+	s.mu.Unlock()
+
+	s.aux.Close()
+	<-s.finished
+	s.fc.Close()
+	return err
+}
+
+func (s *EventSubscription) epb() []byte {
+	epb := []byte{EPB_version1}
+	for _, name := range s.names {
+		epb = append(epb, byte(len(name)))
+		epb = append(epb, name...)
+		epb = append(epb, int32_to_bytes(int32(s.counts[name]))...)
+	}
+	return epb
+}
+
+func (s *EventSubscription) queue() (err error) {
+	s.fc.wp.opQueEvents(s.epb(), s.eventId)
+	_, _, _, err = s.fc.wp.opResponse()
+	return
+}
+
+func (s *EventSubscription) recv(n int) ([]byte, error) {
+	buf := make([]byte, n)
+	_, err := io.ReadFull(s.aux, buf)
+	return buf, err
+}
+
+// waitEvent reads an op_event from the auxiliary connection, and
+// returns the counts in it.
+func (s *EventSubscription) waitEvent() (map[string]int, error) {
+	for {
+		b, err := s.recv(4)
+		if err != nil {
+			return nil, err
+		}
+		switch bytes_to_bint32(b) {
+		case op_dummy:
+			continue
+		case op_exit, op_disconnect:
+			return nil, io.EOF
+		case op_event:
+		default:
+			return nil, fmt.Errorf("firebirdsql: unexpected operation %d in event connection", bytes_to_bint32(b))
+		}
+
+		b, err = s.recv(8) // db handle, buffer length
+		if err != nil {
+			return nil, err
+		}
+		ln := int(bytes_to_bint32(b[4:8]))
+		buf, err := s.recv(ln + (4-ln%4)%4)
+		if err != nil {
+			return nil, err
+		}
+		_, err = s.recv(12) // ast, event id
+		if err != nil {
+			return nil, err
+		}
+		return parseEventBuffer(buf[:ln])
+	}
+}
+
+func parseEventBuffer(buf []byte) (map[string]int, error) {
+	if len(buf) == 0 || buf[0] != EPB_version1 {
+		return nil, errors.New("firebirdsql: invalid event buffer")
+	}
+	counts := make(map[string]int)
+	for i := 1; i < len(buf); {
+		ln := int(buf[i])
+		if i+1+ln+4 > len(buf) {
+			return nil, errors.New("firebirdsql: invalid event buffer")
+		}
+		name := bytes_to_str(buf[i+1 : i+1+ln])
+		counts[name] = int(bytes_to_int32(buf[i+1+ln : i+1+ln+4]))
+		i += 1 + ln + 4
+	}
+	return counts, nil
+}
+
+// receive delivers the differences of the counts, and queues the events
+// again for the next notification.
+func (s *EventSubscription) receive() {
+	defer close(s.finished)
+	defer close(s.events)
+	for {
+		counts, err := s.waitEvent()
+		if err != nil {
+			return
+		}
+		for _, name := range s.names {
+			n, ok := counts[name]
+			if !ok || n <= s.counts[name] {
+				continue
+			}
+			e := Event{Name: name, Count: n - s.counts[name]}
+			s.counts[name] = n
+			select {
+			case s.events <- e:
+			case <-s.done:
+				return
+			}
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return
+		}
+		err = s.queue()
+		s.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
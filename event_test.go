@@ -0,0 +1,91 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"bytes"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestParseEventBuffer(t *testing.T) {
+	buf := bytes.Join([][]byte{
+		[]byte{EPB_version1},
+		[]byte{3}, []byte("foo"), []byte{2, 0, 0, 0},
+		[]byte{6}, []byte("barbaz"), []byte{0, 1, 0, 0},
+	}, nil)
+	counts, err := parseEventBuffer(buf)
+	if err != nil {
+		t.Fatalf("parseEventBuffer: %v", err)
+	}
+	if counts["foo"] != 2 || counts["barbaz"] != 256 || len(counts) != 2 {
+		t.Errorf("counts: %v", counts)
+	}
+
+	if _, err = parseEventBuffer(buf[:len(buf)-1]); err == nil {
+		t.Errorf("truncated buffer was accepted")
+	}
+	if _, err = parseEventBuffer([]byte{0}); err == nil {
+		t.Errorf("invalid version was accepted")
+	}
+}
+
+func TestEventSubscription(t *testing.T) {
+	conn, _ := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_event.fdb")
+	defer conn.Close()
+	conn.Exec("SELECT 1 FROM rdb$database")
+
+	s, err := NewEventSubscription("sysdba:masterkey@localhost:3050/tmp/go_test_event.fdb", "event_a", "event_b")
+	if err != nil {
+		t.Fatalf("NewEventSubscription: %v", err)
+	}
+
+	conn.Exec("EXECUTE BLOCK AS BEGIN POST_EVENT 'event_a'; POST_EVENT 'event_a'; END")
+	select {
+	case e := <-s.Events():
+		if e.Name != "event_a" || e.Count != 2 {
+			t.Errorf("event: %v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("event was not delivered")
+	}
+
+	conn.Exec("EXECUTE BLOCK AS BEGIN POST_EVENT 'event_b'; END")
+	select {
+	case e := <-s.Events():
+		if e.Name != "event_b" || e.Count != 1 {
+			t.Errorf("event: %v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("event was not delivered")
+	}
+
+	if err = s.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	if _, ok := <-s.Events(); ok {
+		t.Errorf("Events() is not closed")
+	}
+}
@@ -0,0 +1,86 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+var logger struct {
+	sync.RWMutex
+	log     func(level string, msg string)
+	verbose bool
+}
+
+// SetLogger sets the logger of the wire protocol of all the connections,
+// and nil stops it. The level is "debug" for the operations, with the
+// prepared SQL, the parameter counts and the round trip times, and "trace"
+// for the packets and the parameter values, which are only logged after
+// SetLogVerbose(true).
+func SetLogger(log func(level string, msg string)) {
+	logger.Lock()
+	logger.log = log
+	logger.Unlock()
+}
+
+// SetLogVerbose sets whether the packets and the parameter values, which
+// may have passwords and other sensitive data, are logged.
+func SetLogVerbose(verbose bool) {
+	logger.Lock()
+	logger.verbose = verbose
+	logger.Unlock()
+}
+
+func currentLogger() (func(level string, msg string), bool) {
+	logger.RLock()
+	defer logger.RUnlock()
+	return logger.log, logger.verbose
+}
+
+func debugPrint(p *wireProtocol, s string) {
+	if log, _ := currentLogger(); log != nil {
+		log("debug", fmt.Sprintf("[%p] %s", p, s))
+	}
+}
+
+// tracePrint logs the message of f, which may have sensitive data, when
+// the logger is verbose.
+func tracePrint(p *wireProtocol, f func() string) {
+	if log, verbose := currentLogger(); log != nil && verbose {
+		log("trace", fmt.Sprintf("[%p] %s", p, f()))
+	}
+}
+
+// roundTripPrint logs the time since the last packets were sent, under the
+// write lock as opCancel may send while a response is awaited.
+func roundTripPrint(p *wireProtocol, op string) {
+	if log, _ := currentLogger(); log != nil {
+		p.writeMutex.Lock()
+		elapsed := time.Since(p.sentAt)
+		p.writeMutex.Unlock()
+		log("debug", fmt.Sprintf("[%p] %s():%v", p, op, elapsed))
+	}
+}
@@ -0,0 +1,65 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"database/sql/driver"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	var logs []string
+	SetLogger(func(level string, msg string) {
+		logs = append(logs, level+" "+msg)
+	})
+	defer SetLogger(nil)
+
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	go io.Copy(io.Discard, c1)
+	p := &wireProtocol{dsn: &firebirdDsn{dialect: 3}, protocolVersion: PROTOCOL_VERSION13}
+	p.conn, _ = newWireChannel(c2)
+
+	p.opExecute(1, 2, []driver.Value{"secret"}, nil)
+	all := strings.Join(logs, "\n")
+	if !strings.Contains(all, "debug") || !strings.Contains(all, "opExecute():2,1,1 params") {
+		t.Errorf("no operation in %q", all)
+	}
+	if strings.Contains(all, "secret") || strings.Contains(all, "trace") {
+		t.Errorf("a parameter value is logged: %q", all)
+	}
+
+	logs = nil
+	SetLogVerbose(true)
+	defer SetLogVerbose(false)
+	p.opExecute(1, 2, []driver.Value{"secret"}, nil)
+	all = strings.Join(logs, "\n")
+	if !strings.Contains(all, "trace") || !strings.Contains(all, "secret") {
+		t.Errorf("no parameter value in %q", all)
+	}
+}
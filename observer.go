@@ -0,0 +1,90 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"time"
+)
+
+// QueryOp is the phase of a statement in a QueryEvent.
+type QueryOp string
+
+const (
+	QueryOpPrepare QueryOp = "prepare"
+	QueryOpExec    QueryOp = "exec"
+	QueryOpQuery   QueryOp = "query"
+)
+
+// QueryEvent is a statement observed by a QueryObserver. Duration,
+// RowsAffected and Err are set for QueryEnd, and RowsAffected is -1 but
+// for an exec.
+type QueryEvent struct {
+	Op           QueryOp
+	SQL          string
+	Duration     time.Duration
+	RowsAffected int64
+	Err          error
+}
+
+// QueryObserver is called around the prepares, execs and queries of the
+// connections of a Connector, e.g. to emit spans or metrics. The context
+// returned by QueryStart, which can carry a span, is given to QueryEnd.
+// A query ends when it is executed, before its rows are fetched, and the
+// prepare of an exec or a query which is not in the statement cache is
+// observed within it.
+type QueryObserver interface {
+	QueryStart(ctx context.Context, event QueryEvent) context.Context
+	QueryEnd(ctx context.Context, event QueryEvent)
+}
+
+// observe runs f, which returns the rows affected, as the op of query for
+// the observer of the DSN, if any. f is given the context returned by
+// QueryStart, so a prepare within it is observed as its child.
+func (d *firebirdDsn) observe(ctx context.Context, op QueryOp, query string, f func(ctx context.Context) (int64, error)) error {
+	if d.observer == nil {
+		_, err := f(ctx)
+		return err
+	}
+	event := QueryEvent{Op: op, SQL: query, RowsAffected: -1}
+	ctx = d.observer.QueryStart(ctx, event)
+	start := time.Now()
+	event.RowsAffected, event.Err = f(ctx)
+	event.Duration = time.Since(start)
+	d.observer.QueryEnd(ctx, event)
+	return event.Err
+}
+
+// rowsAffected is the count of an exec result for observe.
+func rowsAffected(result driver.Result, err error) (int64, error) {
+	if err != nil {
+		return -1, err
+	}
+	n, rerr := result.RowsAffected()
+	if rerr != nil {
+		n = -1
+	}
+	return n, nil
+}
@@ -0,0 +1,89 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type observerKey struct{}
+
+type testObserver struct {
+	started []QueryEvent
+	ended   []QueryEvent
+	ctxOk   bool
+}
+
+func (o *testObserver) QueryStart(ctx context.Context, event QueryEvent) context.Context {
+	o.started = append(o.started, event)
+	return context.WithValue(ctx, observerKey{}, len(o.started))
+}
+
+func (o *testObserver) QueryEnd(ctx context.Context, event QueryEvent) {
+	o.ended = append(o.ended, event)
+	o.ctxOk = ctx.Value(observerKey{}) == len(o.started)
+}
+
+func TestObserve(t *testing.T) {
+	o := &testObserver{}
+	d := &firebirdDsn{observer: o}
+	var fCtx context.Context
+	err := d.observe(context.Background(), QueryOpExec, "UPDATE t SET a = 1", func(ctx context.Context) (int64, error) {
+		fCtx = ctx
+		return 3, nil
+	})
+	if err != nil || len(o.started) != 1 || len(o.ended) != 1 || !o.ctxOk {
+		t.Fatalf("unexpected events %v %v %v", o.started, o.ended, err)
+	}
+	if o.started[0].SQL != "UPDATE t SET a = 1" || o.started[0].Op != QueryOpExec || o.started[0].RowsAffected != -1 {
+		t.Errorf("unexpected start %+v", o.started[0])
+	}
+	if o.ended[0].RowsAffected != 3 || o.ended[0].Err != nil {
+		t.Errorf("unexpected end %+v", o.ended[0])
+	}
+	if fCtx.Value(observerKey{}) != 1 {
+		t.Errorf("f is not given the context of QueryStart")
+	}
+
+	failure := errors.New("failure")
+	err = d.observe(context.Background(), QueryOpQuery, "SELECT", func(ctx context.Context) (int64, error) {
+		return -1, failure
+	})
+	if err != failure || o.ended[1].Err != failure || o.ended[1].Op != QueryOpQuery {
+		t.Errorf("unexpected end %+v %v", o.ended[1], err)
+	}
+
+	// without an observer f is just run
+	d.observer = nil
+	ran := false
+	d.observe(context.Background(), QueryOpPrepare, "SELECT", func(ctx context.Context) (int64, error) {
+		ran = true
+		return -1, nil
+	})
+	if !ran {
+		t.Errorf("f is not run without an observer")
+	}
+}
@@ -23,15 +23,121 @@ CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
 
 package firebirdsql
 
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+)
+
 type firebirdsqlResult struct {
 	affectedRows int64
+	returning    []driver.Value
 }
 
+// LastInsertId returns the first integer column of the RETURNING clause.
+// Firebird does not have default ID, so it is -1 without RETURNING.
 func (res *firebirdsqlResult) LastInsertId() (int64, error) {
-	// Firebird does not have default ID.
+	for _, v := range res.returning {
+		switch i := v.(type) {
+		case int16:
+			return int64(i), nil
+		case int32:
+			return int64(i), nil
+		case int64:
+			return i, nil
+		}
+	}
 	return -1, nil
 }
 
 func (res *firebirdsqlResult) RowsAffected() (int64, error) {
 	return res.affectedRows, nil
 }
+
+// splitOutArgs removes sql.Out arguments, which receive the RETURNING
+// values or the output parameters of EXECUTE PROCEDURE. The current value
+// of an sql.Out with In set is sent in place of the argument.
+func splitOutArgs(args []driver.Value) ([]driver.Value, []sql.Out, error) {
+	var outs []sql.Out
+	values := make([]driver.Value, 0, len(args))
+	for _, arg := range args {
+		out, ok := arg.(sql.Out)
+		if !ok {
+			values = append(values, arg)
+			continue
+		}
+		if out.In {
+			v, err := outInValue(out, len(outs))
+			if err != nil {
+				return nil, nil, err
+			}
+			values = append(values, v)
+		}
+		outs = append(outs, out)
+	}
+	return values, outs, nil
+}
+
+// outInValue returns the input value of the sql.Out i with In set.
+func outInValue(out sql.Out, i int) (driver.Value, error) {
+	dest := reflect.ValueOf(out.Dest)
+	if dest.Kind() != reflect.Ptr || dest.IsNil() {
+		return nil, fmt.Errorf("firebirdsql: sql.Out.Dest %d is not a pointer", i)
+	}
+	dest = dest.Elem()
+	if dest.Kind() == reflect.Ptr || dest.Kind() == reflect.Interface {
+		if dest.IsNil() {
+			return nil, nil
+		}
+	}
+	// as CheckNamedValue, the driver types are sent as they are
+	switch v := dest.Interface().(type) {
+	case Decimal, Date, Time, io.Reader, *big.Int, *big.Rat:
+		return v, nil
+	case NullDecimal:
+		if v.Valid {
+			return v.Decimal, nil
+		}
+		return nil, nil
+	}
+	if isArrayArg(dest.Interface()) {
+		return dest.Interface(), nil
+	}
+	// and the others are converted like database/sql does
+	return driver.DefaultParameterConverter.ConvertValue(dest.Interface())
+}
+
+// assignOutArgs stores the output values to the sql.Out destinations in order.
+func assignOutArgs(outs []sql.Out, returning []driver.Value) error {
+	if len(outs) > len(returning) {
+		return fmt.Errorf("firebirdsql: %d sql.Out arguments for %d output columns", len(outs), len(returning))
+	}
+	for i, out := range outs {
+		dest := reflect.ValueOf(out.Dest)
+		if dest.Kind() != reflect.Ptr || dest.IsNil() {
+			return fmt.Errorf("firebirdsql: sql.Out.Dest %d is not a pointer", i)
+		}
+		dest = dest.Elem()
+		if returning[i] == nil {
+			dest.Set(reflect.Zero(dest.Type()))
+			continue
+		}
+		v := reflect.ValueOf(returning[i])
+		switch {
+		case v.Type().AssignableTo(dest.Type()):
+			dest.Set(v)
+		case dest.Kind() == reflect.String && v.Kind() != reflect.Slice:
+			dest.SetString(fmt.Sprint(returning[i]))
+		case v.Type().ConvertibleTo(dest.Type()) && dest.Kind() != reflect.String:
+			dest.Set(v.Convert(dest.Type()))
+		case v.Kind() == reflect.Slice && dest.Kind() == reflect.String:
+			dest.SetString(string(v.Bytes()))
+		default:
+			return fmt.Errorf("firebirdsql: can't store %T to sql.Out.Dest %d (%s)", returning[i], i, dest.Type())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+)
+
+func TestLastInsertId(t *testing.T) {
+	res := &firebirdsqlResult{returning: []driver.Value{"abc", int32(10), int64(20)}}
+	if id, _ := res.LastInsertId(); id != 10 {
+		t.Errorf("LastInsertId: %d", id)
+	}
+	res = &firebirdsqlResult{}
+	if id, _ := res.LastInsertId(); id != -1 {
+		t.Errorf("LastInsertId without RETURNING: %d", id)
+	}
+}
+
+func TestOutArgs(t *testing.T) {
+	var id int64
+	var name string
+	var raw []byte
+	var any interface{}
+	args, outs, err := splitOutArgs([]driver.Value{
+		int64(1), sql.Out{Dest: &id}, "x", sql.Out{Dest: &name}, sql.Out{Dest: &raw}, sql.Out{Dest: &any},
+	})
+	if err != nil || len(args) != 2 || args[0] != int64(1) || args[1] != "x" || len(outs) != 4 {
+		t.Fatalf("splitOutArgs: %v %v %v", args, outs, err)
+	}
+
+	err = assignOutArgs(outs, []driver.Value{int32(5), int32(7), []byte("abc"), nil})
+	if err != nil {
+		t.Fatalf("assignOutArgs: %v", err)
+	}
+	if id != 5 || name != "7" || string(raw) != "abc" || any != nil {
+		t.Errorf("assignOutArgs: %v %v %v %v", id, name, raw, any)
+	}
+
+	if err = assignOutArgs(outs, []driver.Value{int32(5)}); err == nil {
+		t.Errorf("more sql.Out than RETURNING columns was accepted")
+	}
+	if err = assignOutArgs([]sql.Out{{Dest: id}}, []driver.Value{int32(5)}); err == nil {
+		t.Errorf("non pointer sql.Out.Dest was accepted")
+	}
+}
+
+func TestInOutArgs(t *testing.T) {
+	total := 3
+	var label *string
+	var d Decimal = "1.5"
+	args, outs, err := splitOutArgs([]driver.Value{
+		sql.Out{Dest: &total, In: true}, "x", sql.Out{Dest: &label, In: true}, sql.Out{Dest: &d, In: true},
+	})
+	if err != nil || len(args) != 4 || args[0] != int64(3) || args[1] != "x" || args[2] != nil || args[3] != Decimal("1.5") || len(outs) != 3 {
+		t.Fatalf("splitOutArgs: %v %v %v", args, outs, err)
+	}
+	type level int8
+	var name = "a"
+	var ref *string = &name
+	var u uint16 = 7
+	var l level = 2
+	var n sql.NullString
+	args, _, err = splitOutArgs([]driver.Value{
+		sql.Out{Dest: &ref, In: true}, sql.Out{Dest: &u, In: true}, sql.Out{Dest: &l, In: true}, sql.Out{Dest: &n, In: true},
+	})
+	if err != nil || args[0] != "a" || args[1] != int64(7) || args[2] != int64(2) || args[3] != nil {
+		t.Errorf("converted sql.Out values: %v %v", args, err)
+	}
+	if _, _, err = splitOutArgs([]driver.Value{sql.Out{Dest: total, In: true}}); err == nil {
+		t.Errorf("non pointer sql.Out.Dest was accepted")
+	}
+}
@@ -26,9 +26,39 @@ package firebirdsql
 import (
 	"container/list"
 	"database/sql/driver"
+	"errors"
 	"io"
+	"math"
+	"reflect"
 )
 
+// ColumnSubTyper is implemented by the driver rows for the sub type and
+// character set id of a column, which database/sql doesn't pass through.
+// Get the driver rows with sql.Conn.Raw and driver.QueryerContext.
+type ColumnSubTyper interface {
+	ColumnTypeSubType(index int) (subType int, charsetId int)
+}
+
+// FetchDirection is the row Scroll moves to.
+type FetchDirection int
+
+const (
+	FetchNext     FetchDirection = fetch_next
+	FetchPrior    FetchDirection = fetch_prior
+	FetchFirst    FetchDirection = fetch_first
+	FetchLast     FetchDirection = fetch_last
+	FetchAbsolute FetchDirection = fetch_absolute // the row n, from the last one if negative
+	FetchRelative FetchDirection = fetch_relative // n rows after the current one, before if negative
+)
+
+// Scroller is implemented by the driver rows. Scroll moves the cursor of
+// a scrollable statement (see Scrollable) to the row of direction and n,
+// and reads it to dest. It returns io.EOF if there's no row there, and
+// Next reads on from the new position.
+type Scroller interface {
+	Scroll(direction FetchDirection, n int, dest []driver.Value) error
+}
+
 type firebirdsqlRows struct {
 	stmt            *firebirdsqlStmt
 	currentChunkRow *list.Element
@@ -40,7 +70,7 @@ func newFirebirdsqlRows(stmt *firebirdsqlStmt, result []driver.Value) *firebirds
 	rows := new(firebirdsqlRows)
 	rows.stmt = stmt
 	rows.result = result
-	if stmt.stmtType == isc_info_sql_stmt_select {
+	if stmt.hasCursor() {
 		rows.moreData = true
 	}
 	return rows
@@ -54,17 +84,126 @@ func (rows *firebirdsqlRows) Columns() []string {
 	return columns
 }
 
+// ColumnTypeDatabaseTypeName returns the SQL type name of the column,
+// e.g. VARCHAR, NUMERIC or BLOB SUB_TYPE TEXT.
+func (rows *firebirdsqlRows) ColumnTypeDatabaseTypeName(index int) string {
+	return rows.stmt.xsqlda[index].typeName()
+}
+
+// ColumnTypeScanType returns the Go type of the column values. A content
+// BLOB longer than max_inline_blob is a *BlobReader instead.
+func (rows *firebirdsqlRows) ColumnTypeScanType(index int) reflect.Type {
+	return rows.stmt.xsqlda[index].scanType(rows.stmt.wp.dsn)
+}
+
+// ColumnTypeNullable reports whether the column may be NULL, as described
+// by the server when the statement is prepared. Expressions and aggregates
+// are nullable whatever their arguments.
+func (rows *firebirdsqlRows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	return rows.stmt.xsqlda[index].null_ok, true
+}
+
+// ColumnTypePrecisionScale returns the precision and scale of NUMERIC and
+// DECIMAL columns. The precision is the one of the storage, e.g. 9 for
+// NUMERIC(5, 2).
+func (rows *firebirdsqlRows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+	x := rows.stmt.xsqlda[index]
+	p, ok := x.precision()
+	if !ok {
+		return 0, 0, false
+	}
+	return int64(p), int64(-x.sqlscale), true
+}
+
+// ColumnTypeLength returns the declared length in characters of CHAR and
+// VARCHAR columns, and math.MaxInt64 for BLOB columns, which have no limit.
+func (rows *firebirdsqlRows) ColumnTypeLength(index int) (length int64, ok bool) {
+	x := rows.stmt.xsqlda[index]
+	switch x.sqltype {
+	case SQL_TYPE_TEXT, SQL_TYPE_VARYING:
+		return int64(x.charLength()), true
+	case SQL_TYPE_BLOB:
+		return math.MaxInt64, true
+	}
+	return 0, false
+}
+
+// ColumnTypeSubType returns the sub type of the column (the BLOB sub type,
+// or 1 NUMERIC and 2 DECIMAL), and the character set id of CHAR, VARCHAR
+// and text BLOB columns. See ColumnSubTyper.
+func (rows *firebirdsqlRows) ColumnTypeSubType(index int) (subType int, charsetId int) {
+	x := rows.stmt.xsqlda[index]
+	if x.sqltype == SQL_TYPE_TEXT || x.sqltype == SQL_TYPE_VARYING {
+		return 0, x.charsetId()
+	}
+	return x.sqlsubtype, x.charsetId()
+}
+
 func (rows *firebirdsqlRows) Close() (er error) {
+	rows.stmt.reportStats()
 	rows.stmt.Close()
 	return
 }
 
+// HasNextResultSet implements driver.RowsNextResultSet. A Firebird
+// statement, EXECUTE BLOCK included, has one result set at most, and a
+// query of several statements is refused by the server, so there's never
+// a next one.
+func (rows *firebirdsqlRows) HasNextResultSet() bool {
+	return false
+}
+
+// NextResultSet implements driver.RowsNextResultSet, see HasNextResultSet.
+func (rows *firebirdsqlRows) NextResultSet() error {
+	return io.EOF
+}
+
+func (rows *firebirdsqlRows) columnValue(i int, v driver.Value) (driver.Value, error) {
+	x := rows.stmt.xsqlda[i]
+	if x.sqltype == SQL_TYPE_ARRAY && v != nil {
+		return rows.stmt.arrayValue(i, v.([]byte))
+	}
+	if x.sqltype != SQL_TYPE_BLOB || v == nil || rows.stmt.wp.dsn.blobMode == BLOB_MODE_ID {
+		return v, nil
+	}
+	blobId := v.([]byte)
+	if rows.stmt.wp.dsn.blobMode == BLOB_MODE_STREAM {
+		return newBlobReader(rows.stmt.wp, rows.stmt.tx.transHandle, blobId), nil
+	}
+	var blob []byte
+	var err error
+	if max := rows.stmt.wp.dsn.maxInlineBlob; max > 0 {
+		r := newBlobReader(rows.stmt.wp, rows.stmt.tx.transHandle, blobId)
+		var n int
+		n, err = r.length()
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		if n > max {
+			return r, nil
+		}
+		blob, err = io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		blob, err = rows.stmt.wp.getBlobSegments(blobId, rows.stmt.tx.transHandle)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if x.sqlsubtype == 1 {
+		return bytes_to_str(blob), nil
+	}
+	return blob, nil
+}
+
 func (rows *firebirdsqlRows) Next(dest []driver.Value) (err error) {
 	if rows.stmt.stmtType == isc_info_sql_stmt_exec_procedure {
 		if rows.result != nil {
-			for i, v := range rows.result {
-				dest[i] = v
-			}
+			copy(dest, rows.result)
 			rows.result = nil
 		} else {
 			err = io.EOF
@@ -80,34 +219,53 @@ func (rows *firebirdsqlRows) Next(dest []driver.Value) (err error) {
 	if rows.currentChunkRow == nil && rows.moreData == true {
 		// Get one chunk
 		var chunk *list.List
-		rows.stmt.wp.opFetch(rows.stmt.stmtHandle, rows.stmt.blr)
+		defer rows.stmt.collectStats()()
+		fetchSize := rows.stmt.wp.dsn.fetchSize
+		if rows.stmt.cursorName != "" || rows.stmt.scrollable {
+			// the positioned UPDATE and DELETE and Scroll see the row of Next
+			fetchSize = 1
+		}
+		rows.stmt.wp.opFetch(rows.stmt.stmtHandle, rows.stmt.blr, int32(fetchSize))
 		chunk, rows.moreData, err = rows.stmt.wp.opFetchResponse(rows.stmt.stmtHandle, rows.stmt.tx.transHandle, rows.stmt.xsqlda)
-
-		if err == nil {
-			rows.currentChunkRow = chunk.Front()
+		if err != nil {
+			// not io.EOF, the rows are not all fetched
+			rows.moreData = false
+			return
 		}
+		rows.currentChunkRow = chunk.Front()
 	}
 
-	if rows.currentChunkRow == nil {
-		err = io.EOF
+	return rows.scanRow(dest)
+}
+
+func (rows *firebirdsqlRows) Scroll(direction FetchDirection, n int, dest []driver.Value) (err error) {
+	if !rows.stmt.scrollable || !rows.stmt.hasCursor() {
+		return errors.New("firebirdsql: the cursor is not scrollable")
+	}
+	defer rows.stmt.collectStats()()
+	rows.stmt.wp.opFetchScroll(rows.stmt.stmtHandle, rows.stmt.blr, int32(direction), int32(n))
+	chunk, _, err := rows.stmt.wp.opFetchResponse(rows.stmt.stmtHandle, rows.stmt.tx.transHandle, rows.stmt.xsqlda)
+	if err != nil {
 		return
 	}
+	// the end in a direction is not the end of the others
+	rows.currentChunkRow = chunk.Front()
+	rows.moreData = true
+	return rows.scanRow(dest)
+}
+
+// scanRow decodes the current row to dest, or returns io.EOF if there's
+// none.
+func (rows *firebirdsqlRows) scanRow(dest []driver.Value) (err error) {
+	if rows.currentChunkRow == nil {
+		return io.EOF
+	}
 	row, _ := rows.currentChunkRow.Value.([]driver.Value)
 	for i, v := range row {
-		if rows.stmt.xsqlda[i].sqltype == SQL_TYPE_BLOB && v != nil {
-			blobId := v.([]byte)
-			var blob []byte
-			blob, err = rows.stmt.wp.getBlobSegments(blobId, rows.stmt.tx.transHandle)
-			if rows.stmt.xsqlda[i].sqlsubtype == 1 {
-				dest[i] = bytes_to_str(blob)
-			} else {
-				dest[i] = blob
-			}
-
-		} else {
-			dest[i] = v
+		dest[i], err = rows.columnValue(i, v)
+		if err != nil {
+			return
 		}
 	}
-
 	return
 }
@@ -0,0 +1,212 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"io"
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fetchRows returns the rows of a SELECT of xsqlda whose fetch is answered
+// with response by a fake server, which then closes the connection.
+func fetchRows(t *testing.T, xsqlda []xSQLVAR, response []byte) *firebirdsqlRows {
+	c1, c2 := net.Pipe()
+	t.Cleanup(func() {
+		c1.Close()
+		c2.Close()
+	})
+	go func() {
+		buf := make([]byte, BUFFER_LEN)
+		c1.Read(buf) // op_fetch
+		c1.Write(response)
+		c1.Close()
+	}()
+	p := &wireProtocol{dsn: &firebirdDsn{fetchSize: DEFAULT_FETCH_SIZE}, protocolVersion: PROTOCOL_VERSION13}
+	p.conn, _ = newWireChannel(c2)
+	stmt := &firebirdsqlStmt{wp: p, tx: &firebirdsqlTx{}, xsqlda: xsqlda, stmtType: isc_info_sql_stmt_select}
+	return &firebirdsqlRows{stmt: stmt, moreData: true}
+}
+
+// fetchResponse is the op_fetch_response of the rows of one column, in
+// protocol 13, with the end of the cursor.
+func fetchResponse(values ...[]byte) []byte {
+	var b []byte
+	for _, v := range values {
+		b = append(b, bint32_to_bytes(op_fetch_response)...)
+		b = append(b, bint32_to_bytes(0)...)
+		b = append(b, bint32_to_bytes(1)...)
+		b = append(b, 0, 0, 0, 0) // null indicator
+		b = append(b, v...)
+	}
+	b = append(b, bint32_to_bytes(op_fetch_response)...)
+	b = append(b, bint32_to_bytes(100)...)
+	return append(b, bint32_to_bytes(0)...)
+}
+
+func TestColumnTypeLength(t *testing.T) {
+	rows := &firebirdsqlRows{stmt: &firebirdsqlStmt{xsqlda: []xSQLVAR{
+		{sqltype: SQL_TYPE_VARYING, sqlsubtype: 4, sqllen: 40},
+		{sqltype: SQL_TYPE_TEXT, sqllen: 10},
+		{sqltype: SQL_TYPE_BLOB, sqlsubtype: 1},
+		{sqltype: SQL_TYPE_LONG, sqllen: 4},
+	}}}
+	var tests = []struct {
+		length int64
+		ok     bool
+	}{
+		{10, true},
+		{10, true},
+		{math.MaxInt64, true},
+		{0, false},
+	}
+	for i, tt := range tests {
+		if length, ok := rows.ColumnTypeLength(i); length != tt.length || ok != tt.ok {
+			t.Errorf("column %d: expected %d %v, got %d %v", i, tt.length, tt.ok, length, ok)
+		}
+	}
+}
+
+func TestExecuteProcedureRows(t *testing.T) {
+	stmt := &firebirdsqlStmt{stmtType: isc_info_sql_stmt_exec_procedure, xsqlda: []xSQLVAR{
+		{sqltype: SQL_TYPE_LONG, aliasname: "TOTAL"},
+		{sqltype: SQL_TYPE_VARYING, aliasname: "LABEL"},
+	}}
+	rows := newFirebirdsqlRows(stmt, []driver.Value{int32(5), "three!"})
+	dest := make([]driver.Value, 2)
+	if err := rows.Next(dest); err != nil || dest[0] != int32(5) || dest[1] != "three!" {
+		t.Fatalf("Next: %v %v", dest, err)
+	}
+	if err := rows.Next(dest); err != io.EOF {
+		t.Errorf("expected io.EOF after the output parameters, got %v", err)
+	}
+	if err := newFirebirdsqlRows(stmt, nil).Next(dest); err != io.EOF {
+		t.Errorf("expected io.EOF without output parameters, got %v", err)
+	}
+}
+
+func TestRowsCursor(t *testing.T) {
+	for _, stmtType := range []int32{isc_info_sql_stmt_select, isc_info_sql_stmt_select_for_upd} {
+		if rows := newFirebirdsqlRows(&firebirdsqlStmt{stmtType: stmtType}, nil); !rows.moreData {
+			t.Errorf("statement type %d is not fetched", stmtType)
+		}
+	}
+	if rows := newFirebirdsqlRows(&firebirdsqlStmt{stmtType: isc_info_sql_stmt_exec_procedure}, nil); rows.moreData {
+		t.Errorf("EXECUTE PROCEDURE is fetched")
+	}
+}
+
+func TestNextResultSet(t *testing.T) {
+	var rows driver.RowsNextResultSet = newFirebirdsqlRows(&firebirdsqlStmt{stmtType: isc_info_sql_stmt_select}, nil)
+	if rows.HasNextResultSet() {
+		t.Errorf("a statement has a next result set")
+	}
+	if err := rows.NextResultSet(); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestNextFetchError(t *testing.T) {
+	xsqlda := []xSQLVAR{{sqltype: SQL_TYPE_LONG, sqllen: 4}}
+	// one row, then the connection is cut
+	response := append(bint32_to_bytes(op_fetch_response), bint32_to_bytes(0)...)
+	response = append(response, bint32_to_bytes(1)...)
+	response = append(response, 0, 0, 0, 0) // null indicator
+	response = append(response, bint32_to_bytes(7)...)
+	rows := fetchRows(t, xsqlda, response)
+	dest := make([]driver.Value, 1)
+	if err := rows.Next(dest); err == nil || err == io.EOF {
+		t.Errorf("expected the fetch error, got %v", err)
+	}
+}
+
+func TestNextFetchDeadConn(t *testing.T) {
+	xsqlda := []xSQLVAR{{sqltype: SQL_TYPE_LONG, sqllen: 4}}
+	// a keepalive, then the connection is cut
+	rows := fetchRows(t, xsqlda, bint32_to_bytes(op_dummy))
+	dest := make([]driver.Value, 1)
+	err := rows.Next(dest)
+	if err == nil || err == io.EOF || strings.Contains(err.Error(), "Internal Error") {
+		t.Errorf("expected the read error, got %v", err)
+	}
+}
+
+func TestNextDateOutOfRange(t *testing.T) {
+	xsqlda := []xSQLVAR{{sqltype: SQL_TYPE_DATE, sqllen: 4}}
+	rows := fetchRows(t, xsqlda, fetchResponse(
+		bint32_to_bytes(_encodeDate(10000, 1, 1)),
+		bint32_to_bytes(_encodeDate(2026, 10, 14)),
+	))
+	dest := make([]driver.Value, 1)
+	err := rows.Next(dest)
+	if err == nil || err == io.EOF || !strings.Contains(err.Error(), "DATE value") {
+		t.Errorf("expected the DATE error, got %v %v", dest[0], err)
+	}
+}
+
+func TestNextTimestampOverflow(t *testing.T) {
+	xsqlda := []xSQLVAR{{sqltype: SQL_TYPE_TIMESTAMP, sqllen: 8}}
+	// 10000-01-01 12:00 is beyond the range of Firebird
+	raw := append(bint32_to_bytes(_encodeDate(10000, 1, 1)), encodeTime(12, 0, 0, 0)...)
+	dest := make([]driver.Value, 1)
+
+	rows := fetchRows(t, xsqlda, fetchResponse(raw))
+	if err := rows.Next(dest); err == nil || err == io.EOF {
+		t.Errorf("default timestamp_overflow: got %v %v", dest[0], err)
+	}
+
+	rows = fetchRows(t, xsqlda, fetchResponse(raw))
+	rows.stmt.wp.dsn.timestampOverflow = TIMESTAMP_OVERFLOW_CLAMP
+	err := rows.Next(dest)
+	if v, ok := dest[0].(time.Time); err != nil || !ok || !v.Equal(time.Date(9999, 12, 31, 23, 59, 59, 999900000, time.UTC)) {
+		t.Errorf("timestamp_overflow=clamp: %v %v", dest[0], err)
+	}
+
+	rows = fetchRows(t, xsqlda, fetchResponse(raw))
+	rows.stmt.wp.dsn.timestampOverflow = TIMESTAMP_OVERFLOW_RAW
+	if err := rows.Next(dest); err != nil || dest[0] != (RawTimestamp{10000, 1, 1, 12, 0, 0, 0}) {
+		t.Errorf("timestamp_overflow=raw: %v %v", dest[0], err)
+	}
+}
+
+func TestNextStatementTimeout(t *testing.T) {
+	xsqlda := []xSQLVAR{{sqltype: SQL_TYPE_LONG, sqllen: 4}}
+	// Firebird 4 answers the fetch with the error of the statement timeout
+	rows := fetchRows(t, xsqlda, bytes.Join([][]byte{
+		bint32_to_bytes(op_response),
+		make([]byte, 16), // handle, object id, no buffer
+		bint32_to_bytes(isc_arg_gds), bint32_to_bytes(335544794),
+		bint32_to_bytes(isc_arg_gds), bint32_to_bytes(isc_req_stmt_timeout),
+		bint32_to_bytes(isc_arg_end),
+	}, nil))
+	dest := make([]driver.Value, 1)
+	if err := rows.Next(dest); !IsStatementTimeout(err) {
+		t.Errorf("expected the statement timeout, got %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ServerVersion is the version of the server and the on-disk structure
+// (ODS) version of the database.
+type ServerVersion struct {
+	Version       string // e.g. "LI-V3.0.7.33374 Firebird 3.0"
+	EngineVersion string // e.g. "3.0.7.33374"
+	Major         int
+	Minor         int
+	OdsMajor      int
+	OdsMinor      int
+}
+
+// ServerVersioner is implemented by the driver connection.
+// Get it with sql.Conn.Raw.
+type ServerVersioner interface {
+	ServerVersion() (ServerVersion, error)
+}
+
+// infoInt decodes a little endian integer of an info response item.
+func infoInt(b []byte) int {
+	n := 0
+	for i := len(b) - 1; i >= 0; i-- {
+		n = n<<8 | int(b[i])
+	}
+	return n
+}
+
+// parseEngineVersion parses <platform>-<type><major>.<minor>.<release>.<build> <name>
+func parseEngineVersion(s string) (version string, major, minor int) {
+	if i := strings.IndexByte(s, '-'); i >= 0 && i+2 <= len(s) {
+		version = s[i+2:]
+	}
+	if i := strings.IndexByte(version, ' '); i >= 0 {
+		version = version[:i]
+	}
+	parts := strings.Split(version, ".")
+	major, _ = strconv.Atoi(parts[0])
+	if len(parts) > 1 {
+		minor, _ = strconv.Atoi(parts[1])
+	}
+	return
+}
+
+func parseServerVersion(buf []byte) (v ServerVersion, err error) {
+	for i := 0; i+3 <= len(buf) && buf[i] != isc_info_end; {
+		item := buf[i]
+		ln := int(bytes_to_int16(buf[i+1 : i+3]))
+		i += 3
+		if i+ln > len(buf) {
+			break
+		}
+		data := buf[i : i+ln]
+		i += ln
+		switch item {
+		case isc_info_firebird_version:
+			// count, then a length prefixed string for each hop;
+			// the first one is the server
+			if len(data) > 1 && 2+int(data[1]) <= len(data) {
+				v.Version = string(data[2 : 2+int(data[1])])
+			}
+			v.EngineVersion, v.Major, v.Minor = parseEngineVersion(v.Version)
+		case isc_info_ods_version:
+			v.OdsMajor = infoInt(data)
+		case isc_info_ods_minor_version:
+			v.OdsMinor = infoInt(data)
+		case isc_info_truncated:
+			return v, errors.New("firebirdsql: truncated database info")
+		}
+	}
+	if v.Version == "" {
+		err = errors.New("firebirdsql: no server version in database info")
+	}
+	return
+}
+
+// ServerVersion returns the version of the server and the ODS version of
+// the database. They are requested once and kept for the connection.
+func (fc *firebirdsqlConn) ServerVersion() (ServerVersion, error) {
+	if fc.serverVersion != nil {
+		return *fc.serverVersion, nil
+	}
+	buf, err := fc.wp.infoDatabase([]byte{
+		isc_info_firebird_version,
+		isc_info_ods_version,
+		isc_info_ods_minor_version,
+		isc_info_end,
+	})
+	if err != nil {
+		return ServerVersion{}, err
+	}
+	v, err := parseServerVersion(buf)
+	if err != nil {
+		return v, err
+	}
+	fc.serverVersion = &v
+	return v, nil
+}
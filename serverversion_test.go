@@ -0,0 +1,50 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"testing"
+)
+
+func TestParseServerVersion(t *testing.T) {
+	version := "LI-V3.0.7.33374 Firebird 3.0"
+	buf := []byte{isc_info_firebird_version, byte(len(version) + 2), 0, 1, byte(len(version))}
+	buf = append(buf, version...)
+	buf = append(buf, isc_info_ods_version, 4, 0, 12, 0, 0, 0)
+	buf = append(buf, isc_info_ods_minor_version, 2, 0, 2, 0)
+	buf = append(buf, isc_info_end)
+
+	v, err := parseServerVersion(buf)
+	if err != nil {
+		t.Fatalf("parseServerVersion: %v", err)
+	}
+	expected := ServerVersion{version, "3.0.7.33374", 3, 0, 12, 2}
+	if v != expected {
+		t.Errorf("expected %v, got %v", expected, v)
+	}
+
+	if _, err = parseServerVersion([]byte{isc_info_truncated}); err == nil {
+		t.Errorf("expected an error for a truncated response")
+	}
+}
@@ -0,0 +1,754 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// serviceInfoBufferLength is the buffer of an isc_info_svc_line request,
+// which is longer than any line of the service output.
+const serviceInfoBufferLength = 16384
+
+// ServiceManager is a connection to the service manager of a server, which
+// runs gbak backups and restores and gstat on the server and manages its
+// users.
+//
+//	sm, err := firebirdsql.NewServiceManager("sysdba:masterkey@localhost")
+//	defer sm.Close()
+//	out, err := sm.Backup("/data/foo.fdb", "/backup/foo.fbk", firebirdsql.BackupOptions{Verbose: true})
+//	_, err = io.Copy(os.Stdout, out)
+type ServiceManager struct {
+	wp *wireProtocol
+
+	// SecurityDatabase is a database whose security database has the users
+	// of AddUser, ModifyUser, DeleteUser and Users, on Firebird 3 and later
+	// where a database may have its own. Empty is the default security
+	// database of the server.
+	SecurityDatabase string
+}
+
+// User is a user of the security database.
+type User struct {
+	Username   string
+	Password   string // only set by AddUser and ModifyUser
+	FirstName  string
+	MiddleName string
+	LastName   string
+	UserID     int
+	GroupID    int
+	Admin      bool // the RDB$ADMIN role
+}
+
+// BackupOptions are the gbak options of Backup.
+type BackupOptions struct {
+	Verbose          bool // the output is the progress of gbak -v
+	IgnoreChecksums  bool
+	IgnoreLimbo      bool
+	MetadataOnly     bool
+	NoGarbageCollect bool
+}
+
+// RestoreOptions are the gbak options of Restore.
+type RestoreOptions struct {
+	Verbose           bool // the output is the progress of gbak -v
+	Replace           bool // replace an existing database, else create it
+	PageSize          int  // 0 is the page size of the backup
+	DeactivateIndexes bool
+	NoValidity        bool
+	OneAtATime        bool
+	UseAllSpace       bool
+}
+
+// StatsOptions are the gstat options of DatabaseStats. Without any, the
+// report has the header page and the data pages and indexes of the user
+// tables.
+type StatsOptions struct {
+	HeaderOnly      bool // gstat -h
+	DataPages       bool // gstat -d
+	Indexes         bool // gstat -i
+	SystemRelations bool // gstat -s
+	RecordVersions  bool // gstat -r
+}
+
+// DatabaseHeader is the header page of a gstat report, parsed by
+// ParseDatabaseHeader.
+type DatabaseHeader struct {
+	Generation        int64
+	PageSize          int
+	ODSVersion        string
+	OldestTransaction int64
+	OldestActive      int64
+	OldestSnapshot    int64
+	NextTransaction   int64
+	PageBuffers       int
+	Dialect           int
+	CreationDate      string
+	Attributes        string
+}
+
+// ShutdownMode is the mode of a database shut down by Shutdown or brought
+// online by BringOnline, like the gfix -shut and -online modes.
+type ShutdownMode byte
+
+const (
+	ShutdownNormal ShutdownMode = isc_spb_prp_sm_normal // only for BringOnline
+	ShutdownMulti  ShutdownMode = isc_spb_prp_sm_multi
+	ShutdownSingle ShutdownMode = isc_spb_prp_sm_single
+	ShutdownFull   ShutdownMode = isc_spb_prp_sm_full // only for Shutdown
+)
+
+// ShutdownMethod is how Shutdown waits for the attachments and the
+// transactions of the database during its timeout.
+type ShutdownMethod byte
+
+const (
+	// ShutdownForce disconnects the attachments still there at the timeout.
+	ShutdownForce ShutdownMethod = isc_spb_prp_force_shutdown
+	// ShutdownDenyAttachments fails if attachments are there at the timeout.
+	ShutdownDenyAttachments ShutdownMethod = isc_spb_prp_attachments_shutdown
+	// ShutdownDenyTransactions fails if transactions are running at the
+	// timeout.
+	ShutdownDenyTransactions ShutdownMethod = isc_spb_prp_transactions_shutdown
+)
+
+// NewServiceManager attaches to the service manager of the server of dsn,
+// which is user:password@servername[:port_number] with the parameters of
+// a connection DSN (e.g. auth_plugin_name, wire_crypt). A database path
+// is ignored.
+func NewServiceManager(dsn string) (*ServiceManager, error) {
+	server, query := split1(dsn, "?")
+	at := strings.LastIndex(server, "@") + 1
+	if i := strings.IndexByte(server[at:], '/'); i >= 0 {
+		server = server[:at+i]
+	}
+	d, err := parseDSN(server + "/service_mgr?" + query)
+	if err != nil {
+		return nil, err
+	}
+	wp, err := newWireProtocol(context.Background(), d)
+	if err != nil {
+		return nil, err
+	}
+	clientPublic, clientSecret := getClientSeed()
+	wp.opConnect(d.dbName, d.user, d.passwd, d.authPluginName, d.wireCrypt, clientPublic)
+	err = wp.opAccept(d.user, d.passwd, d.authPluginName, clientPublic, clientSecret)
+	if err == nil {
+		wp.opServiceAttach(d.user, d.passwd)
+		wp.dbHandle, _, _, err = wp.opResponse()
+	}
+	if err != nil {
+		wp.conn.Close()
+		return nil, err
+	}
+	return &ServiceManager{wp: wp}, nil
+}
+
+// Close detaches from the service manager.
+func (s *ServiceManager) Close() error {
+	s.wp.opServiceDetach()
+	_, _, _, err := s.wp.opResponse()
+	s.wp.conn.Close()
+	return err
+}
+
+// spbString appends an action item of a string, which has a 2 bytes length.
+func spbString(spb []byte, item byte, s string) []byte {
+	b := str_to_bytes(s)
+	spb = append(spb, item, byte(len(b)), byte(len(b)>>8))
+	return append(spb, b...)
+}
+
+// spbInt appends an action item of a 4 bytes integer.
+func spbInt(spb []byte, item byte, i int) []byte {
+	return append(append(spb, item), int32_to_bytes(int32(i))...)
+}
+
+func backupSpb(database string, backupFile string, opts BackupOptions) []byte {
+	var options int
+	if opts.IgnoreChecksums {
+		options |= isc_spb_bkp_ignore_checksums
+	}
+	if opts.IgnoreLimbo {
+		options |= isc_spb_bkp_ignore_limbo
+	}
+	if opts.MetadataOnly {
+		options |= isc_spb_bkp_metadata_only
+	}
+	if opts.NoGarbageCollect {
+		options |= isc_spb_bkp_no_garbage_collect
+	}
+	spb := []byte{isc_action_svc_backup}
+	spb = spbString(spb, isc_spb_dbname, database)
+	spb = spbString(spb, isc_spb_bkp_file, backupFile)
+	spb = spbInt(spb, isc_spb_options, options)
+	if opts.Verbose {
+		spb = append(spb, isc_spb_verbose)
+	}
+	return spb
+}
+
+func restoreSpb(backupFile string, database string, opts RestoreOptions) []byte {
+	options := isc_spb_res_create
+	if opts.Replace {
+		options = isc_spb_res_replace
+	}
+	if opts.DeactivateIndexes {
+		options |= isc_spb_res_deactivate_idx
+	}
+	if opts.NoValidity {
+		options |= isc_spb_res_no_validity
+	}
+	if opts.OneAtATime {
+		options |= isc_spb_res_one_at_a_time
+	}
+	if opts.UseAllSpace {
+		options |= isc_spb_res_use_all_space
+	}
+	spb := []byte{isc_action_svc_restore}
+	spb = spbString(spb, isc_spb_bkp_file, backupFile)
+	spb = spbString(spb, isc_spb_dbname, database)
+	if opts.PageSize > 0 {
+		spb = spbInt(spb, isc_spb_res_page_size, opts.PageSize)
+	}
+	spb = spbInt(spb, isc_spb_options, options)
+	if opts.Verbose {
+		spb = append(spb, isc_spb_verbose)
+	}
+	return spb
+}
+
+func statsSpb(database string, opts StatsOptions) []byte {
+	var options int
+	if opts.HeaderOnly {
+		options |= isc_spb_sts_hdr_pages
+	}
+	if opts.DataPages {
+		options |= isc_spb_sts_data_pages
+	}
+	if opts.Indexes {
+		options |= isc_spb_sts_idx_pages
+	}
+	if opts.SystemRelations {
+		options |= isc_spb_sts_sys_relations
+	}
+	if opts.RecordVersions {
+		options |= isc_spb_sts_record_versions
+	}
+	spb := []byte{isc_action_svc_db_stats}
+	spb = spbString(spb, isc_spb_dbname, database)
+	return spbInt(spb, isc_spb_options, options)
+}
+
+// userSpb is the spb of a user action. The names are only set when they
+// are not empty, and the admin role by AddUser when it is granted and by
+// ModifyUser always.
+func userSpb(action byte, u User, securityDatabase string) []byte {
+	spb := []byte{action}
+	spb = spbString(spb, isc_spb_sec_username, u.Username)
+	if u.Password != "" {
+		spb = spbString(spb, isc_spb_sec_password, u.Password)
+	}
+	if u.FirstName != "" {
+		spb = spbString(spb, isc_spb_sec_firstname, u.FirstName)
+	}
+	if u.MiddleName != "" {
+		spb = spbString(spb, isc_spb_sec_middlename, u.MiddleName)
+	}
+	if u.LastName != "" {
+		spb = spbString(spb, isc_spb_sec_lastname, u.LastName)
+	}
+	if u.UserID != 0 {
+		spb = spbInt(spb, isc_spb_sec_userid, u.UserID)
+	}
+	if u.GroupID != 0 {
+		spb = spbInt(spb, isc_spb_sec_groupid, u.GroupID)
+	}
+	if action == isc_action_svc_modify_user || u.Admin {
+		admin := 0
+		if u.Admin {
+			admin = 1
+		}
+		spb = spbInt(spb, isc_spb_sec_admin, admin)
+	}
+	if securityDatabase != "" {
+		spb = spbString(spb, isc_spb_dbname, securityDatabase)
+	}
+	return spb
+}
+
+func shutdownSpb(database string, mode ShutdownMode, method ShutdownMethod, timeout time.Duration) []byte {
+	spb := []byte{isc_action_svc_properties}
+	spb = spbString(spb, isc_spb_dbname, database)
+	spb = append(spb, isc_spb_prp_shutdown_mode, byte(mode))
+	return spbInt(spb, byte(method), int(timeout/time.Second))
+}
+
+func onlineSpb(database string, mode ShutdownMode) []byte {
+	spb := []byte{isc_action_svc_properties}
+	spb = spbString(spb, isc_spb_dbname, database)
+	return append(spb, isc_spb_prp_online_mode, byte(mode))
+}
+
+func writeModeSpb(database string, forcedWrites bool) []byte {
+	mode := byte(isc_spb_prp_wm_async)
+	if forcedWrites {
+		mode = isc_spb_prp_wm_sync
+	}
+	spb := []byte{isc_action_svc_properties}
+	spb = spbString(spb, isc_spb_dbname, database)
+	return append(spb, isc_spb_prp_write_mode, mode)
+}
+
+func accessModeSpb(database string, readOnly bool) []byte {
+	mode := byte(isc_spb_prp_am_readwrite)
+	if readOnly {
+		mode = isc_spb_prp_am_readonly
+	}
+	spb := []byte{isc_action_svc_properties}
+	spb = spbString(spb, isc_spb_dbname, database)
+	return append(spb, isc_spb_prp_access_mode, mode)
+}
+
+func propertySpb(database string, item byte, value int) []byte {
+	spb := []byte{isc_action_svc_properties}
+	spb = spbString(spb, isc_spb_dbname, database)
+	return spbInt(spb, item, value)
+}
+
+// Backup backs up database to backupFile, both paths on the server. The
+// backup runs until the returned output is read to io.EOF, and an error of
+// gbak is returned by its Read.
+func (s *ServiceManager) Backup(database string, backupFile string, opts BackupOptions) (io.Reader, error) {
+	return s.start(backupSpb(database, backupFile, opts))
+}
+
+// Restore restores backupFile to database, both paths on the server, like
+// Backup.
+func (s *ServiceManager) Restore(backupFile string, database string, opts RestoreOptions) (io.Reader, error) {
+	return s.start(restoreSpb(backupFile, database, opts))
+}
+
+// DatabaseStats returns the gstat report of database, a path on the
+// server, like the output of Backup.
+func (s *ServiceManager) DatabaseStats(database string, opts StatsOptions) (io.Reader, error) {
+	return s.start(statsSpb(database, opts))
+}
+
+// Shutdown shuts database down to mode, waiting for its attachments or
+// transactions as method up to timeout, rounded down to seconds, like
+// gfix -shut.
+func (s *ServiceManager) Shutdown(database string, mode ShutdownMode, method ShutdownMethod, timeout time.Duration) error {
+	if mode == ShutdownNormal {
+		return errors.New("firebirdsql: a database can not be shut down to the normal mode")
+	}
+	return s.run(shutdownSpb(database, mode, method, timeout))
+}
+
+// BringOnline brings a shut down database online, to the normal mode or,
+// from the full or single mode, to a less restricted shutdown mode, like
+// gfix -online.
+func (s *ServiceManager) BringOnline(database string, mode ShutdownMode) error {
+	if mode == ShutdownFull {
+		return errors.New("firebirdsql: a database can not be brought online to the full shutdown mode")
+	}
+	return s.run(onlineSpb(database, mode))
+}
+
+// SetSweepInterval sets the sweep interval of database, 0 disables the
+// automatic sweep, like gfix -housekeeping.
+func (s *ServiceManager) SetSweepInterval(database string, interval int) error {
+	return s.run(propertySpb(database, isc_spb_prp_sweep_interval, interval))
+}
+
+// SetPageBuffers sets the page cache of database, in pages, like gfix
+// -buffers.
+func (s *ServiceManager) SetPageBuffers(database string, buffers int) error {
+	return s.run(propertySpb(database, isc_spb_prp_page_buffers, buffers))
+}
+
+// SetForcedWrites sets the synchronous (forced) or asynchronous writes of
+// database, like gfix -write sync|async.
+func (s *ServiceManager) SetForcedWrites(database string, forcedWrites bool) error {
+	return s.run(writeModeSpb(database, forcedWrites))
+}
+
+// SetReadOnly makes database read-only or read-write, like gfix -mode
+// read_only|read_write. It needs no other attachment to database.
+func (s *ServiceManager) SetReadOnly(database string, readOnly bool) error {
+	return s.run(accessModeSpb(database, readOnly))
+}
+
+// AddUser creates the user u with its password.
+func (s *ServiceManager) AddUser(u User) error {
+	if u.Username == "" || u.Password == "" {
+		return errors.New("firebirdsql: AddUser needs a username and a password")
+	}
+	return s.run(userSpb(isc_action_svc_add_user, u, s.SecurityDatabase))
+}
+
+// ModifyUser changes the user u.Username, with the password and names of
+// u which are not empty. The admin role is granted or revoked as u.Admin.
+func (s *ServiceManager) ModifyUser(u User) error {
+	if u.Username == "" {
+		return errors.New("firebirdsql: ModifyUser needs a username")
+	}
+	return s.run(userSpb(isc_action_svc_modify_user, u, s.SecurityDatabase))
+}
+
+// DeleteUser drops the user username.
+func (s *ServiceManager) DeleteUser(username string) error {
+	return s.run(userSpb(isc_action_svc_delete_user, User{Username: username}, s.SecurityDatabase))
+}
+
+// Users returns the users of the security database, without passwords.
+func (s *ServiceManager) Users() ([]User, error) {
+	spb := []byte{isc_action_svc_display_user}
+	if s.SecurityDatabase != "" {
+		spb = spbString(spb, isc_spb_dbname, s.SecurityDatabase)
+	}
+	items, err := s.items(spb, isc_info_svc_get_users)
+	if err != nil {
+		return nil, err
+	}
+	return parseUsers(items)
+}
+
+// ServerLog returns the firebird.log of the server, like the output of
+// Backup.
+func (s *ServiceManager) ServerLog() (io.Reader, error) {
+	return s.start([]byte{isc_action_svc_get_fb_log})
+}
+
+// LimboTransactions returns the ids of the transactions of database which
+// are in limbo, prepared by a two-phase commit that did not end, like gfix
+// -list.
+func (s *ServiceManager) LimboTransactions(database string) ([]int64, error) {
+	spb := []byte{isc_action_svc_repair}
+	spb = spbString(spb, isc_spb_dbname, database)
+	spb = spbInt(spb, isc_spb_options, isc_spb_rpr_list_limbo_trans)
+	items, err := s.items(spb, isc_info_svc_limbo_trans)
+	if err != nil {
+		return nil, err
+	}
+	return parseLimboTransactions(items)
+}
+
+// CommitLimbo commits the limbo transaction id of database, like gfix
+// -commit.
+func (s *ServiceManager) CommitLimbo(database string, id int64) error {
+	return s.run(limboSpb(database, isc_spb_rpr_commit_trans, isc_spb_rpr_commit_trans_64, id))
+}
+
+// RollbackLimbo rolls back the limbo transaction id of database, like gfix
+// -rollback.
+func (s *ServiceManager) RollbackLimbo(database string, id int64) error {
+	return s.run(limboSpb(database, isc_spb_rpr_rollback_trans, isc_spb_rpr_rollback_trans_64, id))
+}
+
+// RecoverLimbo commits or rolls back the limbo transaction id of database
+// as its two-phase commit advises, like gfix -two_phase.
+func (s *ServiceManager) RecoverLimbo(database string, id int64) error {
+	return s.run(limboSpb(database, isc_spb_rpr_recover_two_phase, isc_spb_rpr_recover_two_phase_64, id))
+}
+
+// limboSpb is the spb of the repair of a limbo transaction, whose id is
+// sent with item or, above 32 bits, with item64 of Firebird 3.
+func limboSpb(database string, item byte, item64 byte, id int64) []byte {
+	spb := []byte{isc_action_svc_repair}
+	spb = spbString(spb, isc_spb_dbname, database)
+	if id > math.MaxInt32 {
+		return append(append(spb, item64), int64_to_bytes(id)...)
+	}
+	return spbInt(spb, item, int(id))
+}
+
+// parseLimboTransactions parses the items of isc_info_svc_limbo_trans
+// responses. The ids are the single and the multi-database transactions,
+// and the descriptions of their sites are skipped.
+func parseLimboTransactions(items []byte) (ids []int64, err error) {
+	invalid := errors.New("firebirdsql: invalid limbo transactions response")
+	for i := 0; i < len(items); {
+		item := items[i]
+		i++
+		switch item {
+		case isc_spb_single_tra_id, isc_spb_multi_tra_id, isc_spb_tra_id:
+			if i+4 > len(items) {
+				return nil, invalid
+			}
+			if item != isc_spb_tra_id {
+				ids = append(ids, int64(bytes_to_int32(items[i:i+4])))
+			}
+			i += 4
+		case isc_spb_single_tra_id_64, isc_spb_multi_tra_id_64, isc_spb_tra_id_64:
+			if i+8 > len(items) {
+				return nil, invalid
+			}
+			if item != isc_spb_tra_id_64 {
+				ids = append(ids, bytes_to_int64(items[i:i+8]))
+			}
+			i += 8
+		case isc_spb_tra_state, isc_spb_tra_advise:
+			i++
+		case isc_spb_tra_host_site, isc_spb_tra_remote_site, isc_spb_tra_db_path:
+			if i+2 > len(items) {
+				return nil, invalid
+			}
+			i += 2 + int(bytes_to_int16(items[i:i+2]))
+		case isc_info_end, isc_info_truncated:
+			return ids, nil
+		default:
+			return nil, fmt.Errorf("firebirdsql: unknown item %d in the limbo transactions response", item)
+		}
+		if i > len(items) {
+			return nil, invalid
+		}
+	}
+	return ids, nil
+}
+
+// parseUsers parses the items of isc_info_svc_get_users responses, where
+// each user begins with its isc_spb_sec_username.
+func parseUsers(items []byte) (users []User, err error) {
+	var u *User
+	for i := 0; i < len(items); {
+		item := items[i]
+		i++
+		switch item {
+		case isc_spb_sec_username, isc_spb_sec_firstname, isc_spb_sec_middlename, isc_spb_sec_lastname:
+			if i+2 > len(items) {
+				return nil, errors.New("firebirdsql: invalid users response")
+			}
+			ln := int(bytes_to_int16(items[i : i+2]))
+			i += 2
+			if i+ln > len(items) {
+				return nil, errors.New("firebirdsql: invalid users response")
+			}
+			value := bytes_to_str(items[i : i+ln])
+			i += ln
+			if item == isc_spb_sec_username {
+				users = append(users, User{Username: value})
+				u = &users[len(users)-1]
+				continue
+			}
+			if u == nil {
+				return nil, errors.New("firebirdsql: invalid users response")
+			}
+			switch item {
+			case isc_spb_sec_firstname:
+				u.FirstName = value
+			case isc_spb_sec_middlename:
+				u.MiddleName = value
+			case isc_spb_sec_lastname:
+				u.LastName = value
+			}
+		case isc_spb_sec_userid, isc_spb_sec_groupid, isc_spb_sec_admin:
+			if u == nil || i+4 > len(items) {
+				return nil, errors.New("firebirdsql: invalid users response")
+			}
+			value := int(bytes_to_int32(items[i : i+4]))
+			i += 4
+			switch item {
+			case isc_spb_sec_userid:
+				u.UserID = value
+			case isc_spb_sec_groupid:
+				u.GroupID = value
+			case isc_spb_sec_admin:
+				u.Admin = value != 0
+			}
+		case isc_info_end, isc_info_truncated:
+			return users, nil
+		default:
+			return nil, fmt.Errorf("firebirdsql: unknown item %d in the users response", item)
+		}
+	}
+	return users, nil
+}
+
+// ParseDatabaseHeader reads a gstat report to its end, so the service is
+// finished, and returns the values of its header page. The lines are a
+// name and a value separated by tabs, and the unknown names are skipped.
+func ParseDatabaseHeader(r io.Reader) (h DatabaseHeader, err error) {
+	found, done := false, false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "Database header page information") {
+			found = true
+			continue
+		}
+		if found && strings.TrimSpace(line) == "" {
+			done = true // the values are read, the rest is drained
+		}
+		if !found || done || !strings.HasPrefix(line, "\t") {
+			continue
+		}
+		fields := strings.FieldsFunc(line, func(c rune) bool { return c == '\t' })
+		if len(fields) < 2 {
+			continue
+		}
+		name, value := strings.TrimSpace(fields[0]), strings.TrimSpace(fields[len(fields)-1])
+		switch name {
+		case "Generation":
+			h.Generation, err = strconv.ParseInt(value, 10, 64)
+		case "Page size":
+			h.PageSize, err = strconv.Atoi(value)
+		case "ODS version":
+			h.ODSVersion = value
+		case "Oldest transaction":
+			h.OldestTransaction, err = strconv.ParseInt(value, 10, 64)
+		case "Oldest active":
+			h.OldestActive, err = strconv.ParseInt(value, 10, 64)
+		case "Oldest snapshot":
+			h.OldestSnapshot, err = strconv.ParseInt(value, 10, 64)
+		case "Next transaction":
+			h.NextTransaction, err = strconv.ParseInt(value, 10, 64)
+		case "Page buffers":
+			h.PageBuffers, err = strconv.Atoi(value)
+		case "Database dialect":
+			h.Dialect, err = strconv.Atoi(value)
+		case "Creation date":
+			h.CreationDate = value
+		case "Attributes":
+			h.Attributes = value
+		}
+		if err != nil {
+			return h, fmt.Errorf("firebirdsql: invalid %s %q in the header page", name, value)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return
+	}
+	if !found {
+		err = errors.New("firebirdsql: no header page in the report")
+	}
+	return
+}
+
+func (s *ServiceManager) start(spb []byte) (io.Reader, error) {
+	s.wp.opServiceStart(spb)
+	if _, _, _, err := s.wp.opResponse(); err != nil {
+		return nil, err
+	}
+	return &serviceOutput{s: s}, nil
+}
+
+// run starts the service and waits for its end, discarding its output.
+func (s *ServiceManager) run(spb []byte) error {
+	out, err := s.start(spb)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(io.Discard, out)
+	return err
+}
+
+// items starts the service and returns the items of its info responses
+// for item, until an empty one.
+func (s *ServiceManager) items(spb []byte, item byte) ([]byte, error) {
+	s.wp.opServiceStart(spb)
+	if _, _, _, err := s.wp.opResponse(); err != nil {
+		return nil, err
+	}
+	var items []byte
+	for {
+		s.wp.opServiceInfo([]byte{item}, serviceInfoBufferLength)
+		_, _, buf, err := s.wp.opResponse()
+		if err != nil {
+			return nil, err
+		}
+		if len(buf) < 3 || buf[0] != item {
+			return nil, errors.New("firebirdsql: invalid service info response")
+		}
+		ln := int(bytes_to_int16(buf[1:3]))
+		if ln == 0 {
+			return items, nil
+		}
+		if len(buf) < 3+ln {
+			return nil, fmt.Errorf("firebirdsql: service info of %d bytes is truncated", ln)
+		}
+		items = append(items, buf[3:3+ln]...)
+	}
+}
+
+// serviceOutput reads the output of the running service line by line.
+type serviceOutput struct {
+	s   *ServiceManager
+	buf []byte
+	eof bool
+}
+
+func (r *serviceOutput) Read(b []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.eof {
+			return 0, io.EOF
+		}
+		line, err := r.s.line()
+		if err != nil {
+			return 0, err
+		}
+		if line == nil {
+			r.eof = true
+		} else {
+			r.buf = append(line, '\n')
+		}
+	}
+	n := copy(b, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// line returns the next line of the service output, and nil at its end.
+func (s *ServiceManager) line() ([]byte, error) {
+	s.wp.opServiceInfo([]byte{isc_info_svc_line}, serviceInfoBufferLength)
+	_, _, buf, err := s.wp.opResponse()
+	if err != nil {
+		return nil, err
+	}
+	return parseServiceLine(buf)
+}
+
+// parseServiceLine parses the isc_info_svc_line response. An empty line
+// is the end of the output.
+func parseServiceLine(buf []byte) ([]byte, error) {
+	if len(buf) < 3 || buf[0] != isc_info_svc_line {
+		return nil, errors.New("firebirdsql: invalid service info response")
+	}
+	ln := int(bytes_to_int16(buf[1:3]))
+	if len(buf) < 3+ln {
+		return nil, fmt.Errorf("firebirdsql: service line of %d bytes is truncated", ln)
+	}
+	if ln == 0 {
+		return nil, nil
+	}
+	return append([]byte(nil), buf[3:3+ln]...), nil
+}
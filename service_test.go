@@ -0,0 +1,282 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackupSpb(t *testing.T) {
+	spb := backupSpb("/data/a.fdb", "/b.fbk", BackupOptions{Verbose: true, IgnoreChecksums: true, NoGarbageCollect: true})
+	expected := []byte{
+		isc_action_svc_backup,
+		isc_spb_dbname, 11, 0, '/', 'd', 'a', 't', 'a', '/', 'a', '.', 'f', 'd', 'b',
+		isc_spb_bkp_file, 6, 0, '/', 'b', '.', 'f', 'b', 'k',
+		isc_spb_options, 0x09, 0, 0, 0,
+		isc_spb_verbose,
+	}
+	if !bytes.Equal(spb, expected) {
+		t.Errorf("expected % x, got % x", expected, spb)
+	}
+}
+
+func TestRestoreSpb(t *testing.T) {
+	spb := restoreSpb("/b.fbk", "/a.fdb", RestoreOptions{Replace: true, PageSize: 8192})
+	expected := []byte{
+		isc_action_svc_restore,
+		isc_spb_bkp_file, 6, 0, '/', 'b', '.', 'f', 'b', 'k',
+		isc_spb_dbname, 6, 0, '/', 'a', '.', 'f', 'd', 'b',
+		isc_spb_res_page_size, 0, 0x20, 0, 0,
+		isc_spb_options, 0, 0x10, 0, 0,
+	}
+	if !bytes.Equal(spb, expected) {
+		t.Errorf("expected % x, got % x", expected, spb)
+	}
+	spb = restoreSpb("/b.fbk", "/a.fdb", RestoreOptions{})
+	if !bytes.Equal(spb[len(spb)-5:], []byte{isc_spb_options, 0, 0x20, 0, 0}) {
+		t.Errorf("a restore without Replace doesn't create: % x", spb)
+	}
+}
+
+func TestParseServiceLine(t *testing.T) {
+	line, err := parseServiceLine([]byte{isc_info_svc_line, 5, 0, 'g', 'b', 'a', 'k', ':', isc_info_end})
+	if err != nil || string(line) != "gbak:" {
+		t.Errorf("line: %q %v", line, err)
+	}
+	line, err = parseServiceLine([]byte{isc_info_svc_line, 0, 0, isc_info_end})
+	if err != nil || line != nil {
+		t.Errorf("end of output: %q %v", line, err)
+	}
+	if _, err = parseServiceLine([]byte{isc_info_svc_line, 9, 0, 'g'}); err == nil {
+		t.Errorf("truncated line was accepted")
+	}
+}
+
+func TestStatsSpb(t *testing.T) {
+	spb := statsSpb("/a.fdb", StatsOptions{HeaderOnly: true})
+	expected := []byte{
+		isc_action_svc_db_stats,
+		isc_spb_dbname, 6, 0, '/', 'a', '.', 'f', 'd', 'b',
+		isc_spb_options, isc_spb_sts_hdr_pages, 0, 0, 0,
+	}
+	if !bytes.Equal(spb, expected) {
+		t.Errorf("expected % x, got % x", expected, spb)
+	}
+}
+
+func TestParseDatabaseHeader(t *testing.T) {
+	report := "\nDatabase \"/tmp/a.fdb\"\n" +
+		"Gstat execution time Wed Oct 14 10:00:00 2026\n" +
+		"\n" +
+		"Database header page information:\n" +
+		"\tFlags\t\t\t0\n" +
+		"\tGeneration\t\t180\n" +
+		"\tSystem Change Number\t0\n" +
+		"\tPage size\t\t8192\n" +
+		"\tODS version\t\t13.0\n" +
+		"\tOldest transaction\t172\n" +
+		"\tOldest active\t\t173\n" +
+		"\tOldest snapshot\t\t173\n" +
+		"\tNext transaction\t174\n" +
+		"\tPage buffers\t\t0\n" +
+		"\tDatabase dialect\t3\n" +
+		"\tCreation date\t\tOct 14, 2026 9:59:00\n" +
+		"\tAttributes\t\tforce write\n" +
+		"\n" +
+		"    Variable header data:\n" +
+		"\t*END*\n" +
+		"\n" +
+		"\tGeneration\t\t1\n" +
+		"Gstat completion time Wed Oct 14 10:00:00 2026\n"
+	h, err := ParseDatabaseHeader(strings.NewReader(report))
+	if err != nil {
+		t.Fatalf("ParseDatabaseHeader: %v", err)
+	}
+	expected := DatabaseHeader{
+		Generation:        180,
+		PageSize:          8192,
+		ODSVersion:        "13.0",
+		OldestTransaction: 172,
+		OldestActive:      173,
+		OldestSnapshot:    173,
+		NextTransaction:   174,
+		Dialect:           3,
+		CreationDate:      "Oct 14, 2026 9:59:00",
+		Attributes:        "force write",
+	}
+	if h != expected {
+		t.Errorf("expected %+v, got %+v", expected, h)
+	}
+	if _, err = ParseDatabaseHeader(strings.NewReader("gstat: error\n")); err == nil {
+		t.Errorf("a report without header was parsed")
+	}
+}
+
+func TestUserSpb(t *testing.T) {
+	spb := userSpb(isc_action_svc_add_user, User{Username: "BOB", Password: "pw", LastName: "B"}, "")
+	expected := []byte{
+		isc_action_svc_add_user,
+		isc_spb_sec_username, 3, 0, 'B', 'O', 'B',
+		isc_spb_sec_password, 2, 0, 'p', 'w',
+		isc_spb_sec_lastname, 1, 0, 'B',
+	}
+	if !bytes.Equal(spb, expected) {
+		t.Errorf("expected % x, got % x", expected, spb)
+	}
+
+	spb = userSpb(isc_action_svc_modify_user, User{Username: "BOB"}, "/a.fdb")
+	expected = []byte{
+		isc_action_svc_modify_user,
+		isc_spb_sec_username, 3, 0, 'B', 'O', 'B',
+		isc_spb_sec_admin, 0, 0, 0, 0,
+		isc_spb_dbname, 6, 0, '/', 'a', '.', 'f', 'd', 'b',
+	}
+	if !bytes.Equal(spb, expected) {
+		t.Errorf("expected % x, got % x", expected, spb)
+	}
+}
+
+func TestParseUsers(t *testing.T) {
+	items := []byte{
+		isc_spb_sec_username, 6, 0, 'S', 'Y', 'S', 'D', 'B', 'A',
+		isc_spb_sec_firstname, 0, 0,
+		isc_spb_sec_userid, 0, 0, 0, 0,
+		isc_spb_sec_admin, 1, 0, 0, 0,
+		isc_spb_sec_username, 3, 0, 'B', 'O', 'B',
+		isc_spb_sec_lastname, 5, 0, 'S', 'M', 'I', 'T', 'H',
+		isc_spb_sec_groupid, 7, 0, 0, 0,
+	}
+	users, err := parseUsers(items)
+	if err != nil {
+		t.Fatalf("parseUsers: %v", err)
+	}
+	expected := []User{
+		{Username: "SYSDBA", Admin: true},
+		{Username: "BOB", LastName: "SMITH", GroupID: 7},
+	}
+	if len(users) != len(expected) {
+		t.Fatalf("expected %+v, got %+v", expected, users)
+	}
+	for i := range expected {
+		if users[i] != expected[i] {
+			t.Errorf("expected %+v, got %+v", expected[i], users[i])
+		}
+	}
+	if _, err = parseUsers([]byte{isc_spb_sec_lastname, 1, 0, 'X'}); err == nil {
+		t.Errorf("a name without user was parsed")
+	}
+}
+
+func TestPropertiesSpb(t *testing.T) {
+	db := []byte{isc_action_svc_properties, isc_spb_dbname, 6, 0, '/', 'a', '.', 'f', 'd', 'b'}
+	tests := []struct {
+		spb      []byte
+		expected []byte
+	}{
+		{
+			shutdownSpb("/a.fdb", ShutdownFull, ShutdownForce, 30*time.Second),
+			append(db[:len(db):len(db)], isc_spb_prp_shutdown_mode, isc_spb_prp_sm_full, isc_spb_prp_force_shutdown, 30, 0, 0, 0),
+		},
+		{
+			onlineSpb("/a.fdb", ShutdownNormal),
+			append(db[:len(db):len(db)], isc_spb_prp_online_mode, isc_spb_prp_sm_normal),
+		},
+		{
+			writeModeSpb("/a.fdb", true),
+			append(db[:len(db):len(db)], isc_spb_prp_write_mode, isc_spb_prp_wm_sync),
+		},
+		{
+			writeModeSpb("/a.fdb", false),
+			append(db[:len(db):len(db)], isc_spb_prp_write_mode, isc_spb_prp_wm_async),
+		},
+		{
+			accessModeSpb("/a.fdb", true),
+			append(db[:len(db):len(db)], isc_spb_prp_access_mode, isc_spb_prp_am_readonly),
+		},
+		{
+			propertySpb("/a.fdb", isc_spb_prp_sweep_interval, 20000),
+			append(db[:len(db):len(db)], isc_spb_prp_sweep_interval, 0x20, 0x4e, 0, 0),
+		},
+	}
+	for _, tt := range tests {
+		if !bytes.Equal(tt.spb, tt.expected) {
+			t.Errorf("expected % x, got % x", tt.expected, tt.spb)
+		}
+	}
+}
+
+func TestLimboSpb(t *testing.T) {
+	spb := limboSpb("/a.fdb", isc_spb_rpr_commit_trans, isc_spb_rpr_commit_trans_64, 300)
+	expected := []byte{
+		isc_action_svc_repair,
+		isc_spb_dbname, 6, 0, '/', 'a', '.', 'f', 'd', 'b',
+		isc_spb_rpr_commit_trans, 0x2c, 0x01, 0, 0,
+	}
+	if !bytes.Equal(spb, expected) {
+		t.Errorf("expected % x, got % x", expected, spb)
+	}
+
+	spb = limboSpb("/a.fdb", isc_spb_rpr_rollback_trans, isc_spb_rpr_rollback_trans_64, 1<<32)
+	expected = []byte{
+		isc_action_svc_repair,
+		isc_spb_dbname, 6, 0, '/', 'a', '.', 'f', 'd', 'b',
+		isc_spb_rpr_rollback_trans_64, 0, 0, 0, 0, 1, 0, 0, 0,
+	}
+	if !bytes.Equal(spb, expected) {
+		t.Errorf("expected % x, got % x", expected, spb)
+	}
+}
+
+func TestParseLimboTransactions(t *testing.T) {
+	items := []byte{
+		isc_spb_single_tra_id, 0x2c, 0x01, 0, 0,
+		isc_spb_tra_state, isc_spb_tra_state_limbo,
+		isc_spb_tra_advise, isc_spb_tra_advise_commit,
+		isc_spb_multi_tra_id, 0x2d, 0x01, 0, 0,
+		isc_spb_tra_host_site, 4, 0, 'h', 'o', 's', 't',
+		isc_spb_tra_id, 0x2e, 0x01, 0, 0,
+		isc_spb_tra_db_path, 1, 0, '/',
+		isc_spb_single_tra_id_64, 0, 0, 0, 0, 1, 0, 0, 0,
+		isc_info_end,
+	}
+	ids, err := parseLimboTransactions(items)
+	if err != nil {
+		t.Fatalf("parseLimboTransactions: %v", err)
+	}
+	expected := []int64{300, 301, 1 << 32}
+	if len(ids) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, ids)
+	}
+	for i := range expected {
+		if ids[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected, ids)
+		}
+	}
+	if _, err = parseLimboTransactions([]byte{isc_spb_tra_db_path, 9, 0, '/'}); err == nil {
+		t.Errorf("a truncated response was parsed")
+	}
+}
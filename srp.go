@@ -25,7 +25,9 @@ package firebirdsql
 
 import (
 	"crypto/sha1"
+	"crypto/sha256"
 	"github.com/cznic/mathutil"
+	"hash"
 	"math/big"
 	"math/rand"
 	"time"
@@ -189,7 +191,16 @@ func getServerSession(user string, password string, salt []byte, keyA *big.Int,
 	return bigToSha1(sessionSecret)
 }
 
-func getClientProof(user string, password string, salt []byte, keyA *big.Int, keyB *big.Int, keya *big.Int) (keyM []byte, keyK []byte) {
+// srpProofHash returns the hash of the client proof M.
+// Srp256 differs from Srp only by it.
+func srpProofHash(pluginName string) func() hash.Hash {
+	if pluginName == "Srp256" {
+		return sha256.New
+	}
+	return sha1.New
+}
+
+func getClientProof(user string, password string, salt []byte, keyA *big.Int, keyB *big.Int, keya *big.Int, newHash func() hash.Hash) (keyM []byte, keyK []byte) {
 	// M = H(H(N) xor H(g), H(I), s, A, B, K)
 	prime, g, _ := getPrime()
 	keyK = getClientSession(user, password, salt, keyA, keyB, keya)
@@ -198,14 +209,14 @@ func getClientProof(user string, password string, salt []byte, keyA *big.Int, ke
 	n2 := bytesToBig(bigToSha1(g))
 	n3 := mathutil.ModPowBigInt(n1, n2, prime)
 	n4 := getStringHash(user)
-	sha1 := sha1.New()
-	sha1.Write(n3.Bytes())
-	sha1.Write(n4.Bytes())
-	sha1.Write(salt)
-	sha1.Write(keyA.Bytes())
-	sha1.Write(keyB.Bytes())
-	sha1.Write(keyK)
-	keyM = sha1.Sum(nil)
+	h := newHash()
+	h.Write(n3.Bytes())
+	h.Write(n4.Bytes())
+	h.Write(salt)
+	h.Write(keyA.Bytes())
+	h.Write(keyB.Bytes())
+	h.Write(keyK)
+	keyM = h.Sum(nil)
 
 	return keyM, keyK
 }
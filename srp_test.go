@@ -36,10 +36,31 @@ func TestSrp(t *testing.T) {
 	v := getVerifier(user, password, salt)
 	keyB, keyb := getServerSeed(v)
 	serverKey := getServerSession(user, password, salt, keyA, keyB, keyb)
-	_, clientKey := getClientProof(user, password, salt, keyA, keyB, keya)
+	_, clientKey := getClientProof(user, password, salt, keyA, keyB, keya, srpProofHash("Srp"))
 	for i, _ := range clientKey {
 		if clientKey[i] != serverKey[i] {
 			t.Fatalf("Error srp key exchange")
 		}
 	}
 }
+
+func TestSrp256Proof(t *testing.T) {
+	user := "SYSDBA"
+	password := "masterkey"
+
+	keyA, keya := getClientSeed()
+	salt := getSalt()
+	v := getVerifier(user, password, salt)
+	keyB, _ := getServerSeed(v)
+	proof1, key1 := getClientProof(user, password, salt, keyA, keyB, keya, srpProofHash("Srp"))
+	proof256, key256 := getClientProof(user, password, salt, keyA, keyB, keya, srpProofHash("Srp256"))
+	if len(proof1) != 20 || len(proof256) != 32 {
+		t.Fatalf("Error proof length %d %d", len(proof1), len(proof256))
+	}
+	// The session key is the same, only the proof is hashed by SHA-256.
+	for i, _ := range key1 {
+		if key1[i] != key256[i] {
+			t.Fatalf("Error Srp256 session key")
+		}
+	}
+}
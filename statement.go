@@ -24,19 +24,133 @@ CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
 package firebirdsql
 
 import (
+	"context"
 	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
+// StatementType is the kind of a prepared statement, as the server
+// describes it (isc_info_sql_stmt_type).
+type StatementType int
+
+const (
+	StatementSelect        StatementType = isc_info_sql_stmt_select
+	StatementInsert        StatementType = isc_info_sql_stmt_insert
+	StatementUpdate        StatementType = isc_info_sql_stmt_update
+	StatementDelete        StatementType = isc_info_sql_stmt_delete
+	StatementDDL           StatementType = isc_info_sql_stmt_ddl
+	StatementGetSegment    StatementType = isc_info_sql_stmt_get_segment
+	StatementPutSegment    StatementType = isc_info_sql_stmt_put_segment
+	StatementExecProcedure StatementType = isc_info_sql_stmt_exec_procedure
+	StatementStartTrans    StatementType = isc_info_sql_stmt_start_trans
+	StatementCommit        StatementType = isc_info_sql_stmt_commit
+	StatementRollback      StatementType = isc_info_sql_stmt_rollback
+	StatementSelectForUpd  StatementType = isc_info_sql_stmt_select_for_upd
+	StatementSetGenerator  StatementType = isc_info_sql_stmt_set_generator
+	StatementSavepoint     StatementType = isc_info_sql_stmt_savepoint
+)
+
+var statementTypeNames = map[StatementType]string{
+	StatementSelect:        "SELECT",
+	StatementInsert:        "INSERT",
+	StatementUpdate:        "UPDATE",
+	StatementDelete:        "DELETE",
+	StatementDDL:           "DDL",
+	StatementGetSegment:    "GET SEGMENT",
+	StatementPutSegment:    "PUT SEGMENT",
+	StatementExecProcedure: "EXECUTE PROCEDURE",
+	StatementStartTrans:    "START TRANSACTION",
+	StatementCommit:        "COMMIT",
+	StatementRollback:      "ROLLBACK",
+	StatementSelectForUpd:  "SELECT FOR UPDATE",
+	StatementSetGenerator:  "SET GENERATOR",
+	StatementSavepoint:     "SAVEPOINT",
+}
+
+func (t StatementType) String() string {
+	if s, ok := statementTypeNames[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("StatementType(%d)", int(t))
+}
+
+// StatementTyper is implemented by the driver statements. Prepare one with
+// sql.Conn.Raw and driver.ConnPrepareContext to tell a SELECT from an
+// EXECUTE PROCEDURE or DDL before running it.
+type StatementTyper interface {
+	StatementType() StatementType
+}
+
+// Planner is implemented by the driver statements. Plan returns the PLAN
+// the optimizer chose for the prepared statement, e.g. to log it with the
+// slow queries. With explained it is the detailed plan of Firebird 3 and
+// later, a tree of the access methods like "Select Expression -> Table
+// "T" Full Scan".
+type Planner interface {
+	Plan(explained bool) (string, error)
+}
+
+// CursorNamer is implemented by the driver statements. SetCursorName
+// names the cursor of a prepared SELECT before it is queried, so another
+// statement of the same transaction can UPDATE or DELETE ... WHERE
+// CURRENT OF it. The rows of a named cursor are fetched one by one, and
+// the current row of the cursor is the last one read by Next.
+type CursorNamer interface {
+	SetCursorName(name string) error
+}
+
+// Scrollable is implemented by the driver statements. See SetScrollable.
+type Scrollable interface {
+	SetScrollable(scrollable bool) error
+}
+
 type firebirdsqlStmt struct {
-	wp         *wireProtocol
-	stmtHandle int32
-	tx         *firebirdsqlTx
-	xsqlda     []xSQLVAR
-	blr        []byte
-	stmtType   int32
+	wp            *wireProtocol
+	stmtHandle    int32
+	tx            *firebirdsqlTx
+	xsqlda        []xSQLVAR
+	bindXsqlda    []xSQLVAR
+	bindDescribed bool
+	blr           []byte
+	stmtType      int32
+	paramNames    []string
+	arrayDescs    map[int]*arrayDesc
+	bindArrays    map[int]*arrayDesc
+	query         string
+	cache         *stmtCache
+	stats         StatementStats
+	cursorName    string
+	scrollable    bool
 }
 
+// Close puts the statement back to the cache of the connection if it
+// came from there, or drops it.
 func (stmt *firebirdsqlStmt) Close() (err error) {
+	if stmt.cache == nil {
+		return stmt.drop()
+	}
+	if stmt.hasCursor() {
+		stmt.wp.opFreeStatement(stmt.stmtHandle, 1) // DSQL_close
+		if stmt.wp.acceptType == ptype_lazy_send {
+			stmt.wp.lazyResponseCount++
+		} else {
+			// the cursor may be closed already by the commit
+			stmt.wp.opResponse()
+		}
+	}
+	if evicted := stmt.cache.put(stmt); evicted != nil {
+		err = evicted.drop()
+	}
+	return
+}
+
+func (stmt *firebirdsqlStmt) drop() (err error) {
 	stmt.wp.opFreeStatement(stmt.stmtHandle, 2) // DSQL_drop
 	if stmt.wp.acceptType == ptype_lazy_send {
 		stmt.wp.lazyResponseCount++
@@ -47,57 +161,439 @@ func (stmt *firebirdsqlStmt) Close() (err error) {
 	return
 }
 
+// hasCursor reports whether the rows of the statement are fetched from a
+// cursor: SELECT, SELECT ... FOR UPDATE or WITH LOCK, SELECT from a
+// selectable procedure and EXECUTE BLOCK with SUSPEND.
+func (stmt *firebirdsqlStmt) hasCursor() bool {
+	return stmt.stmtType == isc_info_sql_stmt_select || stmt.stmtType == isc_info_sql_stmt_select_for_upd
+}
+
+// StatementType returns the kind of the statement. INSERT ... RETURNING
+// and EXECUTE BLOCK are StatementExecProcedure.
+func (stmt *firebirdsqlStmt) StatementType() StatementType {
+	return StatementType(stmt.stmtType)
+}
+
+func (stmt *firebirdsqlStmt) SetCursorName(name string) (err error) {
+	if !stmt.hasCursor() {
+		return errors.New("firebirdsql: the statement has no cursor to name")
+	}
+	if name == "" {
+		return errors.New("firebirdsql: empty cursor name")
+	}
+	stmt.wp.opSetCursor(stmt.stmtHandle, name)
+	_, _, _, err = stmt.wp.opResponse()
+	if err == nil {
+		stmt.cursorName = name
+	}
+	return
+}
+
+func (stmt *firebirdsqlStmt) Plan(explained bool) (string, error) {
+	item := byte(isc_info_sql_get_plan)
+	if explained {
+		item = isc_info_sql_explain_plan
+	}
+	buf, err := stmt.wp.infoSql(stmt.stmtHandle, []byte{item})
+	if err != nil {
+		return "", err
+	}
+	return parsePlan(buf, item)
+}
+
+// parsePlan returns the plan of item in the info response buf, without the
+// line break the server puts before it. A statement without a plan, e.g.
+// a DDL, has an empty one.
+func parsePlan(buf []byte, item byte) (string, error) {
+	if len(buf) == 0 || buf[0] == isc_info_end {
+		return "", nil
+	}
+	if buf[0] == isc_info_truncated {
+		return "", errors.New("firebirdsql: the plan is too long")
+	}
+	if buf[0] != item || len(buf) < 3 {
+		// isc_info_error of an older server
+		return "", errors.New("firebirdsql: the server doesn't return the plan")
+	}
+	ln := int(bytes_to_int16(buf[1:3]))
+	if 3+ln > len(buf) {
+		return "", errors.New("firebirdsql: the plan is too long")
+	}
+	return strings.TrimLeft(bytes_to_str(buf[3:3+ln]), "\n"), nil
+}
+
+// SetScrollable opens the cursor of the following queries of the SELECT
+// as a scrollable one, whose rows implement Scroller. It needs Firebird 5
+// (protocol 18), as the older servers can't scroll a remote cursor.
+func (stmt *firebirdsqlStmt) SetScrollable(scrollable bool) error {
+	if !stmt.hasCursor() {
+		return errors.New("firebirdsql: the statement has no cursor to scroll")
+	}
+	if scrollable && stmt.wp.protocolVersion < PROTOCOL_VERSION18 {
+		return errors.New("firebirdsql: the server doesn't support scrollable cursors")
+	}
+	stmt.scrollable = scrollable
+	return nil
+}
+
 func (stmt *firebirdsqlStmt) NumInput() int {
 	return -1
 }
 
+// needsBindXsqlda reports whether any of args can't be encoded without
+// the input parameter description. []byte is sent as OCTETS to an OCTETS
+// column, so the server doesn't transliterate it, time.Time as the
+// DATE, TIME or TIMESTAMP of its column, and a blob with the sub type of
+// its column.
+func needsBindXsqlda(args []driver.Value) bool {
+	for _, arg := range args {
+		switch f := arg.(type) {
+		case Decimal, *big.Int, *big.Rat, []byte, time.Time, io.Reader:
+			return true
+		case string:
+			if len(f) >= MAX_CHAR_LENGTH {
+				return true
+			}
+		}
+		if isArrayArg(arg) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTextArg reports whether any of args is a string or []byte.
+func hasTextArg(args []driver.Value) bool {
+	for _, arg := range args {
+		switch arg.(type) {
+		case string, []byte:
+			return true
+		}
+	}
+	return false
+}
+
+// describeBind gets the input parameter description once, and only if
+// one of args needs it to be encoded or its length is checked
+// (check_param_length). It comes with the prepare, unless the prepare
+// response was truncated. It is kept with the statement handle, so the next
+// executions, and those of the statement cache, don't describe again.
+func (stmt *firebirdsqlStmt) describeBind(args []driver.Value) (err error) {
+	checkLength := stmt.wp.dsn.checkParamLength && hasTextArg(args)
+	if !stmt.bindDescribed && (needsBindXsqlda(args) || checkLength) {
+		stmt.wp.opInfoSql(stmt.stmtHandle, _INFO_SQL_BIND_DESCRIBE_VARS(), BUFFER_LEN)
+		var buf []byte
+		_, _, buf, err = stmt.wp.opResponse()
+		if err != nil {
+			return
+		}
+		_, _, stmt.bindXsqlda, err = stmt.wp.parse_xsqlda(buf, stmt.stmtHandle)
+		if err != nil {
+			return
+		}
+		stmt.bindDescribed = true
+	}
+	if checkLength {
+		err = checkParamLengths(args, stmt.bindXsqlda)
+	}
+	return
+}
+
+// checkParamLengths returns an error for a string longer in characters,
+// or a []byte longer in bytes, than its CHAR or VARCHAR column, instead of
+// the string truncation error of the server.
+func checkParamLengths(args []driver.Value, bindXsqlda []xSQLVAR) error {
+	for i, arg := range args {
+		if i >= len(bindXsqlda) {
+			break
+		}
+		x := &bindXsqlda[i]
+		if x.sqltype != SQL_TYPE_TEXT && x.sqltype != SQL_TYPE_VARYING {
+			continue
+		}
+		switch v := arg.(type) {
+		case string:
+			if n := utf8.RuneCountInString(v); n > x.charLength() {
+				return fmt.Errorf("firebirdsql: parameter %d exceeds column length (got %d chars, max %d)", i+1, n, x.charLength())
+			}
+		case []byte:
+			if len(v) > x.sqllen {
+				return fmt.Errorf("firebirdsql: parameter %d exceeds column length (got %d bytes, max %d)", i+1, len(v), x.sqllen)
+			}
+		}
+	}
+	return nil
+}
+
+// writeArrays writes the slice arguments of ARRAY parameters and returns
+// args with them replaced by the array ids.
+func (stmt *firebirdsqlStmt) writeArrays(args []driver.Value) ([]driver.Value, error) {
+	var values []driver.Value
+	for i, arg := range args {
+		if !isArrayArg(arg) {
+			continue
+		}
+		if i >= len(stmt.bindXsqlda) || stmt.bindXsqlda[i].sqltype != SQL_TYPE_ARRAY {
+			return nil, fmt.Errorf("firebirdsql: parameter %d is not an array column", i+1)
+		}
+		d, ok := stmt.bindArrays[i]
+		if !ok {
+			var err error
+			d, err = loadArrayDesc(stmt.tx.fc, stmt.bindXsqlda[i].relname, stmt.bindXsqlda[i].fieldname)
+			if err != nil {
+				return nil, err
+			}
+			if stmt.bindArrays == nil {
+				stmt.bindArrays = make(map[int]*arrayDesc)
+			}
+			stmt.bindArrays[i] = d
+		}
+		slice, err := d.encode(arg)
+		if err != nil {
+			return nil, err
+		}
+		if values == nil {
+			values = append([]driver.Value(nil), args...)
+		}
+		values[i], err = stmt.wp.putArray(d, slice, stmt.tx.transHandle)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if values == nil {
+		return args, nil
+	}
+	return values, nil
+}
+
 func (stmt *firebirdsqlStmt) Exec(args []driver.Value) (result driver.Result, err error) {
-	stmt.wp.opExecute(stmt.stmtHandle, stmt.tx.transHandle, args)
-	_, _, _, err = stmt.wp.opResponse()
+	defer stmt.reportStats()
+	defer stmt.collectStats()()
+	args, outs, err := splitOutArgs(args)
 	if err != nil {
 		return
 	}
-	stmt.wp.opInfoSql(stmt.stmtHandle, []byte{isc_info_sql_records})
-	_, _, buf, err := stmt.wp.opResponse()
+	if len(outs) > 0 {
+		// checked before the execution, which can't be undone
+		n := 0
+		if stmt.stmtType == isc_info_sql_stmt_exec_procedure {
+			n = len(stmt.xsqlda)
+		}
+		if len(outs) > n {
+			err = fmt.Errorf("firebirdsql: %d sql.Out arguments for %d output columns", len(outs), n)
+			return
+		}
+	}
+	err = stmt.describeBind(args)
+	if err != nil {
+		return
+	}
+	args, err = stmt.writeArrays(args)
 	if err != nil {
 		return
 	}
 
-	var rowcount int64
-	if len(buf) >= 32 {
-		if stmt.stmtType == isc_info_sql_stmt_select {
-			rowcount = int64(bytes_to_int32(buf[20:24]))
-		} else {
-			rowcount = int64(bytes_to_int32(buf[27:31]) + bytes_to_int32(buf[6:10]) + bytes_to_int32(buf[13:17]))
+	var returning []driver.Value
+	if stmt.stmtType == isc_info_sql_stmt_exec_procedure && len(stmt.xsqlda) > 0 {
+		// INSERT ... RETURNING, EXECUTE PROCEDURE with output parameters
+		err = stmt.wp.opExecute2(stmt.stmtHandle, stmt.tx.transHandle, args, stmt.bindXsqlda, stmt.blr)
+		if err != nil {
+			return
+		}
+		returning, err = stmt.wp.opSqlResponse(stmt.xsqlda)
+		if err != nil {
+			return
+		}
+		_, _, _, err = stmt.wp.opResponse()
+		if err != nil {
+			return
+		}
+		rows := newFirebirdsqlRows(stmt, nil)
+		for i, v := range returning {
+			returning[i], err = rows.columnValue(i, v)
+			if err != nil {
+				return
+			}
+		}
+		err = assignOutArgs(outs, returning)
+		if err != nil {
+			return
 		}
 	} else {
-		rowcount = 0
+		err = stmt.wp.opExecute(stmt.stmtHandle, stmt.tx.transHandle, args, stmt.bindXsqlda)
+		if err != nil {
+			return
+		}
+		_, _, _, err = stmt.wp.opResponse()
+		if err != nil {
+			return
+		}
+	}
+	stmt.wp.opInfoSql(stmt.stmtHandle, []byte{isc_info_sql_records}, BUFFER_LEN)
+	_, _, buf, err := stmt.wp.opResponse()
+	if err != nil {
+		return
 	}
 
+	rowcount := parseRecordCount(buf, stmt.stmtType)
+
 	result = &firebirdsqlResult{
 		affectedRows: rowcount,
+		returning:    returning,
 	}
 	return
 }
 
+// arrayValue reads the array of the column i. The array description is
+// loaded at the first time.
+func (stmt *firebirdsqlStmt) arrayValue(i int, arrayId []byte) (driver.Value, error) {
+	d, ok := stmt.arrayDescs[i]
+	if !ok {
+		var err error
+		d, err = loadArrayDesc(stmt.tx.fc, stmt.xsqlda[i].relname, stmt.xsqlda[i].fieldname)
+		if err != nil {
+			return nil, err
+		}
+		if stmt.arrayDescs == nil {
+			stmt.arrayDescs = make(map[int]*arrayDesc)
+		}
+		stmt.arrayDescs[i] = d
+	}
+	return stmt.wp.getArray(d, arrayId, stmt.tx.transHandle)
+}
+
+// parseRecordCount returns the number of rows affected by the statement
+// from the isc_info_sql_records response.
+func parseRecordCount(buf []byte, stmtType int32) int64 {
+	counts := make(map[byte]int64)
+	if len(buf) >= 3 && buf[0] == isc_info_sql_records {
+		for i := 3; i+7 <= len(buf) && buf[i] != isc_info_end; {
+			ln := int(bytes_to_int16(buf[i+1 : i+3]))
+			if i+3+ln > len(buf) {
+				break
+			}
+			counts[buf[i]] = int64(bytes_to_int32(buf[i+3 : i+3+ln]))
+			i += 3 + ln
+		}
+	}
+
+	switch stmtType {
+	case isc_info_sql_stmt_select, isc_info_sql_stmt_select_for_upd:
+		return counts[isc_info_req_select_count]
+	case isc_info_sql_stmt_insert:
+		return counts[isc_info_req_insert_count]
+	case isc_info_sql_stmt_update:
+		return counts[isc_info_req_update_count]
+	case isc_info_sql_stmt_delete:
+		return counts[isc_info_req_delete_count]
+	}
+	// EXECUTE PROCEDURE, EXECUTE BLOCK, UPDATE OR INSERT ... RETURNING
+	return counts[isc_info_req_insert_count] + counts[isc_info_req_update_count] + counts[isc_info_req_delete_count]
+}
+
 func (stmt *firebirdsqlStmt) Query(args []driver.Value) (rows driver.Rows, err error) {
+	defer func() {
+		if err != nil {
+			stmt.reportStats() // else by the close of the rows
+		}
+	}()
+	defer stmt.collectStats()()
+	err = stmt.describeBind(args)
+	if err != nil {
+		return
+	}
+	args, err = stmt.writeArrays(args)
+	if err != nil {
+		return
+	}
 	if stmt.stmtType == isc_info_sql_stmt_exec_procedure {
-		stmt.wp.opExecute2(stmt.stmtHandle, stmt.tx.transHandle, args, stmt.blr)
-		result, _ := stmt.wp.opSqlResponse(stmt.xsqlda)
-		rows = newFirebirdsqlRows(stmt, result)
+		err = stmt.wp.opExecute2(stmt.stmtHandle, stmt.tx.transHandle, args, stmt.bindXsqlda, stmt.blr)
+		if err != nil {
+			return
+		}
+		var result []driver.Value
+		result, err = stmt.wp.opSqlResponse(stmt.xsqlda)
+		if err != nil {
+			return
+		}
 		_, _, _, err = stmt.wp.opResponse()
+		if err != nil {
+			return
+		}
+		// the output parameters are one row, decoded now so the
+		// transaction may be committed before it is read
+		r := newFirebirdsqlRows(stmt, nil)
+		if len(stmt.xsqlda) > 0 {
+			for i, v := range result {
+				result[i], err = r.columnValue(i, v)
+				if err != nil {
+					return
+				}
+			}
+			r.result = result
+		}
+		rows = r
 	} else {
-		stmt.wp.opExecute(stmt.stmtHandle, stmt.tx.transHandle, args)
+		if stmt.scrollable {
+			stmt.wp.cursorFlags = CURSOR_TYPE_SCROLLABLE
+		}
+		err = stmt.wp.opExecute(stmt.stmtHandle, stmt.tx.transHandle, args, stmt.bindXsqlda)
+		stmt.wp.cursorFlags = 0
+		if err != nil {
+			return
+		}
 		_, _, _, err = stmt.wp.opResponse()
 		rows = newFirebirdsqlRows(stmt, nil)
 	}
 	return
 }
 
+type statementTimeoutKey struct{}
+
+// WithStatementTimeout returns a copy of ctx whose ExecContext and
+// QueryContext time out after timeout (rounded down to milliseconds).
+// Firebird 4 times out the statement itself, including the fetches of
+// the rows. An older server is sent a cancel like for the deadline of
+// ctx, and the fetches are not timed out. Check the error with
+// IsStatementTimeout.
+func WithStatementTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, statementTimeoutKey{}, timeout)
+}
+
+func (stmt *firebirdsqlStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (result driver.Result, err error) {
+	values, err := bindNamedValues(stmt.paramNames, args)
+	if err != nil {
+		return
+	}
+	err = stmt.wp.dsn.observe(ctx, QueryOpExec, stmt.query, func(ctx context.Context) (int64, error) {
+		return rowsAffected(result, stmt.wp.withContext(ctx, func() (err error) {
+			result, err = stmt.Exec(values)
+			return
+		}))
+	})
+	return
+}
+
+func (stmt *firebirdsqlStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (rows driver.Rows, err error) {
+	values, err := bindNamedValues(stmt.paramNames, args)
+	if err != nil {
+		return
+	}
+	err = stmt.wp.dsn.observe(ctx, QueryOpQuery, stmt.query, func(ctx context.Context) (int64, error) {
+		return -1, stmt.wp.withContext(ctx, func() (err error) {
+			rows, err = stmt.Query(values)
+			return
+		})
+	})
+	return
+}
+
 func newFirebirdsqlStmt(fc *firebirdsqlConn, query string) (stmt *firebirdsqlStmt, err error) {
 	stmt = new(firebirdsqlStmt)
 	stmt.wp = fc.wp
 	stmt.tx = fc.tx
+	stmt.query = query
+	query, stmt.paramNames = rewriteNamedParams(query)
 
 	fc.wp.opAllocateStatement()
 
@@ -120,8 +616,23 @@ func newFirebirdsqlStmt(fc *firebirdsqlConn, query string) (stmt *firebirdsqlStm
 		return
 	}
 
-	stmt.stmtType, stmt.xsqlda, err = fc.wp.parse_xsqlda(buf, stmt.stmtHandle)
+	stmt.stmtType, stmt.xsqlda, stmt.bindXsqlda, err = fc.wp.parse_xsqlda(buf, stmt.stmtHandle)
+	if err != nil {
+		return
+	}
+	stmt.bindDescribed = stmt.bindXsqlda != nil
 	stmt.blr = calcBlr(stmt.xsqlda)
 
+	for _, x := range stmt.xsqlda {
+		if isTimeZoneType(x.sqltype) && !fc.timeZoneNamesTried {
+			// names are optional, offsets are used if they can't be read
+			fc.timeZoneNamesTried = true
+			if err := loadTimeZoneNames(fc); err != nil {
+				debugPrint(fc.wp, fmt.Sprintf("loadTimeZoneNames():%v", err))
+			}
+			break
+		}
+	}
+
 	return
 }
@@ -0,0 +1,143 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"database/sql/driver"
+	"testing"
+)
+
+func TestParseRecordCount(t *testing.T) {
+	// isc_info_sql_records response of an UPDATE of 3 rows
+	buf := []byte{
+		isc_info_sql_records, 29, 0,
+		isc_info_req_update_count, 4, 0, 3, 0, 0, 0,
+		isc_info_req_delete_count, 4, 0, 0, 0, 0, 0,
+		isc_info_req_select_count, 4, 0, 5, 0, 0, 0,
+		isc_info_req_insert_count, 4, 0, 1, 0, 0, 0,
+		isc_info_end,
+	}
+	var tests = []struct {
+		stmtType int32
+		want     int64
+	}{
+		{isc_info_sql_stmt_update, 3},
+		{isc_info_sql_stmt_delete, 0},
+		{isc_info_sql_stmt_insert, 1},
+		{isc_info_sql_stmt_select, 5},
+		{isc_info_sql_stmt_exec_procedure, 4},
+	}
+	for _, tt := range tests {
+		if got := parseRecordCount(buf, tt.stmtType); got != tt.want {
+			t.Errorf("stmt type %d: %d != %d", tt.stmtType, got, tt.want)
+		}
+	}
+
+	if got := parseRecordCount([]byte{isc_info_end}, isc_info_sql_stmt_update); got != 0 {
+		t.Errorf("empty response: %d", got)
+	}
+}
+
+func TestCheckParamLengths(t *testing.T) {
+	bindXsqlda := []xSQLVAR{
+		{sqltype: SQL_TYPE_LONG, sqllen: 4},
+		{sqltype: SQL_TYPE_VARYING, sqlsubtype: 4, sqllen: 40}, // UTF8 VARCHAR(10)
+		{sqltype: SQL_TYPE_TEXT, sqlsubtype: 1, sqllen: 4},     // OCTETS CHAR(4)
+	}
+	var tests = []struct {
+		args     []driver.Value
+		expected string
+	}{
+		{[]driver.Value{int64(1), "0123456789", []byte("abcd")}, ""},
+		{[]driver.Value{int64(1), "日本語の文字列は十文字", nil}, "firebirdsql: parameter 2 exceeds column length (got 11 chars, max 10)"},
+		{[]driver.Value{int64(1), "日本語", []byte("abcde")}, "firebirdsql: parameter 3 exceeds column length (got 5 bytes, max 4)"},
+		{[]driver.Value{"longer than an integer column", "", nil, "extra"}, ""},
+	}
+	for _, tt := range tests {
+		err := checkParamLengths(tt.args, bindXsqlda)
+		if (err == nil && tt.expected != "") || (err != nil && err.Error() != tt.expected) {
+			t.Errorf("checkParamLengths(%v): expected %q, got %v", tt.args, tt.expected, err)
+		}
+	}
+}
+
+func TestStatementType(t *testing.T) {
+	var st StatementTyper = &firebirdsqlStmt{stmtType: isc_info_sql_stmt_exec_procedure}
+	if st.StatementType() != StatementExecProcedure || st.StatementType().String() != "EXECUTE PROCEDURE" {
+		t.Errorf("unexpected statement type %v", st.StatementType())
+	}
+	if s := StatementType(99).String(); s != "StatementType(99)" {
+		t.Errorf("unexpected name %s", s)
+	}
+}
+
+func TestSetCursorNameWithoutCursor(t *testing.T) {
+	var cn CursorNamer = &firebirdsqlStmt{stmtType: isc_info_sql_stmt_update}
+	if err := cn.SetCursorName("c"); err == nil {
+		t.Errorf("the cursor of an UPDATE was named")
+	}
+	cn = &firebirdsqlStmt{stmtType: isc_info_sql_stmt_select}
+	if err := cn.SetCursorName(""); err == nil {
+		t.Errorf("an empty cursor name was accepted")
+	}
+}
+
+func TestSetScrollable(t *testing.T) {
+	var s Scrollable = &firebirdsqlStmt{stmtType: isc_info_sql_stmt_insert, wp: &wireProtocol{protocolVersion: PROTOCOL_VERSION18}}
+	if err := s.SetScrollable(true); err == nil {
+		t.Errorf("the cursor of an INSERT was scrollable")
+	}
+	stmt := &firebirdsqlStmt{stmtType: isc_info_sql_stmt_select, wp: &wireProtocol{protocolVersion: PROTOCOL_VERSION16}}
+	if err := stmt.SetScrollable(true); err == nil {
+		t.Errorf("a cursor of protocol 16 was scrollable")
+	}
+	if err := (&firebirdsqlRows{stmt: stmt}).Scroll(FetchLast, 0, nil); err == nil {
+		t.Errorf("a cursor which is not scrollable was scrolled")
+	}
+	stmt.wp.protocolVersion = PROTOCOL_VERSION18
+	if err := stmt.SetScrollable(true); err != nil || !stmt.scrollable {
+		t.Errorf("SetScrollable: %v", err)
+	}
+}
+
+func TestParsePlan(t *testing.T) {
+	plan := "\nPLAN (RDB$DATABASE NATURAL)"
+	buf := append([]byte{isc_info_sql_get_plan, byte(len(plan)), 0}, plan...)
+	buf = append(buf, isc_info_end)
+	if s, err := parsePlan(buf, isc_info_sql_get_plan); err != nil || s != "PLAN (RDB$DATABASE NATURAL)" {
+		t.Errorf("plan: %q %v", s, err)
+	}
+	if s, err := parsePlan([]byte{isc_info_sql_get_plan, 0, 0, isc_info_end}, isc_info_sql_get_plan); err != nil || s != "" {
+		t.Errorf("no plan: %q %v", s, err)
+	}
+	for _, bad := range [][]byte{
+		{isc_info_truncated},
+		{isc_info_sql_explain_plan, 200, 0, '\n', 'S'},
+		{4, 0, 0, isc_info_end}, // isc_info_error
+	} {
+		if _, err := parsePlan(bad, isc_info_sql_explain_plan); err == nil {
+			t.Errorf("%v was accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import "time"
+
+// StatementStats are the wire protocol counters of an execution of a
+// statement with the fetches of its rows, reported to Connector.Stats.
+// Firebird does not report its execution time, and Wait is the time spent
+// waiting for the responses, which is the server time and the network.
+type StatementStats struct {
+	Fetches       int // op_fetch round trips
+	RoundTrips    int // responses, including the fetches
+	BytesSent     int64
+	BytesReceived int64
+	Wait          time.Duration
+}
+
+// collectStats counts the wire protocol of stmt until the returned func
+// is called. Nothing is counted without a Connector.Stats.
+func (stmt *firebirdsqlStmt) collectStats() func() {
+	if stmt.wp.dsn.stats == nil {
+		return noStats
+	}
+	stmt.wp.stats = &stmt.stats
+	return func() { stmt.wp.stats = nil }
+}
+
+func noStats() {}
+
+// reportStats reports the counters of the execution of stmt, and resets
+// them for the next.
+func (stmt *firebirdsqlStmt) reportStats() {
+	if report := stmt.wp.dsn.stats; report != nil {
+		report(stmt.query, stmt.stats)
+		stmt.stats = StatementStats{}
+	}
+}
+
+// roundTrip is called when the response of op arrives, to log and count
+// the time since the packets were sent.
+func (p *wireProtocol) roundTrip(op string) {
+	roundTripPrint(p, op)
+	if p.stats != nil {
+		p.writeMutex.Lock()
+		elapsed := time.Since(p.sentAt)
+		p.writeMutex.Unlock()
+		p.stats.RoundTrips++
+		p.stats.Wait += elapsed
+	}
+}
@@ -0,0 +1,78 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"net"
+	"testing"
+)
+
+func TestStatementStats(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	var reported []StatementStats
+	p := &wireProtocol{dsn: &firebirdDsn{}}
+	p.conn, _ = newWireChannel(c2)
+	stmt := &firebirdsqlStmt{wp: p, query: "SELECT 1 FROM rdb$database"}
+
+	// nothing is counted without a Connector.Stats
+	done := stmt.collectStats()
+	if p.stats != nil {
+		t.Fatalf("stats are collected without Connector.Stats")
+	}
+	done()
+
+	p.dsn.stats = func(query string, stats StatementStats) {
+		if query != stmt.query {
+			t.Errorf("unexpected query %q", query)
+		}
+		reported = append(reported, stats)
+	}
+	done = stmt.collectStats()
+	go func() {
+		b := make([]byte, 8)
+		c1.Read(b)
+		c1.Write([]byte{1, 2, 3, 4, 5, 6})
+	}()
+	p.packInt(op_fetch)
+	p.packInt(1)
+	p.sendPackets()
+	p.recvPackets(6)
+	p.roundTrip("opFetchResponse")
+	done()
+	p.sendPackets() // not counted
+	stmt.reportStats()
+
+	expected := StatementStats{RoundTrips: 1, BytesSent: 8, BytesReceived: 6}
+	if len(reported) != 1 {
+		t.Fatalf("reported %v", reported)
+	}
+	if got := reported[0]; got.RoundTrips != expected.RoundTrips || got.BytesSent != expected.BytesSent || got.BytesReceived != expected.BytesReceived {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+	if stmt.stats != (StatementStats{}) {
+		t.Errorf("stats are not reset: %+v", stmt.stats)
+	}
+}
@@ -0,0 +1,108 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"container/list"
+)
+
+// stmtCache keeps the prepared statements of a connection by SQL text,
+// the least recently used one is dropped when it is full.
+// A statement is taken out while it is used, and put back when it is closed.
+type stmtCache struct {
+	size    int
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+func newStmtCache(size int) *stmtCache {
+	if size <= 0 {
+		return nil
+	}
+	return &stmtCache{
+		size:    size,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// get takes out the statement for query, or returns nil.
+func (c *stmtCache) get(query string) *firebirdsqlStmt {
+	if c == nil {
+		return nil
+	}
+	e, ok := c.entries[query]
+	if !ok {
+		return nil
+	}
+	c.lru.Remove(e)
+	delete(c.entries, query)
+	return e.Value.(*firebirdsqlStmt)
+}
+
+// put puts back stmt, and returns the statement to be dropped if any.
+func (c *stmtCache) put(stmt *firebirdsqlStmt) *firebirdsqlStmt {
+	if e, ok := c.entries[stmt.query]; ok {
+		if e.Value.(*firebirdsqlStmt) == stmt {
+			// closed twice
+			return nil
+		}
+		// the same query was used at the same time
+		return stmt
+	}
+	c.entries[stmt.query] = c.lru.PushFront(stmt)
+	if c.lru.Len() <= c.size {
+		return nil
+	}
+	e := c.lru.Back()
+	c.lru.Remove(e)
+	evicted := e.Value.(*firebirdsqlStmt)
+	delete(c.entries, evicted.query)
+	return evicted
+}
+
+// clear removes all statements and returns them.
+func (c *stmtCache) clear() []*firebirdsqlStmt {
+	if c == nil {
+		return nil
+	}
+	var stmts []*firebirdsqlStmt
+	for e := c.lru.Front(); e != nil; e = e.Next() {
+		stmts = append(stmts, e.Value.(*firebirdsqlStmt))
+	}
+	c.lru.Init()
+	c.entries = make(map[string]*list.Element)
+	return stmts
+}
+
+// cacheable reports whether the statement can be kept prepared.
+func (stmt *firebirdsqlStmt) cacheable() bool {
+	switch stmt.stmtType {
+	case isc_info_sql_stmt_select, isc_info_sql_stmt_select_for_upd,
+		isc_info_sql_stmt_insert, isc_info_sql_stmt_update, isc_info_sql_stmt_delete,
+		isc_info_sql_stmt_exec_procedure:
+		return true
+	}
+	return false
+}
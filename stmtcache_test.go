@@ -0,0 +1,70 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"testing"
+)
+
+func TestStmtCacheLRU(t *testing.T) {
+	if newStmtCache(0) != nil {
+		t.Fatal("stmt_cache_size=0 must disable the cache")
+	}
+	var disabled *stmtCache
+	if disabled.get("SELECT 1 FROM rdb$database") != nil || disabled.clear() != nil {
+		t.Error("disabled cache returned statements")
+	}
+
+	c := newStmtCache(2)
+	s1 := &firebirdsqlStmt{query: "q1"}
+	s2 := &firebirdsqlStmt{query: "q2"}
+	s3 := &firebirdsqlStmt{query: "q3"}
+	if c.put(s1) != nil || c.put(s2) != nil {
+		t.Fatal("evicted before full")
+	}
+	if c.put(s1) != nil {
+		t.Error("closing twice must be ignored")
+	}
+	if c.get("q1") != s1 {
+		t.Fatal("get q1")
+	}
+	if c.get("q1") != nil {
+		t.Error("q1 is in use")
+	}
+	if c.put(s1) != nil {
+		t.Fatal("put q1 back")
+	}
+	if evicted := c.put(s3); evicted != s2 {
+		t.Errorf("least recently used q2 must be evicted: %v", evicted)
+	}
+	if other := (&firebirdsqlStmt{query: "q3"}); c.put(other) != other {
+		t.Error("the second statement for q3 must be dropped")
+	}
+	if stmts := c.clear(); len(stmts) != 2 || stmts[0] != s3 || stmts[1] != s1 {
+		t.Errorf("clear: %v", stmts)
+	}
+	if c.get("q3") != nil {
+		t.Error("cleared")
+	}
+}
@@ -0,0 +1,162 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Time is a time of day without a date, the value of TIME columns with
+// time_mode=clock. Firebird keeps 1/10000 second, so Nanosecond is a
+// multiple of 100000. As a parameter it is sent as a TIME.
+type Time struct {
+	Hour       int
+	Minute     int
+	Second     int
+	Nanosecond int
+}
+
+// String returns hh:mm:ss, and the fraction of a second if it is not 0.
+func (t Time) String() string {
+	s := fmt.Sprintf("%02d:%02d:%02d", t.Hour, t.Minute, t.Second)
+	if t.Nanosecond != 0 {
+		s += fmt.Sprintf(".%04d", t.Nanosecond/timeFractionNanoseconds)
+	}
+	return s
+}
+
+// Value implements driver.Valuer for the other drivers, with a time.Time
+// on the day 0 in UTC. This driver sends the Time as a TIME.
+func (t Time) Value() (driver.Value, error) {
+	return time.Date(0, 1, 1, t.Hour, t.Minute, t.Second, t.Nanosecond, time.UTC), nil
+}
+
+// parseTimeOfDay parses hh:mm[:ss[.fraction]].
+func parseTimeOfDay(s string) (t Time, err error) {
+	hms, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		hms, frac = s[:i], s[i+1:]
+	}
+	parts := strings.Split(hms, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return t, fmt.Errorf("firebirdsql: invalid time %s", s)
+	}
+	fields := []*int{&t.Hour, &t.Minute, &t.Second}
+	for i, p := range parts {
+		if *fields[i], err = strconv.Atoi(p); err != nil {
+			return t, fmt.Errorf("firebirdsql: invalid time %s", s)
+		}
+	}
+	if frac != "" {
+		if len(frac) > 9 {
+			frac = frac[:9]
+		}
+		if t.Nanosecond, err = strconv.Atoi(frac + strings.Repeat("0", 9-len(frac))); err != nil {
+			return t, fmt.Errorf("firebirdsql: invalid time %s", s)
+		}
+	}
+	if t.Hour > 23 || t.Minute > 59 || t.Second > 59 || t.Hour < 0 || t.Minute < 0 || t.Second < 0 {
+		return t, fmt.Errorf("firebirdsql: invalid time %s", s)
+	}
+	return t, nil
+}
+
+// Scan implements sql.Scanner. It takes the clock of a time.Time, so it
+// scans TIME columns of both time_mode. The fraction is truncated to
+// 1/10000 second.
+func (t *Time) Scan(src interface{}) (err error) {
+	switch v := src.(type) {
+	case Time:
+		*t = v
+	case time.Time:
+		t.Hour, t.Minute, t.Second = v.Clock()
+		t.Nanosecond = truncateTimeFraction(v.Nanosecond())
+	case string:
+		*t, err = parseTimeOfDay(v)
+		t.Nanosecond = truncateTimeFraction(t.Nanosecond)
+	case []byte:
+		*t, err = parseTimeOfDay(string(v))
+		t.Nanosecond = truncateTimeFraction(t.Nanosecond)
+	default:
+		err = fmt.Errorf("firebirdsql: can't scan %T into Time", src)
+	}
+	return
+}
+
+// Date is a date without a time of day or a location. As a parameter it
+// is sent as a DATE, without the conversion of a time.Time.
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// String returns yyyy-mm-dd.
+func (d Date) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, int(d.Month), d.Day)
+}
+
+// Value implements driver.Valuer for the other drivers, with a time.Time
+// at midnight UTC. This driver sends the Date.
+func (d Date) Value() (driver.Value, error) {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, time.UTC), nil
+}
+
+// Scan implements sql.Scanner. It takes the date of a time.Time, or
+// yyyy-mm-dd.
+func (d *Date) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case Date:
+		*d = v
+	case time.Time:
+		d.Year, d.Month, d.Day = v.Date()
+	case string, []byte:
+		s := fmt.Sprintf("%s", v)
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return fmt.Errorf("firebirdsql: invalid date %s", s)
+		}
+		d.Year, d.Month, d.Day = t.Date()
+	default:
+		return fmt.Errorf("firebirdsql: can't scan %T into Date", src)
+	}
+	return nil
+}
+
+// RawTimestamp is a DATE or TIMESTAMP out of 0001-01-01 .. 9999-12-31
+// with timestamp_overflow=raw, in the components of its wire value in the
+// proleptic Gregorian calendar. Hour is above 23 for a time out of a day.
+type RawTimestamp struct {
+	Year       int
+	Month      int
+	Day        int
+	Hour       int
+	Minute     int
+	Second     int
+	Nanosecond int
+}
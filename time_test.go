@@ -0,0 +1,190 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+)
+
+func TestTimeString(t *testing.T) {
+	if s := (Time{4, 5, 6, 0}).String(); s != "04:05:06" {
+		t.Errorf("got %s", s)
+	}
+	if s := (Time{23, 59, 59, 123400000}).String(); s != "23:59:59.1234" {
+		t.Errorf("got %s", s)
+	}
+}
+
+func TestTimeScan(t *testing.T) {
+	var tests = []struct {
+		src      interface{}
+		expected Time
+	}{
+		{Time{1, 2, 3, 0}, Time{1, 2, 3, 0}},
+		{time.Date(0, 1, 1, 4, 5, 6, 1234567, time.UTC), Time{4, 5, 6, 1200000}},
+		{"12:30", Time{12, 30, 0, 0}},
+		{[]byte("12:30:15.25"), Time{12, 30, 15, 250000000}},
+	}
+	for _, tt := range tests {
+		var v Time
+		if err := v.Scan(tt.src); err != nil || v != tt.expected {
+			t.Errorf("%v: expected %v, got %v %v", tt.src, tt.expected, v, err)
+		}
+	}
+	for _, src := range []interface{}{"24:00:00", "12", "ab:cd", 1} {
+		var v Time
+		if err := v.Scan(src); err == nil {
+			t.Errorf("%v was accepted", src)
+		}
+	}
+}
+
+func TestTimeModeClock(t *testing.T) {
+	raw := _convert_time(time.Date(0, 1, 1, 4, 5, 6, 700000000, time.UTC))
+	x := &xSQLVAR{sqltype: SQL_TYPE_TIME}
+	v, err := x.value(raw, &firebirdDsn{timeMode: TIME_MODE_CLOCK})
+	if err != nil || v != (Time{4, 5, 6, 700000000}) {
+		t.Errorf("time_mode=clock: %v %v", v, err)
+	}
+	v, _ = x.value(raw, &firebirdDsn{})
+	if _, ok := v.(time.Time); !ok {
+		t.Errorf("default time_mode: %T", v)
+	}
+
+	dsn, _ := parseDSN("user:password@localhost/dbname?time_mode=clock")
+	if dsn.timeMode != TIME_MODE_CLOCK {
+		t.Errorf("time_mode=clock: %d", dsn.timeMode)
+	}
+	if _, err = parseDSN("user:password@localhost/dbname?time_mode=foo"); err == nil {
+		t.Errorf("invalid time_mode was accepted")
+	}
+}
+
+func TestTimeFractionRoundTrip(t *testing.T) {
+	x := &xSQLVAR{sqltype: SQL_TYPE_TIMESTAMP}
+	for _, ns := range []int{0, 100000, 123456000, 999999999, 500050} {
+		ts := time.Date(2021, 2, 3, 23, 59, 59, ns, time.UTC)
+		raw := append(_convert_date(ts), _convert_time(ts)...)
+		v, _ := x.value(raw, &firebirdDsn{})
+		expected := time.Date(2021, 2, 3, 23, 59, 59, truncateTimeFraction(ns), time.UTC)
+		if !v.(time.Time).Equal(expected) {
+			t.Errorf("%d: expected %v, got %v", ns, expected, v)
+		}
+		// the value read back is written unchanged
+		raw2 := append(_convert_date(v.(time.Time)), _convert_time(v.(time.Time))...)
+		if string(raw2) != string(raw) {
+			t.Errorf("%d: %v != %v", ns, raw2, raw)
+		}
+	}
+}
+
+func TestParamsToBlrDateTime(t *testing.T) {
+	p := &wireProtocol{dsn: &firebirdDsn{dialect: 3}, protocolVersion: PROTOCOL_VERSION13}
+	tm := time.Date(2026, 10, 14, 12, 30, 15, 123400000, time.UTC)
+	blr, v, err := p.paramsToBlr(0, []driver.Value{Date{2026, time.October, 14}}, nil, p.protocolVersion)
+	if err != nil || blr[6] != 12 || string(v[4:]) != string(_convert_date(tm)) {
+		t.Errorf("Date: %v %v %v", blr, v, err)
+	}
+	blr, v, err = p.paramsToBlr(0, []driver.Value{Time{12, 30, 15, 123400000}}, nil, p.protocolVersion)
+	if err != nil || blr[6] != 13 || string(v[4:]) != string(_convert_time(tm)) {
+		t.Errorf("Time: %v %v %v", blr, v, err)
+	}
+
+	// the encoding is the inverse of _parseDate and _parseTime
+	x := &xSQLVAR{}
+	for _, d := range []Date{{1858, 11, 17}, {1, 1, 1}, {2000, 2, 29}, {9999, 12, 31}} {
+		year, month, day := x._parseDate(bint32_to_bytes(_encodeDate(d.Year, int(d.Month), d.Day)))
+		if (Date{year, time.Month(month), day}) != d {
+			t.Errorf("%v: got %d-%d-%d", d, year, month, day)
+		}
+	}
+	h, m, s, ns := x._parseTime(encodeTime(23, 59, 59, 999900000))
+	if (Time{h, m, s, ns}) != (Time{23, 59, 59, 999900000}) {
+		t.Errorf("got %d:%d:%d.%d", h, m, s, ns)
+	}
+}
+
+func TestDateValueScan(t *testing.T) {
+	d := Date{2026, time.October, 14}
+	if d.String() != "2026-10-14" {
+		t.Errorf("got %s", d)
+	}
+	v, err := d.Value()
+	if err != nil || !v.(time.Time).Equal(time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Value: %v %v", v, err)
+	}
+	v, _ = (Time{1, 2, 3, 0}).Value()
+	if !v.(time.Time).Equal(time.Date(0, 1, 1, 1, 2, 3, 0, time.UTC)) {
+		t.Errorf("Time.Value: %v", v)
+	}
+	for _, src := range []interface{}{d, time.Date(2026, 10, 14, 23, 0, 0, 0, time.UTC), "2026-10-14", []byte("2026-10-14")} {
+		var got Date
+		if err := got.Scan(src); err != nil || got != d {
+			t.Errorf("%v: got %v %v", src, got, err)
+		}
+	}
+	var got Date
+	if err := got.Scan("2026-13-01"); err == nil {
+		t.Errorf("an invalid date was scanned")
+	}
+}
+
+func TestTimestampOverflow(t *testing.T) {
+	// 10000-01-01 12:00 is beyond the range of Firebird
+	raw := append(bint32_to_bytes(_encodeDate(10000, 1, 1)), encodeTime(12, 0, 0, 0)...)
+	x := &xSQLVAR{sqltype: SQL_TYPE_TIMESTAMP}
+	if v, err := x.value(raw, &firebirdDsn{}); err == nil {
+		t.Errorf("default timestamp_overflow: got %v", v)
+	}
+	v, err := x.value(raw, &firebirdDsn{timestampOverflow: TIMESTAMP_OVERFLOW_CLAMP})
+	if err != nil || !v.(time.Time).Equal(time.Date(9999, 12, 31, 23, 59, 59, 999900000, time.UTC)) {
+		t.Errorf("timestamp_overflow=clamp: %v %v", v, err)
+	}
+	v, err = x.value(raw, &firebirdDsn{timestampOverflow: TIMESTAMP_OVERFLOW_RAW})
+	if err != nil || v != (RawTimestamp{10000, 1, 1, 12, 0, 0, 0}) {
+		t.Errorf("timestamp_overflow=raw: %v %v", v, err)
+	}
+
+	d := &xSQLVAR{sqltype: SQL_TYPE_DATE}
+	v, err = d.value(bint32_to_bytes(_encodeDate(0, 12, 31)), &firebirdDsn{timestampOverflow: TIMESTAMP_OVERFLOW_CLAMP})
+	if err != nil || !v.(time.Time).Equal(time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("DATE before 0001-01-01 clamped: %v %v", v, err)
+	}
+	// a time out of a day is clamped in its day
+	raw = append(bint32_to_bytes(_encodeDate(2026, 10, 14)), bint32_to_bytes(timeOfDayUnits+5)...)
+	v, err = x.value(raw, &firebirdDsn{timestampOverflow: TIMESTAMP_OVERFLOW_CLAMP})
+	if err != nil || !v.(time.Time).Equal(time.Date(2026, 10, 14, 23, 59, 59, 999900000, time.UTC)) {
+		t.Errorf("time out of a day clamped: %v %v", v, err)
+	}
+
+	dsn, _ := parseDSN("user:password@localhost/dbname?timestamp_overflow=raw")
+	if dsn.timestampOverflow != TIMESTAMP_OVERFLOW_RAW {
+		t.Errorf("timestamp_overflow=raw: %d", dsn.timestampOverflow)
+	}
+	if _, err = parseDSN("user:password@localhost/dbname?timestamp_overflow=foo"); err == nil {
+		t.Errorf("invalid timestamp_overflow was accepted")
+	}
+}
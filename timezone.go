@@ -0,0 +1,131 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Firebird 4 time zone ids. Ids up to 2 * 1439 are a fixed offset of
+// (id - 1439) minutes, larger ids are regions listed in RDB$TIME_ZONES.
+// The ids are fixed by the engine so one table is shared by all connections.
+const (
+	TIME_ZONE_OFFSET_MAX = 1439
+	TIME_ZONE_GMT_ID     = 65535
+)
+
+var (
+	timeZoneMutex     sync.RWMutex
+	timeZoneNames     map[int]string
+	timeZoneLocations = map[int]*time.Location{}
+)
+
+func isTimeZoneType(sqltype int) bool {
+	switch sqltype {
+	case SQL_TYPE_TIMESTAMP_TZ, SQL_TYPE_TIMESTAMP_TZ_EX, SQL_TYPE_TIME_TZ, SQL_TYPE_TIME_TZ_EX:
+		return true
+	}
+	return false
+}
+
+func offsetZone(minutes int) *time.Location {
+	sign := "+"
+	m := minutes
+	if m < 0 {
+		sign = "-"
+		m = -m
+	}
+	return time.FixedZone(fmt.Sprintf("%s%02d:%02d", sign, m/60, m%60), minutes*60)
+}
+
+func loadTimeZoneNames(fc *firebirdsqlConn) (err error) {
+	timeZoneMutex.RLock()
+	loaded := timeZoneNames != nil
+	timeZoneMutex.RUnlock()
+	if loaded {
+		return
+	}
+
+	rows, err := fc.Query("SELECT RDB$TIME_ZONE_ID, RDB$TIME_ZONE_NAME FROM RDB$TIME_ZONES", nil)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	names := map[int]string{}
+	dest := make([]driver.Value, 2)
+	for rows.Next(dest) == nil {
+		var id int
+		switch v := dest[0].(type) {
+		case int32:
+			id = int(v)
+		case int64:
+			id = int(v)
+		case int16:
+			id = int(v)
+		}
+		if s, ok := dest[1].(string); ok {
+			names[id] = strings.TrimRight(s, " ")
+		}
+	}
+
+	timeZoneMutex.Lock()
+	timeZoneNames = names
+	timeZoneMutex.Unlock()
+	return
+}
+
+// timeZoneLocation resolves a wire time zone id. If the id is a region
+// which is unknown (or not known to the local tz database) the fixed
+// offset sent by the server is used instead.
+func timeZoneLocation(tzId int, offsetMinutes int) *time.Location {
+	if tzId <= TIME_ZONE_OFFSET_MAX*2 {
+		return offsetZone(tzId - TIME_ZONE_OFFSET_MAX)
+	}
+
+	timeZoneMutex.RLock()
+	loc, ok := timeZoneLocations[tzId]
+	name := timeZoneNames[tzId]
+	timeZoneMutex.RUnlock()
+	if ok {
+		return loc
+	}
+
+	if name == "" && tzId == TIME_ZONE_GMT_ID {
+		name = "GMT"
+	}
+	if name != "" {
+		if loc, err := time.LoadLocation(name); err == nil {
+			timeZoneMutex.Lock()
+			timeZoneLocations[tzId] = loc
+			timeZoneMutex.Unlock()
+			return loc
+		}
+	}
+	return offsetZone(offsetMinutes)
+}
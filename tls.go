@@ -0,0 +1,112 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	tlsConfigsMutex sync.RWMutex
+	tlsConfigs      = map[string]*tls.Config{}
+)
+
+// RegisterTLSConfig registers a *tls.Config, which is used by the
+// connections with tls=name in the DSN.
+func RegisterTLSConfig(name string, config *tls.Config) error {
+	if _, err := strconv.ParseBool(name); err == nil {
+		return fmt.Errorf("firebirdsql: TLS config name %s is reserved", name)
+	}
+	tlsConfigsMutex.Lock()
+	defer tlsConfigsMutex.Unlock()
+	tlsConfigs[name] = config.Clone()
+	return nil
+}
+
+// DeregisterTLSConfig removes the *tls.Config registered as name.
+func DeregisterTLSConfig(name string) {
+	tlsConfigsMutex.Lock()
+	defer tlsConfigsMutex.Unlock()
+	delete(tlsConfigs, name)
+}
+
+// parseTLSConfig returns the *tls.Config of tls, tls_server_name and
+// tls_insecure_skip_verify, or nil without TLS.
+func parseTLSConfig(m url.Values, addr string) (config *tls.Config, err error) {
+	values, ok := m["tls"]
+	if !ok {
+		return nil, nil
+	}
+	if on, err := strconv.ParseBool(values[0]); err == nil {
+		if !on {
+			return nil, nil
+		}
+		config = &tls.Config{}
+	} else {
+		tlsConfigsMutex.RLock()
+		registered, ok := tlsConfigs[values[0]]
+		tlsConfigsMutex.RUnlock()
+		if !ok {
+			return nil, errors.New("invalid tls " + values[0])
+		}
+		config = registered.Clone()
+	}
+
+	values, ok = m["tls_server_name"]
+	if ok {
+		config.ServerName = values[0]
+	}
+	if config.ServerName == "" {
+		config.ServerName, _, _ = net.SplitHostPort(addr)
+	}
+	values, ok = m["tls_insecure_skip_verify"]
+	if ok {
+		config.InsecureSkipVerify, err = strconv.ParseBool(values[0])
+		if err != nil {
+			return nil, errors.New("invalid tls_insecure_skip_verify")
+		}
+	}
+	return config, nil
+}
+
+// startTLS wraps the connection in TLS before the protocol handshake.
+func startTLS(conn net.Conn, d *firebirdDsn) (net.Conn, error) {
+	tc := tls.Client(conn, d.tlsConfig)
+	if d.connectTimeout > 0 {
+		tc.SetDeadline(time.Now().Add(d.connectTimeout))
+	}
+	if err := tc.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	tc.SetDeadline(time.Time{})
+	return tc, nil
+}
@@ -0,0 +1,72 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"crypto/tls"
+	"testing"
+)
+
+func TestDSNParseTLS(t *testing.T) {
+	dsn, _ := parseDSN("user:password@localhost/dbname")
+	if dsn.tlsConfig != nil {
+		t.Errorf("TLS without tls")
+	}
+	dsn, _ = parseDSN("user:password@localhost/dbname?tls=false")
+	if dsn.tlsConfig != nil {
+		t.Errorf("TLS with tls=false")
+	}
+
+	dsn, err := parseDSN("user:password@db.example.com:3051/dbname?tls=true&wire_crypt=disabled")
+	if err != nil {
+		t.Fatalf("parseDSN: %v", err)
+	}
+	if dsn.tlsConfig == nil || dsn.tlsConfig.ServerName != "db.example.com" || dsn.tlsConfig.InsecureSkipVerify {
+		t.Errorf("tls=true: %+v", dsn.tlsConfig)
+	}
+	if dsn.wireCrypt != WIRE_CRYPT_DISABLED {
+		t.Errorf("wire_crypt=disabled: %d", dsn.wireCrypt)
+	}
+
+	dsn, _ = parseDSN("user:password@10.0.0.1/dbname?tls=1&tls_server_name=db&tls_insecure_skip_verify=true")
+	if dsn.tlsConfig.ServerName != "db" || !dsn.tlsConfig.InsecureSkipVerify {
+		t.Errorf("tls_server_name and tls_insecure_skip_verify: %+v", dsn.tlsConfig)
+	}
+
+	if _, err = parseDSN("user:password@localhost/dbname?tls=custom"); err == nil {
+		t.Errorf("unregistered TLS config was accepted")
+	}
+	if err = RegisterTLSConfig("true", &tls.Config{}); err == nil {
+		t.Errorf("reserved TLS config name was accepted")
+	}
+	RegisterTLSConfig("custom", &tls.Config{ServerName: "fb", MinVersion: tls.VersionTLS13})
+	defer DeregisterTLSConfig("custom")
+	dsn, err = parseDSN("user:password@localhost/dbname?tls=custom")
+	if err != nil {
+		t.Fatalf("parseDSN: %v", err)
+	}
+	if dsn.tlsConfig.ServerName != "fb" || dsn.tlsConfig.MinVersion != tls.VersionTLS13 {
+		t.Errorf("tls=custom: %+v", dsn.tlsConfig)
+	}
+}
@@ -23,71 +23,255 @@ CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
 
 package firebirdsql
 
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+)
+
+// Savepointer is implemented by the driver connection for the savepoints
+// of its current transaction. Get it with sql.Conn.Raw.
+type Savepointer interface {
+	Savepoint(name string) (string, error)
+	ReleaseSavepoint(name string) error
+	RollbackToSavepoint(name string) error
+}
+
+// Retainer is implemented by the driver connection to commit or roll back
+// the work of its current transaction and keep the transaction going.
+// Get it with sql.Conn.Raw.
+type Retainer interface {
+	CommitRetaining() error
+	RollbackRetaining() error
+}
+
+// TransactionPreparer is implemented by the driver connection for the
+// two-phase commit of its current transaction with other resource managers.
+// Get it with sql.Conn.Raw.
+type TransactionPreparer interface {
+	PrepareTransaction(description []byte) error
+}
+
 type firebirdsqlTx struct {
-	fc           *firebirdsqlConn
-	isAutocommit bool
-	transHandle  int32
+	fc             *firebirdsqlConn
+	isAutocommit   bool
+	transHandle    int32
+	isolationLevel int
+	readOnly       bool
+	lockTimeout    int
+	savepoints     []string
+	savepointSeq   int
+	sentPackets    int // of the wire protocol when it began
 }
 
-func (tx *firebirdsqlTx) begin() (err error) {
-	var tpb []byte
-	switch tx.fc.isolationLevel {
+// isolationLevelFromTxOptions maps sql.TxOptions to ISOLATION_LEVEL_*.
+// sql.LevelDefault is the isolation_level of the connection.
+func isolationLevelFromTxOptions(defaultLevel int, opts driver.TxOptions) (int, error) {
+	switch sql.IsolationLevel(opts.Isolation) {
+	case sql.LevelDefault:
+		return defaultLevel, nil
+	case sql.LevelReadCommitted:
+		return ISOLATION_LEVEL_READ_COMMITED, nil
+	case sql.LevelRepeatableRead, sql.LevelSnapshot:
+		return ISOLATION_LEVEL_REPEATABLE_READ, nil
+	case sql.LevelSerializable:
+		return ISOLATION_LEVEL_SERIALIZABLE, nil
+	}
+	return 0, fmt.Errorf("firebirdsql: unsupported isolation level %s", sql.IsolationLevel(opts.Isolation))
+}
+
+type lockTimeoutKey struct{}
+
+// WithLockTimeout returns a context for BeginTx, which overrides the
+// lock_timeout of the connection for the transaction.
+// -1 waits for a lock conflict forever, 0 doesn't wait (isc_tpb_nowait),
+// and a positive value waits for that many seconds.
+func WithLockTimeout(ctx context.Context, seconds int) context.Context {
+	return context.WithValue(ctx, lockTimeoutKey{}, seconds)
+}
+
+func lockResolutionTpb(lockTimeout int) []byte {
+	switch {
+	case lockTimeout < 0:
+		return []byte{byte(isc_tpb_wait)}
+	case lockTimeout == 0:
+		return []byte{byte(isc_tpb_nowait)}
+	}
+	return bytes.Join([][]byte{
+		[]byte{byte(isc_tpb_wait), byte(isc_tpb_lock_timeout), 4},
+		int32_to_bytes(int32(lockTimeout)),
+	}, nil)
+}
+
+func transactionTpb(isolationLevel int, readOnly bool, lockTimeout int) []byte {
+	access := byte(isc_tpb_write)
+	if readOnly || isolationLevel == ISOLATION_LEVEL_READ_COMMITED_READ_ONLY {
+		access = byte(isc_tpb_read)
+	}
+	tpb := []byte{byte(isc_tpb_version3), access}
+	tpb = append(tpb, lockResolutionTpb(lockTimeout)...)
+
+	switch isolationLevel {
 	case ISOLATION_LEVEL_READ_COMMITED_LEGACY:
-		tpb = []byte{
-			byte(isc_tpb_version3),
-			byte(isc_tpb_write),
-			byte(isc_tpb_wait),
-			byte(isc_tpb_read_committed),
-			byte(isc_tpb_no_rec_version),
-		}
-	case ISOLATION_LEVEL_READ_COMMITED:
-		tpb = []byte{
-			byte(isc_tpb_version3),
-			byte(isc_tpb_write),
-			byte(isc_tpb_wait),
-			byte(isc_tpb_read_committed),
-			byte(isc_tpb_rec_version),
-		}
+		tpb = append(tpb, byte(isc_tpb_read_committed), byte(isc_tpb_no_rec_version))
+	case ISOLATION_LEVEL_READ_COMMITED, ISOLATION_LEVEL_READ_COMMITED_READ_ONLY:
+		tpb = append(tpb, byte(isc_tpb_read_committed), byte(isc_tpb_rec_version))
 	case ISOLATION_LEVEL_REPEATABLE_READ:
-		tpb = []byte{
-			byte(isc_tpb_version3),
-			byte(isc_tpb_write),
-			byte(isc_tpb_wait),
-			byte(isc_tpb_concurrency),
-		}
+		tpb = append(tpb, byte(isc_tpb_concurrency))
 	case ISOLATION_LEVEL_SERIALIZABLE:
-		tpb = []byte{
-			byte(isc_tpb_version3),
-			byte(isc_tpb_write),
-			byte(isc_tpb_wait),
-			byte(isc_tpb_consistency),
-		}
-	case ISOLATION_LEVEL_READ_COMMITED_READ_ONLY:
-		tpb = []byte{
-			byte(isc_tpb_version3),
-			byte(isc_tpb_read),
-			byte(isc_tpb_wait),
-			byte(isc_tpb_read_committed),
-			byte(isc_tpb_rec_version),
-		}
+		tpb = append(tpb, byte(isc_tpb_consistency))
 	}
-	tx.fc.wp.opTransaction(tpb)
+	return tpb
+}
+
+func (tx *firebirdsqlTx) begin() (err error) {
+	tx.fc.wp.opTransaction(transactionTpb(tx.isolationLevel, tx.readOnly, tx.lockTimeout))
 	tx.transHandle, _, _, err = tx.fc.wp.opResponse()
+	tx.sentPackets = tx.fc.wp.sentPackets
+	return
+}
+
+// untouched reports whether tx is a read committed autocommit transaction
+// with the settings of the DSN, and nothing was sent since it began, so it
+// can be kept instead of rolled back and begun again. Another isolation
+// level would keep the snapshot of when it began.
+func (tx *firebirdsqlTx) untouched() bool {
+	switch tx.isolationLevel {
+	case ISOLATION_LEVEL_READ_COMMITED_LEGACY, ISOLATION_LEVEL_READ_COMMITED, ISOLATION_LEVEL_READ_COMMITED_READ_ONLY:
+	default:
+		return false
+	}
+	dsn := tx.fc.dsn
+	return tx.isAutocommit && tx.isolationLevel == dsn.isolationLevel &&
+		tx.readOnly == dsn.readOnly && tx.lockTimeout == dsn.lockTimeout &&
+		tx.sentPackets == tx.fc.wp.sentPackets
+}
+
+func (tx *firebirdsqlTx) executeImmediate(query string) (err error) {
+	tx.fc.wp.opExecuteImmediate(tx.transHandle, query)
+	_, _, _, err = tx.fc.wp.opResponse()
 	return
 }
 
+func (tx *firebirdsqlTx) savepointIndex(name string) int {
+	for i := len(tx.savepoints) - 1; i >= 0; i-- {
+		if tx.savepoints[i] == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Savepoint creates a savepoint in the transaction and returns its name.
+// If name is empty, a name SP_1, SP_2... is generated.
+func (tx *firebirdsqlTx) Savepoint(name string) (string, error) {
+	if name == "" {
+		tx.savepointSeq++
+		name = fmt.Sprintf("SP_%d", tx.savepointSeq)
+	}
+	if !isIdentifier(name) {
+		return "", fmt.Errorf("firebirdsql: invalid savepoint name %s", name)
+	}
+	// Using an existing name moves the savepoint
+	if i := tx.savepointIndex(name); i >= 0 {
+		tx.savepoints = append(tx.savepoints[:i], tx.savepoints[i+1:]...)
+	}
+	err := tx.executeImmediate("SAVEPOINT " + name)
+	if err != nil {
+		return "", err
+	}
+	tx.savepoints = append(tx.savepoints, name)
+	return name, nil
+}
+
+// ReleaseSavepoint releases the savepoint and the savepoints created after it.
+func (tx *firebirdsqlTx) ReleaseSavepoint(name string) error {
+	i := tx.savepointIndex(name)
+	if i < 0 {
+		return fmt.Errorf("firebirdsql: unknown savepoint %s", name)
+	}
+	// RELEASE SAVEPOINT name ONLY would keep the later ones
+	err := tx.executeImmediate("RELEASE SAVEPOINT " + name)
+	if err != nil {
+		return err
+	}
+	tx.savepoints = tx.savepoints[:i]
+	return nil
+}
+
+// RollbackToSavepoint undoes the changes after the savepoint. The
+// savepoint is kept, and the savepoints created after it are released.
+func (tx *firebirdsqlTx) RollbackToSavepoint(name string) error {
+	i := tx.savepointIndex(name)
+	if i < 0 {
+		return fmt.Errorf("firebirdsql: unknown savepoint %s", name)
+	}
+	err := tx.executeImmediate("ROLLBACK TO SAVEPOINT " + name)
+	if err != nil {
+		return err
+	}
+	tx.savepoints = tx.savepoints[:i+1]
+	return nil
+}
+
 func (tx *firebirdsqlTx) Commit() (err error) {
 	tx.fc.wp.opCommit(tx.transHandle)
 	_, _, _, err = tx.fc.wp.opResponse()
 	tx.isAutocommit = tx.fc.isAutocommit
+	tx.isolationLevel = tx.fc.isolationLevel
+	tx.readOnly = tx.fc.dsn.readOnly
+	tx.lockTimeout = tx.fc.dsn.lockTimeout
+	tx.savepoints = nil
 	tx.begin()
 	return
 }
 
+// PrepareTransaction is phase 1 of a two-phase commit. The server writes
+// the transaction and description to the database, so Commit or Rollback
+// of the sql.Tx (phase 2) can't fail for lack of resources. A prepared
+// transaction that is neither is in limbo, and description tells the
+// coordinator about it in the recovery (gfix -list).
+func (tx *firebirdsqlTx) PrepareTransaction(description []byte) (err error) {
+	if tx.isAutocommit {
+		return errors.New("firebirdsql: PrepareTransaction of an autocommit transaction, use BeginTx")
+	}
+	tx.fc.wp.opPrepare2(tx.transHandle, description)
+	_, _, _, err = tx.fc.wp.opResponse()
+	return
+}
+
+// CommitRetaining commits the work of the transaction like Commit, but
+// keeps the transaction handle, its snapshot and its open cursors, so
+// the sql.Tx goes on and is ended by Commit or Rollback. The savepoints
+// are released. A long retained transaction holds back garbage collection
+// like any long transaction.
+func (tx *firebirdsqlTx) CommitRetaining() (err error) {
+	tx.fc.wp.opCommitRetaining(tx.transHandle)
+	_, _, _, err = tx.fc.wp.opResponse()
+	tx.savepoints = nil
+	return
+}
+
+// RollbackRetaining undoes the work of the transaction since it started or
+// was last retained, and keeps the transaction like CommitRetaining.
+func (tx *firebirdsqlTx) RollbackRetaining() (err error) {
+	tx.fc.wp.opRollbackRetaining(tx.transHandle)
+	_, _, _, err = tx.fc.wp.opResponse()
+	tx.savepoints = nil
+	return
+}
+
 func (tx *firebirdsqlTx) Rollback() (err error) {
 	tx.fc.wp.opRollback(tx.transHandle)
 	_, _, _, err = tx.fc.wp.opResponse()
 	tx.isAutocommit = tx.fc.isAutocommit
+	tx.isolationLevel = tx.fc.isolationLevel
+	tx.readOnly = tx.fc.dsn.readOnly
+	tx.lockTimeout = tx.fc.dsn.lockTimeout
+	tx.savepoints = nil
 	tx.begin()
 	return
 }
@@ -96,6 +280,27 @@ func newFirebirdsqlTx(fc *firebirdsqlConn, isAutocommit bool) (tx *firebirdsqlTx
 	tx = new(firebirdsqlTx)
 	tx.fc = fc
 	tx.isAutocommit = isAutocommit
-	tx.begin()
+	tx.isolationLevel = fc.isolationLevel
+	tx.readOnly = fc.dsn.readOnly
+	tx.lockTimeout = fc.dsn.lockTimeout
+	err = tx.begin()
+	return
+}
+
+func newFirebirdsqlTxWithOptions(ctx context.Context, fc *firebirdsqlConn, opts driver.TxOptions) (tx *firebirdsqlTx, err error) {
+	isolationLevel, err := isolationLevelFromTxOptions(fc.isolationLevel, opts)
+	if err != nil {
+		return
+	}
+	tx = new(firebirdsqlTx)
+	tx.fc = fc
+	tx.isAutocommit = false
+	tx.isolationLevel = isolationLevel
+	tx.readOnly = opts.ReadOnly || fc.dsn.readOnly
+	tx.lockTimeout = fc.dsn.lockTimeout
+	if lockTimeout, ok := ctx.Value(lockTimeoutKey{}).(int); ok {
+		tx.lockTimeout = lockTimeout
+	}
+	err = tx.begin()
 	return
 }
@@ -24,8 +24,12 @@ CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
 package firebirdsql
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"testing"
+	"time"
 )
 
 func TestTransaction(t *testing.T) {
@@ -169,3 +173,307 @@ func TestIssue35(t *testing.T) {
 		t.Fatalf("Incorrect count: %v", n)
 	}
 }
+
+func TestIsolationLevelFromTxOptions(t *testing.T) {
+	var tests = []struct {
+		level sql.IsolationLevel
+		want  int
+	}{
+		{sql.LevelDefault, ISOLATION_LEVEL_READ_COMMITED_LEGACY},
+		{sql.LevelReadCommitted, ISOLATION_LEVEL_READ_COMMITED},
+		{sql.LevelRepeatableRead, ISOLATION_LEVEL_REPEATABLE_READ},
+		{sql.LevelSnapshot, ISOLATION_LEVEL_REPEATABLE_READ},
+		{sql.LevelSerializable, ISOLATION_LEVEL_SERIALIZABLE},
+	}
+	for _, tt := range tests {
+		got, err := isolationLevelFromTxOptions(ISOLATION_LEVEL_READ_COMMITED_LEGACY, driver.TxOptions{Isolation: driver.IsolationLevel(tt.level)})
+		if err != nil || got != tt.want {
+			t.Errorf("%s: %d %v", tt.level, got, err)
+		}
+	}
+	for _, level := range []sql.IsolationLevel{sql.LevelReadUncommitted, sql.LevelWriteCommitted, sql.LevelLinearizable} {
+		_, err := isolationLevelFromTxOptions(ISOLATION_LEVEL_READ_COMMITED, driver.TxOptions{Isolation: driver.IsolationLevel(level)})
+		if err == nil {
+			t.Errorf("%s was accepted", level)
+		}
+	}
+}
+
+func TestUntouchedTransaction(t *testing.T) {
+	dsn := &firebirdDsn{isolationLevel: ISOLATION_LEVEL_READ_COMMITED}
+	fc := &firebirdsqlConn{wp: &wireProtocol{dsn: dsn, sentPackets: 3}, dsn: dsn, isAutocommit: true}
+	newTx := func() *firebirdsqlTx {
+		return &firebirdsqlTx{fc: fc, isAutocommit: true, isolationLevel: ISOLATION_LEVEL_READ_COMMITED, sentPackets: 3}
+	}
+	if !newTx().untouched() {
+		t.Errorf("new autocommit transaction is touched")
+	}
+	tx := newTx()
+	tx.sentPackets = 2
+	if tx.untouched() {
+		t.Errorf("transaction with packets sent since it began is untouched")
+	}
+	tx = newTx()
+	tx.isAutocommit = false
+	if tx.untouched() {
+		t.Errorf("transaction of BeginTx is untouched")
+	}
+	tx = newTx()
+	tx.readOnly = true
+	if tx.untouched() {
+		t.Errorf("read-only transaction of a read-write DSN is untouched")
+	}
+	dsn.isolationLevel = ISOLATION_LEVEL_REPEATABLE_READ
+	tx = newTx()
+	tx.isolationLevel = ISOLATION_LEVEL_REPEATABLE_READ
+	if tx.untouched() {
+		t.Errorf("snapshot transaction is untouched")
+	}
+}
+
+func TestTransactionTpb(t *testing.T) {
+	tpb := transactionTpb(ISOLATION_LEVEL_SERIALIZABLE, false, -1)
+	if !bytes.Equal(tpb, []byte{isc_tpb_version3, isc_tpb_write, isc_tpb_wait, isc_tpb_consistency}) {
+		t.Errorf("SERIALIZABLE: %v", tpb)
+	}
+	tpb = transactionTpb(ISOLATION_LEVEL_READ_COMMITED, true, -1)
+	if !bytes.Equal(tpb, []byte{isc_tpb_version3, isc_tpb_read, isc_tpb_wait, isc_tpb_read_committed, isc_tpb_rec_version}) {
+		t.Errorf("READ COMMITTED READ ONLY: %v", tpb)
+	}
+	tpb = transactionTpb(ISOLATION_LEVEL_READ_COMMITED_READ_ONLY, false, -1)
+	if !bytes.Equal(tpb, []byte{isc_tpb_version3, isc_tpb_read, isc_tpb_wait, isc_tpb_read_committed, isc_tpb_rec_version}) {
+		t.Errorf("READ_COMMITED_READ_ONLY: %v", tpb)
+	}
+	tpb = transactionTpb(ISOLATION_LEVEL_REPEATABLE_READ, false, 0)
+	if !bytes.Equal(tpb, []byte{isc_tpb_version3, isc_tpb_write, isc_tpb_nowait, isc_tpb_concurrency}) {
+		t.Errorf("no wait: %v", tpb)
+	}
+	tpb = transactionTpb(ISOLATION_LEVEL_REPEATABLE_READ, false, 5)
+	if !bytes.Equal(tpb, []byte{isc_tpb_version3, isc_tpb_write, isc_tpb_wait, isc_tpb_lock_timeout, 4, 5, 0, 0, 0, isc_tpb_concurrency}) {
+		t.Errorf("lock timeout: %v", tpb)
+	}
+}
+
+func TestBeginTxOptions(t *testing.T) {
+	conn, err := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_begin_tx.fdb")
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	defer conn.Close()
+	conn.Exec("CREATE TABLE test_begin_tx (s varchar(20))")
+
+	tx, err := conn.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelReadCommitted, ReadOnly: true})
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	_, err = tx.Exec("INSERT INTO test_begin_tx (s) values ('A')")
+	if err == nil {
+		t.Errorf("INSERT in a read only transaction succeeded")
+	}
+	tx.Rollback()
+
+	tx, err = conn.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	_, err = tx.Exec("INSERT INTO test_begin_tx (s) values ('A')")
+	if err != nil {
+		t.Errorf("INSERT: %v", err)
+	}
+	tx.Commit()
+
+	_, err = conn.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelReadUncommitted})
+	if err == nil {
+		t.Errorf("LevelReadUncommitted was accepted")
+	}
+}
+
+func TestSavepoint(t *testing.T) {
+	db, err := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_savepoint.fdb")
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	defer db.Close()
+	db.Exec("CREATE TABLE test_savepoint (i integer)")
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("Conn: %v", err)
+	}
+	defer conn.Close()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	tx.Exec("INSERT INTO test_savepoint (i) values (1)")
+	err = conn.Raw(func(dc interface{}) error {
+		sp := dc.(Savepointer)
+		name1, err := sp.Savepoint("")
+		if err != nil {
+			return err
+		}
+		tx.Exec("INSERT INTO test_savepoint (i) values (2)")
+		name2, err := sp.Savepoint("")
+		if err != nil {
+			return err
+		}
+		if name1 == name2 {
+			t.Errorf("same generated name %s", name1)
+		}
+		tx.Exec("INSERT INTO test_savepoint (i) values (3)")
+
+		err = sp.RollbackToSavepoint(name1)
+		if err != nil {
+			return err
+		}
+		// name2 was released by the rollback
+		if sp.ReleaseSavepoint(name2) == nil {
+			t.Errorf("nested savepoint %s was not released", name2)
+		}
+		if _, err = sp.Savepoint("invalid name"); err == nil {
+			t.Errorf("invalid savepoint name was accepted")
+		}
+		return sp.ReleaseSavepoint(name1)
+	})
+	if err != nil {
+		t.Fatalf("savepoint: %v", err)
+	}
+	tx.Commit()
+
+	var n int
+	conn.QueryRowContext(ctx, "SELECT count(*) FROM test_savepoint").Scan(&n)
+	if n != 1 {
+		t.Errorf("Incorrect count: %v", n)
+	}
+}
+
+func TestLockTimeout(t *testing.T) {
+	db, err := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_lock_timeout.fdb")
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	db.Exec("CREATE TABLE test_lock (i integer)")
+	db.Exec("INSERT INTO test_lock (i) values (1)")
+	db.Close()
+
+	// lock_timeout=0 is no wait
+	db, _ = sql.Open("firebirdsql", "sysdba:masterkey@localhost:3050/tmp/go_test_lock_timeout.fdb?lock_timeout=0")
+	defer db.Close()
+	tx1, _ := db.Begin()
+	tx2, _ := db.Begin()
+	if _, err = tx1.Exec("UPDATE test_lock SET i = 2"); err != nil {
+		t.Fatalf("UPDATE: %v", err)
+	}
+	start := time.Now()
+	if _, err = tx2.Exec("UPDATE test_lock SET i = 3"); err == nil {
+		t.Errorf("conflicting UPDATE succeeded")
+	} else if !IsRetryable(err) {
+		t.Errorf("lock conflict is not retryable: %v", err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("conflicting UPDATE waited")
+	}
+	tx2.Rollback()
+
+	// WithLockTimeout overrides it
+	tx3, _ := db.BeginTx(WithLockTimeout(context.Background(), 1), nil)
+	start = time.Now()
+	_, err = tx3.Exec("UPDATE test_lock SET i = 3")
+	if err == nil || time.Since(start) < time.Second {
+		t.Errorf("lock timeout 1 second: %v %v", err, time.Since(start))
+	}
+	if !IsRetryable(err) {
+		t.Errorf("lock timeout is not retryable: %v", err)
+	}
+	tx3.Rollback()
+	tx1.Rollback()
+}
+
+func TestCommitRetaining(t *testing.T) {
+	db, err := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_commit_retaining.fdb")
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	defer db.Close()
+	db.Exec("CREATE TABLE test_commit_retaining (i integer)")
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("Conn: %v", err)
+	}
+	defer conn.Close()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	retain := func(f func(Retainer) error) {
+		if err := conn.Raw(func(dc interface{}) error { return f(dc.(Retainer)) }); err != nil {
+			t.Fatalf("retaining: %v", err)
+		}
+	}
+	count := func() (n int) {
+		db.QueryRow("SELECT count(*) FROM test_commit_retaining").Scan(&n)
+		return
+	}
+
+	tx.Exec("INSERT INTO test_commit_retaining (i) values (1)")
+	retain(Retainer.CommitRetaining)
+	if n := count(); n != 1 {
+		t.Errorf("CommitRetaining: another connection counts %d", n)
+	}
+	tx.Exec("INSERT INTO test_commit_retaining (i) values (2)")
+	retain(Retainer.RollbackRetaining)
+	tx.Exec("INSERT INTO test_commit_retaining (i) values (3)")
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	var sum int
+	db.QueryRow("SELECT sum(i) FROM test_commit_retaining").Scan(&sum)
+	if sum != 4 {
+		t.Errorf("expected rows 1 and 3, got the sum %d", sum)
+	}
+}
+
+func TestPrepareTransaction(t *testing.T) {
+	db, err := sql.Open("firebirdsql_createdb", "sysdba:masterkey@localhost:3050/tmp/go_test_prepare_transaction.fdb")
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	defer db.Close()
+	db.Exec("CREATE TABLE test_prepare_transaction (i integer)")
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("Conn: %v", err)
+	}
+	defer conn.Close()
+	for _, commit := range []bool{true, false} {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatalf("BeginTx: %v", err)
+		}
+		tx.Exec("INSERT INTO test_prepare_transaction (i) values (?)", commit)
+		err = conn.Raw(func(dc interface{}) error {
+			return dc.(TransactionPreparer).PrepareTransaction([]byte("test-xid"))
+		})
+		if err != nil {
+			t.Fatalf("PrepareTransaction: %v", err)
+		}
+		if commit {
+			err = tx.Commit()
+		} else {
+			err = tx.Rollback()
+		}
+		if err != nil {
+			t.Fatalf("phase 2: %v", err)
+		}
+	}
+	var n int
+	conn.QueryRowContext(ctx, "SELECT count(*) FROM test_prepare_transaction").Scan(&n)
+	if n != 1 {
+		t.Errorf("expected the committed row only, got %d", n)
+	}
+}
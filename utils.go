@@ -26,9 +26,16 @@ package firebirdsql
 import (
 	"bytes"
 	"container/list"
+	"context"
+	"crypto/tls"
+	"database/sql/driver"
 	"encoding/binary"
 	"errors"
+	"fmt"
+	"math/big"
+	"net"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -60,6 +67,18 @@ func bint32_to_bytes(i32 int32) []byte {
 	return bs
 }
 
+func bint64_to_bytes(i64 int64) []byte {
+	bs := make([]byte, 8)
+	binary.BigEndian.PutUint64(bs, uint64(i64))
+	return bs
+}
+
+func int64_to_bytes(i64 int64) []byte {
+	bs := make([]byte, 8)
+	binary.LittleEndian.PutUint64(bs, uint64(i64))
+	return bs
+}
+
 func int16_to_bytes(i16 int16) []byte {
 	bs := []byte{
 		byte(i16 & 0xFF),
@@ -106,6 +125,15 @@ func bytes_to_bint64(b []byte) int64 {
 	return i
 }
 
+func bytes_to_bint128(b []byte) *big.Int {
+	// big endian two's complement 128 bit integer
+	i := new(big.Int).SetBytes(b[:16])
+	if b[0]&0x80 != 0 {
+		i.Sub(i, new(big.Int).Lsh(big.NewInt(1), 128))
+	}
+	return i
+}
+
 func bytes_to_int64(b []byte) int64 {
 	var i int64
 	buffer := bytes.NewBuffer(b)
@@ -113,6 +141,24 @@ func bytes_to_int64(b []byte) int64 {
 	return i
 }
 
+var pow10Table = func() []*big.Int {
+	// 10^0 .. 10^38, enough for any NUMERIC scale
+	t := make([]*big.Int, 39)
+	t[0] = big.NewInt(1)
+	for i := 1; i < len(t); i++ {
+		t[i] = new(big.Int).Mul(t[i-1], big.NewInt(10))
+	}
+	return t
+}()
+
+// bigPow10 returns an exact 10^n. The result must not be modified.
+func bigPow10(n int) *big.Int {
+	if n < len(pow10Table) {
+		return pow10Table[n]
+	}
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
 func xdrBytes(bs []byte) []byte {
 	// XDR encoding bytes
 	n := len(bs)
@@ -177,6 +223,115 @@ func _bytesToBlr(v []byte) ([]byte, []byte) {
 	return blr, v
 }
 
+// _octetsToBlr sends v as blr_text2 CHARACTER SET OCTETS, the bytes are
+// stored as they are.
+func _octetsToBlr(v []byte) ([]byte, []byte) {
+	blr, v := _bytesToBlr(v)
+	return append([]byte{15, 1, 0}, blr[1:]...), v
+}
+
+// _scaleRat returns r as an integer of the scale, rounded half away from
+// zero.
+func _scaleRat(r *big.Rat, scale int) *big.Int {
+	n := new(big.Int).Set(r.Num())
+	d := new(big.Int).Set(r.Denom())
+	if scale < 0 {
+		n.Mul(n, bigPow10(-scale))
+	} else {
+		d.Mul(d, bigPow10(scale))
+	}
+	q, m := new(big.Int).QuoRem(n, d, new(big.Int))
+	if m.Sign() != 0 && new(big.Int).Mul(new(big.Int).Abs(m), big.NewInt(2)).Cmp(d) >= 0 {
+		q.Add(q, big.NewInt(int64(n.Sign())))
+	}
+	return q
+}
+
+// _ratToBlr scales r to an integer with the column scale, rounding half
+// away from zero, and sends it as blr_int64.
+func _ratToBlr(r *big.Rat, scale int) ([]byte, []byte, error) {
+	q := _scaleRat(r, scale)
+	if !q.IsInt64() {
+		return nil, nil, errors.New(fmt.Sprintf("firebirdsql: numeric value %s out of range", r.FloatString(-scale)))
+	}
+	blr := []byte{16, byte(scale)}
+	return blr, bint64_to_bytes(q.Int64()), nil
+}
+
+// _ratToColumnBlr scales r exactly to the integer or NUMERIC/DECIMAL
+// column x, and refuses a value with more digits than the precision of
+// the column, or more bits than an integer column.
+func _ratToColumnBlr(r *big.Rat, x *xSQLVAR) ([]byte, []byte, error) {
+	q := _scaleRat(r, x.sqlscale)
+	var fits bool
+	if p, ok := x.precision(); ok {
+		fits = q.CmpAbs(bigPow10(p)) < 0
+	} else {
+		bits := 63
+		switch x.sqltype {
+		case SQL_TYPE_SHORT:
+			bits = 15
+		case SQL_TYPE_LONG:
+			bits = 31
+		case SQL_TYPE_INT128:
+			bits = 127
+		}
+		fits = q.BitLen() <= bits
+	}
+	if !fits {
+		return nil, nil, fmt.Errorf("firebirdsql: numeric value %s doesn't fit the %s column", r.FloatString(-x.sqlscale), x.typeName())
+	}
+	if !q.IsInt64() {
+		// an INT128 column, the server converts the text exactly
+		blr, v := _bytesToBlr(str_to_bytes(new(big.Rat).SetFrac(q, bigPow10(-x.sqlscale)).FloatString(-x.sqlscale)))
+		return blr, v, nil
+	}
+	return []byte{16, byte(x.sqlscale)}, bint64_to_bytes(q.Int64()), nil
+}
+
+// _bigRatToBlr encodes a *big.Rat or *big.Int parameter, scaled to an
+// integer or NUMERIC/DECIMAL column, else as its exact decimal text.
+func _bigRatToBlr(r *big.Rat, x *xSQLVAR) ([]byte, []byte, error) {
+	if x != nil {
+		switch x.sqltype {
+		case SQL_TYPE_SHORT, SQL_TYPE_LONG, SQL_TYPE_INT64, SQL_TYPE_INT128:
+			return _ratToColumnBlr(r, x)
+		}
+	}
+	s, err := ratDecimalString(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	blr, v := _bytesToBlr(str_to_bytes(s))
+	return blr, v, nil
+}
+
+// ratDecimalString returns r in decimal, if it has a finite decimal
+// expansion: its denominator is a product of 2 and 5.
+func ratDecimalString(r *big.Rat) (string, error) {
+	d := new(big.Int).Set(r.Denom())
+	m := new(big.Int)
+	digits := 0
+	for _, f := range []int64{2, 5} {
+		n := 0
+		for {
+			q, _ := new(big.Int).QuoRem(d, big.NewInt(f), m)
+			if m.Sign() != 0 {
+				break
+			}
+			d = q
+			n++
+		}
+		if n > digits {
+			digits = n
+		}
+	}
+	if d.Cmp(big.NewInt(1)) != 0 {
+		return "", fmt.Errorf("firebirdsql: %s has no exact decimal value", r.RatString())
+	}
+	return r.FloatString(digits), nil
+}
+
 func _connection_charset_encoding() string {
   charset_config := os.Getenv("FB_CLIENT_CHARSET")
   if charset_config == "" {
@@ -186,27 +341,49 @@ func _connection_charset_encoding() string {
   }
 }
 
-func _convert_charset_if_required(str string) string {
-  charset_config := os.Getenv("FB_CLIENT_CHARSET")
-  if charset_config == "" {
+// _convert_charset_if_required decodes str from charset_config. UTF8 is
+// str itself, and NONE passes the bytes of the server through untouched.
+func _convert_charset_if_required(charset_config string, str string) string {
+  switch strings.ToUpper(charset_config) {
+  case "", "UTF8", "NONE":
     return str
-  } else {
+  default:
     return charset.ConvertFromCharset(charset_config, str)
   }
 }
 
 func _convert_date(t time.Time) []byte {
-	i := int(t.Month()) + 9
-	jy := t.Year() + (i / 12) - 1
+	return bint32_to_bytes(_encodeDate(t.Year(), int(t.Month()), t.Day()))
+}
+
+// _encodeDate is the inverse of _parseDate, the wire serial of a DATE: the
+// days since 1858-11-17 in the proleptic Gregorian calendar.
+func _encodeDate(year int, month int, day int) int32 {
+	i := month + 9
+	jy := year + (i / 12) - 1
 	jm := i % 12
 	c := jy / 100
 	jy -= 100 * c
-	j := (146097*c)/4 + (1461*jy)/4 + (153*jm+2)/5 + t.Day() - 678882
-	return bint32_to_bytes(int32(j))
+	j := (146097*c)/4 + (1461*jy)/4 + (153*jm+2)/5 + day - 678882
+	return int32(j)
+}
+
+// Firebird keeps 1/10000 (ISC_TIME_SECONDS_PRECISION) second of TIME and
+// TIMESTAMP values. A finer fraction is truncated, so a value read back is
+// written again unchanged.
+const timeFractionNanoseconds = int(time.Second) / ISC_TIME_SECONDS_PRECISION
+
+func truncateTimeFraction(nanosecond int) int {
+	return nanosecond - nanosecond%timeFractionNanoseconds
 }
 
 func _convert_time(t time.Time) []byte {
-	v := (t.Hour()*3600+t.Minute()*60+t.Second())*10000 + t.Nanosecond()/100000
+	return encodeTime(t.Hour(), t.Minute(), t.Second(), t.Nanosecond())
+}
+
+// encodeTime is the inverse of _parseTime, in 1/10000 second.
+func encodeTime(hour int, minute int, second int, nanosecond int) []byte {
+	v := (hour*3600+minute*60+second)*ISC_TIME_SECONDS_PRECISION + nanosecond/timeFractionNanoseconds
 	return bint32_to_bytes(int32(v))
 }
 
@@ -247,48 +424,228 @@ func split1(src string, delm string) (string, string) {
 	return src, ""
 }
 
-func parseDSN(dsn string) (addr string, dbName string, user string, passwd string, role string, authPluginName string, wireCrypt bool, isolationLevel int, err error) {
+type firebirdDsn struct {
+	addr              string
+	dbName            string
+	user              string
+	passwd            string
+	role              string
+	authPluginName    string
+	wireCrypt         int
+	isolationLevel    int
+	decimalMode       int
+	blobMode          int
+	charset           string
+	trimChar          bool
+	wireCompression   bool
+	fetchSize         int
+	lockTimeout       int
+	stmtCacheSize     int
+	dialect           int
+	numBuffers        int
+	dpb               []byte
+	connectTimeout    time.Duration
+	socketTimeout     time.Duration
+	tcpKeepalive      time.Duration
+	tcpNoDelay        bool
+	tlsConfig         *tls.Config
+	dial              func(ctx context.Context) (net.Conn, error)
+	location          *time.Location
+	timeMode          int
+	maxInlineBlob     int
+	checkParamLength  bool
+	timestampOverflow int
+	autocommitDDL     bool
+	appName           string
+	processID         int
+	noDBTriggers      bool
+	readOnly          bool
+	observer          QueryObserver
+	stats             func(query string, stats StatementStats)
+}
+
+// timeLocation is the location of DATE, TIME and TIMESTAMP values.
+func (d *firebirdDsn) timeLocation() *time.Location {
+	if d == nil || d.location == nil {
+		return time.UTC
+	}
+	return d.location
+}
+
+// DSN parameters, and dpb.xxx are passed through to the DPB.
+var dsnParams = map[string]bool{
+	"role":                     true,
+	"auth_plugin_name":         true,
+	"wire_crypt":               true,
+	"isolation_level":          true,
+	"decimal_mode":             true,
+	"blob_mode":                true,
+	"charset":                  true,
+	"trim_char":                true,
+	"wire_compression":         true,
+	"fetch_size":               true,
+	"lock_timeout":             true,
+	"stmt_cache_size":          true,
+	"dialect":                  true,
+	"num_buffers":              true,
+	"connect_timeout":          true,
+	"socket_timeout":           true,
+	"tls":                      true,
+	"tls_server_name":          true,
+	"tls_insecure_skip_verify": true,
+	"timezone":                 true,
+	"time_mode":                true,
+	"max_inline_blob":          true,
+	"check_param_length":       true,
+	"timestamp_overflow":       true,
+	"autocommit_ddl":           true,
+	"app_name":                 true,
+	"process_id":               true,
+	"tcp_keepalive":            true,
+	"tcp_nodelay":              true,
+	"no_db_triggers":           true,
+	"read_only":                true,
+}
+
+// DPB items of dpb.xxx by the name without isc_dpb_ prefix
+var dpbItems = map[string]byte{
+	"page_size":             isc_dpb_page_size,
+	"num_buffers":           isc_dpb_num_buffers,
+	"dbkey_scope":           isc_dpb_dbkey_scope,
+	"no_garbage_collect":    isc_dpb_no_garbage_collect,
+	"sweep_interval":        isc_dpb_sweep_interval,
+	"force_write":           isc_dpb_force_write,
+	"lc_messages":           isc_dpb_lc_messages,
+	"connect_timeout":       isc_dpb_connect_timeout,
+	"dummy_packet_interval": isc_dpb_dummy_packet_interval,
+	"sql_dialect":           isc_dpb_sql_dialect,
+	"set_db_charset":        isc_dpb_set_db_charset,
+	"process_name":          isc_dpb_process_name,
+	"process_id":            isc_dpb_process_id,
+	"no_db_triggers":        isc_dpb_no_db_triggers,
+	"session_time_zone":     isc_dpb_session_time_zone,
+}
+
+// DPB items whose value is a string, even if it looks like an integer
+var dpbStringItems = map[byte]bool{
+	isc_dpb_lc_messages:       true,
+	isc_dpb_set_db_charset:    true,
+	isc_dpb_process_name:      true,
+	isc_dpb_session_time_zone: true,
+}
+
+// dpbItem encodes a dpb.xxx parameter. xxx is a name in dpbItems or the
+// item number, an integer value is 4 bytes and others, or the value of a
+// string item, are bytes.
+func dpbItem(name string, value string) ([]byte, error) {
+	code, ok := dpbItems[name]
+	if !ok {
+		n, err := strconv.Atoi(name)
+		if err != nil || n <= isc_dpb_version1 || n > 255 {
+			return nil, errors.New("invalid dpb." + name)
+		}
+		code = byte(n)
+	}
+	if i, err := strconv.ParseInt(value, 10, 32); err == nil && !dpbStringItems[code] {
+		return append([]byte{code, 4}, int32_to_bytes(int32(i))...), nil
+	}
+	if len(value) > 255 {
+		return nil, errors.New("invalid dpb." + name)
+	}
+	return append([]byte{code, byte(len(value))}, value...), nil
+}
+
+func parseDSN(dsn string) (d *firebirdDsn, err error) {
+	d = new(firebirdDsn)
 	u, err := url.Parse("firebird://" + dsn)
 	if err != nil {
 		return
 	}
-	user = u.User.Username()
-	passwd, _ = u.User.Password()
-	addr = u.Host
-	if !strings.ContainsRune(addr, ':') {
-		addr += ":3050"
+	d.user = u.User.Username()
+	d.passwd, _ = u.User.Password()
+	d.addr = u.Host
+	if !strings.ContainsRune(d.addr, ':') {
+		d.addr += ":3050"
+	}
+	// without servername, the server on the same host
+	if strings.HasPrefix(d.addr, ":") {
+		d.addr = "localhost" + d.addr
 	}
-	dbName = u.Path
-	if !strings.ContainsRune(dbName[1:], '/') {
-		dbName = dbName[1:]
+	d.dbName = u.Path
+	if !strings.ContainsRune(d.dbName[1:], '/') {
+		d.dbName = d.dbName[1:]
 	}
 
 	//Windows Path
-	if strings.ContainsRune(dbName[2:], ':') {
-		dbName = dbName[1:]
+	if strings.ContainsRune(d.dbName[2:], ':') {
+		d.dbName = d.dbName[1:]
 	}
 
 	m, _ := url.ParseQuery(u.RawQuery)
+	err = d.parseParams(m)
+	return
+}
+
+// parseParams sets the options of the DSN parameters.
+func (d *firebirdDsn) parseParams(m url.Values) (err error) {
+	var dpbNames []string
+	for k := range m {
+		if strings.HasPrefix(k, "dpb.") {
+			dpbNames = append(dpbNames, k)
+		} else if !dsnParams[k] {
+			err = errors.New("invalid parameter " + k)
+			return
+		}
+	}
+	sort.Strings(dpbNames)
+	for _, k := range dpbNames {
+		var item []byte
+		item, err = dpbItem(k[4:], m[k][0])
+		if err != nil {
+			return
+		}
+		d.dpb = append(d.dpb, item...)
+	}
 
 	values, ok := m["role"]
 	if ok {
-		role = values[0]
+		d.role = values[0]
 	} else {
-		role = ""
+		d.role = ""
 	}
 
 	values, ok = m["auth_plugin_name"]
 	if ok {
-		authPluginName = values[0]
+		d.authPluginName = values[0]
+		found := false
+		for _, name := range strings.Split(PLUGIN_LIST, ",") {
+			found = found || name == d.authPluginName
+		}
+		if !found {
+			err = errors.New("invalid auth_plugin_name")
+			return
+		}
 	} else {
-		authPluginName = "Srp"
+		d.authPluginName = "Srp"
 	}
 
 	values, ok = m["wire_crypt"]
 	if ok {
-		wireCrypt, _ = strconv.ParseBool(values[0])
+		var kv = map[string]int{
+			"disabled": WIRE_CRYPT_DISABLED,
+			"false":    WIRE_CRYPT_DISABLED,
+			"enabled":  WIRE_CRYPT_ENABLED,
+			"true":     WIRE_CRYPT_ENABLED,
+			"required": WIRE_CRYPT_REQUIRED,
+		}
+		v, ok := kv[strings.ToLower(values[0])]
+		if !ok {
+			err = errors.New("invalid wire_crypt")
+			return
+		}
+		d.wireCrypt = v
 	} else {
-		wireCrypt = true
+		d.wireCrypt = WIRE_CRYPT_ENABLED
 	}
 
 	values, ok = m["isolation_level"]
@@ -300,14 +657,273 @@ func parseDSN(dsn string) (addr string, dbName string, user string, passwd strin
 			"SERIALIZABLE":            ISOLATION_LEVEL_SERIALIZABLE,
 			"READ_COMMITED_READ_ONLY": ISOLATION_LEVEL_READ_COMMITED_READ_ONLY,
 		}
-		isolationLevel, ok = kv[values[0]]
+		d.isolationLevel, ok = kv[values[0]]
 		if !ok {
 			err = errors.New("invalid isolation_level")
+			return
+		}
+	} else {
+		d.isolationLevel = ISOLATION_LEVEL_READ_COMMITED
+	}
+
+	values, ok = m["decimal_mode"]
+	if ok {
+		var kv = map[string]int{
+			"rat":    DECIMAL_MODE_RAT,
+			"string": DECIMAL_MODE_STRING,
+			"float":  DECIMAL_MODE_FLOAT,
+		}
+		d.decimalMode, ok = kv[values[0]]
+		if !ok {
+			err = errors.New("invalid decimal_mode")
+			return
+		}
+	} else {
+		d.decimalMode = DECIMAL_MODE_RAT
+	}
+
+	values, ok = m["blob_mode"]
+	if ok {
+		var kv = map[string]int{
+			"content": BLOB_MODE_CONTENT,
+			"id":      BLOB_MODE_ID,
+			"stream":  BLOB_MODE_STREAM,
+		}
+		d.blobMode, ok = kv[values[0]]
+		if !ok {
+			err = errors.New("invalid blob_mode")
+			return
+		}
+	} else {
+		d.blobMode = BLOB_MODE_CONTENT
+	}
+
+	values, ok = m["max_inline_blob"]
+	if ok {
+		d.maxInlineBlob, err = strconv.Atoi(values[0])
+		if err != nil || d.maxInlineBlob < 0 {
+			err = errors.New("invalid max_inline_blob")
+			return
 		}
 	} else {
-		isolationLevel = ISOLATION_LEVEL_READ_COMMITED
+		d.maxInlineBlob = 1024 * 1024
 	}
 
+	values, ok = m["time_mode"]
+	if ok {
+		var kv = map[string]int{
+			"time":  TIME_MODE_TIME,
+			"clock": TIME_MODE_CLOCK,
+		}
+		d.timeMode, ok = kv[values[0]]
+		if !ok {
+			err = errors.New("invalid time_mode")
+			return
+		}
+	}
+
+	values, ok = m["timestamp_overflow"]
+	if ok {
+		var kv = map[string]int{
+			"error": TIMESTAMP_OVERFLOW_ERROR,
+			"clamp": TIMESTAMP_OVERFLOW_CLAMP,
+			"raw":   TIMESTAMP_OVERFLOW_RAW,
+		}
+		d.timestampOverflow, ok = kv[values[0]]
+		if !ok {
+			err = errors.New("invalid timestamp_overflow")
+			return
+		}
+	}
+
+	values, ok = m["charset"]
+	if ok {
+		d.charset = values[0]
+	} else {
+		d.charset = _connection_charset_encoding()
+	}
+
+	values, ok = m["trim_char"]
+	if ok {
+		d.trimChar, err = strconv.ParseBool(values[0])
+		if err != nil {
+			err = errors.New("invalid trim_char")
+			return
+		}
+	} else {
+		d.trimChar = true
+	}
+
+	values, ok = m["wire_compression"]
+	if ok {
+		d.wireCompression, err = strconv.ParseBool(values[0])
+		if err != nil {
+			err = errors.New("invalid wire_compression")
+			return
+		}
+	}
+
+	values, ok = m["check_param_length"]
+	if ok {
+		d.checkParamLength, err = strconv.ParseBool(values[0])
+		if err != nil {
+			err = errors.New("invalid check_param_length")
+			return
+		}
+	}
+
+	values, ok = m["autocommit_ddl"]
+	if ok {
+		d.autocommitDDL, err = strconv.ParseBool(values[0])
+		if err != nil {
+			err = errors.New("invalid autocommit_ddl")
+			return
+		}
+	}
+
+	values, ok = m["app_name"]
+	if ok {
+		d.appName = values[0]
+		_, dup := m["dpb.process_name"]
+		if dup || len(str_to_bytes(d.appName)) > 255 {
+			err = errors.New("invalid app_name")
+			return
+		}
+	}
+
+	values, ok = m["process_id"]
+	if ok {
+		var pid int64
+		pid, err = strconv.ParseInt(values[0], 10, 32)
+		if err != nil || pid <= 0 {
+			err = errors.New("invalid process_id")
+			return
+		}
+		d.processID = int(pid)
+	} else if _, ok = m["dpb.process_id"]; !ok {
+		d.processID = os.Getpid()
+	}
+
+	values, ok = m["fetch_size"]
+	if ok {
+		d.fetchSize, err = strconv.Atoi(values[0])
+		if err != nil || d.fetchSize <= 0 {
+			err = errors.New("invalid fetch_size")
+			return
+		}
+	} else {
+		d.fetchSize = DEFAULT_FETCH_SIZE
+	}
+
+	values, ok = m["lock_timeout"]
+	if ok {
+		d.lockTimeout, err = strconv.Atoi(values[0])
+		if err != nil || d.lockTimeout < -1 {
+			err = errors.New("invalid lock_timeout")
+			return
+		}
+	} else {
+		d.lockTimeout = -1
+	}
+
+	values, ok = m["stmt_cache_size"]
+	if ok {
+		d.stmtCacheSize, err = strconv.Atoi(values[0])
+		if err != nil || d.stmtCacheSize < 0 {
+			err = errors.New("invalid stmt_cache_size")
+			return
+		}
+	}
+
+	values, ok = m["dialect"]
+	if ok {
+		d.dialect, err = strconv.Atoi(values[0])
+		if err != nil || (d.dialect != 1 && d.dialect != 3) {
+			err = errors.New("invalid dialect")
+			return
+		}
+	} else {
+		d.dialect = 3
+	}
+
+	values, ok = m["num_buffers"]
+	if ok {
+		d.numBuffers, err = strconv.Atoi(values[0])
+		if err != nil || d.numBuffers <= 0 {
+			err = errors.New("invalid num_buffers")
+			return
+		}
+	}
+
+	for _, k := range []string{"connect_timeout", "socket_timeout"} {
+		values, ok = m[k]
+		if !ok {
+			continue
+		}
+		var seconds int
+		seconds, err = strconv.Atoi(values[0])
+		if err != nil || seconds < 0 {
+			err = errors.New("invalid " + k)
+			return
+		}
+		if k == "connect_timeout" {
+			d.connectTimeout = time.Duration(seconds) * time.Second
+		} else {
+			d.socketTimeout = time.Duration(seconds) * time.Second
+		}
+	}
+
+	values, ok = m["read_only"]
+	if ok {
+		d.readOnly, err = strconv.ParseBool(values[0])
+		if err != nil {
+			err = errors.New("invalid read_only")
+			return
+		}
+	}
+
+	values, ok = m["no_db_triggers"]
+	if ok {
+		d.noDBTriggers, err = strconv.ParseBool(values[0])
+		_, dup := m["dpb.no_db_triggers"]
+		if err != nil || dup {
+			err = errors.New("invalid no_db_triggers")
+			return
+		}
+	}
+
+	values, ok = m["tcp_keepalive"]
+	if ok {
+		var seconds int
+		seconds, err = strconv.Atoi(values[0])
+		if err != nil || seconds < -1 {
+			err = errors.New("invalid tcp_keepalive")
+			return
+		}
+		d.tcpKeepalive = time.Duration(seconds) * time.Second
+	}
+
+	d.tcpNoDelay = true
+	values, ok = m["tcp_nodelay"]
+	if ok {
+		d.tcpNoDelay, err = strconv.ParseBool(values[0])
+		if err != nil {
+			err = errors.New("invalid tcp_nodelay")
+			return
+		}
+	}
+
+	values, ok = m["timezone"]
+	if ok {
+		d.location, err = time.LoadLocation(values[0])
+		if err != nil {
+			err = errors.New("invalid timezone " + values[0])
+			return
+		}
+	}
+
+	d.tlsConfig, err = parseTLSConfig(m, d.addr)
+
 	return
 }
 
@@ -351,6 +967,10 @@ func calcBlr(xsqlda []xSQLVAR) []byte {
 			blr[n] = 16
 			blr[n+1] = byte(sqlscale)
 			n += 2
+		case SQL_TYPE_INT128:
+			blr[n] = 26
+			blr[n+1] = byte(sqlscale)
+			n += 2
 		case SQL_TYPE_QUAD:
 			blr[n] = 9
 			blr[n+1] = byte(sqlscale)
@@ -381,9 +1001,27 @@ func calcBlr(xsqlda []xSQLVAR) []byte {
 		case SQL_TYPE_TIMESTAMP:
 			blr[n] = 35
 			n += 1
+		case SQL_TYPE_TIMESTAMP_TZ, SQL_TYPE_TIMESTAMP_TZ_EX:
+			blr[n] = 31 // blr_ex_timestamp_tz
+			n += 1
+		case SQL_TYPE_TIME_TZ, SQL_TYPE_TIME_TZ_EX:
+			blr[n] = 30 // blr_ex_time_tz
+			n += 1
 		case SQL_TYPE_BOOLEAN:
 			blr[n] = 23
 			n += 1
+		case SQL_TYPE_DEC16:
+			blr[n] = 24
+			n += 1
+		case SQL_TYPE_DEC34:
+			blr[n] = 25
+			n += 1
+		case SQL_TYPE_NULL:
+			// [blr_text, 0, 0], only the NULL indicator is sent
+			blr[n] = 14
+			blr[n+1] = 0
+			blr[n+2] = 0
+			n += 3
 		}
 		// [blr_short, 0]
 		blr[n] = 7
@@ -397,3 +1035,148 @@ func calcBlr(xsqlda []xSQLVAR) []byte {
 
 	return blr[:n]
 }
+
+func isNameChar(c byte) bool {
+	return c == '_' || c == '$' || ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// isDDL reports whether the first keyword of query, after the blanks and
+// comments, is the one of a DDL statement, which has neither parameters
+// nor rows.
+func isDDL(query string) bool {
+	for i := 0; i < len(query); {
+		switch {
+		case query[i] == ' ' || query[i] == '\t' || query[i] == '\r' || query[i] == '\n':
+			i++
+		case strings.HasPrefix(query[i:], "--"):
+			j := strings.IndexByte(query[i:], '\n')
+			if j < 0 {
+				return false
+			}
+			i += j + 1
+		case strings.HasPrefix(query[i:], "/*"):
+			j := strings.Index(query[i+2:], "*/")
+			if j < 0 {
+				return false
+			}
+			i += j + 4
+		default:
+			j := i
+			for j < len(query) && isNameChar(query[j]) {
+				j++
+			}
+			switch strings.ToUpper(query[i:j]) {
+			case "CREATE", "ALTER", "DROP", "RECREATE", "COMMENT", "GRANT", "REVOKE":
+				return true
+			}
+			return false
+		}
+	}
+	return false
+}
+
+// isIdentifier reports whether s is a regular (unquoted) identifier.
+func isIdentifier(s string) bool {
+	if s == "" || !(('a' <= s[0] && s[0] <= 'z') || ('A' <= s[0] && s[0] <= 'Z')) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isNameChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// rewriteNamedParams replaces @name placeholders with ? and returns the
+// name of each placeholder in order ("" for a positional ?). names is
+// nil if the query has no named placeholder. String literals, quoted
+// identifiers and comments are left as they are.
+func rewriteNamedParams(query string) (string, []string) {
+	var buf bytes.Buffer
+	var names []string
+	hasNamed := false
+	// index just after term, searching from the given index
+	skip := func(from int, term string) int {
+		j := strings.Index(query[from:], term)
+		if j < 0 {
+			return len(query)
+		}
+		return from + j + len(term)
+	}
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"':
+			end := skip(i+1, string(c))
+			buf.WriteString(query[i:end])
+			i = end - 1
+		case strings.HasPrefix(query[i:], "--"):
+			end := skip(i+2, "\n")
+			buf.WriteString(query[i:end])
+			i = end - 1
+		case strings.HasPrefix(query[i:], "/*"):
+			end := skip(i+2, "*/")
+			buf.WriteString(query[i:end])
+			i = end - 1
+		case c == '?':
+			names = append(names, "")
+			buf.WriteByte(c)
+		case c == '@' && i+1 < len(query) && isNameChar(query[i+1]):
+			j := i + 1
+			for j < len(query) && isNameChar(query[j]) {
+				j++
+			}
+			names = append(names, query[i+1:j])
+			hasNamed = true
+			buf.WriteByte('?')
+			i = j - 1
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	if !hasNamed {
+		names = nil
+	}
+	return buf.String(), names
+}
+
+// bindNamedValues orders args for the placeholders returned by
+// rewriteNamedParams. A name may be used by several placeholders.
+func bindNamedValues(names []string, args []driver.NamedValue) ([]driver.Value, error) {
+	var positional []driver.Value
+	named := make(map[string]driver.Value)
+	for _, arg := range args {
+		if arg.Name == "" {
+			positional = append(positional, arg.Value)
+		} else {
+			named[arg.Name] = arg.Value
+		}
+	}
+	if names == nil {
+		if len(named) > 0 {
+			return nil, errors.New("firebirdsql: named parameters are given but the statement has no @name placeholder")
+		}
+		return positional, nil
+	}
+
+	values := make([]driver.Value, len(names))
+	for i, name := range names {
+		if name == "" {
+			if len(positional) == 0 {
+				return nil, errors.New("firebirdsql: missing positional parameter")
+			}
+			values[i] = positional[0]
+			positional = positional[1:]
+			continue
+		}
+		v, ok := named[name]
+		if !ok {
+			return nil, fmt.Errorf("firebirdsql: missing named parameter @%s", name)
+		}
+		values[i] = v
+	}
+	// sql.Out arguments are not bound to placeholders
+	values = append(values, positional...)
+	return values, nil
+}
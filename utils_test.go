@@ -24,9 +24,15 @@ CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
 package firebirdsql
 
 import (
+	"bytes"
+	"database/sql/driver"
 	"errors"
 	"fmt"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestDSNParse(t *testing.T) {
@@ -38,43 +44,52 @@ func TestDSNParse(t *testing.T) {
 		passwd         string
 		role           string
 		authPluginName string
-		wireCrypt      bool
+		wireCrypt      int
 		isolationLevel int
 	}{
-		{"user:password@localhost:3000/dbname", "localhost:3000", "dbname", "user", "password", "", "Srp", true, 1},
-		{"user:password@localhost/dbname", "localhost:3050", "dbname", "user", "password", "", "Srp", true, 1},
-		{"user:password@localhost/dir/dbname", "localhost:3050", "/dir/dbname", "user", "password", "", "Srp", true, 1},
-		{"user:password@localhost/c:\\fbdata\\database.fdb", "localhost:3050", "c:\\fbdata\\database.fdb", "user", "password", "", "Srp", true, 1},
-		{"user:password@localhost/c:/fbdata/database.fdb", "localhost:3050", "c:/fbdata/database.fdb", "user", "password", "", "Srp", true, 1},
-		{"user:password@localhost/dbname?role=role", "localhost:3050", "dbname", "user", "password", "role", "Srp", true, 1},
-		{"user:password@localhost/dbname?auth_plugin_name=Legacy_Auth", "localhost:3050", "dbname", "user", "password", "", "Legacy_Auth", true, 1},
-		{"user:password@localhost/dbname?auth_plugin_name=Legacy_Auth&wire_crypt=false", "localhost:3050", "dbname", "user", "password", "", "Legacy_Auth", false, 1},
-		{"user:password@localhost/dbname?isolation_level=READ_COMMITED_LEGACY", "localhost:3050", "dbname", "user", "password", "", "Srp", true, 0},
-		{"user:password@localhost/dbname?isolation_level=READ_COMMITED", "localhost:3050", "dbname", "user", "password", "", "Srp", true, 1},
-		{"user:password@localhost/dbname?isolation_level=REPEATABLE_READ", "localhost:3050", "dbname", "user", "password", "", "Srp", true, 2},
-		{"user:password@localhost/dbname?isolation_level=SERIALIZABLE", "localhost:3050", "dbname", "user", "password", "", "Srp", true, 3},
-		{"user:password@localhost/dbname?isolation_level=READ_COMMITED_READ_ONLY", "localhost:3050", "dbname", "user", "password", "", "Srp", true, 4},
-		{"user:password@localhost:3000/c:/fbdata/database.fdb?role=role&wire_crypt=false", "localhost:3000", "c:/fbdata/database.fdb", "user", "password", "role", "Srp", false, 1},
+		{"user:password@localhost:3000/dbname", "localhost:3000", "dbname", "user", "password", "", "Srp", WIRE_CRYPT_ENABLED, 1},
+		{"user:password@localhost/dbname", "localhost:3050", "dbname", "user", "password", "", "Srp", WIRE_CRYPT_ENABLED, 1},
+		{"user:password@localhost/dir/dbname", "localhost:3050", "/dir/dbname", "user", "password", "", "Srp", WIRE_CRYPT_ENABLED, 1},
+		{"user:password@localhost/c:\\fbdata\\database.fdb", "localhost:3050", "c:\\fbdata\\database.fdb", "user", "password", "", "Srp", WIRE_CRYPT_ENABLED, 1},
+		{"user:password@localhost/c:/fbdata/database.fdb", "localhost:3050", "c:/fbdata/database.fdb", "user", "password", "", "Srp", WIRE_CRYPT_ENABLED, 1},
+		{"user:password@localhost/dbname?role=role", "localhost:3050", "dbname", "user", "password", "role", "Srp", WIRE_CRYPT_ENABLED, 1},
+		{"user:password@localhost/dbname?auth_plugin_name=Legacy_Auth", "localhost:3050", "dbname", "user", "password", "", "Legacy_Auth", WIRE_CRYPT_ENABLED, 1},
+		{"user:password@localhost/dbname?auth_plugin_name=Srp256", "localhost:3050", "dbname", "user", "password", "", "Srp256", WIRE_CRYPT_ENABLED, 1},
+		{"user:password@localhost/dbname?auth_plugin_name=Legacy_Auth&wire_crypt=false", "localhost:3050", "dbname", "user", "password", "", "Legacy_Auth", WIRE_CRYPT_DISABLED, 1},
+		{"user:password@localhost/dbname?isolation_level=READ_COMMITED_LEGACY", "localhost:3050", "dbname", "user", "password", "", "Srp", WIRE_CRYPT_ENABLED, 0},
+		{"user:password@localhost/dbname?isolation_level=READ_COMMITED", "localhost:3050", "dbname", "user", "password", "", "Srp", WIRE_CRYPT_ENABLED, 1},
+		{"user:password@localhost/dbname?isolation_level=REPEATABLE_READ", "localhost:3050", "dbname", "user", "password", "", "Srp", WIRE_CRYPT_ENABLED, 2},
+		{"user:password@localhost/dbname?isolation_level=SERIALIZABLE", "localhost:3050", "dbname", "user", "password", "", "Srp", WIRE_CRYPT_ENABLED, 3},
+		{"user:password@localhost/dbname?isolation_level=READ_COMMITED_READ_ONLY", "localhost:3050", "dbname", "user", "password", "", "Srp", WIRE_CRYPT_ENABLED, 4},
+		{"user:password@localhost/dbname?wire_crypt=required", "localhost:3050", "dbname", "user", "password", "", "Srp", WIRE_CRYPT_REQUIRED, 1},
+		{"user:password@localhost/dbname?wire_crypt=disabled", "localhost:3050", "dbname", "user", "password", "", "Srp", WIRE_CRYPT_DISABLED, 1},
+		{"user:password@localhost:3000/c:/fbdata/database.fdb?role=role&wire_crypt=false", "localhost:3000", "c:/fbdata/database.fdb", "user", "password", "role", "Srp", WIRE_CRYPT_DISABLED, 1},
+		{"user:password@/dir/dbname", "localhost:3050", "/dir/dbname", "user", "password", "", "Srp", WIRE_CRYPT_ENABLED, 1},
+		{"user:password@:3000/dbname", "localhost:3000", "dbname", "user", "password", "", "Srp", WIRE_CRYPT_ENABLED, 1},
 	}
 
 	for _, d := range testDSNs {
-		addr, dbName, user, passwd, role, authPluginName, wireCrypt, isolationLevel, err := parseDSN(d.dsn)
-		if addr != d.addr {
-			err = errors.New(fmt.Sprintf("parse DSN fail:%s(%s != %s)", d.dsn, addr, d.addr))
-		} else if dbName != d.dbName {
-			err = errors.New(fmt.Sprintf("parse DSN fail:%s(%s != %s)", d.dsn, dbName, d.dbName))
-		} else if user != d.user {
-			err = errors.New(fmt.Sprintf("parse DSN fail:%s(%s != %s)", d.dsn, user, d.user))
-		} else if passwd != d.passwd {
-			err = errors.New(fmt.Sprintf("parse DSN fail:%s(%s != %s)", d.dsn, passwd, d.passwd))
-		} else if role != d.role {
-			err = errors.New(fmt.Sprintf("parse DSN fail:%s(%s != %s)", d.dsn, role, d.role))
-		} else if authPluginName != d.authPluginName {
-			err = errors.New(fmt.Sprintf("parse DSN fail:%s(%s != %s)", d.dsn, authPluginName, d.authPluginName))
-		} else if wireCrypt != d.wireCrypt {
-			err = errors.New(fmt.Sprintf("parse DSN fail:%s(%v != %v)", d.dsn, wireCrypt, d.wireCrypt))
-		} else if isolationLevel != d.isolationLevel {
-			err = errors.New(fmt.Sprintf("parse DSN fail:%s(%v != %v)", d.dsn, isolationLevel, d.isolationLevel))
+		dsn, err := parseDSN(d.dsn)
+		if err != nil {
+			t.Error(err.Error())
+			continue
+		}
+		if dsn.addr != d.addr {
+			err = errors.New(fmt.Sprintf("parse DSN fail:%s(%s != %s)", d.dsn, dsn.addr, d.addr))
+		} else if dsn.dbName != d.dbName {
+			err = errors.New(fmt.Sprintf("parse DSN fail:%s(%s != %s)", d.dsn, dsn.dbName, d.dbName))
+		} else if dsn.user != d.user {
+			err = errors.New(fmt.Sprintf("parse DSN fail:%s(%s != %s)", d.dsn, dsn.user, d.user))
+		} else if dsn.passwd != d.passwd {
+			err = errors.New(fmt.Sprintf("parse DSN fail:%s(%s != %s)", d.dsn, dsn.passwd, d.passwd))
+		} else if dsn.role != d.role {
+			err = errors.New(fmt.Sprintf("parse DSN fail:%s(%s != %s)", d.dsn, dsn.role, d.role))
+		} else if dsn.authPluginName != d.authPluginName {
+			err = errors.New(fmt.Sprintf("parse DSN fail:%s(%s != %s)", d.dsn, dsn.authPluginName, d.authPluginName))
+		} else if dsn.wireCrypt != d.wireCrypt {
+			err = errors.New(fmt.Sprintf("parse DSN fail:%s(%v != %v)", d.dsn, dsn.wireCrypt, d.wireCrypt))
+		} else if dsn.isolationLevel != d.isolationLevel {
+			err = errors.New(fmt.Sprintf("parse DSN fail:%s(%v != %v)", d.dsn, dsn.isolationLevel, d.isolationLevel))
 		}
 
 		if err != nil {
@@ -82,3 +97,485 @@ func TestDSNParse(t *testing.T) {
 		}
 	}
 }
+
+func TestDSNParseDecimalMode(t *testing.T) {
+	dsn, err := parseDSN("user:password@localhost/dbname")
+	if err != nil || dsn.decimalMode != DECIMAL_MODE_RAT {
+		t.Errorf("default decimal_mode: %v, %v", dsn.decimalMode, err)
+	}
+	dsn, err = parseDSN("user:password@localhost/dbname?decimal_mode=string")
+	if err != nil || dsn.decimalMode != DECIMAL_MODE_STRING {
+		t.Errorf("decimal_mode=string: %v, %v", dsn.decimalMode, err)
+	}
+	dsn, err = parseDSN("user:password@localhost/dbname?decimal_mode=float")
+	if err != nil || dsn.decimalMode != DECIMAL_MODE_FLOAT {
+		t.Errorf("decimal_mode=float: %v, %v", dsn.decimalMode, err)
+	}
+	_, err = parseDSN("user:password@localhost/dbname?decimal_mode=foo")
+	if err == nil {
+		t.Errorf("invalid decimal_mode was accepted")
+	}
+}
+
+func TestDSNParseBlobMode(t *testing.T) {
+	dsn, err := parseDSN("user:password@localhost/dbname")
+	if err != nil || dsn.blobMode != BLOB_MODE_CONTENT {
+		t.Errorf("default blob_mode: %v, %v", dsn.blobMode, err)
+	}
+	dsn, err = parseDSN("user:password@localhost/dbname?blob_mode=id")
+	if err != nil || dsn.blobMode != BLOB_MODE_ID {
+		t.Errorf("blob_mode=id: %v, %v", dsn.blobMode, err)
+	}
+	_, err = parseDSN("user:password@localhost/dbname?blob_mode=foo")
+	if err == nil {
+		t.Errorf("invalid blob_mode was accepted")
+	}
+}
+
+func TestDSNParseCharset(t *testing.T) {
+	os.Setenv("FB_CLIENT_CHARSET", "")
+	dsn, _ := parseDSN("user:password@localhost/dbname")
+	if dsn.charset != "UTF8" {
+		t.Errorf("default charset: %v", dsn.charset)
+	}
+	dsn, _ = parseDSN("user:password@localhost/dbname?charset=WIN1251")
+	if dsn.charset != "WIN1251" {
+		t.Errorf("charset=WIN1251: %v", dsn.charset)
+	}
+	os.Setenv("FB_CLIENT_CHARSET", "WIN1252")
+	dsn, _ = parseDSN("user:password@localhost/dbname")
+	os.Setenv("FB_CLIENT_CHARSET", "")
+	if dsn.charset != "WIN1252" {
+		t.Errorf("FB_CLIENT_CHARSET charset: %v", dsn.charset)
+	}
+}
+
+func TestDSNParseTrimChar(t *testing.T) {
+	dsn, _ := parseDSN("user:password@localhost/dbname")
+	if !dsn.trimChar {
+		t.Errorf("trim_char should be enabled by default")
+	}
+	dsn, _ = parseDSN("user:password@localhost/dbname?trim_char=false")
+	if dsn.trimChar {
+		t.Errorf("trim_char=false was ignored")
+	}
+	if _, err := parseDSN("user:password@localhost/dbname?trim_char=yes"); err == nil {
+		t.Errorf("invalid trim_char was accepted")
+	}
+}
+
+func TestRewriteNamedParams(t *testing.T) {
+	var tests = []struct {
+		query string
+		want  string
+		names []string
+	}{
+		{"SELECT * FROM foo WHERE a = ?", "SELECT * FROM foo WHERE a = ?", nil},
+		{"SELECT * FROM foo WHERE a = @id", "SELECT * FROM foo WHERE a = ?", []string{"id"}},
+		{"SELECT * FROM foo WHERE a = @id OR b = @id AND c = @c_2", "SELECT * FROM foo WHERE a = ? OR b = ? AND c = ?", []string{"id", "id", "c_2"}},
+		{"SELECT '@x', \"@y\" FROM foo WHERE a = @a", "SELECT '@x', \"@y\" FROM foo WHERE a = ?", []string{"a"}},
+		{"SELECT 'it''s @x' FROM foo -- @y\nWHERE a = /* @z ? */ @a", "SELECT 'it''s @x' FROM foo -- @y\nWHERE a = /* @z ? */ ?", []string{"a"}},
+		{"SELECT * FROM foo WHERE a = ? AND b = @b", "SELECT * FROM foo WHERE a = ? AND b = ?", []string{"", "b"}},
+		{"SELECT 'unterminated @a", "SELECT 'unterminated @a", nil},
+	}
+	for _, tt := range tests {
+		got, names := rewriteNamedParams(tt.query)
+		if got != tt.want || !reflect.DeepEqual(names, tt.names) {
+			t.Errorf("%s: got %s %v, want %s %v", tt.query, got, names, tt.want, tt.names)
+		}
+	}
+}
+
+func TestBindNamedValues(t *testing.T) {
+	values, err := bindNamedValues([]string{"id", "", "id"}, []driver.NamedValue{
+		{Ordinal: 1, Value: int64(1)},
+		{Name: "id", Ordinal: 2, Value: "x"},
+	})
+	if err != nil || !reflect.DeepEqual(values, []driver.Value{"x", int64(1), "x"}) {
+		t.Errorf("bindNamedValues: %v %v", values, err)
+	}
+
+	_, err = bindNamedValues([]string{"id"}, []driver.NamedValue{{Name: "other", Ordinal: 1, Value: 1}})
+	if err == nil {
+		t.Errorf("missing named parameter was accepted")
+	}
+	_, err = bindNamedValues(nil, []driver.NamedValue{{Name: "id", Ordinal: 1, Value: 1}})
+	if err == nil {
+		t.Errorf("named parameter without placeholder was accepted")
+	}
+}
+
+func TestDSNParseWireCrypt(t *testing.T) {
+	_, err := parseDSN("user:password@localhost/dbname?wire_crypt=maybe")
+	if err == nil {
+		t.Errorf("invalid wire_crypt was accepted")
+	}
+}
+
+func TestDSNParseWireCompression(t *testing.T) {
+	dsn, _ := parseDSN("user:password@localhost/dbname")
+	if dsn.wireCompression {
+		t.Errorf("wire_compression should be disabled by default")
+	}
+	dsn, _ = parseDSN("user:password@localhost/dbname?wire_compression=true")
+	if !dsn.wireCompression {
+		t.Errorf("wire_compression=true was ignored")
+	}
+	if _, err := parseDSN("user:password@localhost/dbname?wire_compression=yes"); err == nil {
+		t.Errorf("invalid wire_compression was accepted")
+	}
+}
+
+func TestDSNParseFetchSize(t *testing.T) {
+	dsn, _ := parseDSN("user:password@localhost/dbname")
+	if dsn.fetchSize != DEFAULT_FETCH_SIZE {
+		t.Errorf("default fetch_size: %d", dsn.fetchSize)
+	}
+	dsn, _ = parseDSN("user:password@localhost/dbname?fetch_size=500")
+	if dsn.fetchSize != 500 {
+		t.Errorf("fetch_size=500: %d", dsn.fetchSize)
+	}
+	for _, v := range []string{"0", "-1", "many"} {
+		if _, err := parseDSN("user:password@localhost/dbname?fetch_size=" + v); err == nil {
+			t.Errorf("invalid fetch_size %s was accepted", v)
+		}
+	}
+}
+
+func TestDSNParseLockTimeout(t *testing.T) {
+	dsn, _ := parseDSN("user:password@localhost/dbname")
+	if dsn.lockTimeout != -1 {
+		t.Errorf("default lock_timeout: %d", dsn.lockTimeout)
+	}
+	dsn, _ = parseDSN("user:password@localhost/dbname?lock_timeout=0")
+	if dsn.lockTimeout != 0 {
+		t.Errorf("lock_timeout=0: %d", dsn.lockTimeout)
+	}
+	if _, err := parseDSN("user:password@localhost/dbname?lock_timeout=-2"); err == nil {
+		t.Errorf("invalid lock_timeout was accepted")
+	}
+}
+
+func TestDSNParseStmtCacheSize(t *testing.T) {
+	dsn, _ := parseDSN("user:password@localhost/dbname")
+	if dsn.stmtCacheSize != 0 {
+		t.Errorf("default stmt_cache_size: %d", dsn.stmtCacheSize)
+	}
+	dsn, _ = parseDSN("user:password@localhost/dbname?stmt_cache_size=20")
+	if dsn.stmtCacheSize != 20 {
+		t.Errorf("stmt_cache_size=20: %d", dsn.stmtCacheSize)
+	}
+	if _, err := parseDSN("user:password@localhost/dbname?stmt_cache_size=-1"); err == nil {
+		t.Errorf("invalid stmt_cache_size was accepted")
+	}
+}
+
+func TestDSNParseDialect(t *testing.T) {
+	dsn, _ := parseDSN("user:password@localhost/dbname")
+	if dsn.dialect != 3 {
+		t.Errorf("default dialect: %d", dsn.dialect)
+	}
+	dsn, _ = parseDSN("user:password@localhost/dbname?dialect=1")
+	if dsn.dialect != 1 {
+		t.Errorf("dialect=1: %d", dsn.dialect)
+	}
+	for _, v := range []string{"2", "0", "x"} {
+		if _, err := parseDSN("user:password@localhost/dbname?dialect=" + v); err == nil {
+			t.Errorf("invalid dialect %s was accepted", v)
+		}
+	}
+}
+
+func TestDSNParseTimeouts(t *testing.T) {
+	dsn, _ := parseDSN("user:password@localhost/dbname")
+	if dsn.connectTimeout != 0 || dsn.socketTimeout != 0 {
+		t.Errorf("default timeouts: %v %v", dsn.connectTimeout, dsn.socketTimeout)
+	}
+	dsn, _ = parseDSN("user:password@localhost/dbname?connect_timeout=5&socket_timeout=30")
+	if dsn.connectTimeout != 5*time.Second || dsn.socketTimeout != 30*time.Second {
+		t.Errorf("timeouts: %v %v", dsn.connectTimeout, dsn.socketTimeout)
+	}
+	for _, v := range []string{"connect_timeout=-1", "socket_timeout=x"} {
+		if _, err := parseDSN("user:password@localhost/dbname?" + v); err == nil {
+			t.Errorf("invalid %s was accepted", v)
+		}
+	}
+}
+
+func TestDSNParseTimezone(t *testing.T) {
+	dsn, _ := parseDSN("user:password@localhost/dbname")
+	if dsn.timeLocation() != time.UTC {
+		t.Errorf("default timezone: %v", dsn.timeLocation())
+	}
+	dsn, err := parseDSN("user:password@localhost/dbname?timezone=Asia/Tokyo")
+	if err != nil || dsn.timeLocation().String() != "Asia/Tokyo" {
+		t.Errorf("timezone=Asia/Tokyo: %v %v", dsn.location, err)
+	}
+	if _, err = parseDSN("user:password@localhost/dbname?timezone=Nowhere/Town"); err == nil {
+		t.Errorf("invalid timezone was accepted")
+	}
+}
+
+func TestDSNParseDpb(t *testing.T) {
+	dsn, err := parseDSN("user:password@localhost/dbname?role=admin&num_buffers=2048&dpb.process_name=app&dpb.no_db_triggers=1&dpb.70=")
+	if err != nil {
+		t.Fatalf("parseDSN: %v", err)
+	}
+	if dsn.role != "admin" || dsn.numBuffers != 2048 {
+		t.Errorf("role %s, num_buffers %d", dsn.role, dsn.numBuffers)
+	}
+	want := []byte{
+		70, 0,
+		isc_dpb_no_db_triggers, 4, 1, 0, 0, 0,
+		isc_dpb_process_name, 3, 'a', 'p', 'p',
+	}
+	if !bytes.Equal(dsn.dpb, want) {
+		t.Errorf("dpb: %v", dsn.dpb)
+	}
+	p := &wireProtocol{dsn: dsn}
+	pid := append([]byte{isc_dpb_process_id, 4}, int32_to_bytes(int32(os.Getpid()))...)
+	if opts := p.dpbOptions(); !bytes.Equal(opts, append(append([]byte{isc_dpb_num_buffers, 4, 0, 8, 0, 0}, pid...), want...)) {
+		t.Errorf("dpbOptions: %v", opts)
+	}
+
+	// a string item that looks like an integer
+	if item, err := dpbItem("process_name", "123"); err != nil || !bytes.Equal(item, []byte{isc_dpb_process_name, 3, '1', '2', '3'}) {
+		t.Errorf("dpb.process_name=123: %v %v", item, err)
+	}
+
+	for _, q := range []string{"dpb.unknown=1", "dpb.1=1", "dpb.256=1", "num_buffers=0", "unknown_param=1"} {
+		if _, err := parseDSN("user:password@localhost/dbname?" + q); err == nil {
+			t.Errorf("invalid %s was accepted", q)
+		}
+	}
+}
+
+func TestDSNParseAuthPluginName(t *testing.T) {
+	_, err := parseDSN("user:password@localhost/dbname?auth_plugin_name=Unknown")
+	if err == nil {
+		t.Errorf("invalid auth_plugin_name was accepted")
+	}
+}
+
+func TestIsIdentifier(t *testing.T) {
+	for _, s := range []string{"SP_1", "a", "abc$1"} {
+		if !isIdentifier(s) {
+			t.Errorf("%s is an identifier", s)
+		}
+	}
+	for _, s := range []string{"", "1a", "_a", "a b", "a;DROP"} {
+		if isIdentifier(s) {
+			t.Errorf("%s is not an identifier", s)
+		}
+	}
+}
+
+func TestIsDDL(t *testing.T) {
+	for _, q := range []string{
+		"CREATE TABLE t (a integer)",
+		"  recreate view v as select 1 from rdb$database",
+		"-- migration 1\nALTER TABLE t ADD b integer",
+		"/* drop */ DROP TABLE t",
+		"\tGRANT SELECT ON t TO PUBLIC",
+	} {
+		if !isDDL(q) {
+			t.Errorf("%q is DDL", q)
+		}
+	}
+	for _, q := range []string{
+		"",
+		"SELECT * FROM t",
+		"INSERT INTO t (a) VALUES (1)",
+		"CREATED",
+		"-- CREATE TABLE t",
+		"/* CREATE TABLE t",
+		"SET TRANSACTION",
+		"EXECUTE BLOCK AS BEGIN EXECUTE STATEMENT 'CREATE TABLE t (a integer)'; END",
+	} {
+		if isDDL(q) {
+			t.Errorf("%q is not DDL", q)
+		}
+	}
+}
+
+func TestDSNParseMaxInlineBlob(t *testing.T) {
+	dsn, _ := parseDSN("user:password@localhost/dbname")
+	if dsn.maxInlineBlob != 1024*1024 {
+		t.Errorf("default max_inline_blob: %d", dsn.maxInlineBlob)
+	}
+	dsn, _ = parseDSN("user:password@localhost/dbname?max_inline_blob=0")
+	if dsn.maxInlineBlob != 0 {
+		t.Errorf("max_inline_blob=0: %d", dsn.maxInlineBlob)
+	}
+	if _, err := parseDSN("user:password@localhost/dbname?max_inline_blob=-1"); err == nil {
+		t.Errorf("invalid max_inline_blob was accepted")
+	}
+}
+
+func TestDSNParseCheckParamLength(t *testing.T) {
+	dsn, _ := parseDSN("user:password@localhost/dbname")
+	if dsn.checkParamLength {
+		t.Errorf("check_param_length is enabled by default")
+	}
+	dsn, _ = parseDSN("user:password@localhost/dbname?check_param_length=true")
+	if !dsn.checkParamLength {
+		t.Errorf("check_param_length=true is not enabled")
+	}
+	if _, err := parseDSN("user:password@localhost/dbname?check_param_length=yes"); err == nil {
+		t.Errorf("invalid check_param_length was accepted")
+	}
+}
+
+func TestDSNParseAutocommitDDL(t *testing.T) {
+	dsn, _ := parseDSN("user:password@localhost/dbname")
+	if dsn.autocommitDDL {
+		t.Errorf("autocommit_ddl is enabled by default")
+	}
+	dsn, _ = parseDSN("user:password@localhost/dbname?autocommit_ddl=true")
+	if !dsn.autocommitDDL {
+		t.Errorf("autocommit_ddl=true is not enabled")
+	}
+	if _, err := parseDSN("user:password@localhost/dbname?autocommit_ddl=yes"); err == nil {
+		t.Errorf("invalid autocommit_ddl was accepted")
+	}
+}
+
+func TestDSNParseAppName(t *testing.T) {
+	dsn, err := parseDSN("user:password@localhost/dbname")
+	if err != nil || dsn.appName != "" || dsn.processID != os.Getpid() {
+		t.Errorf("default app_name %q, process_id %d: %v", dsn.appName, dsn.processID, err)
+	}
+	dsn, err = parseDSN("user:password@localhost/dbname?app_name=billing&process_id=4242")
+	if err != nil {
+		t.Fatalf("parseDSN: %v", err)
+	}
+	p := &wireProtocol{dsn: dsn}
+	want := []byte{
+		isc_dpb_process_id, 4, 0x92, 0x10, 0, 0,
+		isc_dpb_process_name, 7, 'b', 'i', 'l', 'l', 'i', 'n', 'g',
+	}
+	if opts := p.dpbOptions(); !bytes.Equal(opts, want) {
+		t.Errorf("dpbOptions: %v", opts)
+	}
+
+	dsn, _ = parseDSN("user:password@localhost/dbname?dpb.process_id=7")
+	p = &wireProtocol{dsn: dsn}
+	if opts := p.dpbOptions(); !bytes.Equal(opts, []byte{isc_dpb_process_id, 4, 7, 0, 0, 0}) {
+		t.Errorf("dpb.process_id: %v", opts)
+	}
+
+	for _, q := range []string{"process_id=0", "process_id=pid", "process_id=4294967296", "app_name=" + strings.Repeat("a", 256), "app_name=a&dpb.process_name=b"} {
+		if _, err := parseDSN("user:password@localhost/dbname?" + q); err == nil {
+			t.Errorf("invalid %s was accepted", q)
+		}
+	}
+}
+
+func TestDSNParseNoDBTriggers(t *testing.T) {
+	dsn, _ := parseDSN("user:password@localhost/dbname?process_id=1&no_db_triggers=true")
+	p := &wireProtocol{dsn: dsn}
+	want := []byte{
+		isc_dpb_process_id, 4, 1, 0, 0, 0,
+		isc_dpb_no_db_triggers, 4, 1, 0, 0, 0,
+	}
+	if opts := p.dpbOptions(); !bytes.Equal(opts, want) {
+		t.Errorf("dpbOptions: %v", opts)
+	}
+	dsn, _ = parseDSN("user:password@localhost/dbname?no_db_triggers=false")
+	if dsn.noDBTriggers {
+		t.Errorf("no_db_triggers=false is enabled")
+	}
+	for _, q := range []string{"no_db_triggers=maybe", "no_db_triggers=true&dpb.no_db_triggers=1"} {
+		if _, err := parseDSN("user:password@localhost/dbname?" + q); err == nil {
+			t.Errorf("invalid %s was accepted", q)
+		}
+	}
+}
+
+func TestDSNParseReadOnly(t *testing.T) {
+	dsn, _ := parseDSN("user:password@localhost/dbname")
+	if dsn.readOnly {
+		t.Errorf("read_only is enabled by default")
+	}
+	dsn, _ = parseDSN("user:password@localhost/dbname?read_only=true")
+	if !dsn.readOnly {
+		t.Errorf("read_only=true is not enabled")
+	}
+	if _, err := parseDSN("user:password@localhost/dbname?read_only=maybe"); err == nil {
+		t.Errorf("invalid read_only was accepted")
+	}
+}
+
+func TestDSNParseTCP(t *testing.T) {
+	dsn, _ := parseDSN("user:password@localhost/dbname")
+	if dsn.tcpKeepalive != 0 || !dsn.tcpNoDelay {
+		t.Errorf("default tcp_keepalive %v, tcp_nodelay %v", dsn.tcpKeepalive, dsn.tcpNoDelay)
+	}
+	dsn, _ = parseDSN("user:password@localhost/dbname?tcp_keepalive=30&tcp_nodelay=false")
+	if dsn.tcpKeepalive != 30*time.Second || dsn.tcpNoDelay {
+		t.Errorf("tcp_keepalive %v, tcp_nodelay %v", dsn.tcpKeepalive, dsn.tcpNoDelay)
+	}
+	dsn, _ = parseDSN("user:password@localhost/dbname?tcp_keepalive=-1")
+	if dsn.tcpKeepalive >= 0 {
+		t.Errorf("tcp_keepalive=-1: %v", dsn.tcpKeepalive)
+	}
+	for _, q := range []string{"tcp_keepalive=-2", "tcp_keepalive=30s", "tcp_nodelay=maybe"} {
+		if _, err := parseDSN("user:password@localhost/dbname?" + q); err == nil {
+			t.Errorf("invalid %s was accepted", q)
+		}
+	}
+}
+
+func TestConvertCharsetNone(t *testing.T) {
+	raw := bytes_to_str([]byte{'c', 'a', 'f', 0xe9, 0xff})
+	for _, cs := range []string{"NONE", "none"} {
+		if s := _convert_charset_if_required(cs, raw); s != raw {
+			t.Errorf("%s: expected the raw bytes % x, got % x", cs, raw, s)
+		}
+	}
+	x := &xSQLVAR{sqltype: SQL_TYPE_VARYING}
+	v, err := x.value([]byte{'c', 'a', 'f', 0xe9, 0xff}, &firebirdDsn{charset: "NONE"})
+	if err != nil || v != raw {
+		t.Errorf("value(): expected the raw bytes % x, got % x %v", raw, v, err)
+	}
+}
+
+func TestEncodeDate(t *testing.T) {
+	tests := []struct {
+		year, month, day int
+		serial           int32
+	}{
+		{1858, 11, 17, 0},
+		{1858, 11, 16, -1},
+		{1900, 1, 1, 15020},
+		{1900, 2, 28, 15078},
+		{1900, 3, 1, 15079}, // 1900 is not a leap year
+		{1970, 1, 1, 40587},
+		{2000, 1, 1, 51544},
+		{2000, 2, 29, 51603},
+		{2000, 3, 1, 51604},
+		{2024, 2, 29, 60369},
+	}
+	x := &xSQLVAR{}
+	for _, tt := range tests {
+		serial := _encodeDate(tt.year, tt.month, tt.day)
+		if serial != tt.serial {
+			t.Errorf("%d-%d-%d: expected %d, got %d", tt.year, tt.month, tt.day, tt.serial, serial)
+		}
+		year, month, day := x._parseDate(bint32_to_bytes(serial))
+		if year != tt.year || month != tt.month || day != tt.day {
+			t.Errorf("%d: expected %d-%d-%d, got %d-%d-%d", serial, tt.year, tt.month, tt.day, year, month, day)
+		}
+	}
+
+	// every day of the centuries around 1900 and 2000 round trips
+	for d := time.Date(1896, 1, 1, 0, 0, 0, 0, time.UTC); d.Year() < 2004; d = d.AddDate(0, 0, 1) {
+		if d.Year() == 1904 {
+			d = time.Date(1996, 1, 1, 0, 0, 0, 0, time.UTC)
+		}
+		year, month, day := x._parseDate(bint32_to_bytes(_encodeDate(d.Year(), int(d.Month()), d.Day())))
+		if year != d.Year() || time.Month(month) != d.Month() || day != d.Day() {
+			t.Errorf("%v: got %d-%d-%d", d, year, month, day)
+		}
+	}
+}
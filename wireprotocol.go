@@ -25,33 +25,37 @@ package firebirdsql
 
 import (
 	"bytes"
+	"compress/zlib"
 	"container/list"
+	"context"
+	"crypto/cipher"
 	"crypto/rc4"
+	"crypto/sha256"
 	"database/sql/driver"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"gitlab.com/nyarla/go-crypt"
+	"golang.org/x/crypto/chacha20"
+	"io"
 	"math/big"
 	"net"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
-	//"unsafe"
 )
 
 const (
-	PLUGIN_LIST       = "Srp,Legacy_Auth"
-	BUFFER_LEN        = 1024
-	MAX_CHAR_LENGTH   = 32767
-	BLOB_SEGMENT_SIZE = 32000
+	PLUGIN_LIST        = "Srp256,Srp,Legacy_Auth"
+	BUFFER_LEN         = 1024
+	MAX_CHAR_LENGTH    = 32767
+	BLOB_SEGMENT_SIZE  = 32000
+	DEFAULT_FETCH_SIZE = 400
 )
 
-func debugPrint(p *wireProtocol, s string) {
-	//fmt.Printf("[%x] %s\n", uintptr(unsafe.Pointer(p)), s)
-}
-
 func _INFO_SQL_SELECT_DESCRIBE_VARS() []byte {
 	return []byte{
 		isc_info_sql_select,
@@ -70,10 +74,54 @@ func _INFO_SQL_SELECT_DESCRIBE_VARS() []byte {
 	}
 }
 
+func _INFO_SQL_BIND_DESCRIBE_VARS() []byte {
+	return []byte{
+		isc_info_sql_bind,
+		isc_info_sql_describe_vars,
+		isc_info_sql_sqlda_seq,
+		isc_info_sql_type,
+		isc_info_sql_sub_type,
+		isc_info_sql_scale,
+		isc_info_sql_length,
+		isc_info_sql_null_ind,
+		isc_info_sql_field,
+		isc_info_sql_relation,
+		isc_info_sql_owner,
+		isc_info_sql_alias,
+		isc_info_sql_describe_end,
+	}
+}
+
 type wireChannel struct {
-	conn      net.Conn
-	rc4reader *rc4.Cipher
-	rc4writer *rc4.Cipher
+	conn   net.Conn
+	reader cipher.Stream
+	writer cipher.Stream
+	// zlib streams over the encrypted connection, if compressed
+	compressed bool
+	zreader    io.ReadCloser
+	zwriter    *zlib.Writer
+	// socket_timeout of each read and write, and the deadline of SetDeadline
+	timeout  time.Duration
+	deadline time.Time
+	// broken after a timeout or a dead socket, the stream is out of sync
+	broken bool
+	// attached after the handshake, the server may have done a request
+	// when the connection dies before the response
+	attached bool
+	written  int // bytes of the current request sent
+}
+
+// wireChannelRaw reads and writes the encrypted but uncompressed stream.
+type wireChannelRaw struct {
+	c *wireChannel
+}
+
+func (r wireChannelRaw) Read(buf []byte) (int, error) {
+	return r.c.readRaw(buf)
+}
+
+func (r wireChannelRaw) Write(buf []byte) (int, error) {
+	return r.c.writeRaw(buf)
 }
 
 func newWireChannel(conn net.Conn) (wireChannel, error) {
@@ -84,32 +132,138 @@ func newWireChannel(conn net.Conn) (wireChannel, error) {
 	return *c, err
 }
 
-func (c *wireChannel) setAuthKey(key []byte) (err error) {
-	c.rc4reader, err = rc4.NewCipher(key)
-	c.rc4writer, err = rc4.NewCipher(key)
+// setCryptKey starts the wire encryption with the session key of the
+// authentication. ChaCha uses the SHA-256 of it and the server's nonce.
+func (c *wireChannel) setCryptKey(plugin string, key []byte, nonce []byte) (err error) {
+	switch plugin {
+	case "Arc4":
+		c.reader, err = rc4.NewCipher(key)
+		if err != nil {
+			return
+		}
+		c.writer, err = rc4.NewCipher(key)
+	case "ChaCha":
+		k := sha256.Sum256(key)
+		c.reader, err = chacha20.NewUnauthenticatedCipher(k[:], nonce)
+		if err != nil {
+			return
+		}
+		c.writer, err = chacha20.NewUnauthenticatedCipher(k[:], nonce)
+	default:
+		err = fmt.Errorf("firebirdsql: unknown wire crypt plugin %s", plugin)
+	}
 	return
 }
 
-func (c *wireChannel) Read(buf []byte) (n int, err error) {
-	if c.rc4reader != nil {
+// startCompression compresses everything after the accept packet.
+// The compressed stream is encrypted if wire encryption starts later.
+func (c *wireChannel) startCompression() {
+	c.compressed = true
+	c.zwriter = zlib.NewWriter(wireChannelRaw{c})
+}
+
+// SetDeadline sets the deadline of the connection, which is kept if it is
+// earlier than the socket_timeout of a read or write.
+func (c *wireChannel) SetDeadline(t time.Time) error {
+	c.deadline = t
+	return c.conn.SetDeadline(t)
+}
+
+func (c *wireChannel) opDeadline() time.Time {
+	d := time.Now().Add(c.timeout)
+	if !c.deadline.IsZero() && c.deadline.Before(d) {
+		return c.deadline
+	}
+	return d
+}
+
+// isDeadConn reports whether err is a timed out read or write, or the
+// connection closed or reset by the server or the network.
+func isDeadConn(err error) bool {
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.ErrClosedPipe) || errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE)
+}
+
+// badConn marks the channel broken for a dead connection, the stream is
+// out of sync after it so database/sql must discard the connection. It
+// returns driver.ErrBadConn, which makes database/sql retry the operation
+// on another connection, only if the server can't have done the request:
+// in the handshake, or when nothing of the request was sent. Otherwise a
+// timeout returns the net.Error of the socket.
+func (c *wireChannel) badConn(err error) error {
+	if err == nil || !isDeadConn(err) {
+		return err
+	}
+	c.broken = true
+	if !c.attached || c.written == 0 {
+		return driver.ErrBadConn
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return err
+	}
+	return fmt.Errorf("firebirdsql: connection lost: %w", err)
+}
+
+func (c *wireChannel) readRaw(buf []byte) (n int, err error) {
+	if c.timeout > 0 {
+		c.conn.SetReadDeadline(c.opDeadline())
+	}
+	if c.reader != nil {
 		src := make([]byte, len(buf))
 		n, err = c.conn.Read(src)
-		c.rc4reader.XORKeyStream(buf, src)
-		return
+		c.reader.XORKeyStream(buf[:n], src[:n])
+	} else {
+		n, err = c.conn.Read(buf)
 	}
-	return c.conn.Read(buf)
+	return n, c.badConn(err)
 }
 
-func (c *wireChannel) Write(buf []byte) (n int, err error) {
-	if c.rc4writer != nil {
+func (c *wireChannel) writeRaw(buf []byte) (n int, err error) {
+	if c.timeout > 0 {
+		c.conn.SetWriteDeadline(c.opDeadline())
+	}
+	if c.writer != nil {
 		dst := make([]byte, len(buf))
-		c.rc4writer.XORKeyStream(dst, buf)
+		c.writer.XORKeyStream(dst, buf)
 		n, err = c.conn.Write(dst)
 	} else {
 		n, err = c.conn.Write(buf)
 	}
+	c.written += n
+	return n, c.badConn(err)
+}
+
+func (c *wireChannel) Read(buf []byte) (n int, err error) {
+	if !c.compressed {
+		return c.readRaw(buf)
+	}
+	if c.zreader == nil {
+		// zlib.NewReader reads the stream header, so it waits for the first response.
+		c.zreader, err = zlib.NewReader(wireChannelRaw{c})
+		if err != nil {
+			return
+		}
+	}
+	return c.zreader.Read(buf)
+}
+
+func (c *wireChannel) Write(buf []byte) (n int, err error) {
+	if !c.compressed {
+		return c.writeRaw(buf)
+	}
+	n, err = c.zwriter.Write(buf)
+	if err != nil {
+		return
+	}
+	err = c.zwriter.Flush()
 	return
 }
+
 func (c *wireChannel) Close() error {
 	return c.conn.Close()
 }
@@ -117,35 +271,84 @@ func (c *wireChannel) Close() error {
 type wireProtocol struct {
 	buf []byte
 
-	conn     wireChannel
-	dbHandle int32
-	addr     string
+	conn       wireChannel
+	writeMutex sync.Mutex // sendPackets and opCancel may run concurrently
+	dbHandle   int32
+	addr       string
+	dsn        *firebirdDsn
 
 	protocolVersion    int32
 	acceptArchitecture int32
 	acceptType         int32
 	lazyResponseCount  int
+	stmtTimeout        time.Duration   // of the executes in WithStatementTimeout
+	cursorFlags        int32           // of the executes, CURSOR_TYPE_SCROLLABLE
+	sentAt             time.Time       // of the last packets, for the round trips
+	sentPackets        int             // for an untouched transaction
+	stats              *StatementStats // of the running statement, if collected
 
 	pluginName string
 	user       string
 	password   string
 }
 
-func newWireProtocol(addr string) (*wireProtocol, error) {
+func newWireProtocol(ctx context.Context, dsn *firebirdDsn) (*wireProtocol, error) {
 	p := new(wireProtocol)
 	p.buf = make([]byte, 0, BUFFER_LEN)
 
-	p.addr = addr
-	conn, err := net.Dial("tcp", p.addr)
+	p.addr = dsn.addr
+	p.dsn = dsn
+	conn, err := dialServer(ctx, dsn, p.addr)
 	if err != nil {
 		return nil, err
 	}
 
 	p.conn, err = newWireChannel(conn)
+	p.conn.timeout = dsn.socketTimeout
 
 	return p, err
 }
 
+// dialServer connects to addr with connect_timeout, tcp_keepalive and
+// tcp_nodelay, and the TLS of the DSN. The dial func of a Connector
+// replaces the TCP connection to the address of the DSN, addr may be
+// another port of the server, e.g. that of the events.
+func dialServer(ctx context.Context, dsn *firebirdDsn, addr string) (net.Conn, error) {
+	var conn net.Conn
+	var err error
+	if dsn.dial != nil && addr == dsn.addr {
+		conn, err = dsn.dial(ctx)
+	} else {
+		dialer := net.Dialer{Timeout: dsn.connectTimeout, KeepAlive: dsn.tcpKeepalive}
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	tuneTCP(conn, dsn)
+	if dsn.tlsConfig != nil {
+		return startTLS(conn, dsn)
+	}
+	return conn, nil
+}
+
+// tuneTCP sets tcp_nodelay and tcp_keepalive on a TCP connection, also
+// the one of Dial.
+func tuneTCP(conn net.Conn, dsn *firebirdDsn) {
+	tc, ok := conn.(*net.TCPConn)
+	if !ok {
+		return
+	}
+	tc.SetNoDelay(dsn.tcpNoDelay)
+	switch {
+	case dsn.tcpKeepalive > 0:
+		tc.SetKeepAlive(true)
+		tc.SetKeepAlivePeriod(dsn.tcpKeepalive)
+	case dsn.tcpKeepalive < 0:
+		tc.SetKeepAlive(false)
+	}
+}
+
 func (p *wireProtocol) packInt(i int32) {
 	// pack big endian int32
 	p.buf = append(p.buf, byte(i>>24&0xFF))
@@ -187,7 +390,7 @@ func getSrpClientPublicBytes(clientPublic *big.Int) (bs []byte) {
 	return bs
 }
 
-func (p *wireProtocol) uid(user string, password string, authPluginName string, wireCrypt bool, clientPublic *big.Int) []byte {
+func (p *wireProtocol) uid(user string, password string, authPluginName string, wireCrypt int, clientPublic *big.Int) []byte {
 	sysUser := os.Getenv("USER")
 	if sysUser == "" {
 		sysUser = os.Getenv("USERNAME")
@@ -196,18 +399,14 @@ func (p *wireProtocol) uid(user string, password string, authPluginName string,
 
 	sysUserBytes := str_to_bytes(sysUser)
 	hostnameBytes := str_to_bytes(hostname)
-	pluginListNameBytes := str_to_bytes(PLUGIN_LIST)
+	// Only the requested plugin is offered, so the server can't fall back to another one.
+	pluginListNameBytes := str_to_bytes(authPluginName)
 	pluginNameBytes := str_to_bytes(authPluginName)
 	userBytes := str_to_bytes(strings.ToUpper(user))
-	var wireCryptByte byte
-	if wireCrypt {
-		wireCryptByte = 1
-	} else {
-		wireCryptByte = 0
-	}
+	wireCryptByte := byte(wireCrypt) // WIRE_CRYPT_* are the CNCT_client_crypt values
 
 	var specific_data []byte
-	if authPluginName == "Srp" {
+	if authPluginName == "Srp" || authPluginName == "Srp256" {
 		specific_data = getSrpClientPublicBytes(clientPublic)
 	} else if authPluginName == "Legacy_Auth" {
 		b := str_to_bytes(crypt.Crypt(password, "9z")[2:])
@@ -231,7 +430,12 @@ func (p *wireProtocol) uid(user string, password string, authPluginName string,
 }
 
 func (p *wireProtocol) sendPackets() (written int, err error) {
-	debugPrint(p, fmt.Sprintf("\tsendPackets():%v", p.buf))
+	tracePrint(p, func() string { return fmt.Sprintf("\tsendPackets():%v", p.buf) })
+	p.writeMutex.Lock()
+	defer p.writeMutex.Unlock()
+	p.sentAt = time.Now()
+	p.sentPackets++
+	p.conn.written = 0
 	n := 0
 	for written < len(p.buf) {
 		n, err = p.conn.Write(p.buf[written:])
@@ -241,18 +445,21 @@ func (p *wireProtocol) sendPackets() (written int, err error) {
 		written += n
 	}
 	p.buf = make([]byte, 0, BUFFER_LEN)
+	if p.stats != nil {
+		p.stats.BytesSent += int64(written)
+	}
 	return
 }
 
 func (p *wireProtocol) suspendBuffer() []byte {
-	debugPrint(p, fmt.Sprintf("\tsuspendBuffer():%v", p.buf))
+	tracePrint(p, func() string { return fmt.Sprintf("\tsuspendBuffer():%v", p.buf) })
 	buf := p.buf
 	p.buf = make([]byte, 0, BUFFER_LEN)
 	return buf
 }
 
 func (p *wireProtocol) resumeBuffer(buf []byte) {
-	debugPrint(p, fmt.Sprintf("\tresumeBuffer():%v", buf))
+	tracePrint(p, func() string { return fmt.Sprintf("\tresumeBuffer():%v", buf) })
 	p.buf = buf
 }
 
@@ -264,12 +471,15 @@ func (p *wireProtocol) recvPackets(n int) ([]byte, error) {
 	for totalRead < n {
 		read, err = p.conn.Read(buf[totalRead:n])
 		if err != nil {
-			debugPrint(p, fmt.Sprintf("\trecvPackets():%v:%v", buf, err))
+			tracePrint(p, func() string { return fmt.Sprintf("\trecvPackets():%v:%v", buf, err) })
 			return buf, err
 		}
 		totalRead += read
 	}
-	debugPrint(p, fmt.Sprintf("\trecvPackets():%v:%v", buf, err))
+	if p.stats != nil {
+		p.stats.BytesReceived += int64(n)
+	}
+	tracePrint(p, func() string { return fmt.Sprintf("\trecvPackets():%v:%v", buf, err) })
 	return buf, err
 }
 
@@ -282,20 +492,24 @@ func (p *wireProtocol) recvPacketsAlignment(n int) ([]byte, error) {
 	return buf[0:n], err
 }
 
-func (p *wireProtocol) _parse_status_vector() (*list.List, int, string, error) {
+func (p *wireProtocol) _parse_status_vector() (*list.List, int, string, string, error) {
 	sql_code := 0
 	gds_code := 0
 	gds_codes := list.New()
 	num_arg := 0
 	message := ""
+	sql_state := ""
 
 	b, err := p.recvPackets(4)
+	if err != nil {
+		return gds_codes, 0, "", "", err
+	}
 	n := bytes_to_bint32(b)
 	for n != isc_arg_end {
 		switch {
 		case n == isc_arg_gds:
 			b, err = p.recvPackets(4)
-			gds_code := int(bytes_to_bint32(b))
+			gds_code = int(bytes_to_bint32(b))
 			if gds_code != 0 {
 				gds_codes.PushBack(gds_code)
 				message += errmsgs[gds_code]
@@ -326,31 +540,48 @@ func (p *wireProtocol) _parse_status_vector() (*list.List, int, string, error) {
 			b, err = p.recvPackets(4)
 			nbytes := int(bytes_to_bint32(b))
 			b, err = p.recvPacketsAlignment(nbytes)
-			_ = bytes_to_str(b) // skip status code
+			sql_state = bytes_to_str(b)
+		}
+		if err != nil {
+			break
 		}
 		b, err = p.recvPackets(4)
+		if err != nil {
+			break
+		}
 		n = bytes_to_bint32(b)
 	}
 
-	return gds_codes, sql_code, message, err
+	return gds_codes, sql_code, message, sql_state, err
 }
 
 func (p *wireProtocol) _parse_op_response() (int32, []byte, []byte, error) {
 	b, err := p.recvPackets(16)
+	if err != nil {
+		return 0, nil, nil, err
+	}
 	h := bytes_to_bint32(b[0:4])            // Object handle
 	oid := b[4:12]                          // Object ID
 	buf_len := int(bytes_to_bint32(b[12:])) // buffer length
 	buf, err := p.recvPacketsAlignment(buf_len)
+	if err != nil {
+		return 0, nil, nil, err
+	}
 
-	gds_code_list, sql_code, message, err := p._parse_status_vector()
+	gds_code_list, sql_code, message, sql_state, err := p._parse_status_vector()
+	if err != nil {
+		return 0, nil, nil, err
+	}
 	if gds_code_list.Len() > 0 || sql_code != 0 {
-		err = errors.New(message)
+		err = newFirebirdError(gds_code_list, sql_code, sql_state, message)
 	}
 
 	return h, oid, buf, err
 }
 
-func (p *wireProtocol) _parse_select_items(buf []byte, xsqlda []xSQLVAR) (int, error) {
+// _parse_select_items returns the next index to request if the items are
+// truncated, else -1, and the offset of the item after them.
+func (p *wireProtocol) _parse_select_items(buf []byte, xsqlda []xSQLVAR) (int, int, error) {
 	var err error
 	var ln int
 	index := 0
@@ -413,23 +644,27 @@ func (p *wireProtocol) _parse_select_items(buf []byte, xsqlda []xSQLVAR) (int, e
 			xsqlda[index-1].aliasname = bytes_to_str(buf[i : i+ln])
 			i += ln
 		case isc_info_truncated:
-			return index, err // return next index
+			return index, i, err // return next index
 		case isc_info_sql_describe_end:
 			/* NOTHING */
+		case isc_info_sql_select, isc_info_sql_bind:
+			return -1, i - 1, err // the next describe
 		default:
 			err = errors.New(fmt.Sprintf("Invalid item [%02x] ! i=%d", buf[i], i))
 			break
 		}
 	}
-	return -1, err // no more info
+	return -1, i, err // no more info
 }
 
-func (p *wireProtocol) parse_xsqlda(buf []byte, stmtHandle int32) (int32, []xSQLVAR, error) {
-	var ln, col_len, next_index int
+// parse_xsqlda returns the statement type and the select and bind
+// descriptions of buf. A description that isn't in buf is nil.
+func (p *wireProtocol) parse_xsqlda(buf []byte, stmtHandle int32) (int32, []xSQLVAR, []xSQLVAR, error) {
+	var ln, col_len, next_index, n int
 	var err error
 	var stmt_type int32
 	var rbuf []byte
-	var xsqlda []xSQLVAR
+	var xsqlda, bindXsqlda []xSQLVAR
 	i := 0
 
 	for i < len(buf) {
@@ -439,32 +674,50 @@ func (p *wireProtocol) parse_xsqlda(buf []byte, stmtHandle int32) (int32, []xSQL
 			i += 2
 			stmt_type = int32(bytes_to_int32(buf[i : i+ln]))
 			i += ln
-		} else if buf[i] == byte(isc_info_sql_select) && buf[i+1] == byte(isc_info_sql_describe_vars) {
+		} else if (buf[i] == byte(isc_info_sql_select) || buf[i] == byte(isc_info_sql_bind)) && buf[i+1] == byte(isc_info_sql_describe_vars) {
+			bind := buf[i] == byte(isc_info_sql_bind)
+			describeVars := _INFO_SQL_SELECT_DESCRIBE_VARS()
+			if bind {
+				describeVars = _INFO_SQL_BIND_DESCRIBE_VARS()
+			}
 			i += 2
 			ln = int(bytes_to_int16(buf[i : i+2]))
 			i += 2
 			col_len = int(bytes_to_int32(buf[i : i+ln]))
-			xsqlda = make([]xSQLVAR, col_len)
-			next_index, err = p._parse_select_items(buf[i+ln:], xsqlda)
+			vars := make([]xSQLVAR, col_len)
+			if bind {
+				bindXsqlda = vars
+			} else {
+				xsqlda = vars
+			}
+			next_index, n, err = p._parse_select_items(buf[i+ln:], vars)
+			i += ln + n
+			if next_index > 0 {
+				// the rest of buf is cut, the next describe is left out
+				i = len(buf)
+			}
 			for next_index > 0 { // more describe vars
 				p.opInfoSql(stmtHandle,
 					bytes.Join([][]byte{
 						[]byte{isc_info_sql_sqlda_start, 2},
 						int16_to_bytes(int16(next_index)),
-						_INFO_SQL_SELECT_DESCRIBE_VARS(),
-					}, nil))
+						describeVars,
+					}, nil), BUFFER_LEN)
 
 				_, _, rbuf, err = p.opResponse()
-				// buf[:2] == []byte{0x04,0x07}
+				if err != nil {
+					return stmt_type, xsqlda, bindXsqlda, err
+				}
+				// buf[:2] == []byte{0x04,0x07} or []byte{0x05,0x07}
 				ln = int(bytes_to_int16(rbuf[2:4]))
 				// bytes_to_int(rbuf[4:4+l]) == col_len
-				next_index, err = p._parse_select_items(rbuf[4+ln:], xsqlda)
+				next_index, _, err = p._parse_select_items(rbuf[4+ln:], vars)
 			}
 		} else {
 			break
 		}
 	}
-	return stmt_type, xsqlda, err
+	return stmt_type, xsqlda, bindXsqlda, err
 }
 
 func (p *wireProtocol) getBlobSegments(blobId []byte, transHandle int32) ([]byte, error) {
@@ -483,6 +736,10 @@ func (p *wireProtocol) getBlobSegments(blobId []byte, transHandle int32) ([]byte
 	for more_data != 2 {
 		p.opGetSegment(blobHandle)
 		more_data, _, rbuf, err = p.opResponse()
+		if err != nil {
+			p.resumeBuffer(suspendBuf)
+			return nil, err
+		}
 		buf := rbuf
 		for len(buf) > 0 {
 			ln := int(bytes_to_int16(buf[0:2]))
@@ -502,7 +759,7 @@ func (p *wireProtocol) getBlobSegments(blobId []byte, transHandle int32) ([]byte
 	return blob, err
 }
 
-func (p *wireProtocol) opConnect(dbName string, user string, password string, authPluginName string, wireCrypt bool, clientPublic *big.Int) {
+func (p *wireProtocol) opConnect(dbName string, user string, password string, authPluginName string, wireCrypt int, clientPublic *big.Int) {
 	debugPrint(p, "opConnect")
 	protocols := []string{
 		// PROTOCOL_VERSION, Arch type (Generic=1), min, max, weight
@@ -510,6 +767,16 @@ func (p *wireProtocol) opConnect(dbName string, user string, password string, au
 		"ffff800b00000001000000000000000500000004", // 11, 1, 0, 5, 4
 		"ffff800c00000001000000000000000500000006", // 12, 1, 0, 5, 6
 		"ffff800d00000001000000000000000500000008", // 13, 1, 0, 5, 8
+		"ffff80100000000100000000000000050000000a", // 16, 1, 0, 5, 10
+		"ffff80110000000100000000000000050000000c", // 17, 1, 0, 5, 12
+		"ffff80120000000100000000000000050000000e", // 18, 1, 0, 5, 14
+	}
+	if p.dsn.wireCompression {
+		// max type ptype_lazy_send|pflag_compress for protocol 13 and later
+		protocols[3] = "ffff800d00000001000000000000010500000008"
+		protocols[4] = "ffff80100000000100000000000001050000000a"
+		protocols[5] = "ffff80110000000100000000000001050000000c"
+		protocols[6] = "ffff80120000000100000000000001050000000e"
 	}
 	p.packInt(op_connect)
 	p.packInt(op_attach)
@@ -528,7 +795,7 @@ func (p *wireProtocol) opCreate(dbName string, user string, password string, rol
 	var page_size int32
 	page_size = 4096
 
-	encode := str_to_bytes(_connection_charset_encoding())
+	encode := str_to_bytes(p.dsn.charset)
 	userBytes := str_to_bytes(strings.ToUpper(user))
 	passwordBytes := str_to_bytes(password)
 	roleBytes := []byte(role)
@@ -539,10 +806,11 @@ func (p *wireProtocol) opCreate(dbName string, user string, password string, rol
 		[]byte{28, byte(len(userBytes))}, userBytes,
 		[]byte{29, byte(len(passwordBytes))}, passwordBytes,
 		[]byte{60, byte(len(roleBytes))}, roleBytes,
-		[]byte{63, 4}, int32_to_bytes(3),
+		[]byte{63, 4}, int32_to_bytes(int32(p.dsn.dialect)),
 		[]byte{24, 4}, bint32_to_bytes(1),
 		[]byte{54, 4}, bint32_to_bytes(1),
 		[]byte{4, 4}, int32_to_bytes(page_size),
+		p.dpbOptions(),
 	}, nil)
 
 	p.packInt(op_create)
@@ -556,6 +824,9 @@ func (p *wireProtocol) opAccept(user string, password string, authPluginName str
 	debugPrint(p, "opAccept")
 
 	b, err := p.recvPackets(4)
+	if err != nil {
+		return
+	}
 	opcode := bytes_to_bint32(b)
 
 	for opcode == op_dummy {
@@ -563,6 +834,7 @@ func (p *wireProtocol) opAccept(user string, password string, authPluginName str
 	}
 
 	if opcode == op_reject {
+		err = fmt.Errorf("firebirdsql: server rejected auth plugin %s", authPluginName)
 		return
 	}
 	if opcode == op_response {
@@ -574,6 +846,8 @@ func (p *wireProtocol) opAccept(user string, password string, authPluginName str
 	p.protocolVersion = int32(b[3])
 	p.acceptArchitecture = bytes_to_bint32(b[4:8])
 	p.acceptType = bytes_to_bint32(b[8:12])
+	compress := p.acceptType&pflag_compress != 0
+	p.acceptType &= ptype_MASK
 
 	if opcode == op_cond_accept || opcode == op_accept_data {
 		var readLength, ln int
@@ -586,6 +860,10 @@ func (p *wireProtocol) opAccept(user string, password string, authPluginName str
 		ln = int(bytes_to_bint32(b))
 		pluginName, _ := p.recvPacketsAlignment(ln)
 		p.pluginName = bytes_to_str(pluginName)
+		if p.pluginName != authPluginName {
+			err = fmt.Errorf("firebirdsql: server rejected auth plugin %s, it requires %s", authPluginName, p.pluginName)
+			return
+		}
 
 		b, _ = p.recvPackets(4)
 		isAuthenticated := bytes_to_bint32(b)
@@ -593,58 +871,141 @@ func (p *wireProtocol) opAccept(user string, password string, authPluginName str
 
 		b, _ = p.recvPackets(4)
 		ln = int(bytes_to_bint32(b))
-		_, _ = p.recvPacketsAlignment(ln) // keys
+		keys, _ := p.recvPacketsAlignment(ln)
+
+		if compress {
+			p.conn.startCompression()
+		}
 
 		if p.pluginName == "Legacy_Auth" && isAuthenticated == 0 {
 			err = errors.New("opAccept() Unauthorized")
 			return
 		}
 
-		if p.pluginName == "Srp" {
+		if p.pluginName == "Srp" || p.pluginName == "Srp256" {
 			ln = int(bytes_to_int16(data[:2]))
 			serverSalt := data[2 : ln+2]
 			serverPublic := bigFromHexString(bytes_to_str(data[4+ln:]))
-			clientProof, authKey := getClientProof(strings.ToUpper(user), password, serverSalt, clientPublic, serverPublic, clientSecret)
+			clientProof, authKey := getClientProof(strings.ToUpper(user), password, serverSalt, clientPublic, serverPublic, clientSecret, srpProofHash(p.pluginName))
 
 			// Send op_cont_auth
 			p.packInt(op_cont_auth)
 			p.packString(hex.EncodeToString(clientProof))
 			p.packString(authPluginName)
-			p.packString(PLUGIN_LIST)
+			p.packString(authPluginName)
 			p.packString("")
 			p.sendPackets()
-			_, _, _, err = p.opResponse()
+			var buf []byte
+			_, _, buf, err = p.opResponse()
 			if err != nil {
 				return
 			}
 
-			// Send op_crypt
-			p.packInt(op_crypt)
-			p.packString("Arc4")
-			p.packString("Symmetric")
-			p.sendPackets()
-			p.conn.setAuthKey(authKey)
-
-			_, _, _, err = p.opResponse()
+			// The server may send its crypt keys with op_cont_auth response.
+			err = p.opCrypt(append(keys, buf...), authKey)
 			if err != nil {
 				return
 			}
-
 		}
-
 	} else {
 		if opcode != op_accept {
 			err = errors.New("opAccept() protocol error")
 			return
 		}
+		if compress {
+			p.conn.startCompression()
+		}
+	}
+
+	if p.dsn.wireCrypt == WIRE_CRYPT_REQUIRED && p.conn.writer == nil {
+		err = errors.New("firebirdsql: wire_crypt is required but the connection is not encrypted")
+	}
+	return
+}
+
+// guessWireCrypt chooses the crypt plugin from the keys the server sent
+// in op_accept_data/op_cond_accept. It returns "" if there is none.
+func guessWireCrypt(keys []byte) (plugin string, nonce []byte) {
+	var available []string
+	nonces := make(map[string][]byte)
+	for len(keys) >= 2 {
+		tag, ln := keys[0], int(keys[1])
+		if len(keys) < 2+ln {
+			break
+		}
+		v := keys[2 : 2+ln]
+		keys = keys[2+ln:]
+		switch tag {
+		case TAG_KEY_PLUGINS:
+			available = append(available, strings.Fields(bytes_to_str(v))...)
+		case TAG_PLUGIN_SPECIFIC:
+			if i := bytes.IndexByte(v, 0); i > 0 {
+				nonces[bytes_to_str(v[:i])] = v[i+1:]
+			}
+		}
+	}
+	for _, name := range available {
+		if name == "ChaCha" && len(nonces[name]) >= 12 {
+			return name, nonces[name][:12]
+		}
+	}
+	for _, name := range available {
+		if name == "Arc4" {
+			return name, nil
+		}
+	}
+	return "", nil
+}
+
+func (p *wireProtocol) opCrypt(keys []byte, authKey []byte) (err error) {
+	if p.dsn.wireCrypt == WIRE_CRYPT_DISABLED {
+		return
+	}
+	plugin, nonce := guessWireCrypt(keys)
+	if plugin == "" {
+		return // checked by the caller if wire_crypt=required
+	}
+
+	debugPrint(p, "opCrypt")
+	p.packInt(op_crypt)
+	p.packString(plugin)
+	p.packString("Symmetric")
+	p.sendPackets()
+	err = p.conn.setCryptKey(plugin, authKey, nonce)
+	if err != nil {
+		return
 	}
 
+	_, _, _, err = p.opResponse()
 	return
 }
 
+// dpbOptions returns the DPB items of num_buffers and dpb.xxx parameters.
+func (p *wireProtocol) dpbOptions() []byte {
+	var dpb []byte
+	if p.dsn.numBuffers > 0 {
+		dpb = append([]byte{isc_dpb_num_buffers, 4}, int32_to_bytes(int32(p.dsn.numBuffers))...)
+	}
+	// MON$REMOTE_PID and MON$REMOTE_PROCESS of the attachment
+	if p.dsn.processID > 0 {
+		dpb = append(dpb, isc_dpb_process_id, 4)
+		dpb = append(dpb, int32_to_bytes(int32(p.dsn.processID))...)
+	}
+	if p.dsn.noDBTriggers {
+		dpb = append(dpb, isc_dpb_no_db_triggers, 4)
+		dpb = append(dpb, int32_to_bytes(1)...)
+	}
+	if p.dsn.appName != "" {
+		name := str_to_bytes(p.dsn.appName)
+		dpb = append(dpb, isc_dpb_process_name, byte(len(name)))
+		dpb = append(dpb, name...)
+	}
+	return append(dpb, p.dsn.dpb...)
+}
+
 func (p *wireProtocol) opAttach(dbName string, user string, password string, role string) {
 	debugPrint(p, "opAttach")
-	encode := str_to_bytes(_connection_charset_encoding())
+	encode := str_to_bytes(p.dsn.charset)
 	userBytes := str_to_bytes(strings.ToUpper(user))
 	passwordBytes := str_to_bytes(password)
 	roleBytes := []byte(role)
@@ -654,6 +1015,8 @@ func (p *wireProtocol) opAttach(dbName string, user string, password string, rol
 		[]byte{28, byte(len(userBytes))}, userBytes,
 		[]byte{29, byte(len(passwordBytes))}, passwordBytes,
 		[]byte{60, byte(len(roleBytes))}, roleBytes,
+		[]byte{63, 4}, int32_to_bytes(int32(p.dsn.dialect)),
+		p.dpbOptions(),
 	}, nil)
 	p.packInt(op_attach)
 	p.packInt(0) // Database Object ID
@@ -662,6 +1025,49 @@ func (p *wireProtocol) opAttach(dbName string, user string, password string, rol
 	p.sendPackets()
 }
 
+func (p *wireProtocol) opServiceAttach(user string, password string) {
+	debugPrint(p, "opServiceAttach")
+	userBytes := str_to_bytes(strings.ToUpper(user))
+	passwordBytes := str_to_bytes(password)
+	spb := bytes.Join([][]byte{
+		[]byte{isc_spb_version, isc_spb_current_version},
+		[]byte{isc_spb_user_name, byte(len(userBytes))}, userBytes,
+		[]byte{isc_spb_password, byte(len(passwordBytes))}, passwordBytes,
+	}, nil)
+	p.packInt(op_service_attach)
+	p.packInt(0)
+	p.packString("service_mgr")
+	p.packBytes(spb)
+	p.sendPackets()
+}
+
+func (p *wireProtocol) opServiceStart(spb []byte) {
+	debugPrint(p, "opServiceStart")
+	p.packInt(op_service_start)
+	p.packInt(p.dbHandle)
+	p.packInt(0)
+	p.packBytes(spb)
+	p.sendPackets()
+}
+
+func (p *wireProtocol) opServiceInfo(items []byte, bufferLength int32) {
+	debugPrint(p, "opServiceInfo")
+	p.packInt(op_service_info)
+	p.packInt(p.dbHandle)
+	p.packInt(0)
+	p.packBytes(nil) // send items
+	p.packBytes(items)
+	p.packInt(bufferLength)
+	p.sendPackets()
+}
+
+func (p *wireProtocol) opServiceDetach() {
+	debugPrint(p, "opServiceDetach")
+	p.packInt(op_service_detach)
+	p.packInt(p.dbHandle)
+	p.sendPackets()
+}
+
 func (p *wireProtocol) opDropDatabase() {
 	debugPrint(p, "opDropDatabase")
 	p.packInt(op_drop_database)
@@ -705,6 +1111,16 @@ func (p *wireProtocol) opRollbackRetaining(transHandle int32) {
 	p.sendPackets()
 }
 
+// opPrepare2 is phase 1 of the two-phase commit of the transaction, with
+// the description the server keeps for the recovery of a limbo transaction.
+func (p *wireProtocol) opPrepare2(transHandle int32, description []byte) {
+	debugPrint(p, fmt.Sprintf("opPrepare2():%d", transHandle))
+	p.packInt(op_prepare2)
+	p.packInt(transHandle)
+	p.packBytes(description)
+	p.sendPackets()
+}
+
 func (p *wireProtocol) opAllocateStatement() {
 	debugPrint(p, "opAllocateStatement")
 	p.packInt(op_allocate_statement)
@@ -722,12 +1138,73 @@ func (p *wireProtocol) opInfoTransaction(transHandle int32, b []byte) {
 	p.sendPackets()
 }
 
-func (p *wireProtocol) opInfoDatabase(bs []byte) {
+func (p *wireProtocol) opInfoDatabase(bs []byte, bufferLength int32) {
 	debugPrint(p, "opInfoDatabase")
 	p.packInt(op_info_database)
 	p.packInt(p.dbHandle)
 	p.packInt(0)
 	p.packBytes(bs)
+	p.packInt(bufferLength)
+	p.sendPackets()
+}
+
+// maxInfoBufferLength is the largest buffer infoDatabase and infoSql request.
+const maxInfoBufferLength = 65535
+
+// infoTruncated reports whether the info response buf ends with
+// isc_info_truncated, as the items didn't fit in the buffer.
+func infoTruncated(buf []byte) bool {
+	for i := 0; i < len(buf); {
+		switch buf[i] {
+		case isc_info_end:
+			return false
+		case isc_info_truncated:
+			return true
+		}
+		if i+3 > len(buf) {
+			return false
+		}
+		i += 3 + int(bytes_to_int16(buf[i+1:i+3]))
+	}
+	return false
+}
+
+// infoDatabase requests the database info items, again with a doubled
+// buffer while the response is truncated, up to maxInfoBufferLength.
+func (p *wireProtocol) infoDatabase(items []byte) (buf []byte, err error) {
+	for n := int32(BUFFER_LEN); ; n *= 2 {
+		if n > maxInfoBufferLength {
+			n = maxInfoBufferLength
+		}
+		p.opInfoDatabase(items, n)
+		_, _, buf, err = p.opResponse()
+		if err != nil || n == maxInfoBufferLength || !infoTruncated(buf) {
+			return
+		}
+	}
+}
+
+// infoSql requests the statement info items like infoDatabase, e.g. a
+// plan longer than BUFFER_LEN.
+func (p *wireProtocol) infoSql(stmtHandle int32, items []byte) (buf []byte, err error) {
+	for n := int32(BUFFER_LEN); ; n *= 2 {
+		if n > maxInfoBufferLength {
+			n = maxInfoBufferLength
+		}
+		p.opInfoSql(stmtHandle, items, n)
+		_, _, buf, err = p.opResponse()
+		if err != nil || n == maxInfoBufferLength || !infoTruncated(buf) {
+			return
+		}
+	}
+}
+
+func (p *wireProtocol) opInfoBlob(blobHandle int32, bs []byte) {
+	debugPrint(p, "opInfoBlob")
+	p.packInt(op_info_blob)
+	p.packInt(blobHandle)
+	p.packInt(0)
+	p.packBytes(bs)
 	p.packInt(int32(BUFFER_LEN))
 	p.sendPackets()
 }
@@ -740,35 +1217,59 @@ func (p *wireProtocol) opFreeStatement(stmtHandle int32, mode int32) {
 	p.sendPackets()
 }
 
+func (p *wireProtocol) opSetCursor(stmtHandle int32, name string) {
+	debugPrint(p, fmt.Sprintf("opSetCursor:<%v>%s", stmtHandle, name))
+	p.packInt(op_set_cursor)
+	p.packInt(stmtHandle)
+	p.packBytes(append([]byte(name), 0))
+	p.packInt(0) // cursor type
+	p.sendPackets()
+}
+
 func (p *wireProtocol) opPrepareStatement(stmtHandle int32, transHandle int32, query string) {
 	debugPrint(p, fmt.Sprintf("opPrepareStatement():%d,%d,%v", transHandle, stmtHandle, query))
 
+	// the bind description too, used to encode []byte, time.Time etc.
 	bs := bytes.Join([][]byte{
 		[]byte{isc_info_sql_stmt_type},
 		_INFO_SQL_SELECT_DESCRIBE_VARS(),
+		_INFO_SQL_BIND_DESCRIBE_VARS(),
 	}, nil)
 	p.packInt(op_prepare_statement)
 	p.packInt(transHandle)
 	p.packInt(stmtHandle)
-	p.packInt(3) // dialect = 3
+	p.packInt(int32(p.dsn.dialect)) // dialect
 	p.packString(query)
 	p.packBytes(bs)
 	p.packInt(int32(BUFFER_LEN))
 	p.sendPackets()
 }
 
-func (p *wireProtocol) opInfoSql(stmtHandle int32, vars []byte) {
+func (p *wireProtocol) opExecuteImmediate(transHandle int32, query string) {
+	debugPrint(p, fmt.Sprintf("opExecuteImmediate():%d,%v", transHandle, query))
+	p.packInt(op_execute_immediate)
+	p.packInt(transHandle)
+	p.packInt(p.dbHandle)
+	p.packInt(int32(p.dsn.dialect)) // dialect
+	p.packString(query)
+	p.packBytes([]byte{})
+	p.packInt(int32(BUFFER_LEN))
+	p.sendPackets()
+}
+
+func (p *wireProtocol) opInfoSql(stmtHandle int32, vars []byte, bufferLength int32) {
 	debugPrint(p, "opInfoSql")
 	p.packInt(op_info_sql)
 	p.packInt(stmtHandle)
 	p.packInt(0)
 	p.packBytes(vars)
-	p.packInt(int32(BUFFER_LEN))
+	p.packInt(bufferLength)
 	p.sendPackets()
 }
 
-func (p *wireProtocol) opExecute(stmtHandle int32, transHandle int32, params []driver.Value) {
-	debugPrint(p, fmt.Sprintf("opExecute():%d,%d,%v", transHandle, stmtHandle, params))
+func (p *wireProtocol) opExecute(stmtHandle int32, transHandle int32, params []driver.Value, bindXsqlda []xSQLVAR) error {
+	debugPrint(p, fmt.Sprintf("opExecute():%d,%d,%d params", transHandle, stmtHandle, len(params)))
+	tracePrint(p, func() string { return fmt.Sprintf("opExecute():%v", params) })
 	p.packInt(op_execute)
 	p.packInt(stmtHandle)
 	p.packInt(transHandle)
@@ -777,19 +1278,53 @@ func (p *wireProtocol) opExecute(stmtHandle int32, transHandle int32, params []d
 		p.packInt(0) // packBytes([])
 		p.packInt(0)
 		p.packInt(0)
-		p.sendPackets()
 	} else {
-		blr, values := p.paramsToBlr(transHandle, params, p.protocolVersion)
+		blr, values, err := p.paramsToBlr(transHandle, params, bindXsqlda, p.protocolVersion)
+		if err != nil {
+			p.buf = make([]byte, 0, BUFFER_LEN)
+			return err
+		}
 		p.packBytes(blr)
 		p.packInt(0)
 		p.packInt(1)
 		p.appendBytes(values)
-		p.sendPackets()
 	}
+	if p.protocolVersion >= PROTOCOL_VERSION16 {
+		p.packInt(int32(p.stmtTimeout / time.Millisecond)) // statement timeout
+	}
+	if p.protocolVersion >= PROTOCOL_VERSION18 {
+		p.packInt(p.cursorFlags)
+	}
+	p.sendPackets()
+	return nil
+}
+
+// opExecuteMessage executes with the message encoded by paramsToBlr.
+func (p *wireProtocol) opExecuteMessage(stmtHandle int32, transHandle int32, blr []byte, values []byte) {
+	debugPrint(p, "opExecuteMessage")
+	p.packInt(op_execute)
+	p.packInt(stmtHandle)
+	p.packInt(transHandle)
+	p.packBytes(blr)
+	p.packInt(0)
+	if blr == nil {
+		p.packInt(0)
+	} else {
+		p.packInt(1)
+		p.appendBytes(values)
+	}
+	if p.protocolVersion >= PROTOCOL_VERSION16 {
+		p.packInt(int32(p.stmtTimeout / time.Millisecond)) // statement timeout
+	}
+	if p.protocolVersion >= PROTOCOL_VERSION18 {
+		p.packInt(p.cursorFlags)
+	}
+	p.sendPackets()
 }
 
-func (p *wireProtocol) opExecute2(stmtHandle int32, transHandle int32, params []driver.Value, outputBlr []byte) {
-	debugPrint(p, "opExecute2")
+func (p *wireProtocol) opExecute2(stmtHandle int32, transHandle int32, params []driver.Value, bindXsqlda []xSQLVAR, outputBlr []byte) error {
+	debugPrint(p, fmt.Sprintf("opExecute2():%d,%d,%d params", transHandle, stmtHandle, len(params)))
+	tracePrint(p, func() string { return fmt.Sprintf("opExecute2():%v", params) })
 	p.packInt(op_execute2)
 	p.packInt(stmtHandle)
 	p.packInt(transHandle)
@@ -799,7 +1334,11 @@ func (p *wireProtocol) opExecute2(stmtHandle int32, transHandle int32, params []
 		p.packInt(0)
 		p.packInt(0)
 	} else {
-		blr, values := p.paramsToBlr(transHandle, params, p.protocolVersion)
+		blr, values, err := p.paramsToBlr(transHandle, params, bindXsqlda, p.protocolVersion)
+		if err != nil {
+			p.buf = make([]byte, 0, BUFFER_LEN)
+			return err
+		}
 		p.packBytes(blr)
 		p.packInt(0)
 		p.packInt(1)
@@ -808,95 +1347,170 @@ func (p *wireProtocol) opExecute2(stmtHandle int32, transHandle int32, params []
 
 	p.packBytes(outputBlr)
 	p.packInt(0)
+	if p.protocolVersion >= PROTOCOL_VERSION16 {
+		p.packInt(int32(p.stmtTimeout / time.Millisecond)) // statement timeout
+	}
+	if p.protocolVersion >= PROTOCOL_VERSION18 {
+		p.packInt(p.cursorFlags)
+	}
 	p.sendPackets()
+	return nil
 }
 
-func (p *wireProtocol) opFetch(stmtHandle int32, blr []byte) {
+func (p *wireProtocol) opFetch(stmtHandle int32, blr []byte, fetchSize int32) {
 	debugPrint(p, "opFetch")
+	if p.stats != nil {
+		p.stats.Fetches++
+	}
 	p.packInt(op_fetch)
 	p.packInt(stmtHandle)
 	p.packBytes(blr)
 	p.packInt(0)
-	p.packInt(400)
+	p.packInt(fetchSize)
+	p.sendPackets()
+}
+
+// opFetchScroll fetches one row at the position of the scrollable cursor
+// given by the op_fetch_scroll operation and pos.
+func (p *wireProtocol) opFetchScroll(stmtHandle int32, blr []byte, operation int32, pos int32) {
+	debugPrint(p, fmt.Sprintf("opFetchScroll:%d,%d", operation, pos))
+	if p.stats != nil {
+		p.stats.Fetches++
+	}
+	p.packInt(op_fetch_scroll)
+	p.packInt(stmtHandle)
+	p.packBytes(blr)
+	p.packInt(0)
+	p.packInt(1)
+	p.packInt(operation)
+	p.packInt(pos)
 	p.sendPackets()
 }
 
+// recvNullIndicator reads the null bitmap of a protocol 13 row, the bit i
+// is set if the column i is NULL and has no value in the row.
+func (p *wireProtocol) recvNullIndicator(columns int) (*big.Int, error) {
+	n := columns / 8
+	if columns%8 != 0 {
+		n++
+	}
+	b, err := p.recvPacketsAlignment(n)
+	if err != nil {
+		return nil, err
+	}
+	null_indicator := new(big.Int)
+	for i := len(b) - 1; i >= 0; i-- {
+		null_indicator.Lsh(null_indicator, 8)
+		null_indicator.Or(null_indicator, big.NewInt(int64(b[i])))
+	}
+	return null_indicator, nil
+}
+
+// recvColumn reads the raw value of the column x of a fetched row.
+func (p *wireProtocol) recvColumn(x *xSQLVAR) ([]byte, error) {
+	ln := x.ioLength()
+	if ln < 0 {
+		b, err := p.recvPackets(4)
+		if err != nil {
+			return nil, err
+		}
+		ln = int(bytes_to_bint32(b))
+	}
+	return p.recvPacketsAlignment(ln)
+}
+
 func (p *wireProtocol) opFetchResponse(stmtHandle int32, transHandle int32, xsqlda []xSQLVAR) (*list.List, bool, error) {
-	debugPrint(p, "opFetchResponse")
+	// a read error of a dead connection marks it broken
 	b, err := p.recvPackets(4)
-	for bytes_to_bint32(b) == op_dummy {
-		b, _ = p.recvPackets(4)
+	for err == nil && bytes_to_bint32(b) == op_dummy {
+		b, err = p.recvPackets(4)
+	}
+	if err != nil {
+		return nil, false, err
 	}
+	p.roundTrip("opFetchResponse")
 
 	for bytes_to_bint32(b) == op_response && p.lazyResponseCount > 0 {
 		p.lazyResponseCount--
-		p._parse_op_response()
-		b, _ = p.recvPackets(4)
+		var fbErr *FirebirdError
+		if _, _, _, err = p._parse_op_response(); err != nil && !errors.As(err, &fbErr) {
+			return nil, false, err
+		}
+		if b, err = p.recvPackets(4); err != nil {
+			return nil, false, err
+		}
+	}
+	if bytes_to_bint32(b) == op_response {
+		// the fetch failed, e.g. the statement timeout expired
+		_, _, _, err = p._parse_op_response()
+		if err == nil {
+			err = errors.New("opFetchResponse:Internal Error")
+		}
+		return nil, false, err
 	}
 	if bytes_to_bint32(b) != op_fetch_response {
 		return nil, false, errors.New("opFetchResponse:Internal Error")
 	}
-	b, err = p.recvPackets(8)
+	if b, err = p.recvPackets(8); err != nil {
+		return nil, false, err
+	}
 	status := bytes_to_bint32(b[:4])
 	count := int(bytes_to_bint32(b[4:8]))
 	rows := list.New()
+	// the error of the first value that can't be converted, the rest of
+	// the response is still read so that the stream stays in sync
+	var valueErr error
 
 	for count > 0 {
 		r := make([]driver.Value, len(xsqlda))
 		if p.protocolVersion < PROTOCOL_VERSION13 {
-			for i, x := range xsqlda {
-				var ln int
-				if x.ioLength() < 0 {
-					b, err = p.recvPackets(4)
-					ln = int(bytes_to_bint32(b))
-				} else {
-					ln = x.ioLength()
+			for i := range xsqlda {
+				x := &xsqlda[i]
+				raw_value, rerr := p.recvColumn(x)
+				if rerr == nil {
+					b, rerr = p.recvPackets(4)
+				}
+				if rerr != nil {
+					return nil, false, rerr
 				}
-				raw_value, _ := p.recvPacketsAlignment(ln)
-				b, err = p.recvPackets(4)
-				if bytes_to_bint32(b) == 0 { // Not NULL
-					r[i], err = x.value(raw_value)
+				if bytes_to_bint32(b) == 0 && valueErr == nil { // Not NULL
+					r[i], valueErr = x.value(raw_value, p.dsn)
 				}
 			}
 		} else { // PROTOCOL_VERSION13
-			bi256 := big.NewInt(256)
-			n := len(xsqlda) / 8
-			if len(xsqlda)%8 != 0 {
-				n++
-			}
-			null_indicator := new(big.Int)
-			b, _ := p.recvPacketsAlignment(n)
-			for n = len(b); n > 0; n-- {
-				null_indicator = null_indicator.Mul(null_indicator, bi256)
-				bi := big.NewInt(int64(b[n-1]))
-				null_indicator = null_indicator.Add(null_indicator, bi)
+			null_indicator, rerr := p.recvNullIndicator(len(xsqlda))
+			if rerr != nil {
+				return nil, false, rerr
 			}
-
-			for i, x := range xsqlda {
+			for i := range xsqlda {
 				if null_indicator.Bit(i) != 0 {
 					continue
 				}
-				var ln int
-				if x.ioLength() < 0 {
-					b, err = p.recvPackets(4)
-					ln = int(bytes_to_bint32(b))
-				} else {
-					ln = x.ioLength()
+				x := &xsqlda[i]
+				raw_value, rerr := p.recvColumn(x)
+				if rerr != nil {
+					return nil, false, rerr
+				}
+				if valueErr == nil {
+					r[i], valueErr = x.value(raw_value, p.dsn)
 				}
-				raw_value, _ := p.recvPacketsAlignment(ln)
-				r[i], err = x.value(raw_value)
 			}
 		}
 
 		rows.PushBack(r)
 
-		b, err = p.recvPackets(12)
+		if b, err = p.recvPackets(12); err != nil {
+			return nil, false, err
+		}
 		// op := int(bytes_to_bint32(b[:4]))
 		status = bytes_to_bint32(b[4:8])
 		count = int(bytes_to_bint32(b[8:]))
 	}
+	if valueErr != nil {
+		return nil, false, valueErr
+	}
 
-	return rows, status != 100, err
+	return rows, status != 100, nil
 }
 
 func (p *wireProtocol) opDetach() {
@@ -906,6 +1520,111 @@ func (p *wireProtocol) opDetach() {
 	p.sendPackets()
 }
 
+// opCancel may be called while another goroutine waits for a response,
+// so it is written directly and doesn't touch p.buf.
+// The server sends no response to it.
+func (p *wireProtocol) opCancel(kind int32) (err error) {
+	debugPrint(p, "opCancel")
+	buf := append(bint32_to_bytes(op_cancel), bint32_to_bytes(kind)...)
+	p.writeMutex.Lock()
+	defer p.writeMutex.Unlock()
+	_, err = p.conn.Write(buf)
+	return
+}
+
+// withContext runs f, and sends fb_cancel_raise if ctx is done before f
+// returns, so the operation f is waiting for fails promptly.
+//
+// The statement timeout of WithStatementTimeout is sent with the executes
+// of f to a Firebird 4 server, and is a deadline of ctx for an older one.
+func (p *wireProtocol) withContext(ctx context.Context, f func() error) error {
+	timeout, ok := ctx.Value(statementTimeoutKey{}).(time.Duration)
+	if !ok {
+		return p.withCancel(ctx, f)
+	}
+	if p.protocolVersion >= PROTOCOL_VERSION16 {
+		p.stmtTimeout = timeout
+		defer func() { p.stmtTimeout = 0 }()
+		return p.withCancel(ctx, f)
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := p.withCancel(timeoutCtx, f)
+	if err == context.DeadlineExceeded && ctx.Err() == nil {
+		err = ErrStatementTimeout
+	}
+	return err
+}
+
+func (p *wireProtocol) withCancel(ctx context.Context, f func() error) error {
+	if ctx.Done() == nil {
+		return f()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		select {
+		case <-ctx.Done():
+			p.opCancel(fb_cancel_raise)
+		case <-done:
+		}
+	}()
+
+	err := f()
+	close(done)
+	<-finished
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// opConnectRequest asks the server for the auxiliary connection of events,
+// and returns the port to connect.
+func (p *wireProtocol) opConnectRequest() (port int, err error) {
+	debugPrint(p, "opConnectRequest")
+	p.packInt(op_connect_request)
+	p.packInt(1) // P_REQ_async
+	p.packInt(p.dbHandle)
+	p.packInt(0)
+	p.sendPackets()
+	_, _, buf, err := p.opResponse()
+	if err != nil {
+		return
+	}
+	if len(buf) < 4 {
+		err = errors.New("opConnectRequest: invalid response")
+		return
+	}
+	// sockaddr_in: family, port (network byte order), address
+	port = int(buf[2])<<8 | int(buf[3])
+	return
+}
+
+func (p *wireProtocol) opQueEvents(epb []byte, eventId int32) {
+	debugPrint(p, "opQueEvents")
+	p.packInt(op_que_events)
+	p.packInt(p.dbHandle)
+	p.packBytes(epb)
+	p.packInt(0) // ast
+	p.packInt(0) // args
+	p.packInt(eventId)
+	p.sendPackets()
+}
+
+func (p *wireProtocol) opCancelEvents(eventId int32) {
+	debugPrint(p, "opCancelEvents")
+	p.packInt(op_cancel_events)
+	p.packInt(p.dbHandle)
+	p.packInt(eventId)
+	p.sendPackets()
+}
+
 func (p *wireProtocol) opOpenBlob(blobId []byte, transHandle int32) {
 	debugPrint(p, "opOpenBlob")
 	p.packInt(op_open_blob)
@@ -914,10 +1633,10 @@ func (p *wireProtocol) opOpenBlob(blobId []byte, transHandle int32) {
 	p.sendPackets()
 }
 
-func (p *wireProtocol) opCreateBlob2(transHandle int32) {
+func (p *wireProtocol) opCreateBlob2(transHandle int32, bpb []byte) {
 	debugPrint(p, "opCreateBlob2")
 	p.packInt(op_create_blob2)
-	p.packInt(0)
+	p.packBytes(bpb)
 	p.packInt(transHandle)
 	p.packInt(0)
 	p.packInt(0)
@@ -967,15 +1686,24 @@ func (p *wireProtocol) opCloseBlob(blobHandle int32) {
 }
 
 func (p *wireProtocol) opResponse() (int32, []byte, []byte, error) {
-	debugPrint(p, "opResponse")
-	b, _ := p.recvPackets(4)
-	for bytes_to_bint32(b) == op_dummy {
-		b, _ = p.recvPackets(4)
+	// a read error of a dead connection marks it broken
+	b, err := p.recvPackets(4)
+	for err == nil && bytes_to_bint32(b) == op_dummy {
+		b, err = p.recvPackets(4)
+	}
+	if err != nil {
+		return 0, nil, nil, err
 	}
+	p.roundTrip("opResponse")
 	for bytes_to_bint32(b) == op_response && p.lazyResponseCount > 0 {
 		p.lazyResponseCount--
-		_, _, _, _ = p._parse_op_response()
-		b, _ = p.recvPackets(4)
+		var fbErr *FirebirdError
+		if _, _, _, err = p._parse_op_response(); err != nil && !errors.As(err, &fbErr) {
+			return 0, nil, nil, err
+		}
+		if b, err = p.recvPackets(4); err != nil {
+			return 0, nil, nil, err
+		}
 	}
 
 	if bytes_to_bint32(b) != op_response {
@@ -985,87 +1713,113 @@ func (p *wireProtocol) opResponse() (int32, []byte, []byte, error) {
 }
 
 func (p *wireProtocol) opSqlResponse(xsqlda []xSQLVAR) ([]driver.Value, error) {
-	debugPrint(p, "opSqlResponse")
 	b, err := p.recvPackets(4)
-	for bytes_to_bint32(b) == op_dummy {
+	for err == nil && bytes_to_bint32(b) == op_dummy {
 		b, err = p.recvPackets(4)
 	}
+	if err != nil {
+		return nil, err
+	}
+	p.roundTrip("opSqlResponse")
 
+	if bytes_to_bint32(b) == op_response {
+		// op_execute2 failed
+		_, _, _, err = p._parse_op_response()
+		if err == nil {
+			err = errors.New("Error op_sql_response")
+		}
+		return nil, err
+	}
 	if bytes_to_bint32(b) != op_sql_response {
 		return nil, errors.New("Error op_sql_response")
 	}
 
-	b, err = p.recvPackets(4)
-	// count := int(bytes_to_bint32(b))
+	if _, err = p.recvPackets(4); err != nil { // count
+		return nil, err
+	}
 
 	r := make([]driver.Value, len(xsqlda))
-	var ln int
 
 	if p.protocolVersion < PROTOCOL_VERSION13 {
-		for i, x := range xsqlda {
-			if x.ioLength() < 0 {
-				b, err = p.recvPackets(4)
-				ln = int(bytes_to_bint32(b))
-			} else {
-				ln = x.ioLength()
+		for i := range xsqlda {
+			x := &xsqlda[i]
+			raw_value, rerr := p.recvColumn(x)
+			if rerr != nil {
+				return nil, rerr
 			}
-			raw_value, _ := p.recvPacketsAlignment(ln)
-			b, err = p.recvPackets(4)
-			if bytes_to_bint32(b) == 0 { // Not NULL
-				r[i], err = x.value(raw_value)
+			if b, rerr = p.recvPackets(4); rerr != nil {
+				return nil, rerr
+			}
+			if bytes_to_bint32(b) == 0 && err == nil { // Not NULL
+				r[i], err = x.value(raw_value, p.dsn)
 			}
 		}
 	} else { // PROTOCOL_VERSION13
-		n := len(xsqlda) / 8
-		if len(xsqlda)%8 != 0 {
-			n++
-		}
-		null_indicator := 0
-		b, _ := p.recvPacketsAlignment(n)
-		for n = len(b) - 1; n > 0; n-- {
-			null_indicator <<= 8
-			null_indicator += int(b[n])
+		null_indicator, rerr := p.recvNullIndicator(len(xsqlda))
+		if rerr != nil {
+			return nil, rerr
 		}
-
-		for i, x := range xsqlda {
-			if (null_indicator & (1 << 1)) != 0 {
+		for i := range xsqlda {
+			if null_indicator.Bit(i) != 0 {
 				continue
 			}
-			if x.ioLength() < 0 {
-				b, err = p.recvPackets(4)
-				ln = int(bytes_to_bint32(b))
-			} else {
-				ln = x.ioLength()
+			x := &xsqlda[i]
+			raw_value, rerr := p.recvColumn(x)
+			if rerr != nil {
+				return nil, rerr
+			}
+			if err == nil {
+				r[i], err = x.value(raw_value, p.dsn)
 			}
-			raw_value, _ := p.recvPacketsAlignment(ln)
-			r[i], err = x.value(raw_value)
 		}
 	}
 
 	return r, err
 }
 
-func (p *wireProtocol) createBlob(value []byte, transHandle int32) ([]byte, error) {
+func (p *wireProtocol) createBlob(value []byte, transHandle int32, bpb []byte) ([]byte, error) {
+	return p.createBlobFromReader(bytes.NewReader(value), transHandle, bpb)
+}
+
+// blobBpb is the blob parameter block of a new blob for the column x. A
+// blob is binary (sub type 0) unless it declares the sub type of a BLOB
+// column, as both source and target so that no filter is applied.
+func blobBpb(x *xSQLVAR) []byte {
+	if x == nil || x.sqltype != SQL_TYPE_BLOB || x.sqlsubtype <= 0 || x.sqlsubtype > 255 {
+		return nil
+	}
+	subtype := byte(x.sqlsubtype)
+	return []byte{isc_bpb_version1, isc_bpb_source_type, 1, subtype, isc_bpb_target_type, 1, subtype}
+}
+
+// createBlobFromReader writes all data of r into a new blob
+// in BLOB_SEGMENT_SIZE segments and returns the blob id.
+func (p *wireProtocol) createBlobFromReader(r io.Reader, transHandle int32, bpb []byte) ([]byte, error) {
 	buf := p.suspendBuffer()
-	p.opCreateBlob2(transHandle)
+	p.opCreateBlob2(transHandle, bpb)
 	blobHandle, blobId, _, err := p.opResponse()
 	if err != nil {
 		p.resumeBuffer(buf)
 		return blobId, err
 	}
 
-	i := 0
-	for i < len(value) {
-		end := i + BLOB_SEGMENT_SIZE
-		if end > len(value) {
-			end = len(value)
+	segment := make([]byte, BLOB_SEGMENT_SIZE)
+	for {
+		n, rerr := io.ReadFull(r, segment)
+		if n > 0 {
+			p.opPutSegment(blobHandle, segment[:n])
+			_, _, _, err = p.opResponse()
+			if err != nil {
+				break
+			}
 		}
-		p.opPutSegment(blobHandle, value[i:end])
-		_, _, _, err := p.opResponse()
-		if err != nil {
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			break
+		}
+		if rerr != nil {
+			err = rerr
 			break
 		}
-		i += BLOB_SEGMENT_SIZE
 	}
 	if err != nil {
 		p.resumeBuffer(buf)
@@ -1079,9 +1833,11 @@ func (p *wireProtocol) createBlob(value []byte, transHandle int32) ([]byte, erro
 	return blobId, err
 }
 
-func (p *wireProtocol) paramsToBlr(transHandle int32, params []driver.Value, protocolVersion int32) ([]byte, []byte) {
+func (p *wireProtocol) paramsToBlr(transHandle int32, params []driver.Value, bindXsqlda []xSQLVAR, protocolVersion int32) ([]byte, []byte, error) {
 	// Convert parameter array to BLR and values format.
+	// bindXsqlda is the input parameter description, it may be nil.
 	var v, blr []byte
+	var err error
 	bi256 := big.NewInt(256)
 
 	ln := len(params) * 2
@@ -1091,7 +1847,7 @@ func (p *wireProtocol) paramsToBlr(transHandle int32, params []driver.Value, pro
 
 	if protocolVersion >= PROTOCOL_VERSION13 {
 		null_indicator := new(big.Int)
-		for i := len(params) - 1; i > 0; i-- {
+		for i := len(params) - 1; i >= 0; i-- {
 			if params[i] == nil {
 				null_indicator.SetBit(null_indicator, i, 1)
 			}
@@ -1109,14 +1865,18 @@ func (p *wireProtocol) paramsToBlr(transHandle int32, params []driver.Value, pro
 		}
 	}
 
-	for _, param := range params {
+	for i, param := range params {
+		var x *xSQLVAR
+		if i < len(bindXsqlda) {
+			x = &bindXsqlda[i]
+		}
 		switch f := param.(type) {
 		case string:
 			b := str_to_bytes(f)
 			if len(b) < MAX_CHAR_LENGTH {
 				blr, v = _bytesToBlr(b)
 			} else {
-				v, _ = p.createBlob(b, transHandle)
+				v, err = p.createBlob(b, transHandle, blobBpb(x))
 				blr = []byte{9, 0}
 			}
 		case int:
@@ -1128,11 +1888,26 @@ func (p *wireProtocol) paramsToBlr(transHandle int32, params []driver.Value, pro
 		case int64:
 			blr, v = _int32ToBlr(int32(f))
 		case time.Time:
-			if f.Year() == 0 {
+			// only the part of the described column is sent, and without
+			// the description dialect 1 has no TIME, and its DATE is a
+			// timestamp. It is sent in the location it is read in.
+			year := f.Year()
+			f = f.In(p.dsn.timeLocation())
+			if x != nil && x.sqltype == SQL_TYPE_DATE {
+				blr, v = _dateToBlr(f)
+			} else if x != nil && x.sqltype == SQL_TYPE_TIME {
+				blr, v = _timeToBlr(f)
+			} else if x != nil && x.sqltype == SQL_TYPE_TIMESTAMP {
+				blr, v = _timestampToBlr(f)
+			} else if year == 0 && p.dsn.dialect != 1 {
 				blr, v = _timeToBlr(f)
 			} else {
 				blr, v = _timestampToBlr(f)
 			}
+		case Date:
+			blr, v = []byte{12}, bint32_to_bytes(_encodeDate(f.Year, int(f.Month), f.Day))
+		case Time:
+			blr, v = []byte{13}, encodeTime(f.Hour, f.Minute, f.Second, f.Nanosecond)
 		case bool:
 			if f {
 				v = []byte{1, 0, 0, 0}
@@ -1143,11 +1918,33 @@ func (p *wireProtocol) paramsToBlr(transHandle int32, params []driver.Value, pro
 		case nil:
 			v = []byte{}
 			blr = []byte{14, 0, 0}
+		case io.Reader:
+			v, err = p.createBlobFromReader(f, transHandle, blobBpb(x))
+			blr = []byte{9, 0}
+		case arrayId:
+			v = f
+			blr = []byte{9, 0}
+		case Decimal:
+			if x != nil && x.isScaledInteger() {
+				var r *big.Rat
+				r, err = f.rat()
+				if err == nil {
+					blr, v, err = _ratToColumnBlr(r, x)
+				}
+			} else {
+				blr, v = _bytesToBlr(str_to_bytes(string(f)))
+			}
+		case *big.Int:
+			blr, v, err = _bigRatToBlr(new(big.Rat).SetInt(f), x)
+		case *big.Rat:
+			blr, v, err = _bigRatToBlr(f, x)
 		case []byte:
-			if len(f) < MAX_CHAR_LENGTH {
+			if len(f) < MAX_CHAR_LENGTH && x != nil && x.isOctets() {
+				blr, v = _octetsToBlr(f)
+			} else if len(f) < MAX_CHAR_LENGTH {
 				blr, v = _bytesToBlr(f)
 			} else {
-				v, _ = p.createBlob(f, transHandle)
+				v, err = p.createBlob(f, transHandle, blobBpb(x))
 				blr = []byte{9, 0}
 			}
 		default:
@@ -1156,10 +1953,13 @@ func (p *wireProtocol) paramsToBlr(transHandle int32, params []driver.Value, pro
 			if len(b) < MAX_CHAR_LENGTH {
 				blr, v = _bytesToBlr(b)
 			} else {
-				v, _ = p.createBlob(b, transHandle)
+				v, err = p.createBlob(b, transHandle, blobBpb(x))
 				blr = []byte{9, 0}
 			}
 		}
+		if err != nil {
+			return nil, nil, err
+		}
 		valuesList.PushBack(v)
 		if protocolVersion < PROTOCOL_VERSION13 {
 			if param == nil {
@@ -1176,5 +1976,5 @@ func (p *wireProtocol) paramsToBlr(transHandle int32, params []driver.Value, pro
 	blr = flattenBytes(blrList)
 	v = flattenBytes(valuesList)
 
-	return blr, v
+	return blr, v, nil
 }
@@ -0,0 +1,654 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGuessWireCrypt(t *testing.T) {
+	nonce := []byte("0123456789ab")
+	keys := bytes.Join([][]byte{
+		[]byte{TAG_KEY_TYPE, 9}, []byte("Symmetric"),
+		[]byte{TAG_KEY_PLUGINS, 11}, []byte("ChaCha Arc4"),
+		[]byte{TAG_PLUGIN_SPECIFIC, 19}, []byte("ChaCha\x00"), nonce,
+	}, nil)
+	plugin, n := guessWireCrypt(keys)
+	if plugin != "ChaCha" || !bytes.Equal(n, nonce) {
+		t.Errorf("ChaCha: %s %v", plugin, n)
+	}
+
+	// ChaCha without a nonce can't be used
+	plugin, _ = guessWireCrypt(keys[:len(keys)-21])
+	if plugin != "Arc4" {
+		t.Errorf("Arc4: %s", plugin)
+	}
+
+	plugin, _ = guessWireCrypt(nil)
+	if plugin != "" {
+		t.Errorf("no plugin: %s", plugin)
+	}
+}
+
+func TestWireChannelCrypt(t *testing.T) {
+	for _, plugin := range []string{"Arc4", "ChaCha"} {
+		c1, c2 := net.Pipe()
+		w, _ := newWireChannel(c1)
+		r, _ := newWireChannel(c2)
+		key := []byte("session key")
+		nonce := []byte("0123456789ab")
+		if err := w.setCryptKey(plugin, key, nonce); err != nil {
+			t.Fatalf("%s: %v", plugin, err)
+		}
+		r.setCryptKey(plugin, key, nonce)
+
+		msg := []byte("SELECT * FROM rdb$database")
+		go func() {
+			w.Write(msg[:5])
+			w.Write(msg[5:])
+		}()
+		buf := make([]byte, len(msg))
+		for n := 0; n < len(buf); {
+			m, err := r.Read(buf[n:])
+			if err != nil {
+				t.Fatalf("%s: %v", plugin, err)
+			}
+			n += m
+		}
+		if !bytes.Equal(buf, msg) {
+			t.Errorf("%s: %s", plugin, buf)
+		}
+		c1.Close()
+		c2.Close()
+	}
+
+	var c wireChannel
+	if err := c.setCryptKey("Unknown", []byte("key"), nil); err == nil {
+		t.Errorf("unknown plugin was accepted")
+	}
+}
+
+func TestWireChannelTimeout(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	r, _ := newWireChannel(c2)
+	r.timeout = 50 * time.Millisecond
+
+	go c1.Write([]byte("in time"))
+	buf := make([]byte, 7)
+	if _, err := r.Read(buf); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	// the server doesn't answer
+	if _, err := r.Read(buf); err != driver.ErrBadConn {
+		t.Errorf("expected driver.ErrBadConn, got %v", err)
+	}
+}
+
+func TestWireChannelTimeoutAfterRequest(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	p := &wireProtocol{dsn: &firebirdDsn{}}
+	p.conn, _ = newWireChannel(c2)
+	p.conn.timeout = 50 * time.Millisecond
+	p.conn.attached = true
+
+	go c1.Read(make([]byte, 4))
+	p.packInt(op_commit)
+	if _, err := p.sendPackets(); err != nil {
+		t.Fatalf("sendPackets: %v", err)
+	}
+	// the server may have done the commit
+	_, _, _, err := p.opResponse()
+	var ne net.Error
+	if !errors.As(err, &ne) || !ne.Timeout() || err == driver.ErrBadConn {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+	fc := &firebirdsqlConn{wp: p}
+	if fc.IsValid() {
+		t.Errorf("timed out connection is valid")
+	}
+}
+
+func TestWireChannelDeadConn(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+	r, _ := newWireChannel(c2)
+	c1.Close()
+
+	buf := make([]byte, 4)
+	if _, err := r.Read(buf); err != driver.ErrBadConn || !r.broken {
+		t.Errorf("closed by the server: expected driver.ErrBadConn, got %v", err)
+	}
+	fc := &firebirdsqlConn{wp: &wireProtocol{conn: r}}
+	if fc.IsValid() {
+		t.Errorf("broken connection is valid")
+	}
+	if err := fc.ResetSession(context.Background()); err != driver.ErrBadConn {
+		t.Errorf("ResetSession: expected driver.ErrBadConn, got %v", err)
+	}
+}
+
+func TestWireChannelConnLost(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+	p := &wireProtocol{dsn: &firebirdDsn{}}
+	p.conn, _ = newWireChannel(c2)
+	p.conn.attached = true
+	go func() {
+		buf := make([]byte, 4)
+		c1.Read(buf)
+		c1.Close()
+	}()
+
+	// the request was sent, the server may have done it
+	p.packInt(op_commit)
+	p.sendPackets()
+	if _, _, _, err := p.opResponse(); err == nil || err == driver.ErrBadConn || !p.conn.broken {
+		t.Errorf("response: expected the connection lost, got %v", err)
+	}
+	// nothing of the request is sent
+	p.packInt(op_commit)
+	if _, err := p.sendPackets(); err != driver.ErrBadConn {
+		t.Errorf("request: expected driver.ErrBadConn, got %v", err)
+	}
+}
+
+func TestGetBlobSegmentsDeadConn(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+	p := &wireProtocol{dsn: &firebirdDsn{}}
+	p.conn, _ = newWireChannel(c2)
+	go io.Copy(io.Discard, c1)
+	go func() {
+		// the blob is opened, then the connection is cut
+		c1.Write(bytes.Join([][]byte{
+			bint32_to_bytes(op_response),
+			make([]byte, 12), // handle, object id
+			xdrBytes(nil),
+			bint32_to_bytes(isc_arg_end),
+		}, nil))
+		c1.Close()
+	}()
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.getBlobSegments(make([]byte, 8), 0)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Errorf("expected the read error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("getBlobSegments does not return")
+	}
+}
+
+func TestDialServer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer ln.Close()
+	dialErr := errors.New("no route")
+	dsn := &firebirdDsn{
+		addr:           "db.example.com:3050",
+		connectTimeout: time.Second,
+		dial: func(ctx context.Context) (net.Conn, error) {
+			return nil, dialErr
+		},
+	}
+	if _, err = dialServer(context.Background(), dsn, dsn.addr); err != dialErr {
+		t.Errorf("expected the error of dial, got %v", err)
+	}
+	// another port, e.g. of the events, is a TCP connection
+	conn, err := dialServer(context.Background(), dsn, ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dialServer: %v", err)
+	}
+	conn.Close()
+	if _, ok := conn.(*net.TCPConn); !ok {
+		t.Errorf("unexpected connection %T", conn)
+	}
+}
+
+func TestOpResponseDeadConn(t *testing.T) {
+	for _, sent := range [][]byte{
+		nil,
+		bint32_to_bytes(op_response),
+		append(bint32_to_bytes(op_response), make([]byte, 8)...), // cut in the handle
+	} {
+		c1, c2 := net.Pipe()
+		p := &wireProtocol{dsn: &firebirdDsn{}}
+		p.conn, _ = newWireChannel(c2)
+		go func() {
+			c1.Write(sent)
+			c1.Close()
+		}()
+		if _, _, _, err := p.opResponse(); err != driver.ErrBadConn {
+			t.Errorf("%d bytes: expected driver.ErrBadConn, got %v", len(sent), err)
+		}
+		c2.Close()
+	}
+
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+	p := &wireProtocol{dsn: &firebirdDsn{}}
+	p.conn, _ = newWireChannel(c2)
+	go func() {
+		c1.Write(bint32_to_bytes(op_sql_response))
+		c1.Close()
+	}()
+	if _, err := p.opSqlResponse(nil); err != driver.ErrBadConn {
+		t.Errorf("opSqlResponse: expected driver.ErrBadConn, got %v", err)
+	}
+}
+
+func TestWireChannelCompression(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	w, _ := newWireChannel(c1)
+	r, _ := newWireChannel(c2)
+	w.startCompression()
+	r.startCompression()
+
+	msgs := [][]byte{
+		[]byte("compressed"),
+		bytes.Repeat([]byte("compressed and encrypted "), 100),
+	}
+	go func() {
+		w.Write(msgs[0])
+		w.setCryptKey("Arc4", []byte("session key"), nil)
+		w.Write(msgs[1])
+	}()
+	for i, msg := range msgs {
+		if i == 1 {
+			r.setCryptKey("Arc4", []byte("session key"), nil)
+		}
+		buf := make([]byte, len(msg))
+		for n := 0; n < len(buf); {
+			m, err := r.Read(buf[n:])
+			if err != nil {
+				t.Fatalf("%d: %v", i, err)
+			}
+			n += m
+		}
+		if !bytes.Equal(buf, msg) {
+			t.Errorf("%d: %s", i, buf)
+		}
+	}
+}
+
+func TestParamsToBlrDialect1(t *testing.T) {
+	tm := time.Date(0, 1, 1, 12, 30, 0, 0, time.UTC)
+	p := &wireProtocol{dsn: &firebirdDsn{dialect: 3}, protocolVersion: PROTOCOL_VERSION13}
+	blr, _, _ := p.paramsToBlr(0, []driver.Value{tm}, nil, p.protocolVersion)
+	if blr[6] != 13 {
+		t.Errorf("dialect 3 TIME: %v", blr)
+	}
+	// dialect 1 has no TIME
+	p.dsn.dialect = 1
+	blr, v, _ := p.paramsToBlr(0, []driver.Value{tm}, nil, p.protocolVersion)
+	if blr[6] != 35 || !bytes.Equal(v[4:], append(_convert_date(tm), _convert_time(tm)...)) {
+		t.Errorf("dialect 1 TIMESTAMP: %v %v", blr, v)
+	}
+}
+
+func TestRecvNullIndicator(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	p := &wireProtocol{}
+	p.conn, _ = newWireChannel(c2)
+
+	// 10 columns, the columns 0 and 9 are NULL, padded to 4 bytes
+	go c1.Write([]byte{0x01, 0x02, 0, 0})
+	null_indicator, err := p.recvNullIndicator(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if (null_indicator.Bit(i) != 0) != (i == 0 || i == 9) {
+			t.Errorf("column %d: %d", i, null_indicator.Bit(i))
+		}
+	}
+}
+
+func TestParamsToBlrOctets(t *testing.T) {
+	b := []byte{'a', 0, 0xff}
+	p := &wireProtocol{dsn: &firebirdDsn{dialect: 3}, protocolVersion: PROTOCOL_VERSION13}
+	blr, v, _ := p.paramsToBlr(0, []driver.Value{b}, nil, p.protocolVersion)
+	if !bytes.Equal(blr[6:9], []byte{14, 3, 0}) {
+		t.Errorf("without description: %v", blr)
+	}
+	octets := []xSQLVAR{{sqltype: SQL_TYPE_VARYING, sqlsubtype: 1, sqllen: 10}}
+	blr, v, _ = p.paramsToBlr(0, []driver.Value{b}, octets, p.protocolVersion)
+	if !bytes.Equal(blr[6:11], []byte{15, 1, 0, 3, 0}) || !bytes.Equal(v[4:8], []byte{'a', 0, 0xff, 0}) {
+		t.Errorf("OCTETS: %v %v", blr, v)
+	}
+}
+
+func TestParamsToBlrTimeColumns(t *testing.T) {
+	tm := time.Date(2026, 10, 14, 12, 30, 15, 0, time.UTC)
+	p := &wireProtocol{dsn: &firebirdDsn{dialect: 3}, protocolVersion: PROTOCOL_VERSION13}
+	tests := []struct {
+		sqltype int
+		blr     byte
+		value   []byte
+	}{
+		{SQL_TYPE_DATE, 12, _convert_date(tm)},
+		{SQL_TYPE_TIME, 13, _convert_time(tm)},
+		{SQL_TYPE_TIMESTAMP, 35, append(_convert_date(tm), _convert_time(tm)...)},
+	}
+	for _, tt := range tests {
+		x := []xSQLVAR{{sqltype: tt.sqltype}}
+		blr, v, err := p.paramsToBlr(0, []driver.Value{tm}, x, p.protocolVersion)
+		if err != nil || blr[6] != tt.blr || !bytes.Equal(v[4:], tt.value) {
+			t.Errorf("sqltype %d: %v %v %v", tt.sqltype, blr, v, err)
+		}
+	}
+
+	// a time of day goes to a TIMESTAMP column on the day 0
+	tod := time.Date(0, 1, 1, 8, 0, 0, 0, time.UTC)
+	x := []xSQLVAR{{sqltype: SQL_TYPE_TIMESTAMP}}
+	blr, _, _ := p.paramsToBlr(0, []driver.Value{tod}, x, p.protocolVersion)
+	if blr[6] != 35 {
+		t.Errorf("time of day to TIMESTAMP: %v", blr)
+	}
+	if !needsBindXsqlda([]driver.Value{tm}) {
+		t.Errorf("time.Time needs no description")
+	}
+
+	// with timezone= the time is sent in the location it is read in
+	tokyo := time.FixedZone("JST", 9*60*60)
+	p.dsn.location = tokyo
+	x = []xSQLVAR{{sqltype: SQL_TYPE_TIMESTAMP}}
+	_, v, _ := p.paramsToBlr(0, []driver.Value{tm}, x, p.protocolVersion)
+	if !bytes.Equal(v[4:], append(_convert_date(tm.In(tokyo)), _convert_time(tm.In(tokyo))...)) {
+		t.Errorf("timestamp in timezone: %v", v)
+	}
+	if got := x[0].parseTimestamp(v[4:12], tokyo); !got.Equal(tm) {
+		t.Errorf("round trip in timezone: %v", got)
+	}
+}
+
+func TestBlobBpb(t *testing.T) {
+	if bpb := blobBpb(&xSQLVAR{sqltype: SQL_TYPE_BLOB, sqlsubtype: 1}); !bytes.Equal(bpb, []byte{1, 1, 1, 1, 2, 1, 1}) {
+		t.Errorf("text blob: %v", bpb)
+	}
+	for _, x := range []*xSQLVAR{nil, {sqltype: SQL_TYPE_BLOB}, {sqltype: SQL_TYPE_VARYING, sqlsubtype: 1}} {
+		if bpb := blobBpb(x); bpb != nil {
+			t.Errorf("%v: %v", x, bpb)
+		}
+	}
+	if !needsBindXsqlda([]driver.Value{strings.NewReader("a")}) || !needsBindXsqlda([]driver.Value{strings.Repeat("a", MAX_CHAR_LENGTH)}) {
+		t.Errorf("blob parameters need no description")
+	}
+}
+
+func TestParamsToBlrNull(t *testing.T) {
+	p := &wireProtocol{dsn: &firebirdDsn{dialect: 3}, protocolVersion: PROTOCOL_VERSION13}
+	// the first parameter is NULL too
+	_, v, err := p.paramsToBlr(0, []driver.Value{nil, int32(1), nil}, nil, p.protocolVersion)
+	if err != nil || !bytes.Equal(v[:4], []byte{0x05, 0, 0, 0}) || !bytes.Equal(v[4:], []byte{0, 0, 0, 1}) {
+		t.Errorf("null indicator: %v %v", v, err)
+	}
+
+	fc := &firebirdsqlConn{}
+	for _, arg := range []interface{}{(*bytes.Buffer)(nil), []int32(nil), []byte(nil)} {
+		nv := &driver.NamedValue{Value: arg}
+		if err := fc.CheckNamedValue(nv); err != nil || nv.Value != nil {
+			t.Errorf("%T nil: %v %v", arg, nv.Value, err)
+		}
+	}
+	// an empty []byte is not NULL
+	nv := &driver.NamedValue{Value: []byte{}}
+	fc.CheckNamedValue(nv)
+	if nv.Value == nil {
+		t.Errorf("empty []byte is NULL")
+	}
+}
+
+func TestParseSelectItemsNullable(t *testing.T) {
+	item := func(code byte, v int32) []byte {
+		return append([]byte{code, 4, 0}, int32_to_bytes(v)...)
+	}
+	var buf []byte
+	buf = append(buf, item(isc_info_sql_sqlda_seq, 1)...)
+	buf = append(buf, item(isc_info_sql_type, SQL_TYPE_LONG)...)
+	buf = append(buf, item(isc_info_sql_null_ind, 0)...)
+	buf = append(buf, isc_info_sql_describe_end)
+	buf = append(buf, item(isc_info_sql_sqlda_seq, 2)...)
+	buf = append(buf, item(isc_info_sql_type, SQL_TYPE_LONG+1)...)
+	buf = append(buf, item(isc_info_sql_null_ind, 1)...)
+	buf = append(buf, isc_info_sql_describe_end, isc_info_end)
+
+	xsqlda := make([]xSQLVAR, 2)
+	p := &wireProtocol{}
+	if _, _, err := p._parse_select_items(buf, xsqlda); err != nil {
+		t.Fatalf("_parse_select_items: %v", err)
+	}
+	rows := &firebirdsqlRows{stmt: &firebirdsqlStmt{xsqlda: xsqlda}}
+	for i, expected := range []bool{false, true} {
+		if nullable, ok := rows.ColumnTypeNullable(i); nullable != expected || !ok {
+			t.Errorf("column %d: expected nullable %v, got %v %v", i, expected, nullable, ok)
+		}
+		if xsqlda[i].sqltype != SQL_TYPE_LONG {
+			t.Errorf("column %d: unexpected sqltype %d", i, xsqlda[i].sqltype)
+		}
+	}
+}
+
+func TestParseXsqldaBind(t *testing.T) {
+	item := func(code byte, v int32) []byte {
+		return append([]byte{code, 4, 0}, int32_to_bytes(v)...)
+	}
+	describe := func(code byte, types ...int32) []byte {
+		buf := append([]byte{code}, item(isc_info_sql_describe_vars, int32(len(types)))...)
+		for i, sqltype := range types {
+			buf = append(buf, item(isc_info_sql_sqlda_seq, int32(i+1))...)
+			buf = append(buf, item(isc_info_sql_type, sqltype)...)
+			buf = append(buf, isc_info_sql_describe_end)
+		}
+		return buf
+	}
+	var buf []byte
+	buf = append(buf, item(isc_info_sql_stmt_type, isc_info_sql_stmt_select)...)
+	buf = append(buf, describe(isc_info_sql_select, SQL_TYPE_LONG)...)
+	buf = append(buf, describe(isc_info_sql_bind, SQL_TYPE_TIMESTAMP, SQL_TYPE_BLOB)...)
+	buf = append(buf, isc_info_end)
+
+	p := &wireProtocol{}
+	stmtType, xsqlda, bindXsqlda, err := p.parse_xsqlda(buf, 0)
+	if err != nil || stmtType != isc_info_sql_stmt_select {
+		t.Fatalf("parse_xsqlda: %v %v", stmtType, err)
+	}
+	if len(xsqlda) != 1 || xsqlda[0].sqltype != SQL_TYPE_LONG {
+		t.Errorf("select description: %v", xsqlda)
+	}
+	if len(bindXsqlda) != 2 || bindXsqlda[0].sqltype != SQL_TYPE_TIMESTAMP || bindXsqlda[1].sqltype != SQL_TYPE_BLOB {
+		t.Errorf("bind description: %v", bindXsqlda)
+	}
+
+	// without parameters the bind description is empty, not missing
+	buf = append(item(isc_info_sql_stmt_type, isc_info_sql_stmt_select), describe(isc_info_sql_select)...)
+	buf = append(buf, describe(isc_info_sql_bind)...)
+	buf = append(buf, isc_info_end)
+	if _, _, bindXsqlda, err = p.parse_xsqlda(buf, 0); err != nil || bindXsqlda == nil || len(bindXsqlda) != 0 {
+		t.Errorf("empty bind description: %v %v", bindXsqlda, err)
+	}
+}
+
+func TestWithStatementTimeout(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	go io.Copy(io.Discard, c1) // the cancel of the fallback
+	p := &wireProtocol{}
+	p.conn, _ = newWireChannel(c2)
+	ctx := WithStatementTimeout(context.Background(), 20*time.Millisecond)
+
+	// Firebird 4 times out the statement
+	p.protocolVersion = PROTOCOL_VERSION16
+	var sent time.Duration
+	err := p.withContext(ctx, func() error {
+		sent = p.stmtTimeout
+		return nil
+	})
+	if err != nil || sent != 20*time.Millisecond || p.stmtTimeout != 0 {
+		t.Errorf("protocol 16: sent %v, left %v, err %v", sent, p.stmtTimeout, err)
+	}
+
+	// older servers are cancelled at the deadline
+	p.protocolVersion = PROTOCOL_VERSION13
+	err = p.withContext(ctx, func() error {
+		if p.stmtTimeout != 0 {
+			t.Errorf("protocol 13: sent %v", p.stmtTimeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+		return errors.New("operation was cancelled")
+	})
+	if err != ErrStatementTimeout || !IsStatementTimeout(err) {
+		t.Errorf("protocol 13: expected ErrStatementTimeout, got %v", err)
+	}
+}
+
+func TestOpPrepare2(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	p := &wireProtocol{}
+	p.conn, _ = newWireChannel(c2)
+	go p.opPrepare2(7, []byte("xid-1"))
+
+	buf := make([]byte, 20)
+	if _, err := io.ReadFull(c1, buf); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	expected := []byte{
+		0, 0, 0, op_prepare2,
+		0, 0, 0, 7,
+		0, 0, 0, 5, 'x', 'i', 'd', '-', '1', 0, 0, 0,
+	}
+	if !bytes.Equal(buf, expected) {
+		t.Errorf("expected % x, got % x", expected, buf)
+	}
+	if err := (&firebirdsqlTx{isAutocommit: true}).PrepareTransaction(nil); err == nil {
+		t.Errorf("an autocommit transaction was prepared")
+	}
+}
+
+func TestOpFetchScroll(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	p := &wireProtocol{dsn: &firebirdDsn{dialect: 3}, protocolVersion: PROTOCOL_VERSION18}
+	p.conn, _ = newWireChannel(c2)
+
+	read := func(n int) []byte {
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(c1, buf); err != nil {
+			t.Fatalf("ReadFull: %v", err)
+		}
+		return buf
+	}
+	go p.opFetchScroll(7, []byte{1, 2}, fetch_absolute, -2)
+	expected := []byte{
+		0, 0, 0, op_fetch_scroll, 0, 0, 0, 7, 0, 0, 0, 2, 1, 2, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, fetch_absolute, 0xff, 0xff, 0xff, 0xfe,
+	}
+	if b := read(len(expected)); !bytes.Equal(b, expected) {
+		t.Errorf("op_fetch_scroll: %v", b)
+	}
+
+	// the cursor flags follow the statement timeout since protocol 18
+	p.cursorFlags = CURSOR_TYPE_SCROLLABLE
+	go p.opExecute(7, 3, nil, nil)
+	if b := read(32); !bytes.Equal(b[24:], []byte{0, 0, 0, 0, 0, 0, 0, CURSOR_TYPE_SCROLLABLE}) {
+		t.Errorf("op_execute: %v", b)
+	}
+}
+
+func TestInfoTruncated(t *testing.T) {
+	var tests = []struct {
+		buf       []byte
+		truncated bool
+	}{
+		{[]byte{isc_info_page_size, 4, 0, 0, 0x10, 0, 0, isc_info_end}, false},
+		{[]byte{isc_info_page_size, 4, 0, 0, 0x10, 0, 0, isc_info_truncated}, true},
+		{[]byte{isc_info_truncated}, true},
+		{[]byte{isc_info_user_names, 2, 0, 1, 'A', isc_info_user_names}, false},
+	}
+	for _, tt := range tests {
+		if truncated := infoTruncated(tt.buf); truncated != tt.truncated {
+			t.Errorf("%v: expected %v, got %v", tt.buf, tt.truncated, truncated)
+		}
+	}
+}
+
+func TestInfoDatabaseRetry(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	p := &wireProtocol{dsn: &firebirdDsn{}}
+	p.conn, _ = newWireChannel(c2)
+
+	full := []byte{isc_info_page_size, 4, 0, 0, 0x10, 0, 0, isc_info_end}
+	var lengths []int32
+	go func() {
+		for _, buf := range [][]byte{{isc_info_truncated}, {isc_info_truncated}, full} {
+			// op, handle, 0, the 2 items, the buffer length
+			b := make([]byte, 24)
+			if _, err := io.ReadFull(c1, b); err != nil {
+				return
+			}
+			lengths = append(lengths, bytes_to_bint32(b[20:]))
+			c1.Write(bytes.Join([][]byte{
+				bint32_to_bytes(op_response),
+				make([]byte, 12), // handle, object id
+				xdrBytes(buf),
+				bint32_to_bytes(isc_arg_end),
+			}, nil))
+		}
+	}()
+	buf, err := p.infoDatabase([]byte{isc_info_page_size, isc_info_end})
+	if err != nil || !bytes.Equal(buf, full) {
+		t.Fatalf("infoDatabase: %v %v", buf, err)
+	}
+	if len(lengths) != 3 || lengths[0] != BUFFER_LEN || lengths[1] != 2*BUFFER_LEN || lengths[2] != 4*BUFFER_LEN {
+		t.Errorf("buffer lengths %v", lengths)
+	}
+}
@@ -26,60 +26,89 @@ package firebirdsql
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"math"
 	"math/big"
+	"reflect"
+	"strings"
 	"time"
 )
 
 const (
-	SQL_TYPE_TEXT      = 452
-	SQL_TYPE_VARYING   = 448
-	SQL_TYPE_SHORT     = 500
-	SQL_TYPE_LONG      = 496
-	SQL_TYPE_FLOAT     = 482
-	SQL_TYPE_DOUBLE    = 480
-	SQL_TYPE_D_FLOAT   = 530
-	SQL_TYPE_TIMESTAMP = 510
-	SQL_TYPE_BLOB      = 520
-	SQL_TYPE_ARRAY     = 540
-	SQL_TYPE_QUAD      = 550
-	SQL_TYPE_TIME      = 560
-	SQL_TYPE_DATE      = 570
-	SQL_TYPE_INT64     = 580
-	SQL_TYPE_BOOLEAN   = 32764
-	SQL_TYPE_NULL      = 32766
+	SQL_TYPE_TEXT            = 452
+	SQL_TYPE_VARYING         = 448
+	SQL_TYPE_SHORT           = 500
+	SQL_TYPE_LONG            = 496
+	SQL_TYPE_FLOAT           = 482
+	SQL_TYPE_DOUBLE          = 480
+	SQL_TYPE_D_FLOAT         = 530
+	SQL_TYPE_TIMESTAMP       = 510
+	SQL_TYPE_BLOB            = 520
+	SQL_TYPE_ARRAY           = 540
+	SQL_TYPE_QUAD            = 550
+	SQL_TYPE_TIME            = 560
+	SQL_TYPE_DATE            = 570
+	SQL_TYPE_INT64           = 580
+	SQL_TYPE_TIMESTAMP_TZ_EX = 32748
+	SQL_TYPE_TIME_TZ_EX      = 32750
+	SQL_TYPE_INT128          = 32752
+	SQL_TYPE_TIMESTAMP_TZ    = 32754
+	SQL_TYPE_TIME_TZ         = 32756
+	SQL_TYPE_DEC16           = 32760
+	SQL_TYPE_DEC34           = 32762
+	SQL_TYPE_BOOLEAN         = 32764
+	SQL_TYPE_NULL            = 32766
 )
 
 var xsqlvarTypeLength = map[int]int{
-	SQL_TYPE_VARYING:   -1,
-	SQL_TYPE_SHORT:     4,
-	SQL_TYPE_LONG:      4,
-	SQL_TYPE_FLOAT:     4,
-	SQL_TYPE_TIME:      4,
-	SQL_TYPE_DATE:      4,
-	SQL_TYPE_DOUBLE:    8,
-	SQL_TYPE_TIMESTAMP: 8,
-	SQL_TYPE_BLOB:      8,
-	SQL_TYPE_ARRAY:     8,
-	SQL_TYPE_QUAD:      8,
-	SQL_TYPE_INT64:     8,
-	SQL_TYPE_BOOLEAN:   1,
+	SQL_TYPE_VARYING:         -1,
+	SQL_TYPE_SHORT:           4,
+	SQL_TYPE_LONG:            4,
+	SQL_TYPE_FLOAT:           4,
+	SQL_TYPE_TIME:            4,
+	SQL_TYPE_DATE:            4,
+	SQL_TYPE_DOUBLE:          8,
+	SQL_TYPE_D_FLOAT:         8,
+	SQL_TYPE_TIMESTAMP:       8,
+	SQL_TYPE_BLOB:            8,
+	SQL_TYPE_ARRAY:           8,
+	SQL_TYPE_QUAD:            8,
+	SQL_TYPE_INT64:           8,
+	SQL_TYPE_INT128:          16,
+	SQL_TYPE_TIMESTAMP_TZ:    16, // always fetched as blr_ex_timestamp_tz
+	SQL_TYPE_TIME_TZ:         12, // always fetched as blr_ex_time_tz
+	SQL_TYPE_TIME_TZ_EX:      12,
+	SQL_TYPE_TIMESTAMP_TZ_EX: 16,
+	SQL_TYPE_DEC16:           8,
+	SQL_TYPE_DEC34:           16,
+	SQL_TYPE_BOOLEAN:         1,
+	SQL_TYPE_NULL:            0,
 }
 
 var xsqlvarTypeDisplayLength = map[int]int{
-	SQL_TYPE_VARYING:   -1,
-	SQL_TYPE_SHORT:     6,
-	SQL_TYPE_LONG:      11,
-	SQL_TYPE_FLOAT:     17,
-	SQL_TYPE_TIME:      11,
-	SQL_TYPE_DATE:      10,
-	SQL_TYPE_DOUBLE:    17,
-	SQL_TYPE_TIMESTAMP: 22,
-	SQL_TYPE_BLOB:      0,
-	SQL_TYPE_ARRAY:     -1,
-	SQL_TYPE_QUAD:      20,
-	SQL_TYPE_INT64:     20,
-	SQL_TYPE_BOOLEAN:   5,
+	SQL_TYPE_VARYING:         -1,
+	SQL_TYPE_SHORT:           6,
+	SQL_TYPE_LONG:            11,
+	SQL_TYPE_FLOAT:           17,
+	SQL_TYPE_TIME:            11,
+	SQL_TYPE_DATE:            10,
+	SQL_TYPE_DOUBLE:          17,
+	SQL_TYPE_D_FLOAT:         17,
+	SQL_TYPE_TIMESTAMP:       22,
+	SQL_TYPE_BLOB:            0,
+	SQL_TYPE_ARRAY:           -1,
+	SQL_TYPE_QUAD:            20,
+	SQL_TYPE_INT64:           20,
+	SQL_TYPE_INT128:          40,
+	SQL_TYPE_TIMESTAMP_TZ:    58,
+	SQL_TYPE_TIMESTAMP_TZ_EX: 58,
+	SQL_TYPE_TIME_TZ:         47,
+	SQL_TYPE_TIME_TZ_EX:      47,
+	SQL_TYPE_DEC16:           23,
+	SQL_TYPE_DEC34:           42,
+	SQL_TYPE_BOOLEAN:         5,
+	SQL_TYPE_NULL:            0,
 }
 
 type xSQLVAR struct {
@@ -94,6 +123,201 @@ type xSQLVAR struct {
 	aliasname  string
 }
 
+func (x *xSQLVAR) isScaledInteger() bool {
+	switch x.sqltype {
+	case SQL_TYPE_SHORT, SQL_TYPE_LONG, SQL_TYPE_INT64, SQL_TYPE_INT128:
+		return x.sqlscale != 0
+	}
+	return false
+}
+
+// isOctets reports whether the column is CHAR or VARCHAR CHARACTER SET OCTETS.
+func (x *xSQLVAR) isOctets() bool {
+	return (x.sqltype == SQL_TYPE_TEXT || x.sqltype == SQL_TYPE_VARYING) && x.sqlsubtype == 1
+}
+
+var xsqlvarTypeName = map[int]string{
+	SQL_TYPE_TEXT:            "CHAR",
+	SQL_TYPE_VARYING:         "VARCHAR",
+	SQL_TYPE_SHORT:           "SMALLINT",
+	SQL_TYPE_LONG:            "INTEGER",
+	SQL_TYPE_FLOAT:           "FLOAT",
+	SQL_TYPE_DOUBLE:          "DOUBLE PRECISION",
+	SQL_TYPE_D_FLOAT:         "DOUBLE PRECISION",
+	SQL_TYPE_TIMESTAMP:       "TIMESTAMP",
+	SQL_TYPE_BLOB:            "BLOB",
+	SQL_TYPE_ARRAY:           "ARRAY",
+	SQL_TYPE_QUAD:            "QUAD",
+	SQL_TYPE_TIME:            "TIME",
+	SQL_TYPE_DATE:            "DATE",
+	SQL_TYPE_INT64:           "BIGINT",
+	SQL_TYPE_TIMESTAMP_TZ_EX: "TIMESTAMP WITH TIME ZONE",
+	SQL_TYPE_TIME_TZ_EX:      "TIME WITH TIME ZONE",
+	SQL_TYPE_INT128:          "INT128",
+	SQL_TYPE_TIMESTAMP_TZ:    "TIMESTAMP WITH TIME ZONE",
+	SQL_TYPE_TIME_TZ:         "TIME WITH TIME ZONE",
+	SQL_TYPE_DEC16:           "DECFLOAT(16)",
+	SQL_TYPE_DEC34:           "DECFLOAT(34)",
+	SQL_TYPE_BOOLEAN:         "BOOLEAN",
+	SQL_TYPE_NULL:            "NULL",
+}
+
+// typeName returns the SQL type name of the column. The sub type of an
+// exact numeric tells NUMERIC (1) from DECIMAL (2), and the one of a BLOB
+// is part of the name, e.g. BLOB SUB_TYPE TEXT.
+func (x *xSQLVAR) typeName() string {
+	switch x.sqltype {
+	case SQL_TYPE_SHORT, SQL_TYPE_LONG, SQL_TYPE_INT64, SQL_TYPE_INT128:
+		switch {
+		case x.sqlsubtype == 2:
+			return "DECIMAL"
+		case x.sqlsubtype == 1 || x.sqlscale != 0:
+			return "NUMERIC"
+		}
+	case SQL_TYPE_BLOB:
+		switch x.sqlsubtype {
+		case 0:
+			return "BLOB SUB_TYPE BINARY"
+		case 1:
+			return "BLOB SUB_TYPE TEXT"
+		}
+		return fmt.Sprintf("BLOB SUB_TYPE %d", x.sqlsubtype)
+	}
+	return xsqlvarTypeName[x.sqltype]
+}
+
+// precision returns the precision of a NUMERIC or DECIMAL column, and false
+// for other columns. The server describes only the storage of the value,
+// so it is the largest precision of it, e.g. 9 for NUMERIC(5, 2) stored as
+// INTEGER.
+func (x *xSQLVAR) precision() (int, bool) {
+	if x.sqlsubtype != 1 && x.sqlsubtype != 2 && x.sqlscale == 0 {
+		return 0, false
+	}
+	switch x.sqltype {
+	case SQL_TYPE_SHORT:
+		return 4, true
+	case SQL_TYPE_LONG:
+		return 9, true
+	case SQL_TYPE_INT64:
+		return 18, true
+	case SQL_TYPE_INT128:
+		return 38, true
+	}
+	return 0, false
+}
+
+// charsetId returns the character set id of a CHAR, VARCHAR or text
+// BLOB column, and 0 (NONE) for other columns.
+func (x *xSQLVAR) charsetId() int {
+	switch x.sqltype {
+	case SQL_TYPE_TEXT, SQL_TYPE_VARYING:
+		return x.sqlsubtype & 0xff
+	case SQL_TYPE_BLOB:
+		// the scale of a text blob is its character set
+		if x.sqlsubtype == 1 {
+			return x.sqlscale & 0xff
+		}
+	}
+	return 0
+}
+
+// charsetNames is the names of the character sets by id. ASCII and
+// UNICODE_FSS are read as UTF8, which they are a subset of.
+var charsetNames = map[int]string{
+	2:  "UTF8", // ASCII
+	3:  "UTF8", // UNICODE_FSS
+	4:  "UTF8",
+	5:  "SJIS_0208",
+	6:  "EUCJ_0208",
+	9:  "DOS737",
+	10: "DOS437",
+	11: "DOS850",
+	12: "DOS865",
+	13: "DOS860",
+	14: "DOS863",
+	15: "DOS775",
+	16: "DOS858",
+	17: "DOS862",
+	18: "DOS864",
+	19: "NEXT",
+	21: "ISO8859_1",
+	22: "ISO8859_2",
+	23: "ISO8859_3",
+	34: "ISO8859_4",
+	35: "ISO8859_5",
+	36: "ISO8859_6",
+	37: "ISO8859_7",
+	38: "ISO8859_8",
+	39: "ISO8859_9",
+	40: "ISO8859_13",
+	44: "KSC_5601",
+	45: "DOS852",
+	46: "DOS857",
+	47: "DOS861",
+	48: "DOS866",
+	49: "DOS869",
+	50: "CYRL",
+	51: "WIN1250",
+	52: "WIN1251",
+	53: "WIN1252",
+	54: "WIN1253",
+	55: "WIN1254",
+	56: "BIG_5",
+	57: "GB_2312",
+	58: "WIN1255",
+	59: "WIN1256",
+	60: "WIN1257",
+	63: "KOI8R",
+	64: "KOI8U",
+	65: "WIN1258",
+	66: "TIS620",
+	67: "GBK",
+	68: "CP943C",
+	69: "GB18030",
+}
+
+// textCharset returns the character set to decode a CHAR or VARCHAR value
+// with. The server sends the values of a connection with charset=NONE in
+// the character set of their column, so a database with columns of mixed
+// character sets is decoded column by column. A NONE column is in the
+// connection charset, the default one without a DSN.
+func (x *xSQLVAR) textCharset(dsn *firebirdDsn) string {
+	charset := _connection_charset_encoding()
+	if dsn != nil {
+		charset = dsn.charset
+	}
+	name, ok := charsetNames[x.charsetId()]
+	if !ok || strings.EqualFold(name, charset) {
+		return charset
+	}
+	return name
+}
+
+// charsetBytesPerChar is the maximum bytes per character of the multi byte
+// character sets by id, the others are single byte.
+var charsetBytesPerChar = map[int]int{
+	3:  3, // UNICODE_FSS
+	4:  4, // UTF8
+	5:  2, // SJIS_0208
+	6:  2, // EUCJ_0208
+	44: 2, // KSC_5601
+	56: 2, // BIG_5
+	57: 2, // GB_2312
+	67: 2, // GBK
+	68: 2, // CP943C
+	69: 4, // GB18030
+}
+
+// charLength returns the declared length in characters of a CHAR or
+// VARCHAR column, e.g. 10 for a UTF8 VARCHAR(10) of 40 bytes.
+func (x *xSQLVAR) charLength() int {
+	if n, ok := charsetBytesPerChar[x.charsetId()]; ok {
+		return x.sqllen / n
+	}
+	return x.sqllen
+}
+
 func (x *xSQLVAR) ioLength() int {
 	if x.sqltype == SQL_TYPE_TEXT {
 		return x.sqllen
@@ -133,79 +357,228 @@ func (x *xSQLVAR) _parseDate(raw_value []byte) (int, int, int) {
 	return year, month, day
 }
 
+// The DATE range of Firebird, in which _parseDate and _encodeDate are
+// exact in the proleptic Gregorian calendar.
+var (
+	minDateSerial = _encodeDate(1, 1, 1)
+	maxDateSerial = _encodeDate(9999, 12, 31)
+)
+
+const timeOfDayUnits = 24 * 3600 * ISC_TIME_SECONDS_PRECISION
+
+// checkDate returns an error for a DATE out of 0001-01-01 .. 9999-12-31,
+// which would be parsed to a shifted day.
+func checkDate(raw_value []byte) error {
+	if n := bytes_to_bint32(raw_value); n < minDateSerial || n > maxDateSerial {
+		return fmt.Errorf("firebirdsql: DATE value %d is out of 0001-01-01 .. 9999-12-31", n)
+	}
+	return nil
+}
+
+// checkTime returns an error for a TIME out of a day, which would shift
+// the day of a TIMESTAMP.
+func checkTime(raw_value []byte) error {
+	if n := bytes_to_bint32(raw_value); n < 0 || n >= timeOfDayUnits {
+		return fmt.Errorf("firebirdsql: TIME value %d is out of a day", n)
+	}
+	return nil
+}
+
+// overflowValue is the value of a DATE or TIMESTAMP out of the range of
+// checkDate and checkTime, by timestamp_overflow: err, the time.Time of
+// the nearest end, or a RawTimestamp in UTC.
+func (x *xSQLVAR) overflowValue(raw_value []byte, dsn *firebirdDsn, err error) (interface{}, error) {
+	if dsn == nil || dsn.timestampOverflow == TIMESTAMP_OVERFLOW_ERROR {
+		return nil, err
+	}
+	date := bytes_to_bint32(raw_value)
+	var units int32
+	if x.sqltype != SQL_TYPE_DATE {
+		units = bytes_to_bint32(raw_value[4:8])
+	}
+	if dsn.timestampOverflow == TIMESTAMP_OVERFLOW_RAW {
+		t := time.Date(1858, 11, 17+int(date), 0, 0, 0, 0, time.UTC)
+		h, m, s, n := x._parseTime(bint32_to_bytes(units))
+		return RawTimestamp{t.Year(), int(t.Month()), t.Day(), h, m, s, n}, nil
+	}
+	if date < minDateSerial || (date == minDateSerial && units < 0) {
+		date, units = minDateSerial, 0
+	} else if date > maxDateSerial || (date == maxDateSerial && units >= timeOfDayUnits) {
+		date, units = maxDateSerial, timeOfDayUnits-1
+	} else if units < 0 {
+		units = 0
+	} else if units >= timeOfDayUnits {
+		units = timeOfDayUnits - 1
+	}
+	clamped := append(bint32_to_bytes(date), raw_value[4:]...)
+	if x.sqltype != SQL_TYPE_DATE {
+		copy(clamped[4:8], bint32_to_bytes(units))
+	}
+	return x.value(clamped, dsn)
+}
+
 func (x *xSQLVAR) _parseTime(raw_value []byte) (int, int, int, int) {
 	n := int(bytes_to_bint32(raw_value))
-	s := n / 10000
+	s := n / ISC_TIME_SECONDS_PRECISION
 	m := s / 60
 	h := m / 60
 	m = m % 60
 	s = s % 60
-	return h, m, s, (n % 10000) * 100000
+	return h, m, s, (n % ISC_TIME_SECONDS_PRECISION) * timeFractionNanoseconds
 }
 
-func (x *xSQLVAR) parseDate(raw_value []byte) time.Time {
+func (x *xSQLVAR) parseDate(raw_value []byte, loc *time.Location) time.Time {
 	year, month, day := x._parseDate(raw_value)
-	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, loc)
 }
 
-func (x *xSQLVAR) parseTime(raw_value []byte) time.Time {
+func (x *xSQLVAR) parseTime(raw_value []byte, loc *time.Location) time.Time {
 	h, m, s, n := x._parseTime(raw_value)
-	return time.Date(0, time.Month(1), 1, h, m, s, n, time.UTC)
+	return time.Date(0, time.Month(1), 1, h, m, s, n, loc)
 }
 
-func (x *xSQLVAR) parseTimestamp(raw_value []byte) time.Time {
+func (x *xSQLVAR) parseTimestamp(raw_value []byte, loc *time.Location) time.Time {
 	year, month, day := x._parseDate(raw_value[:4])
 	h, m, s, n := x._parseTime(raw_value[4:])
-	return time.Date(year, time.Month(month), day, h, m, s, n, time.UTC)
+	return time.Date(year, time.Month(month), day, h, m, s, n, loc)
+}
+
+func (x *xSQLVAR) parseTimestampTz(raw_value []byte) time.Time {
+	// date, time (in UTC), time zone id, offset minutes; each xdr 4 bytes
+	t := x.parseTimestamp(raw_value[:8], time.UTC)
+	tzId := int(uint16(bytes_to_bint32(raw_value[8:12])))
+	offset := int(int16(bytes_to_bint32(raw_value[12:16])))
+	return t.In(timeZoneLocation(tzId, offset))
 }
 
-func (x *xSQLVAR) value(raw_value []byte) (v interface{}, err error) {
+func (x *xSQLVAR) parseTimeTz(raw_value []byte) time.Time {
+	// time (in UTC), time zone id, offset minutes; each xdr 4 bytes
+	t := x.parseTime(raw_value[:4], time.UTC)
+	tzId := int(uint16(bytes_to_bint32(raw_value[4:8])))
+	offset := int(int16(bytes_to_bint32(raw_value[8:12])))
+	// the wall clock comes from the sent offset, region rules for year 0
+	// would give a meaningless local mean time.
+	t = t.Add(time.Duration(offset) * time.Minute)
+	h, m, s := t.Clock()
+	return time.Date(0, time.Month(1), 1, h, m, s, t.Nanosecond(), timeZoneLocation(tzId, offset))
+}
+
+func decimalValue(r *big.Rat, scale int, dsn *firebirdDsn) interface{} {
+	if dsn != nil {
+		switch dsn.decimalMode {
+		case DECIMAL_MODE_STRING:
+			return r.FloatString(-scale)
+		case DECIMAL_MODE_FLOAT:
+			f, _ := r.Float64()
+			return f
+		}
+	}
+	return r
+}
+
+// scaledValue returns mantissa * 10^scale (scale != 0). SHORT, LONG and
+// INT64 columns share it, so a NUMERIC value has the same Go type
+// whatever its storage width.
+func scaledValue(mantissa int64, scale int, dsn *firebirdDsn) interface{} {
+	if scale > 0 {
+		return mantissa * bigPow10(scale).Int64()
+	}
+	return decimalValue(new(big.Rat).SetFrac(big.NewInt(mantissa), bigPow10(-scale)), scale, dsn)
+}
+
+// value decodes the raw value of the column. The callers skip NULL columns
+// by the null indicator, but an empty raw value of a type other than CHAR
+// and VARCHAR is NULL too, and a short one is an error, so it never reads
+// past the end of raw_value.
+func (x *xSQLVAR) value(raw_value []byte, dsn *firebirdDsn) (v interface{}, err error) {
+	if x.sqltype != SQL_TYPE_TEXT && x.sqltype != SQL_TYPE_VARYING {
+		if len(raw_value) == 0 {
+			return nil, nil
+		}
+		if len(raw_value) < x.ioLength() {
+			return nil, fmt.Errorf("firebirdsql: short value of %d bytes for column %s of %d bytes", len(raw_value), x.aliasname, x.ioLength())
+		}
+	}
 	switch x.sqltype {
 	case SQL_TYPE_TEXT:
 		if x.sqlsubtype == 1 { // OCTETS
 			v = raw_value
 		} else {
-			v = _convert_charset_if_required(bytes_to_str(raw_value))
+			s := _convert_charset_if_required(x.textCharset(dsn), bytes_to_str(raw_value))
+			if dsn == nil || dsn.trimChar {
+				// CHAR is blank padded, and Firebird ignores trailing spaces in comparison.
+				s = strings.TrimRight(s, " ")
+			}
+			v = s
 		}
 	case SQL_TYPE_VARYING:
 		if x.sqlsubtype == 1 { // OCTETS
 			v = raw_value
 		} else {
-			v = _convert_charset_if_required(bytes_to_str(raw_value))
+			v = _convert_charset_if_required(x.textCharset(dsn), bytes_to_str(raw_value))
 		}
 	case SQL_TYPE_SHORT:
 		i16 := int16(bytes_to_bint32(raw_value))
-		if x.sqlscale > 0 {
-			v = int64(i16) * int64(math.Pow10(x.sqlscale))
-		} else if x.sqlscale < 0 {
-			v = big.NewRat(int64(i16), int64(math.Pow10(x.sqlscale*-1)))
+		if x.sqlscale != 0 {
+			v = scaledValue(int64(i16), x.sqlscale, dsn)
 		} else {
 			v = i16
 		}
 	case SQL_TYPE_LONG:
 		i32 := bytes_to_bint32(raw_value)
-		if x.sqlscale > 0 {
-			v = int64(i32) * int64(math.Pow10(x.sqlscale))
-		} else if x.sqlscale < 0 {
-			v = big.NewRat(int64(i32), int64(math.Pow10(x.sqlscale*-1)))
+		if x.sqlscale != 0 {
+			v = scaledValue(int64(i32), x.sqlscale, dsn)
 		} else {
 			v = i32
 		}
 	case SQL_TYPE_INT64:
 		i64 := bytes_to_bint64(raw_value)
+		if x.sqlscale != 0 {
+			v = scaledValue(i64, x.sqlscale, dsn)
+		} else {
+			v = i64
+		}
+	case SQL_TYPE_INT128:
+		i128 := bytes_to_bint128(raw_value)
 		if x.sqlscale > 0 {
-			v = i64 * int64(math.Pow10(x.sqlscale))
+			v = i128.Mul(i128, bigPow10(x.sqlscale))
 		} else if x.sqlscale < 0 {
-			v = big.NewRat(i64, int64(math.Pow10(x.sqlscale*-1)))
+			v = decimalValue(new(big.Rat).SetFrac(i128, bigPow10(-x.sqlscale)), x.sqlscale, dsn)
 		} else {
-			v = i64
+			v = i128
 		}
 	case SQL_TYPE_DATE:
-		v = x.parseDate(raw_value)
+		if err = checkDate(raw_value); err != nil {
+			return x.overflowValue(raw_value, dsn, err)
+		}
+		v = x.parseDate(raw_value, dsn.timeLocation())
 	case SQL_TYPE_TIME:
-		v = x.parseTime(raw_value)
-	case SQL_TYPE_TIMESTAMP:
-		v = x.parseTimestamp(raw_value)
+		if err = checkTime(raw_value); err != nil {
+			return nil, err
+		}
+		if dsn != nil && dsn.timeMode == TIME_MODE_CLOCK {
+			h, m, s, n := x._parseTime(raw_value)
+			v = Time{h, m, s, n}
+		} else {
+			v = x.parseTime(raw_value, dsn.timeLocation())
+		}
+	case SQL_TYPE_TIMESTAMP, SQL_TYPE_TIMESTAMP_TZ, SQL_TYPE_TIMESTAMP_TZ_EX:
+		if err = checkDate(raw_value[:4]); err == nil {
+			err = checkTime(raw_value[4:8])
+		}
+		if err != nil {
+			return x.overflowValue(raw_value, dsn, err)
+		}
+		if x.sqltype == SQL_TYPE_TIMESTAMP {
+			v = x.parseTimestamp(raw_value, dsn.timeLocation())
+		} else {
+			v = x.parseTimestampTz(raw_value)
+		}
+	case SQL_TYPE_TIME_TZ, SQL_TYPE_TIME_TZ_EX:
+		if err = checkTime(raw_value[:4]); err != nil {
+			return nil, err
+		}
+		v = x.parseTimeTz(raw_value)
 	case SQL_TYPE_FLOAT:
 		var f32 float32
 		b := bytes.NewReader(raw_value)
@@ -216,10 +589,136 @@ func (x *xSQLVAR) value(raw_value []byte) (v interface{}, err error) {
 		var f64 float64
 		err = binary.Read(b, binary.BigEndian, &f64)
 		v = f64
+	case SQL_TYPE_D_FLOAT:
+		v, err = dFloatToFloat64(raw_value)
+	case SQL_TYPE_DEC16:
+		v = decimal64ToString(raw_value)
+	case SQL_TYPE_DEC34:
+		v = decimal128ToString(raw_value)
 	case SQL_TYPE_BOOLEAN:
 		v = raw_value[0] != 0
-	case SQL_TYPE_BLOB:
+	case SQL_TYPE_BLOB, SQL_TYPE_ARRAY, SQL_TYPE_QUAD:
 		v = raw_value
+	case SQL_TYPE_NULL:
+		// e.g. SELECT NULL, it has no other value
+		v = nil
 	}
 	return
 }
+
+// dFloatToFloat64 converts a VAX D_floating value of the legacy D_FLOAT
+// columns. It is 4 little endian 16 bit words, most significant first: the
+// sign, the exponent of 8 bits (excess 128) and the 55 bits of the fraction
+// 0.1f with the hidden bit, so 1.0 is 0x4080 0 0 0.
+func dFloatToFloat64(b []byte) (float64, error) {
+	var bits uint64
+	for i := 0; i < 8; i += 2 {
+		bits = bits<<16 | uint64(binary.LittleEndian.Uint16(b[i:]))
+	}
+	exp := int(bits>>55) & 0xff
+	if exp == 0 {
+		if bits>>63 != 0 {
+			return 0, errors.New("firebirdsql: D_FLOAT reserved operand")
+		}
+		return 0, nil
+	}
+	f := math.Ldexp(float64(bits&(1<<55-1)|1<<55), exp-128-56)
+	if bits>>63 != 0 {
+		f = -f
+	}
+	return f, nil
+}
+
+var (
+	scanTypeBytes     = reflect.TypeOf([]byte(nil))
+	scanTypeString    = reflect.TypeOf("")
+	scanTypeInt16     = reflect.TypeOf(int16(0))
+	scanTypeInt32     = reflect.TypeOf(int32(0))
+	scanTypeInt64     = reflect.TypeOf(int64(0))
+	scanTypeBigInt    = reflect.TypeOf((*big.Int)(nil))
+	scanTypeBigRat    = reflect.TypeOf((*big.Rat)(nil))
+	scanTypeFloat32   = reflect.TypeOf(float32(0))
+	scanTypeFloat64   = reflect.TypeOf(float64(0))
+	scanTypeTime      = reflect.TypeOf(time.Time{})
+	scanTypeClock     = reflect.TypeOf(Time{})
+	scanTypeBool      = reflect.TypeOf(false)
+	scanTypeArray     = reflect.TypeOf([]interface{}(nil))
+	scanTypeBlob      = reflect.TypeOf((*BlobReader)(nil))
+	scanTypeInterface = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+// scanType returns the Go type of the non NULL values of the column, as
+// value() and the rows decode them with the modes of dsn.
+func (x *xSQLVAR) scanType(dsn *firebirdDsn) reflect.Type {
+	switch x.sqltype {
+	case SQL_TYPE_TEXT, SQL_TYPE_VARYING:
+		if x.sqlsubtype == 1 { // OCTETS
+			return scanTypeBytes
+		}
+		return scanTypeString
+	case SQL_TYPE_SHORT, SQL_TYPE_LONG, SQL_TYPE_INT64:
+		if x.sqlscale < 0 {
+			return decimalScanType(dsn)
+		}
+		switch {
+		case x.sqlscale > 0 || x.sqltype == SQL_TYPE_INT64:
+			return scanTypeInt64
+		case x.sqltype == SQL_TYPE_SHORT:
+			return scanTypeInt16
+		}
+		return scanTypeInt32
+	case SQL_TYPE_INT128:
+		if x.sqlscale < 0 {
+			return decimalScanType(dsn)
+		}
+		return scanTypeBigInt
+	case SQL_TYPE_DATE, SQL_TYPE_TIMESTAMP, SQL_TYPE_TIMESTAMP_TZ, SQL_TYPE_TIMESTAMP_TZ_EX,
+		SQL_TYPE_TIME_TZ, SQL_TYPE_TIME_TZ_EX:
+		return scanTypeTime
+	case SQL_TYPE_TIME:
+		if dsn != nil && dsn.timeMode == TIME_MODE_CLOCK {
+			return scanTypeClock
+		}
+		return scanTypeTime
+	case SQL_TYPE_FLOAT:
+		return scanTypeFloat32
+	case SQL_TYPE_DOUBLE, SQL_TYPE_D_FLOAT:
+		return scanTypeFloat64
+	case SQL_TYPE_DEC16, SQL_TYPE_DEC34:
+		return scanTypeString
+	case SQL_TYPE_BOOLEAN:
+		return scanTypeBool
+	case SQL_TYPE_ARRAY:
+		return scanTypeArray
+	case SQL_TYPE_BLOB:
+		if dsn != nil {
+			switch dsn.blobMode {
+			case BLOB_MODE_STREAM:
+				return scanTypeBlob
+			case BLOB_MODE_ID:
+				return scanTypeBytes
+			}
+		}
+		if x.sqlsubtype == 1 {
+			return scanTypeString
+		}
+		return scanTypeBytes
+	case SQL_TYPE_QUAD:
+		return scanTypeBytes
+	}
+	return scanTypeInterface
+}
+
+// decimalScanType returns the Go type of a NUMERIC or DECIMAL value in the
+// decimal_mode of dsn.
+func decimalScanType(dsn *firebirdDsn) reflect.Type {
+	if dsn != nil {
+		switch dsn.decimalMode {
+		case DECIMAL_MODE_STRING:
+			return scanTypeString
+		case DECIMAL_MODE_FLOAT:
+			return scanTypeFloat64
+		}
+	}
+	return scanTypeBigRat
+}
@@ -0,0 +1,532 @@
+/*******************************************************************************
+The MIT License (MIT)
+
+Copyright (c) 2016 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*******************************************************************************/
+
+package firebirdsql
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecimalModeString(t *testing.T) {
+	dsn := &firebirdDsn{decimalMode: DECIMAL_MODE_STRING}
+	var tests = []struct {
+		sqltype  int
+		sqlscale int
+		raw      []byte
+		expected string
+	}{
+		{SQL_TYPE_LONG, -2, bint32_to_bytes(12345), "123.45"},
+		{SQL_TYPE_LONG, -2, bint32_to_bytes(-12345), "-123.45"},
+		{SQL_TYPE_LONG, -2, bint32_to_bytes(0), "0.00"},
+		{SQL_TYPE_LONG, -2, bint32_to_bytes(5), "0.05"},
+		{SQL_TYPE_LONG, -2, bint32_to_bytes(-5), "-0.05"},
+		{SQL_TYPE_LONG, -3, bint32_to_bytes(1200), "1.200"},
+		{SQL_TYPE_SHORT, -1, bint32_to_bytes(-1), "-0.1"},
+		{SQL_TYPE_INT64, -4, []byte{0, 0, 0, 0, 0, 0, 0x30, 0x39}, "1.2345"},
+	}
+
+	for _, tt := range tests {
+		x := &xSQLVAR{sqltype: tt.sqltype, sqlscale: tt.sqlscale}
+		v, err := x.value(tt.raw, dsn)
+		if err != nil {
+			t.Fatalf("value(): %v", err)
+		}
+		if v != tt.expected {
+			t.Errorf("Expected <%v>, got <%v>", tt.expected, v)
+		}
+	}
+}
+
+func TestDecimalModeFloat(t *testing.T) {
+	dsn := &firebirdDsn{decimalMode: DECIMAL_MODE_FLOAT}
+	var tests = []struct {
+		x        *xSQLVAR
+		raw      []byte
+		expected interface{}
+	}{
+		{&xSQLVAR{sqltype: SQL_TYPE_LONG, sqlscale: -2}, bint32_to_bytes(12345), 123.45},
+		{&xSQLVAR{sqltype: SQL_TYPE_SHORT, sqlscale: -1}, bint32_to_bytes(-1), -0.1},
+		{&xSQLVAR{sqltype: SQL_TYPE_INT64, sqlscale: -4}, []byte{0, 0, 0, 0, 0, 0, 0x30, 0x39}, 1.2345},
+		{&xSQLVAR{sqltype: SQL_TYPE_INT128, sqlscale: -2}, append(make([]byte, 15), 5), 0.05},
+		// not scaled
+		{&xSQLVAR{sqltype: SQL_TYPE_LONG}, bint32_to_bytes(7), int32(7)},
+	}
+
+	for _, tt := range tests {
+		v, err := tt.x.value(tt.raw, dsn)
+		if err != nil {
+			t.Fatalf("value(): %v", err)
+		}
+		if v != tt.expected {
+			t.Errorf("Expected <%v>, got <%v> %T", tt.expected, v, v)
+		}
+	}
+}
+
+func TestInt128(t *testing.T) {
+	var tests = []struct {
+		sqlscale int
+		raw      string
+		expected string
+	}{
+		{0, "00000000000000000000000000000001", "1"},
+		{0, "ffffffffffffffffffffffffffffffff", "-1"},
+		{0, "7fffffffffffffffffffffffffffffff", "170141183460469231731687303715884105727"},
+		{0, "80000000000000000000000000000000", "-170141183460469231731687303715884105728"},
+		{0, "fffffffffffffffeffffffffffffffff", "-18446744073709551617"},
+		{2, "0000000000000000000000000000000c", "1200"},
+		{-2, "ffffffffffffffffffffffffffffcfc7", "-123.45"},
+	}
+
+	for _, tt := range tests {
+		raw, _ := hex.DecodeString(tt.raw)
+		x := &xSQLVAR{sqltype: SQL_TYPE_INT128, sqlscale: tt.sqlscale}
+		if len(raw) != x.ioLength() {
+			t.Fatalf("bad ioLength %d", x.ioLength())
+		}
+		v, err := x.value(raw, &firebirdDsn{decimalMode: DECIMAL_MODE_STRING})
+		if err != nil {
+			t.Fatalf("value(): %v", err)
+		}
+		if fmt.Sprint(v) != tt.expected {
+			t.Errorf("%s: expected <%v>, got <%v>", tt.raw, tt.expected, v)
+		}
+	}
+}
+
+func TestTimestampTz(t *testing.T) {
+	// 2020-01-02 03:04:05 UTC
+	ts := append(_convert_date(time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)),
+		_convert_time(time.Date(0, 1, 1, 3, 4, 5, 0, time.UTC))...)
+	var tests = []struct {
+		tzId     int32
+		offset   int32
+		expected string
+	}{
+		{1439 + 90, 90, "2020-01-02 04:34:05 +0130 +01:30"},
+		{1439 - 300, -300, "2020-01-01 22:04:05 -0500 -05:00"},
+		{65535, 0, "2020-01-02 03:04:05 +0000 GMT"},
+		{1, 120, "2020-01-01 03:06:05 -2358 -23:58"},
+		{60000, 120, "2020-01-02 05:04:05 +0200 +02:00"}, // unknown region
+	}
+
+	for _, tt := range tests {
+		raw := append(append(ts, bint32_to_bytes(tt.tzId)...), bint32_to_bytes(tt.offset)...)
+		x := &xSQLVAR{sqltype: SQL_TYPE_TIMESTAMP_TZ}
+		v, err := x.value(raw, &firebirdDsn{})
+		if err != nil {
+			t.Fatalf("value(): %v", err)
+		}
+		got := v.(time.Time)
+		if got.Format("2006-01-02 15:04:05 -0700 MST") != tt.expected {
+			t.Errorf("tz %d: expected <%v>, got <%v>", tt.tzId, tt.expected, got)
+		}
+		if !got.Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)) {
+			t.Errorf("tz %d: instant changed <%v>", tt.tzId, got)
+		}
+	}
+}
+
+func TestTimeTz(t *testing.T) {
+	// 17:00:00 UTC is 12:00:00 in America/New_York (-05:00)
+	raw := append(_convert_time(time.Date(0, 1, 1, 17, 0, 0, 0, time.UTC)), bint32_to_bytes(1439-300)...)
+	raw = append(raw, bint32_to_bytes(-300)...)
+	x := &xSQLVAR{sqltype: SQL_TYPE_TIME_TZ}
+	v, err := x.value(raw, &firebirdDsn{})
+	if err != nil {
+		t.Fatalf("value(): %v", err)
+	}
+	got := v.(time.Time)
+	if h, m, s := got.Clock(); h != 12 || m != 0 || s != 0 {
+		t.Errorf("Expected wall clock 12:00:00, got <%v>", got)
+	}
+	if got.Location().String() != "-05:00" {
+		t.Errorf("Expected location -05:00, got <%v>", got.Location())
+	}
+}
+
+func TestScaleExact(t *testing.T) {
+	for scale := 1; scale <= 38; scale++ {
+		x := &xSQLVAR{sqltype: SQL_TYPE_INT128, sqlscale: -scale}
+		raw, _ := hex.DecodeString("00000000000000000000000000000001")
+		v, _ := x.value(raw, &firebirdDsn{})
+		denom := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)
+		if v.(*big.Rat).Cmp(new(big.Rat).SetFrac(big.NewInt(1), denom)) != 0 {
+			t.Errorf("scale %d: got %v", scale, v)
+		}
+
+		expected := "0." + strings.Repeat("0", scale-1) + "1"
+		v, _ = x.value(raw, &firebirdDsn{decimalMode: DECIMAL_MODE_STRING})
+		if v != expected {
+			t.Errorf("scale %d: expected <%v>, got <%v>", scale, expected, v)
+		}
+
+		if scale <= 18 {
+			x = &xSQLVAR{sqltype: SQL_TYPE_INT64, sqlscale: -scale}
+			v, _ = x.value([]byte{0x0d, 0xe0, 0xb6, 0xb3, 0xa7, 0x63, 0xff, 0xff}, &firebirdDsn{})
+			mantissa := big.NewInt(999999999999999999)
+			if v.(*big.Rat).Cmp(new(big.Rat).SetFrac(mantissa, denom)) != 0 {
+				t.Errorf("int64 scale %d: got %v", scale, v)
+			}
+		}
+	}
+}
+
+func TestScaledValueWidth(t *testing.T) {
+	// NUMERIC(4,2), NUMERIC(9,2) and NUMERIC(18,2) holding -12.34
+	columns := []struct {
+		x   xSQLVAR
+		raw []byte
+	}{
+		{xSQLVAR{sqltype: SQL_TYPE_SHORT, sqlscale: -2}, bint32_to_bytes(-1234)},
+		{xSQLVAR{sqltype: SQL_TYPE_LONG, sqlscale: -2}, bint32_to_bytes(-1234)},
+		{xSQLVAR{sqltype: SQL_TYPE_INT64, sqlscale: -2}, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfb, 0x2e}},
+	}
+	for _, mode := range []int{DECIMAL_MODE_RAT, DECIMAL_MODE_STRING} {
+		dsn := &firebirdDsn{decimalMode: mode}
+		for _, c := range columns {
+			v, err := c.x.value(c.raw, dsn)
+			if err != nil {
+				t.Fatalf("value(): %v", err)
+			}
+			if fmt.Sprintf("%T", v) != map[int]string{DECIMAL_MODE_RAT: "*big.Rat", DECIMAL_MODE_STRING: "string"}[mode] {
+				t.Errorf("sqltype %d: unexpected type %T", c.x.sqltype, v)
+			}
+			s := fmt.Sprint(v)
+			if r, ok := v.(*big.Rat); ok {
+				s = r.FloatString(2)
+			}
+			if s != "-12.34" {
+				t.Errorf("sqltype %d: expected <-12.34>, got <%v>", c.x.sqltype, s)
+			}
+		}
+	}
+}
+
+func TestTrimCharValue(t *testing.T) {
+	raw := []byte("ab   ")
+	x := &xSQLVAR{sqltype: SQL_TYPE_TEXT, sqllen: 5}
+	v, _ := x.value(raw, &firebirdDsn{trimChar: true})
+	if v.(string) != "ab" {
+		t.Errorf("trim_char=true: <%v>", v)
+	}
+	v, _ = x.value(raw, &firebirdDsn{trimChar: false})
+	if v.(string) != "ab   " {
+		t.Errorf("trim_char=false: <%v>", v)
+	}
+
+	// OCTETS are never trimmed
+	x.sqlsubtype = 1
+	v, _ = x.value(raw, &firebirdDsn{trimChar: true})
+	if string(v.([]byte)) != "ab   " {
+		t.Errorf("OCTETS: <%v>", v)
+	}
+}
+
+func TestQuadValue(t *testing.T) {
+	raw := []byte{0, 0, 0, 1, 0, 0, 0, 2}
+	x := &xSQLVAR{sqltype: SQL_TYPE_QUAD, sqllen: 8}
+	v, err := x.value(raw, &firebirdDsn{})
+	if err != nil {
+		t.Fatalf("value: %v", err)
+	}
+	if b, ok := v.([]byte); !ok || !bytes.Equal(b, raw) {
+		t.Errorf("quad: %v", v)
+	}
+	if x.ioLength() != 8 {
+		t.Errorf("quad length: %d", x.ioLength())
+	}
+}
+
+func TestBooleanValue(t *testing.T) {
+	x := &xSQLVAR{sqltype: SQL_TYPE_BOOLEAN, sqllen: 1}
+	for raw, expected := range map[string]interface{}{"\x01": true, "\x00": false, "": nil} {
+		v, err := x.value([]byte(raw), &firebirdDsn{})
+		if err != nil || v != expected {
+			t.Errorf("%v: expected %v, got %v %v", []byte(raw), expected, v, err)
+		}
+	}
+}
+
+func TestNullValue(t *testing.T) {
+	dsn := &firebirdDsn{}
+	for sqltype, ln := range xsqlvarTypeLength {
+		x := &xSQLVAR{sqltype: sqltype, sqllen: ln}
+		v, err := x.value(nil, dsn)
+		if sqltype == SQL_TYPE_VARYING {
+			if v != "" || err != nil {
+				t.Errorf("empty VARCHAR: %v %v", v, err)
+			}
+			continue
+		}
+		if v != nil || err != nil {
+			t.Errorf("NULL %d: %v %v", sqltype, v, err)
+		}
+		if ln > 1 {
+			if _, err = x.value([]byte{1}, dsn); err == nil {
+				t.Errorf("short value of %d was accepted", sqltype)
+			}
+		}
+	}
+}
+
+func TestTimeLocation(t *testing.T) {
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	raw := append(_convert_date(time.Date(2021, 2, 3, 0, 0, 0, 0, time.UTC)), _convert_time(time.Date(0, 1, 1, 4, 5, 6, 0, time.UTC))...)
+	x := &xSQLVAR{sqltype: SQL_TYPE_TIMESTAMP}
+	for _, dsn := range []*firebirdDsn{nil, {}, {location: tokyo}} {
+		v, err := x.value(raw, dsn)
+		if err != nil {
+			t.Fatalf("value: %v", err)
+		}
+		expected := time.Date(2021, 2, 3, 4, 5, 6, 0, dsn.timeLocation())
+		if ts := v.(time.Time); !ts.Equal(expected) || ts.Location() != dsn.timeLocation() {
+			t.Errorf("expected %v, got %v", expected, ts)
+		}
+	}
+}
+
+func TestTypeName(t *testing.T) {
+	var tests = []struct {
+		sqltype    int
+		sqlsubtype int
+		sqlscale   int
+		name       string
+		charsetId  int
+	}{
+		{SQL_TYPE_VARYING, 4, 0, "VARCHAR", 4},
+		{SQL_TYPE_TEXT, 0x104, 0, "CHAR", 4},
+		{SQL_TYPE_LONG, 0, 0, "INTEGER", 0},
+		{SQL_TYPE_LONG, 1, -2, "NUMERIC", 0},
+		{SQL_TYPE_INT64, 2, -2, "DECIMAL", 0},
+		{SQL_TYPE_BLOB, 0, 0, "BLOB SUB_TYPE BINARY", 0},
+		{SQL_TYPE_BLOB, 1, 4, "BLOB SUB_TYPE TEXT", 4},
+		{SQL_TYPE_BLOB, 2, 0, "BLOB SUB_TYPE 2", 0},
+		{SQL_TYPE_TIMESTAMP_TZ, 0, 0, "TIMESTAMP WITH TIME ZONE", 0},
+	}
+	for _, tt := range tests {
+		x := &xSQLVAR{sqltype: tt.sqltype, sqlsubtype: tt.sqlsubtype, sqlscale: tt.sqlscale}
+		if s := x.typeName(); s != tt.name {
+			t.Errorf("typeName(%d, %d): got %q, want %q", tt.sqltype, tt.sqlsubtype, s, tt.name)
+		}
+		if c := x.charsetId(); c != tt.charsetId {
+			t.Errorf("charsetId(%d, %d): got %d, want %d", tt.sqltype, tt.sqlsubtype, c, tt.charsetId)
+		}
+	}
+}
+
+func TestScanType(t *testing.T) {
+	var tests = []struct {
+		x        xSQLVAR
+		dsn      *firebirdDsn
+		expected interface{}
+	}{
+		{xSQLVAR{sqltype: SQL_TYPE_SHORT}, &firebirdDsn{}, int16(0)},
+		{xSQLVAR{sqltype: SQL_TYPE_LONG}, &firebirdDsn{}, int32(0)},
+		{xSQLVAR{sqltype: SQL_TYPE_INT64}, &firebirdDsn{}, int64(0)},
+		{xSQLVAR{sqltype: SQL_TYPE_LONG, sqlscale: -2}, &firebirdDsn{}, new(big.Rat)},
+		{xSQLVAR{sqltype: SQL_TYPE_LONG, sqlscale: -2}, &firebirdDsn{decimalMode: DECIMAL_MODE_STRING}, ""},
+		{xSQLVAR{sqltype: SQL_TYPE_INT64, sqlscale: -2}, &firebirdDsn{decimalMode: DECIMAL_MODE_FLOAT}, float64(0)},
+		{xSQLVAR{sqltype: SQL_TYPE_INT128}, &firebirdDsn{}, new(big.Int)},
+		{xSQLVAR{sqltype: SQL_TYPE_VARYING}, &firebirdDsn{}, ""},
+		{xSQLVAR{sqltype: SQL_TYPE_TEXT, sqlsubtype: 1}, &firebirdDsn{}, []byte{}},
+		{xSQLVAR{sqltype: SQL_TYPE_TIME}, &firebirdDsn{}, time.Time{}},
+		{xSQLVAR{sqltype: SQL_TYPE_TIME}, &firebirdDsn{timeMode: TIME_MODE_CLOCK}, Time{}},
+		{xSQLVAR{sqltype: SQL_TYPE_BLOB, sqlsubtype: 1}, &firebirdDsn{}, ""},
+		{xSQLVAR{sqltype: SQL_TYPE_BLOB, sqlsubtype: 1}, &firebirdDsn{blobMode: BLOB_MODE_STREAM}, &BlobReader{}},
+		{xSQLVAR{sqltype: SQL_TYPE_BOOLEAN}, &firebirdDsn{}, false},
+	}
+	for _, tt := range tests {
+		v, err := tt.x.value(make([]byte, 16), tt.dsn)
+		// the rows convert BLOB ids
+		if err == nil && tt.x.sqltype != SQL_TYPE_BLOB && reflect.TypeOf(v) != tt.x.scanType(tt.dsn) {
+			t.Errorf("type %d: value() is %T, scanType() is %v", tt.x.sqltype, v, tt.x.scanType(tt.dsn))
+		}
+		if st := tt.x.scanType(tt.dsn); st != reflect.TypeOf(tt.expected) {
+			t.Errorf("type %d scale %d: expected %T, got %v", tt.x.sqltype, tt.x.sqlscale, tt.expected, st)
+		}
+	}
+}
+
+func TestPrecision(t *testing.T) {
+	var tests = []struct {
+		sqltype    int
+		sqlsubtype int
+		sqlscale   int
+		precision  int
+		ok         bool
+	}{
+		{SQL_TYPE_SHORT, 1, -2, 4, true},  // NUMERIC(4, 2)
+		{SQL_TYPE_LONG, 1, -4, 9, true},   // NUMERIC(9, 4)
+		{SQL_TYPE_INT64, 2, -6, 18, true}, // DECIMAL(18, 6)
+		{SQL_TYPE_INT128, 1, -10, 38, true},
+		{SQL_TYPE_LONG, 1, 0, 9, true}, // NUMERIC(9)
+		{SQL_TYPE_LONG, 0, 0, 0, false},
+		{SQL_TYPE_DOUBLE, 0, 0, 0, false},
+		{SQL_TYPE_VARYING, 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		x := &xSQLVAR{sqltype: tt.sqltype, sqlsubtype: tt.sqlsubtype, sqlscale: tt.sqlscale}
+		if p, ok := x.precision(); p != tt.precision || ok != tt.ok {
+			t.Errorf("precision(%d, %d, %d): got %d %v, want %d %v", tt.sqltype, tt.sqlsubtype, tt.sqlscale, p, ok, tt.precision, tt.ok)
+		}
+	}
+}
+
+func TestCharLength(t *testing.T) {
+	var tests = []struct {
+		sqltype    int
+		sqlsubtype int
+		sqllen     int
+		expected   int
+	}{
+		{SQL_TYPE_VARYING, 4, 40, 10},  // UTF8
+		{SQL_TYPE_TEXT, 0x204, 12, 3},  // UTF8 with a collation
+		{SQL_TYPE_VARYING, 53, 10, 10}, // WIN1252
+		{SQL_TYPE_VARYING, 3, 30, 10},  // UNICODE_FSS
+		{SQL_TYPE_TEXT, 1, 8, 8},       // OCTETS
+		{SQL_TYPE_VARYING, 0, 5, 5},    // NONE
+	}
+	for _, tt := range tests {
+		x := &xSQLVAR{sqltype: tt.sqltype, sqlsubtype: tt.sqlsubtype, sqllen: tt.sqllen}
+		if n := x.charLength(); n != tt.expected {
+			t.Errorf("charLength(%d, %d): got %d, want %d", tt.sqlsubtype, tt.sqllen, n, tt.expected)
+		}
+	}
+}
+
+func TestTextCharset(t *testing.T) {
+	var tests = []struct {
+		charset    string
+		sqlsubtype int
+		expected   string
+	}{
+		{"UTF8", 4, "UTF8"},
+		{"NONE", 53, "WIN1252"},
+		{"NONE", 0x134, "WIN1251"}, // WIN1251 with a collation
+		{"NONE", 0, "NONE"},
+		{"win1252", 53, "win1252"},
+		{"WIN1252", 3, "UTF8"},
+		{"WIN1252", 127, "WIN1252"},
+	}
+	for _, tt := range tests {
+		x := &xSQLVAR{sqltype: SQL_TYPE_VARYING, sqlsubtype: tt.sqlsubtype}
+		if s := x.textCharset(&firebirdDsn{charset: tt.charset}); s != tt.expected {
+			t.Errorf("textCharset(%s, %d): got %s, want %s", tt.charset, tt.sqlsubtype, s, tt.expected)
+		}
+	}
+}
+
+func TestTextValueNoDSN(t *testing.T) {
+	x := &xSQLVAR{sqltype: SQL_TYPE_TEXT, sqlsubtype: 4, sqllen: 8}
+	if v, err := x.value([]byte("abc     "), nil); err != nil || v != "abc" {
+		t.Errorf("CHAR: %q %v", v, err)
+	}
+	x = &xSQLVAR{sqltype: SQL_TYPE_VARYING, sqlsubtype: 4, sqllen: 8}
+	if v, err := x.value([]byte("abc "), nil); err != nil || v != "abc " {
+		t.Errorf("VARCHAR: %q %v", v, err)
+	}
+}
+
+func TestParseDateRange(t *testing.T) {
+	// every day of 0001-01-01 .. 9999-12-31 is the day after the previous
+	x := &xSQLVAR{sqltype: SQL_TYPE_DATE}
+	for n := minDateSerial; n <= maxDateSerial; n++ {
+		year, month, day := x._parseDate(bint32_to_bytes(n))
+		expected := time.Date(1858, 11, 17+int(n), 0, 0, 0, 0, time.UTC)
+		if year != expected.Year() || time.Month(month) != expected.Month() || day != expected.Day() {
+			t.Fatalf("%d: expected %v, got %d-%d-%d", n, expected, year, month, day)
+		}
+	}
+	// the Gregorian calendar continues before 1582-10-15
+	for _, d := range []string{"0001-01-01", "1582-10-04", "1582-10-05", "1582-10-15", "1600-02-29", "9999-12-31"} {
+		tm, _ := time.Parse("2006-01-02", d)
+		v, err := x.value(_convert_date(tm), &firebirdDsn{})
+		if err != nil || !v.(time.Time).Equal(tm) {
+			t.Errorf("%s: got %v %v", d, v, err)
+		}
+	}
+
+	for _, n := range []int32{minDateSerial - 1, maxDateSerial + 1} {
+		if v, err := x.value(bint32_to_bytes(n), &firebirdDsn{}); err == nil {
+			t.Errorf("DATE %d: got %v", n, v)
+		}
+	}
+	ts := &xSQLVAR{sqltype: SQL_TYPE_TIMESTAMP}
+	raw := append(bint32_to_bytes(0), bint32_to_bytes(timeOfDayUnits)...)
+	if v, err := ts.value(raw, &firebirdDsn{}); err == nil {
+		t.Errorf("TIMESTAMP with 24:00: got %v", v)
+	}
+	tod := &xSQLVAR{sqltype: SQL_TYPE_TIME}
+	if v, err := tod.value(bint32_to_bytes(-1), &firebirdDsn{}); err == nil {
+		t.Errorf("TIME -1: got %v", v)
+	}
+}
+
+func TestDFloatValue(t *testing.T) {
+	x := &xSQLVAR{sqltype: SQL_TYPE_D_FLOAT, sqllen: 8}
+	var tests = []struct {
+		raw  string
+		want float64
+	}{
+		{"8040000000000000", 1},
+		{"20c1000000000000", -2.5},
+		{"4941da0f21a2c268", math.Pi},
+		{"0000000000000000", 0},
+	}
+	for _, tt := range tests {
+		raw, _ := hex.DecodeString(tt.raw)
+		v, err := x.value(raw, &firebirdDsn{})
+		if err != nil || v != tt.want {
+			t.Errorf("%s: got %v %v, want %v", tt.raw, v, err, tt.want)
+		}
+	}
+	if _, err := x.value([]byte{0, 0x80, 0, 0, 0, 0, 0, 0}, &firebirdDsn{}); err == nil {
+		t.Errorf("reserved operand was accepted")
+	}
+	if typ := x.scanType(nil); typ != scanTypeFloat64 {
+		t.Errorf("scanType: %v", typ)
+	}
+}
+
+func TestNullTypeValue(t *testing.T) {
+	x := xSQLVAR{sqltype: SQL_TYPE_NULL, aliasname: "CONSTANT"}
+	if x.ioLength() != 0 || x.displayLenght() != 0 {
+		t.Errorf("lengths: %d %d", x.ioLength(), x.displayLenght())
+	}
+	if v, err := x.value([]byte{}, &firebirdDsn{}); v != nil || err != nil {
+		t.Errorf("value: %v %v", v, err)
+	}
+	want := []byte{5, 2, 4, 0, 2, 0, 14, 0, 0, 7, 0, 255, 76}
+	if blr := calcBlr([]xSQLVAR{x}); !bytes.Equal(blr, want) {
+		t.Errorf("calcBlr: %v", blr)
+	}
+}